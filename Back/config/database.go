@@ -24,6 +24,7 @@ var (
 	EventBookingsCol  *mongo.Collection
 	PlayBookingsCol   *mongo.Collection
 	SlotLocksCol      *mongo.Collection
+	SeatClaimsCol     *mongo.Collection
 	DiningBookingsCol *mongo.Collection
 	CouponsCol        *mongo.Collection
 	OffersCol         *mongo.Collection
@@ -33,6 +34,27 @@ var (
 	ChatSessionsCol   *mongo.Collection
 	ChatMessagesCol   *mongo.Collection
 	ChatQuestionsCol  *mongo.Collection
+
+	EventChangesCol        *mongo.Collection
+	EventChangeBookingsCol *mongo.Collection
+	PurchaseLimitHitsCol   *mongo.Collection
+	PassPlansCol           *mongo.Collection
+	PassGiftsCol           *mongo.Collection
+	CouponRedemptionsCol   *mongo.Collection
+	CouponCampaignsCol     *mongo.Collection
+	ReferralsCol           *mongo.Collection
+	ReferralSettingsCol    *mongo.Collection
+	WalletsCol             *mongo.Collection
+	WalletEntriesCol       *mongo.Collection
+	PassLedgerCol          *mongo.Collection
+	OfferRedemptionsCol    *mongo.Collection
+	PhoneOTPsCol           *mongo.Collection
+	SessionsCol            *mongo.Collection
+	AdminRolesCol          *mongo.Collection
+	OrgMembersCol          *mongo.Collection
+	AdminLoginEventsCol    *mongo.Collection
+	AdminSettingsCol       *mongo.Collection
+	AuditLogsCol           *mongo.Collection
 )
 
 func ConnectDB() error {
@@ -74,6 +96,7 @@ func ConnectDB() error {
 	EventBookingsCol = db.Collection("event_bookings")
 	PlayBookingsCol = db.Collection("play_bookings")
 	SlotLocksCol = db.Collection("slot_locks")
+	SeatClaimsCol = db.Collection("event_seat_claims")
 	DiningBookingsCol = db.Collection("dining_bookings")
 	CouponsCol = db.Collection("coupons")
 	OffersCol = db.Collection("offers")
@@ -82,6 +105,26 @@ func ConnectDB() error {
 	ChatSessionsCol = db.Collection("chat_sessions")
 	ChatMessagesCol = db.Collection("chat_messages")
 	ChatQuestionsCol = db.Collection("chat_questions")
+	EventChangesCol = db.Collection("event_changes")
+	EventChangeBookingsCol = db.Collection("event_change_bookings")
+	PurchaseLimitHitsCol = db.Collection("purchase_limit_hits")
+	PassPlansCol = db.Collection("pass_plans")
+	PassGiftsCol = db.Collection("pass_gifts")
+	CouponRedemptionsCol = db.Collection("coupon_redemptions")
+	CouponCampaignsCol = db.Collection("coupon_campaigns")
+	ReferralsCol = db.Collection("referrals")
+	ReferralSettingsCol = db.Collection("referral_settings")
+	WalletsCol = db.Collection("wallets")
+	WalletEntriesCol = db.Collection("wallet_entries")
+	PassLedgerCol = db.Collection("pass_benefit_ledger")
+	OfferRedemptionsCol = db.Collection("offer_redemptions")
+	PhoneOTPsCol = db.Collection("phone_otps")
+	SessionsCol = db.Collection("sessions")
+	AdminRolesCol = db.Collection("admin_roles")
+	OrgMembersCol = db.Collection("organizer_members")
+	AdminLoginEventsCol = db.Collection("admin_login_events")
+	AdminSettingsCol = db.Collection("admin_settings")
+	AuditLogsCol = db.Collection("audit_logs")
 
 	fmt.Println("Database collections initialized")
 	CreateIndexes()
@@ -100,6 +143,7 @@ func CreateIndexes() {
 	UsersCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "phone", Value: 1}}, Options: options.Index().SetUnique(true)},
 		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "referral_code", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
 	})
 
 	ProfilesCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
@@ -139,6 +183,11 @@ func CreateIndexes() {
 	PassesCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{{Key: "user_id", Value: 1}}},
 		{Keys: bson.D{{Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "end_date", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "auto_renew.subscription_id", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
 	})
 
 	PlayBookingsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
@@ -198,6 +247,19 @@ func CreateIndexes() {
 		// Removed SetUnique(true) - multiple locks can share same booking_id for multi-slot/multi-court bookings
 	})
 
+	// One booking holds a reserved seat at a time
+	SeatClaimsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "event_id", Value: 1},
+				{Key: "showtime_id", Value: 1},
+				{Key: "seat_id", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "booking_id", Value: 1}}},
+	})
+
 	EventBookingsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{Keys: bson.D{
 			{Key: "event_id", Value: 1},
@@ -238,9 +300,9 @@ func CreateIndexes() {
 		Options: options.Index().SetUnique(true).SetSparse(true),
 	})
 
-	CouponsCol.Indexes().CreateOne(ctx, mongo.IndexModel{
-		Keys:    bson.D{{Key: "code", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	CouponsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "campaign_id", Value: 1}}, Options: options.Index().SetSparse(true)},
 	})
 
 	OffersCol.Indexes().CreateOne(ctx, mongo.IndexModel{
@@ -256,9 +318,129 @@ func CreateIndexes() {
 		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}},
 	})
 
+	ChatSessionsCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "assigned_agent", Value: 1}, {Key: "status", Value: 1}, {Key: "updated_at", Value: -1}},
+	})
+
 	ChatMessagesCol.Indexes().CreateOne(ctx, mongo.IndexModel{
 		Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "created_at", Value: 1}},
 	})
+
+	EventChangesCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "event_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+
+	EventChangeBookingsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "change_id", Value: 1}, {Key: "booking_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "refund_status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "booking_id", Value: 1}}},
+	})
+
+	PurchaseLimitHitsCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "event_id", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+
+	PassPlansCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	CouponRedemptionsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "coupon_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "coupon_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "booking_id", Value: 1}}},
+		{Keys: bson.D{{Key: "order_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "campaign_id", Value: 1}, {Key: "status", Value: 1}}},
+	})
+
+	OfferRedemptionsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "offer_id", Value: 1}, {Key: "day", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "booking_id", Value: 1}}},
+		{Keys: bson.D{{Key: "order_id", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}}},
+	})
+
+	PhoneOTPsCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "purge_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	SessionsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "refresh_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "prev_refresh_hash", Value: 1}}},
+		{Keys: bson.D{{Key: "kind", Value: 1}, {Key: "subject_id", Value: 1}, {Key: "last_used_at", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+
+	AdminRolesCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	AdminLoginEventsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(365 * 24 * 60 * 60)},
+	})
+
+	AuditLogsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_email", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "entity", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	OrgMembersCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "organizer_id", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}},
+	})
+
+	CouponCampaignsCol.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "created_at", Value: -1}},
+	})
+
+	ReferralsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "referee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "referrer_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "device_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+
+	WalletEntriesCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "type", Value: 1}, {Key: "expires_at", Value: 1}}},
+		{Keys: bson.D{{Key: "booking_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}}},
+		{
+			// One refund credit per booking, however often cancellation is retried
+			Keys:    bson.D{{Key: "booking_type", Value: 1}, {Key: "booking_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"source": "refund"}),
+		},
+	})
+
+	PassGiftsCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "order_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			// A payment pays for one gift, whichever order it is reported with
+			Keys:    bson.D{{Key: "payment_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"payment_id": bson.M{"$gt": ""}}),
+		},
+		{Keys: bson.D{{Key: "buyer_user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+
+	PassLedgerCol.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "pass_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "booking_type", Value: 1}, {Key: "booking_id", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "reversal_of", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"type": "reversal"}),
+		},
+	})
 }
 
 func IsDuplicateKeyError(err error) bool {
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"os"
 	"time"
 
@@ -37,12 +38,40 @@ func IsProduction() bool {
 	return isProdCached || os.Getenv("ENV") == "production"
 }
 
+// Access tokens are short lived; the session behind them is kept alive by
+// a rotating refresh token (see services/session).
+const (
+	AccessTokenTTL      = 15 * time.Minute
+	OrganizerSessionTTL = 7 * 24 * time.Hour
+	UserSessionTTL      = 30 * 24 * time.Hour
+)
+
+// AuthSession identifies the server-side session an access token belongs
+// to. RefreshToken is only set when a new one has been issued. Renewal is
+// set when tokens are reissued for an existing sign-in, so that the session
+// info cookie the frontend may have filled in is kept. MemberID is set when
+// a team member, not the owner, is signed in to the organizer. MFA is set
+// once the session has passed an admin two-factor check.
+type AuthSession struct {
+	ID            string
+	ClaimsVersion int
+	RefreshToken  string
+	ExpiresAt     time.Time
+	Renewal       bool
+	MemberID      string
+	MFA           bool
+}
+
 type OrganizerClaims struct {
 	OrganizerID    string            `json:"organizerId"`
 	Email          string            `json:"email"`
 	Role           string            `json:"role"`
 	IsAdmin        bool              `json:"isAdmin"`
 	CategoryStatus map[string]string `json:"categoryStatus"`
+	SessionID      string            `json:"sid"`
+	ClaimsVersion  int               `json:"cv"`
+	MemberID       string            `json:"mid,omitempty"`
+	MFA            bool              `json:"mfa,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -52,9 +81,10 @@ type SessionInfo struct {
 	Vertical       string            `json:"vertical"`
 	IsAdmin        bool              `json:"isAdmin"`
 	CategoryStatus map[string]string `json:"categoryStatus"`
+	MemberID       string            `json:"memberId,omitempty"`
 }
 
-func GenerateOrganizerToken(organizerID, email, role string, isAdmin bool, categoryStatus map[string]string) (string, error) {
+func GenerateOrganizerToken(organizerID, email, role string, isAdmin bool, categoryStatus map[string]string, sess AuthSession) (string, error) {
 
 	if role == "" {
 		if isAdmin {
@@ -70,8 +100,12 @@ func GenerateOrganizerToken(organizerID, email, role string, isAdmin bool, categ
 		Role:           role,
 		IsAdmin:        isAdmin,
 		CategoryStatus: categoryStatus,
+		SessionID:      sess.ID,
+		ClaimsVersion:  sess.ClaimsVersion,
+		MemberID:       sess.MemberID,
+		MFA:            sess.MFA,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -79,8 +113,48 @@ func GenerateOrganizerToken(organizerID, email, role string, isAdmin bool, categ
 	return token.SignedString(JWTSecret())
 }
 
-func SetAuthCookies(c *fiber.Ctx, organizerID, email, role, vertical string, isAdmin bool, categoryStatus map[string]string) error {
-	token, err := GenerateOrganizerToken(organizerID, email, role, isAdmin, categoryStatus)
+func parseToken(tokenStr string, claims jwt.Claims) error {
+	if tokenStr == "" {
+		return errors.New("missing token")
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fiber.ErrUnauthorized
+		}
+		return JWTSecret(), nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// ParseOrganizerToken validates an organizer access token. Tokens issued
+// before sessions existed carry no session id and are rejected.
+func ParseOrganizerToken(tokenStr string) (*OrganizerClaims, error) {
+	claims := &OrganizerClaims{}
+	if err := parseToken(tokenStr, claims); err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, errors.New("token has no session")
+	}
+	return claims, nil
+}
+
+func secondsUntil(t time.Time) int {
+	secs := int(time.Until(t).Seconds())
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+func SetAuthCookies(c *fiber.Ctx, organizerID, email, role, vertical string, isAdmin bool, categoryStatus map[string]string, sess AuthSession) error {
+	token, err := GenerateOrganizerToken(organizerID, email, role, isAdmin, categoryStatus, sess)
 	if err != nil {
 		return err
 	}
@@ -95,15 +169,36 @@ func SetAuthCookies(c *fiber.Ctx, organizerID, email, role, vertical string, isA
 		Secure:   IsProduction(),
 		SameSite: "Lax",
 		Path:     "/",
-		MaxAge:   7 * 24 * 60 * 60,
+		MaxAge:   int(AccessTokenTTL.Seconds()),
 	})
 
+	if sess.RefreshToken != "" {
+		c.Cookie(&fiber.Cookie{
+			Name:     "ticpin_refresh",
+			Value:    sess.RefreshToken,
+			HTTPOnly: true,
+			Secure:   IsProduction(),
+			SameSite: "Lax",
+			Path:     "/",
+			MaxAge:   secondsUntil(sess.ExpiresAt),
+		})
+	}
+
+	if sess.Renewal {
+		var current SessionInfo
+		if raw, err := base64.StdEncoding.DecodeString(c.Cookies("ticpin_session")); err == nil {
+			if json.Unmarshal(raw, &current) == nil && current.ID == organizerID && current.Vertical != "" {
+				vertical = current.Vertical
+			}
+		}
+	}
 	info := SessionInfo{
 		ID:             organizerID,
 		Email:          email,
 		Vertical:       vertical,
 		IsAdmin:        isAdmin,
 		CategoryStatus: categoryStatus,
+		MemberID:       sess.MemberID,
 	}
 	raw, _ := json.Marshal(info)
 	encoded := base64.StdEncoding.EncodeToString(raw)
@@ -114,19 +209,22 @@ func SetAuthCookies(c *fiber.Ctx, organizerID, email, role, vertical string, isA
 		Secure:   IsProduction(),
 		SameSite: "Lax",
 		Path:     "/",
-		MaxAge:   7 * 24 * 60 * 60,
+		MaxAge:   secondsUntil(sess.ExpiresAt),
 	})
 	return nil
 }
 
 func ClearAuthCookies(c *fiber.Ctx) {
 	c.Cookie(&fiber.Cookie{Name: "ticpin_token", Value: "", MaxAge: -1, HTTPOnly: true, SameSite: "Lax", Path: "/"})
+	c.Cookie(&fiber.Cookie{Name: "ticpin_refresh", Value: "", MaxAge: -1, HTTPOnly: true, SameSite: "Lax", Path: "/"})
 	c.Cookie(&fiber.Cookie{Name: "ticpin_session", Value: "", MaxAge: -1, SameSite: "Lax", Path: "/"})
 }
 
 type UserClaims struct {
-	UserID string `json:"userId"`
-	Phone  string `json:"phone"`
+	UserID        string `json:"userId"`
+	Phone         string `json:"phone"`
+	SessionID     string `json:"sid"`
+	ClaimsVersion int    `json:"cv"`
 	jwt.RegisteredClaims
 }
 
@@ -136,12 +234,14 @@ type UserSessionInfo struct {
 	Name  string `json:"name,omitempty"`
 }
 
-func GenerateUserToken(userID, phone string) (string, error) {
+func GenerateUserToken(userID, phone string, sess AuthSession) (string, error) {
 	claims := UserClaims{
-		UserID: userID,
-		Phone:  phone,
+		UserID:        userID,
+		Phone:         phone,
+		SessionID:     sess.ID,
+		ClaimsVersion: sess.ClaimsVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -149,8 +249,21 @@ func GenerateUserToken(userID, phone string) (string, error) {
 	return token.SignedString(JWTSecret())
 }
 
-func SetUserAuthCookies(c *fiber.Ctx, userID, phone, name string) error {
-	token, err := GenerateUserToken(userID, phone)
+// ParseUserToken validates a user access token. Tokens issued before
+// sessions existed carry no session id and are rejected.
+func ParseUserToken(tokenStr string) (*UserClaims, error) {
+	claims := &UserClaims{}
+	if err := parseToken(tokenStr, claims); err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, errors.New("token has no session")
+	}
+	return claims, nil
+}
+
+func SetUserAuthCookies(c *fiber.Ctx, userID, phone, name string, sess AuthSession) error {
+	token, err := GenerateUserToken(userID, phone, sess)
 	if err != nil {
 		return err
 	}
@@ -162,9 +275,21 @@ func SetUserAuthCookies(c *fiber.Ctx, userID, phone, name string) error {
 		Secure:   IsProduction(),
 		SameSite: "Lax",
 		Path:     "/",
-		MaxAge:   30 * 24 * 60 * 60,
+		MaxAge:   int(AccessTokenTTL.Seconds()),
 	})
 
+	if sess.RefreshToken != "" {
+		c.Cookie(&fiber.Cookie{
+			Name:     "ticpin_user_refresh",
+			Value:    sess.RefreshToken,
+			HTTPOnly: true,
+			Secure:   IsProduction(),
+			SameSite: "Lax",
+			Path:     "/",
+			MaxAge:   secondsUntil(sess.ExpiresAt),
+		})
+	}
+
 	info := UserSessionInfo{
 		ID:    userID,
 		Phone: phone,
@@ -172,6 +297,14 @@ func SetUserAuthCookies(c *fiber.Ctx, userID, phone, name string) error {
 	}
 	raw, _ := json.Marshal(info)
 	encoded := base64.StdEncoding.EncodeToString(raw)
+	if sess.Renewal {
+		var current UserSessionInfo
+		if existing := c.Cookies("ticpin_user_session"); existing != "" {
+			if raw, err := base64.StdEncoding.DecodeString(existing); err == nil && json.Unmarshal(raw, &current) == nil && current.ID == userID {
+				encoded = existing
+			}
+		}
+	}
 	c.Cookie(&fiber.Cookie{
 		Name:     "ticpin_user_session",
 		Value:    encoded,
@@ -179,13 +312,14 @@ func SetUserAuthCookies(c *fiber.Ctx, userID, phone, name string) error {
 		Secure:   IsProduction(),
 		SameSite: "Lax",
 		Path:     "/",
-		MaxAge:   30 * 24 * 60 * 60,
+		MaxAge:   secondsUntil(sess.ExpiresAt),
 	})
 	return nil
 }
 
 func ClearUserAuthCookies(c *fiber.Ctx) {
 	c.Cookie(&fiber.Cookie{Name: "ticpin_user_token", Value: "", MaxAge: -1, HTTPOnly: true, SameSite: "Lax", Path: "/"})
+	c.Cookie(&fiber.Cookie{Name: "ticpin_user_refresh", Value: "", MaxAge: -1, HTTPOnly: true, SameSite: "Lax", Path: "/"})
 	c.Cookie(&fiber.Cookie{Name: "ticpin_user_session", Value: "", MaxAge: -1, SameSite: "Lax", Path: "/"})
 }
 
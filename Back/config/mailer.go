@@ -311,6 +311,48 @@ func GenerateOTP() string {
 	return fmt.Sprintf("%06d", rand.Intn(1000000))
 }
 
+// SendTeamInviteEmail sends the sign-in code for an organizer team
+// member. The first code doubles as the invitation.
+func SendTeamInviteEmail(toEmail, teamName, otp string, invite bool) error {
+	from := os.Getenv("ADMIN_EMAIL")
+	pass := os.Getenv("ADMIN_APP_PASSWORD")
+
+	html := template.HTMLEscapeString
+	subject := "Your Ticpin team sign-in code"
+	body := fmt.Sprintf("<h2>Your Ticpin OTP: <b>%s</b></h2><p>Use it to sign in to the <b>%s</b> team. Valid for 10 minutes.</p>", otp, html(teamName))
+	if invite {
+		subject = fmt.Sprintf("You have been invited to %s on Ticpin", teamName)
+		body = fmt.Sprintf("<h2>You're invited</h2><p><b>%s</b> has added you to their team on Ticpin.</p><p>Sign in at the organizer team login with this email and the code <b>%s</b>. It is valid for 10 minutes; you can request a new one from the login page.</p>", html(teamName), otp)
+	}
+	return sendOTP(from, pass, toEmail, subject, body)
+}
+
+// SendAdminLoginAlert tells an admin their account was signed in to from a
+// device it has not been used on before.
+func SendAdminLoginAlert(toEmail, device, ip string, at time.Time) error {
+	from := os.Getenv("ADMIN_EMAIL")
+	pass := os.Getenv("ADMIN_APP_PASSWORD")
+
+	html := template.HTMLEscapeString
+	subject := "New sign-in to your Ticpin admin account"
+	body := fmt.Sprintf("<h2>New device sign-in</h2><p>Your Ticpin admin account was signed in to from a new device.</p><p><b>Device:</b> %s<br><b>IP address:</b> %s<br><b>Time:</b> %s</p><p>If this was not you, contact another admin right away to lock your account and reset your two-factor authentication.</p>",
+		html(device), html(ip), at.Format("02 Jan 2006, 15:04 MST"))
+	return sendOTP(from, pass, toEmail, subject, body)
+}
+
+// SendRefundFailedAlert tells the Ticpin admins that a refund owed to a
+// customer could not be made and needs to be handled by hand.
+func SendRefundFailedAlert(bookingID, paymentID string, amount float64, lastErr string) error {
+	from := os.Getenv("ADMIN_EMAIL")
+	pass := os.Getenv("ADMIN_APP_PASSWORD")
+
+	html := template.HTMLEscapeString
+	subject := fmt.Sprintf("Refund failed for booking %s", bookingID)
+	body := fmt.Sprintf("<h2>Refund needs attention</h2><p>A refund of <b>₹%.2f</b> for booking <b>%s</b> (payment %s) failed after every retry. The customer has been charged and has no booking.</p><p><b>Last error:</b> %s</p>",
+		amount, html(bookingID), html(paymentID), html(lastErr))
+	return sendOTP(from, pass, from, subject, body)
+}
+
 func SendStatusEmail(toEmail, vertical, status, reason string) error {
 	var from, pass string
 
@@ -443,6 +485,103 @@ func SendCancellationEmail(toEmail, bookingID, category, venueName, date, grandT
 
 	return sendOTP(from, pass, toEmail, subject, body)
 }
+func SendEventRescheduledEmail(toEmail, bookingID, eventName, oldDate, newDate, respondBy string) error {
+	from := os.Getenv("EVENTS_EMAIL")
+	if from == "" {
+		from = os.Getenv("ADMIN_EMAIL")
+	}
+	pass := os.Getenv("EVENTS_APP_PASSWORD")
+	if pass == "" {
+		pass = os.Getenv("ADMIN_APP_PASSWORD")
+	}
+	if from == "" || pass == "" {
+		return nil
+	}
+	subject := fmt.Sprintf("[Ticpin] Event Rescheduled: %s", eventName)
+	body := fmt.Sprintf(`
+<html><body style="font-family:sans-serif;color:#222;">
+  <h2 style="color:#5331EA;">Your event has been rescheduled</h2>
+  <table style="border-collapse:collapse;width:100%%;max-width:480px;">
+    <tr><td style="padding:8px 0;color:#686868;">Event</td><td style="padding:8px 0;font-weight:600;">%s</td></tr>
+    <tr><td style="padding:8px 0;color:#686868;">Booking ID</td><td style="padding:8px 0;font-family:monospace;">#%s</td></tr>
+    <tr><td style="padding:8px 0;color:#686868;">Original Date</td><td style="padding:8px 0;">%s</td></tr>
+    <tr><td style="padding:8px 0;color:#686868;">New Date</td><td style="padding:8px 0;font-weight:600;">%s</td></tr>
+  </table>
+  <p style="margin-top:16px;line-height:1.6;">Your tickets remain valid for the new date. If you can no longer attend, you can request a full refund from your bookings page until <b>%s</b>.</p>
+  <p style="color:#AEAEAE;font-size:12px;margin-top:24px;">This is an automated notification from Ticpin.</p>
+</body></html>`, eventName, bookingID, oldDate, newDate, respondBy)
+	return sendOTP(from, pass, toEmail, subject, body)
+}
+
+// SendRefundDelayedEmail tells a booker that the refund for their cancelled
+// event booking could not be sent automatically and is being handled by the
+// Ticpin team.
+func SendRefundDelayedEmail(toEmail, bookingID, eventName string, amount float64) error {
+	from := os.Getenv("EVENTS_EMAIL")
+	if from == "" {
+		from = os.Getenv("ADMIN_EMAIL")
+	}
+	pass := os.Getenv("EVENTS_APP_PASSWORD")
+	if pass == "" {
+		pass = os.Getenv("ADMIN_APP_PASSWORD")
+	}
+	if from == "" || pass == "" {
+		return nil
+	}
+	html := template.HTMLEscapeString
+	subject := fmt.Sprintf("[Ticpin] Refund Delayed: #%s", bookingID)
+	body := fmt.Sprintf(`
+<html><body style="font-family:sans-serif;color:#222;">
+  <h2 style="color:#5331EA;">Your refund is delayed</h2>
+  <p style="line-height:1.6;">Your booking <b>#%s</b> for <b>%s</b> was cancelled, but we could not send the refund of <b>₹%.2f</b> automatically.</p>
+  <p style="line-height:1.6;">Our team has been notified and will process it by hand. You do not need to do anything.</p>
+  <p style="color:#AEAEAE;font-size:12px;margin-top:24px;">This is an automated notification from Ticpin.</p>
+</body></html>`, html(bookingID), html(eventName), amount)
+	return sendOTP(from, pass, toEmail, subject, body)
+}
+
+// SendPassRenewalReminderEmail warns a pass holder that their Ticpass is
+// about to expire. With autoRenew the mail confirms the upcoming charge
+// instead of asking them to renew.
+func SendPassRenewalReminderEmail(toEmail, planName, expiresOn string, price float64, autoRenew bool) error {
+	from := os.Getenv("ADMIN_EMAIL")
+	pass := os.Getenv("ADMIN_APP_PASSWORD")
+	if from == "" || pass == "" {
+		return nil
+	}
+	subject := fmt.Sprintf("[Ticpin] Your %s expires on %s", planName, expiresOn)
+	message := fmt.Sprintf("Renew from your pass page before <b>%s</b> to keep your benefits without a break.", expiresOn)
+	if autoRenew {
+		subject = fmt.Sprintf("[Ticpin] Your %s renews on %s", planName, expiresOn)
+		message = fmt.Sprintf("Auto-renew is on, so we will charge <b>₹%.2f</b> on <b>%s</b>. You can pause or cancel auto-renew from your pass page before then.", price, expiresOn)
+	}
+	body := fmt.Sprintf(`
+<html><body style="font-family:sans-serif;color:#222;">
+  <h2 style="color:#5331EA;">%s</h2>
+  <p style="line-height:1.6;">%s</p>
+  <p style="color:#AEAEAE;font-size:12px;margin-top:24px;">This is an automated notification from Ticpin.</p>
+</body></html>`, planName, message)
+	return sendOTP(from, pass, toEmail, subject, body)
+}
+
+// SendPassRenewalFailedEmail tells a pass holder that the automatic renewal
+// charge failed and how long the grace period lasts.
+func SendPassRenewalFailedEmail(toEmail, planName, graceEndsOn string) error {
+	from := os.Getenv("ADMIN_EMAIL")
+	pass := os.Getenv("ADMIN_APP_PASSWORD")
+	if from == "" || pass == "" {
+		return nil
+	}
+	subject := fmt.Sprintf("[Ticpin] We couldn't renew your %s", planName)
+	body := fmt.Sprintf(`
+<html><body style="font-family:sans-serif;color:#222;">
+  <h2 style="color:#5331EA;">Renewal payment failed</h2>
+  <p style="line-height:1.6;">We were unable to charge your saved payment method for <b>%s</b>. Your benefits stay available until <b>%s</b>. Renew from your pass page before then to avoid losing them.</p>
+  <p style="color:#AEAEAE;font-size:12px;margin-top:24px;">This is an automated notification from Ticpin.</p>
+</body></html>`, planName, graceEndsOn)
+	return sendOTP(from, pass, toEmail, subject, body)
+}
+
 func SendPassConfirmationEmail(toEmail string, data BookingEmailData) error {
 	from := os.Getenv("ADMIN_EMAIL")
 	pass := os.Getenv("ADMIN_APP_PASSWORD")
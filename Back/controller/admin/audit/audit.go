@@ -0,0 +1,74 @@
+package adminaudit
+
+import (
+	"strconv"
+	"time"
+
+	auditsvc "ticpin-backend/services/audit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseDate accepts RFC3339 or a plain date. A plain "to" date includes
+// the whole day.
+func parseDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// ListAuditLogs searches the audit log by ?actor= (email or id), ?entity=,
+// ?entity_id=, ?action= and a ?from= / ?to= date range.
+func ListAuditLogs(c *fiber.Ctx) error {
+	page, _ := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+	limit, _ := strconv.ParseInt(c.Query("limit", "50"), 10, 64)
+
+	from, err := parseDate(c.Query("from"), false)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "from must be a date (YYYY-MM-DD) or RFC3339 time"})
+	}
+	to, err := parseDate(c.Query("to"), true)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "to must be a date (YYYY-MM-DD) or RFC3339 time"})
+	}
+
+	filter := auditsvc.Filter{
+		Actor:    c.Query("actor"),
+		Entity:   c.Query("entity"),
+		EntityID: c.Query("entity_id"),
+		Action:   c.Query("action"),
+		From:     from,
+		To:       to,
+		Page:     page,
+		Limit:    limit,
+	}
+	logs, total, err := auditsvc.List(filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	return c.JSON(fiber.Map{
+		"logs":       logs,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + limit - 1) / limit,
+	})
+}
@@ -1,44 +1,186 @@
 package auth
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+
 	"ticpin-backend/models"
 	adminsvc "ticpin-backend/services/admin"
+	otpsvc "ticpin-backend/services/otp"
+	sessionsvc "ticpin-backend/services/session"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+func attemptOf(c *fiber.Ctx) adminsvc.LoginAttempt {
+	return adminsvc.LoginAttempt{
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		DeviceID:  c.Get("X-Device-ID"),
+	}
+}
+
+func lockedError(c *fiber.Ctx, err error) (bool, error) {
+	var locked *adminsvc.LockedError
+	if !errors.As(err, &locked) {
+		return false, nil
+	}
+	seconds := int(locked.RetryAfter.Seconds())
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return true, c.Status(429).JSON(fiber.Map{"error": locked.Error(), "retry_after": seconds})
+}
+
+func adminInfo(a *models.Admin) fiber.Map {
+	return fiber.Map{
+		"id":       a.ID.Hex(),
+		"email":    a.Email,
+		"phone":    a.Phone,
+		"name":     a.Name,
+		"isSuper":  a.IsSuper,
+		"roles":    a.Roles,
+		"userType": "admin",
+	}
+}
+
+// finishLogin starts the admin session once every required factor has
+// been checked.
+func finishLogin(c *fiber.Ctx, a *models.Admin, method string, mfa bool) error {
+	at := attemptOf(c)
+	org, err := adminsvc.CompleteLogin(a, method, at)
+	if err == adminsvc.ErrNoAdminAccess {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to sign in"})
+	}
+	if err := sessionsvc.StartAdmin(c, org, mfa); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to set session"})
+	}
+
+	resp := adminInfo(a)
+	if !a.TOTPEnabled {
+		if state, err := adminsvc.MFAStateFor(a.Email); err == nil && state.Required {
+			resp["mfa_setup_required"] = true
+		}
+	}
+	return c.JSON(resp)
+}
+
+// AdminLogin checks the first factor, a password or a phone code. Admins
+// with two-factor authentication get an mfa_token to finish the sign-in
+// with at /api/admin/login/verify.
 func AdminLogin(c *fiber.Ctx) error {
 	var req struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 		Phone    string `json:"phone"`
+		OTP      string `json:"otp"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
 	}
 
+	at := attemptOf(c)
 	var adminUser *models.Admin
+	var method string
 	var err error
 
 	if req.Email != "" {
-		adminUser, err = adminsvc.Login(req.Email, req.Password)
+		method = "password"
+		adminUser, err = adminsvc.Authenticate(req.Email, req.Password)
 	} else if req.Phone != "" {
+		method = "phone"
+		if req.OTP == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "otp is required"})
+		}
 		adminUser, err = adminsvc.GetByPhone(req.Phone)
+		if err != nil {
+			adminsvc.RecordLoginEvent(req.Phone, method, "invalid_credentials", "unknown phone", at, false)
+			return c.Status(401).JSON(fiber.Map{"error": "invalid admin credentials"})
+		}
+		err = adminsvc.CheckPhoneLogin(adminUser, otpsvc.VerifyPhoneOTP(req.Phone, req.OTP))
 	} else {
 		return c.Status(400).JSON(fiber.Map{"error": "email or phone required"})
 	}
 
 	if err != nil {
+		email := req.Email
+		if adminUser != nil {
+			email = adminUser.Email
+		}
+		if locked, resp := lockedError(c, err); locked {
+			adminsvc.RecordLoginEvent(email, method, "locked", "", at, false)
+			return resp
+		}
+		adminsvc.RecordLoginEvent(email, method, "invalid_credentials", err.Error(), at, false)
 		return c.Status(401).JSON(fiber.Map{"error": "invalid admin credentials"})
 	}
 
-	return c.JSON(fiber.Map{
-		"id":       adminUser.ID.Hex(),
-		"email":    adminUser.Email,
-		"phone":    adminUser.Phone,
-		"name":     adminUser.Name,
-		"isSuper":  adminUser.IsSuper,
-		"userType": "admin",
-	})
+	if adminUser.TOTPEnabled {
+		token, err := adminsvc.IssueMFAToken(adminUser.Email, method)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to sign in"})
+		}
+		adminsvc.RecordLoginEvent(adminUser.Email, method, "mfa_required", "", at, false)
+		return c.JSON(fiber.Map{"mfa_required": true, "mfa_token": token})
+	}
+
+	return finishLogin(c, adminUser, method, false)
+}
+
+// VerifyAdminLogin finishes a sign-in with a TOTP code or a recovery code.
+func VerifyAdminLogin(c *fiber.Ctx) error {
+	var req struct {
+		MFAToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "code or recovery_code is required"})
+	}
+
+	email, _, err := adminsvc.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	at := attemptOf(c)
+	method, err := adminsvc.VerifySecondFactor(email, req.Code, req.RecoveryCode)
+	if err != nil {
+		if locked, resp := lockedError(c, err); locked {
+			adminsvc.RecordLoginEvent(email, "totp", "locked", "", at, false)
+			return resp
+		}
+		adminsvc.RecordLoginEvent(email, "totp", "invalid_code", err.Error(), at, false)
+		return c.Status(401).JSON(fiber.Map{"error": "invalid authentication code"})
+	}
+
+	adminUser, err := adminsvc.GetByEmail(email)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "invalid admin credentials"})
+	}
+	return finishLogin(c, adminUser, method, true)
+}
+
+// SendAdminPhoneOTP texts a login code to an admin's phone. It answers the
+// same whether or not the number belongs to an admin.
+func SendAdminPhoneOTP(c *fiber.Ctx) error {
+	var req struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Phone == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "phone is required"})
+	}
+
+	if _, err := adminsvc.GetByPhone(req.Phone); err == nil {
+		if err := otpsvc.SendPhoneOTP(req.Phone); err != nil {
+			fmt.Printf("ERROR: Failed to send admin login code: %v\n", err)
+		}
+	}
+	return c.JSON(fiber.Map{"message": "if this number belongs to an admin, a code has been sent"})
 }
@@ -0,0 +1,157 @@
+package admincoupon
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+
+	"ticpin-backend/models"
+	couponsvc "ticpin-backend/services/coupon"
+	"ticpin-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CreateCampaign(c *fiber.Ctx) error {
+	var input struct {
+		Name          string              `json:"name" validate:"required"`
+		Partner       string              `json:"partner"`
+		Description   string              `json:"description"`
+		Category      string              `json:"category" validate:"required,oneof=event play dining"`
+		DiscountType  string              `json:"discount_type" validate:"required,oneof=percent flat"`
+		DiscountValue float64             `json:"discount_value" validate:"required,gt=0"`
+		ValidFrom     string              `json:"valid_from"`
+		ValidUntil    string              `json:"valid_until"`
+		Rules         *models.CouponRules `json:"rules"`
+		Prefix        string              `json:"prefix"`
+		Alphabet      string              `json:"alphabet"`
+		CodeLength    int                 `json:"code_length"`
+		Count         int                 `json:"count"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	var validFrom, validUntil time.Time
+	var err error
+	if input.ValidFrom != "" {
+		validFrom, err = time.Parse(time.RFC3339, input.ValidFrom)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid valid_from date: " + err.Error()})
+		}
+	}
+	if input.ValidUntil != "" {
+		validUntil, err = time.Parse(time.RFC3339, input.ValidUntil)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid valid_until date: " + err.Error()})
+		}
+	}
+
+	campaign := models.CouponCampaign{
+		Name:          input.Name,
+		Partner:       input.Partner,
+		Description:   input.Description,
+		Category:      input.Category,
+		DiscountType:  input.DiscountType,
+		DiscountValue: input.DiscountValue,
+		ValidFrom:     validFrom,
+		ValidUntil:    validUntil,
+		Rules:         input.Rules,
+		Prefix:        input.Prefix,
+		Alphabet:      input.Alphabet,
+		CodeLength:    input.CodeLength,
+	}
+	if err := couponsvc.CreateCampaign(&campaign); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if input.Count > 0 {
+		issued, err := couponsvc.GenerateCodes(campaign.ID.Hex(), input.Count)
+		campaign.IssuedCount = issued
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error(), "campaign": campaign, "issued": issued})
+		}
+	}
+	return c.Status(201).JSON(fiber.Map{"message": "campaign created", "campaign": campaign})
+}
+
+func ListCampaigns(c *fiber.Ctx) error {
+	list, err := couponsvc.ListCampaigns()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func UpdateCampaign(c *fiber.Ctx) error {
+	var input struct {
+		IsActive *bool `json:"is_active"`
+	}
+	if err := c.BodyParser(&input); err != nil || input.IsActive == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "is_active required"})
+	}
+	if err := couponsvc.SetCampaignActive(c.Params("id"), *input.IsActive); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "campaign updated"})
+}
+
+func GenerateCampaignCodes(c *fiber.Ctx) error {
+	var input struct {
+		Count int `json:"count"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	issued, err := couponsvc.GenerateCodes(c.Params("id"), input.Count)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error(), "issued": issued})
+	}
+	return c.JSON(fiber.Map{"message": "codes generated", "issued": issued})
+}
+
+// ExportCampaignCodes downloads a campaign's codes and their redemption
+// state as CSV.
+func ExportCampaignCodes(c *fiber.Ctx) error {
+	campaign, codes, err := couponsvc.ListCampaignCodes(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"code", "status", "user_id", "booking_id", "redeemed_at", "created_at"})
+	for _, code := range codes {
+		redeemedAt := ""
+		if code.RedeemedAt != nil {
+			redeemedAt = code.RedeemedAt.Format(time.RFC3339)
+		}
+		_ = w.Write([]string{
+			code.Code,
+			code.Status,
+			code.UserID,
+			code.BookingID,
+			redeemedAt,
+			code.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	name := strings.ToLower(strings.Join(strings.Fields(campaign.Name), "-"))
+	c.Set(fiber.HeaderContentType, "text/csv")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s-codes.csv"`, name))
+	return c.Send(buf.Bytes())
+}
+
+func GetCampaignReport(c *fiber.Ctx) error {
+	report, err := couponsvc.GetCampaignReport(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(report)
+}
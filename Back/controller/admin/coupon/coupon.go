@@ -2,6 +2,7 @@ package admincoupon
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"ticpin-backend/config"
@@ -16,16 +17,17 @@ import (
 
 func CreateCoupon(c *fiber.Ctx) error {
 	var input struct {
-		Code          string   `json:"code" validate:"required"`
-		Description   string   `json:"description"`
-		Category      string   `json:"category" validate:"required,oneof=event play dining"`
-		DiscountType  string   `json:"discount_type" validate:"required,oneof=percent flat"`
-		DiscountValue float64  `json:"discount_value" validate:"required,gt=0"`
-		UserIDs       []string `json:"user_ids"`
-		IsPublic      bool     `json:"is_public"`
-		ValidFrom     string   `json:"valid_from"`
-		ValidUntil    string   `json:"valid_until"`
-		MaxUses       int      `json:"max_uses"`
+		Code          string              `json:"code" validate:"required"`
+		Description   string              `json:"description"`
+		Category      string              `json:"category" validate:"required,oneof=event play dining"`
+		DiscountType  string              `json:"discount_type" validate:"required,oneof=percent flat"`
+		DiscountValue float64             `json:"discount_value" validate:"required,gt=0"`
+		UserIDs       []string            `json:"user_ids"`
+		IsPublic      bool                `json:"is_public"`
+		ValidFrom     string              `json:"valid_from"`
+		ValidUntil    string              `json:"valid_until"`
+		MaxUses       int                 `json:"max_uses"`
+		Rules         *models.CouponRules `json:"rules"`
 	}
 	if err := utils.ParseAndValidate(c, &input); err != nil {
 		return err
@@ -70,6 +72,7 @@ func CreateCoupon(c *fiber.Ctx) error {
 		ValidUntil:    validUntil,
 		MaxUses:       input.MaxUses,
 		IsActive:      true,
+		Rules:         input.Rules,
 	}
 
 	if err := couponsvc.Create(&coupon); err != nil {
@@ -98,7 +101,10 @@ func GetCouponsByCategory(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid category"})
 	}
 	userID := c.Query("user_id")
-	coupons, err := couponsvc.GetByCategory(category, userID)
+	coupons, err := couponsvc.GetByCategory(category, userID, couponsvc.ListingScope{
+		TargetID: c.Query("target_id"),
+		City:     c.Query("city"),
+	})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -109,7 +115,7 @@ func ListUsers(c *fiber.Ctx) error {
 	col := config.GetDB().Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := col.Find(ctx, bson.M{})
+	cursor, err := col.Find(ctx, bson.M{"deleted_at": bson.M{"$exists": false}})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -128,12 +134,29 @@ func ValidateCoupon(c *fiber.Ctx) error {
 		OrderAmount float64 `json:"order_amount"`
 		UserID      string  `json:"user_id"`
 		UserEmail   string  `json:"user_email"`
+		TargetID    string  `json:"target_id"`
+		City        string  `json:"city"`
+		OfferID     string  `json:"offer_id"`
+		UseTicpass  bool    `json:"use_ticpass"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
 	}
-	result, err := couponsvc.Validate(req.Code, req.Category, req.OrderAmount, req.UserID, req.UserEmail)
+	result, err := couponsvc.Validate(req.Code, couponsvc.Checkout{
+		Category:    req.Category,
+		OrderAmount: req.OrderAmount,
+		UserID:      req.UserID,
+		UserEmail:   req.UserEmail,
+		TargetID:    req.TargetID,
+		City:        req.City,
+		WithOffer:   req.OfferID != "",
+		WithTicpass: req.UseTicpass,
+	})
 	if err != nil {
+		var rejection *couponsvc.Rejection
+		if errors.As(err, &rejection) {
+			return c.Status(400).JSON(fiber.Map{"error": rejection.Message, "rule": rejection.Rule})
+		}
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(fiber.Map{
@@ -146,17 +169,18 @@ func ValidateCoupon(c *fiber.Ctx) error {
 func UpdateCoupon(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var input struct {
-		Code          string   `json:"code"`
-		Description   string   `json:"description"`
-		Category      string   `json:"category"`
-		DiscountType  string   `json:"discount_type"`
-		DiscountValue float64  `json:"discount_value"`
-		UserIDs       []string `json:"user_ids"`
-		IsPublic      bool     `json:"is_public"`
-		ValidFrom     string   `json:"valid_from"`
-		ValidUntil    string   `json:"valid_until"`
-		MaxUses       int      `json:"max_uses"`
-		IsActive      bool     `json:"is_active"`
+		Code          string              `json:"code"`
+		Description   string              `json:"description"`
+		Category      string              `json:"category"`
+		DiscountType  string              `json:"discount_type"`
+		DiscountValue float64             `json:"discount_value"`
+		UserIDs       []string            `json:"user_ids"`
+		IsPublic      bool                `json:"is_public"`
+		ValidFrom     string              `json:"valid_from"`
+		ValidUntil    string              `json:"valid_until"`
+		MaxUses       int                 `json:"max_uses"`
+		IsActive      bool                `json:"is_active"`
+		Rules         *models.CouponRules `json:"rules"`
 	}
 	if err := c.BodyParser(&input); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
@@ -194,6 +218,7 @@ func UpdateCoupon(c *fiber.Ctx) error {
 		ValidUntil:    validUntil,
 		MaxUses:       input.MaxUses,
 		IsActive:      input.IsActive,
+		Rules:         input.Rules,
 	}
 
 	if err := couponsvc.Update(id, &coupon); err != nil {
@@ -209,3 +234,11 @@ func DeleteCoupon(c *fiber.Ctx) error {
 	}
 	return c.JSON(fiber.Map{"message": "coupon deleted"})
 }
+
+func ListCouponRedemptions(c *fiber.Ctx) error {
+	list, err := couponsvc.ListRedemptions(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
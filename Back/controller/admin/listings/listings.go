@@ -10,34 +10,25 @@ import (
 	diningservice "ticpin-backend/services/dining"
 	eventservice "ticpin-backend/services/event"
 	playservice "ticpin-backend/services/play"
+	trashsvc "ticpin-backend/services/trash"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func deleteDoc(collection string, id string) error {
-	objID, err := primitive.ObjectIDFromHex(id)
+// softDelete moves a listing to the deleted items, where an admin can
+// restore it until the retention job purges it.
+func softDelete(c *fiber.Ctx, kind, noun string) error {
+	actor, _ := c.Locals("email").(string)
+	err := trashsvc.SoftDelete(kind, c.Params("id"), actor)
+	if err == trashsvc.ErrNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": noun + " not found"})
+	}
 	if err != nil {
-		return err
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	col := config.GetDB().Collection(collection)
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	_, err = col.DeleteOne(ctx, bson.M{"_id": objID})
-	if err == nil {
-
-		keyType := collection
-		if collection == "plays" {
-			keyType = "play"
-		} else if collection == "events" {
-			keyType = "event"
-		} else if collection == "dinings" {
-			keyType = "dining"
-		}
-		cache.GlobalCache.Delete(keyType + ":" + id)
-	}
-	return err
+	return c.JSON(fiber.Map{"message": noun + " deleted"})
 }
 
 func ListAllEvents(c *fiber.Ctx) error {
@@ -269,22 +260,13 @@ func UpdatePlay(c *fiber.Ctx) error {
 }
 
 func DeleteEvent(c *fiber.Ctx) error {
-	if err := deleteDoc("events", c.Params("id")); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-	return c.JSON(fiber.Map{"message": "event deleted"})
+	return softDelete(c, trashsvc.KindEvents, "event")
 }
 
 func DeleteDining(c *fiber.Ctx) error {
-	if err := deleteDoc("dinings", c.Params("id")); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-	return c.JSON(fiber.Map{"message": "dining deleted"})
+	return softDelete(c, trashsvc.KindDining, "dining")
 }
 
 func DeletePlay(c *fiber.Ctx) error {
-	if err := deleteDoc("plays", c.Params("id")); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
-	}
-	return c.JSON(fiber.Map{"message": "play deleted"})
+	return softDelete(c, trashsvc.KindPlay, "play")
 }
@@ -58,6 +58,10 @@ func CreateOffer(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid valid_until date format"})
 	}
+	rules, err := parseOfferRules(c, form, validUntilTime)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 	var imageURL string
 	fileHeader, err := c.FormFile("image")
 	if err == nil && fileHeader != nil {
@@ -100,18 +104,24 @@ func CreateOffer(c *fiber.Ctx) error {
 	}
 
 	offer := &models.EventOffer{
-		ID:            primitive.NewObjectID(),
-		Title:         title,
-		Description:   description,
-		Image:         imageURL,
-		DiscountType:  discountTypeStr,
-		DiscountValue: discountValue,
-		AppliesTo:     appliesTo,
-		EntityIDs:     entityObjIDs,
-		ValidUntil:    validUntilTime,
-		IsActive:      true,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		ID:             primitive.NewObjectID(),
+		Title:          title,
+		Description:    description,
+		Image:          imageURL,
+		DiscountType:   discountTypeStr,
+		DiscountValue:  discountValue,
+		AppliesTo:      appliesTo,
+		EntityIDs:      entityObjIDs,
+		StartsAt:       rules.StartsAt,
+		ValidUntil:     validUntilTime,
+		AutoApply:      rules.AutoApply,
+		MinOrderAmount: rules.MinOrderAmount,
+		MaxDiscount:    rules.MaxDiscount,
+		DailyQuota:     rules.DailyQuota,
+		Payment:        rules.Payment,
+		IsActive:       true,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	if err := offersvc.Create(offer); err != nil {
@@ -212,6 +222,10 @@ func UpdateOffer(c *fiber.Ctx) error {
 	fmt.Sscanf(discountValueStr, "%f", &discountValue)
 
 	validUntilTime, _ := time.Parse(time.RFC3339, validUntil)
+	rules, err := parseOfferRules(c, form, validUntilTime)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	var imageURL string
 	fileHeader, err := c.FormFile("image")
@@ -248,15 +262,21 @@ func UpdateOffer(c *fiber.Ctx) error {
 	}
 
 	offer := &models.EventOffer{
-		Title:         title,
-		Description:   description,
-		DiscountType:  discountTypeStr,
-		DiscountValue: discountValue,
-		AppliesTo:     appliesTo,
-		EntityIDs:     entityObjIDs,
-		ValidUntil:    validUntilTime,
-		IsActive:      isActiveStr == "true" || isActiveStr == "",
-		UpdatedAt:     time.Now(),
+		Title:          title,
+		Description:    description,
+		DiscountType:   discountTypeStr,
+		DiscountValue:  discountValue,
+		AppliesTo:      appliesTo,
+		EntityIDs:      entityObjIDs,
+		StartsAt:       rules.StartsAt,
+		ValidUntil:     validUntilTime,
+		AutoApply:      rules.AutoApply,
+		MinOrderAmount: rules.MinOrderAmount,
+		MaxDiscount:    rules.MaxDiscount,
+		DailyQuota:     rules.DailyQuota,
+		Payment:        rules.Payment,
+		IsActive:       isActiveStr == "true" || isActiveStr == "",
+		UpdatedAt:      time.Now(),
 	}
 	if imageURL != "" {
 		offer.Image = imageURL
@@ -0,0 +1,148 @@
+package adminoffer
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"time"
+
+	"ticpin-backend/models"
+	offersvc "ticpin-backend/services/offer"
+	"ticpin-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type offerRules struct {
+	StartsAt       time.Time
+	AutoApply      bool
+	MinOrderAmount float64
+	MaxDiscount    float64
+	DailyQuota     int
+	Payment        *models.OfferPaymentCondition
+}
+
+var paymentMethods = map[string]bool{"card": true, "upi": true, "netbanking": true, "wallet": true}
+
+func formList(form *multipart.Form, key string) []string {
+	if form == nil {
+		return nil
+	}
+	list := []string{}
+	for _, v := range form.Value[key] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				list = append(list, part)
+			}
+		}
+	}
+	return list
+}
+
+func formNumber(c *fiber.Ctx, key string) (float64, error) {
+	v := c.FormValue(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a number >= 0", key)
+	}
+	return n, nil
+}
+
+// parseOfferRules reads the scheduling, limit and payment fields of the
+// offer form. List fields accept repeated values or a comma separated one.
+func parseOfferRules(c *fiber.Ctx, form *multipart.Form, validUntil time.Time) (*offerRules, error) {
+	r := &offerRules{AutoApply: c.FormValue("auto_apply") == "true"}
+
+	if v := c.FormValue("starts_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("invalid starts_at date format")
+		}
+		if !validUntil.IsZero() && !t.Before(validUntil) {
+			return nil, errors.New("starts_at must be before valid_until")
+		}
+		r.StartsAt = t
+	}
+
+	var err error
+	if r.MinOrderAmount, err = formNumber(c, "min_order_amount"); err != nil {
+		return nil, err
+	}
+	if r.MaxDiscount, err = formNumber(c, "max_discount"); err != nil {
+		return nil, err
+	}
+	quota, err := formNumber(c, "daily_quota")
+	if err != nil {
+		return nil, err
+	}
+	r.DailyQuota = int(quota)
+
+	cond := &models.OfferPaymentCondition{
+		Methods:      formList(form, "payment_methods"),
+		CardBINs:     formList(form, "card_bins"),
+		CardNetworks: formList(form, "card_networks"),
+		Issuers:      formList(form, "issuers"),
+	}
+	for i, m := range cond.Methods {
+		cond.Methods[i] = strings.ToLower(m)
+		if !paymentMethods[cond.Methods[i]] {
+			return nil, fmt.Errorf("invalid payment method: %s (card, upi, netbanking, wallet)", m)
+		}
+	}
+	for _, bin := range cond.CardBINs {
+		if len(bin) < 6 || len(bin) > 8 || strings.Trim(bin, "0123456789") != "" {
+			return nil, fmt.Errorf("invalid card BIN: %s (6 to 8 digits)", bin)
+		}
+	}
+	if len(cond.Methods) > 0 || len(cond.CardBINs) > 0 || len(cond.CardNetworks) > 0 || len(cond.Issuers) > 0 {
+		r.Payment = cond
+	}
+	return r, nil
+}
+
+// GetBestOffer previews the offer a booking would get: the one the user
+// picked if it still applies, otherwise the best auto-apply offer.
+func GetBestOffer(c *fiber.Ctx) error {
+	var input struct {
+		Category      string  `json:"category" validate:"required,oneof=event play dining"`
+		EntityID      string  `json:"entity_id" validate:"required"`
+		OrderAmount   float64 `json:"order_amount" validate:"required,gt=0"`
+		OfferID       string  `json:"offer_id"`
+		PaymentMethod string  `json:"payment_method"`
+		CardBIN       string  `json:"card_bin"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	best, err := offersvc.Best(offersvc.Checkout{
+		Category:      input.Category,
+		EntityID:      input.EntityID,
+		OrderAmount:   input.OrderAmount,
+		UserID:        userID,
+		PaymentMethod: input.PaymentMethod,
+		CardBIN:       input.CardBIN,
+		AutoApply:     true,
+	}, input.OfferID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if best == nil {
+		return c.JSON(fiber.Map{"offer": nil, "discount_amount": 0})
+	}
+	return c.JSON(fiber.Map{"offer": best.Offer, "discount_amount": best.DiscountAmount})
+}
+
+func ListOfferRedemptions(c *fiber.Ctx) error {
+	list, err := offersvc.ListRedemptions(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
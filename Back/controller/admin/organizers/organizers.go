@@ -5,6 +5,8 @@ import (
 	"ticpin-backend/config"
 	"ticpin-backend/models"
 	organizersvc "ticpin-backend/services/organizer"
+	sessionsvc "ticpin-backend/services/session"
+	trashsvc "ticpin-backend/services/trash"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -17,7 +19,7 @@ func ListOrganizers(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 	search := c.Query("search", "")
 
-	filter := bson.M{}
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
 	if search != "" {
 		filter["email"] = bson.M{"$regex": search, "$options": "i"}
 	}
@@ -134,6 +136,8 @@ func UpdateCategoryStatus(c *fiber.Ctx) error {
 	if err := organizersvc.UpdateCategoryStatus(id, body.Category, body.Status); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	// Signed-in sessions pick up the new status on their next request
+	_ = sessionsvc.MarkStale(sessionsvc.KindOrganizer, id)
 
 	return c.JSON(fiber.Map{"message": "status updated for " + body.Category})
 }
@@ -144,14 +148,24 @@ func DeleteOrganizer(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid id"})
 	}
 
-	_, err = config.GetDB().Collection("organizers").DeleteOne(c.Context(), bson.M{"_id": id})
+	// Setups, profile and team are kept so that a restore is complete; the
+	// retention job removes them with the organizer
+	actor, _ := c.Locals("email").(string)
+	err = trashsvc.SoftDelete(trashsvc.KindOrganizers, id.Hex(), actor)
+	if err == trashsvc.ErrNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "organizer not found"})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to delete organizer"})
 	}
-	_, _ = config.GetDB().Collection("organizer_setups").DeleteMany(c.Context(), bson.M{"organizerId": id})
-	_, _ = config.GetDB().Collection("profiles").DeleteOne(c.Context(), bson.M{"organizerId": id})
+	_, _ = sessionsvc.RevokeAll(sessionsvc.KindOrganizer, id.Hex(), "", "account_deleted")
+	if memberIDs, err := organizersvc.MemberIDs(id.Hex()); err == nil {
+		for _, memberID := range memberIDs {
+			_, _ = sessionsvc.RevokeAll(sessionsvc.KindMember, memberID, "", "account_deleted")
+		}
+	}
 
-	return c.JSON(fiber.Map{"message": "organizer and related data deleted"})
+	return c.JSON(fiber.Map{"message": "organizer and their listings deleted"})
 }
 
 func UpdateOrganizer(c *fiber.Ctx) error {
@@ -175,6 +189,7 @@ func UpdateOrganizer(c *fiber.Ctx) error {
 		"email": payload.Organizer.Email,
 		"name":  payload.Organizer.Name,
 	}})
+	_ = sessionsvc.MarkStale(sessionsvc.KindOrganizer, id)
 
 	config.GetDB().Collection("profiles").UpdateOne(c.Context(), bson.M{"organizerId": objID}, bson.M{"$set": payload.Profile}, options.Update().SetUpsert(true))
 
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"ticpin-backend/config"
 	"ticpin-backend/models"
-	_ "ticpin-backend/services/pass"
+	passsvc "ticpin-backend/services/pass"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -66,52 +66,35 @@ func ListUsersForPass(c *fiber.Ctx) error {
 // CreateAdminPass allows admin to manually create a pass for a user
 func CreateAdminPass(c *fiber.Ctx) error {
 	var req struct {
-		UserID         string `json:"user_id"`
-		DurationMonths int    `json:"duration_months"` // 3 or 6
+		UserID         string  `json:"user_id"`
+		PlanID         string  `json:"plan_id"`
+		DurationMonths int     `json:"duration_months"` // defaults to the plan's duration
 		Price          float64 `json:"price"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
 	}
 
-	if req.UserID == "" || (req.DurationMonths != 3 && req.DurationMonths != 6) {
-		return c.Status(400).JSON(fiber.Map{"error": "user_id and valid duration (3 or 6 months) required"})
+	if req.UserID == "" || req.DurationMonths < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "user_id and a valid duration required"})
+	}
+	userObjID, err := primitive.ObjectIDFromHex(req.UserID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid user_id"})
 	}
 
-	// Prepare pass details
-	now := time.Now()
-	expiryDate := now.AddDate(0, req.DurationMonths, 0)
-	userObjID, _ := primitive.ObjectIDFromHex(req.UserID)
-
-	pass := models.TicpinPass{
-		UserID:    userObjID,
-		PaymentID: "ADMIN_CREATED_" + fmt.Sprintf("%d", now.Unix()),
-		QRToken:   primitive.NewObjectID().Hex(),
-		Price:     req.Price,
-		Status:    "active",
-		StartDate: now,
-		EndDate:   expiryDate,
-		Benefits: models.PassBenefits{
-			TurfBookings: models.BenefitCounter{
-				Total:     2,
-				Used:      0,
-				Remaining: 2,
-			},
-			DiningVouchers: models.DiningVoucherBenefit{
-				Total:     2,
-				Used:      0,
-				Remaining: 2,
-				ValueEach: 250.0,
-			},
-			EventsDiscountActive: true,
-		},
-		Renewals:  []models.RenewalRecord{},
-		CreatedAt: now,
-		UpdatedAt: now,
+	plan, err := passsvc.ResolvePlan(req.PlanID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	now := time.Now()
+	pass := passsvc.NewPassFromPlan(plan, userObjID, req.DurationMonths)
+	pass.PaymentID = "ADMIN_CREATED_" + fmt.Sprintf("%d", now.Unix())
+	pass.Price = req.Price
+
 	// Create record
-	_, err := config.PassesCol.InsertOne(context.Background(), pass)
+	_, err = config.PassesCol.InsertOne(context.Background(), pass)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -200,14 +183,6 @@ func UpdateAdminPass(c *fiber.Ctx) error {
 		update["end_date"] = req.EndDate
 	}
 	
-	// Update benefits if provided
-	if req.TurfLeft >= 0 {
-		update["benefits.turf_bookings.remaining"] = req.TurfLeft
-	}
-	if req.DiningLeft >= 0 {
-		update["benefits.dining_vouchers.remaining"] = req.DiningLeft
-	}
-
 	result, err := config.PassesCol.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": update})
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
@@ -216,6 +191,18 @@ func UpdateAdminPass(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "pass not found"})
 	}
 
+	// Benefit changes go through the ledger so history stays complete
+	if req.TurfLeft >= 0 {
+		if err := passsvc.AdjustBenefit(id.Hex(), passsvc.BenefitTurf, req.TurfLeft, "admin update"); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.DiningLeft >= 0 {
+		if err := passsvc.AdjustBenefit(id.Hex(), passsvc.BenefitDining, req.DiningLeft, "admin update"); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
 	return c.JSON(fiber.Map{"message": "pass updated successfully"})
 }
 
@@ -227,14 +214,14 @@ func RenewAdminPass(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		DurationMonths int `json:"duration_months"` // 3 or 6
+		DurationMonths int `json:"duration_months"` // defaults to the plan's duration
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
 	}
 
-	if req.DurationMonths != 3 && req.DurationMonths != 6 {
-		return c.Status(400).JSON(fiber.Map{"error": "valid duration (3 or 6 months) required"})
+	if req.DurationMonths < 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "valid duration required"})
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -245,6 +232,11 @@ func RenewAdminPass(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "pass not found"})
 	}
 
+	plan := passsvc.PlanFor(&p)
+	if req.DurationMonths == 0 {
+		req.DurationMonths = plan.DurationMonths
+	}
+
 	newStart := time.Now()
 	// If pass is still active, start from its end date
 	if p.EndDate.After(newStart) && p.Status == "active" {
@@ -265,10 +257,10 @@ func RenewAdminPass(c *fiber.Ctx) error {
 			"status":                       "active",
 			"end_date":                     newEnd,
 			"updatedAt":                    time.Now(),
-			"benefits.turf_bookings.used":   0,
-			"benefits.turf_bookings.remaining": 2,
-			"benefits.dining_vouchers.used": 0,
-			"benefits.dining_vouchers.remaining": 2,
+			"benefits.turf_bookings.used":        0,
+			"benefits.turf_bookings.remaining":   plan.TurfBookings,
+			"benefits.dining_vouchers.used":      0,
+			"benefits.dining_vouchers.remaining": plan.DiningVouchers,
 		},
 		"$push": bson.M{
 			"renewals": renewal,
@@ -332,3 +324,21 @@ func GetUserBySearch(c *fiber.Ctx) error {
 
 	return c.JSON(users)
 }
+
+// GetPassLedger returns a pass's benefit history with ledger-derived counters
+func GetPassLedger(c *fiber.Ctx) error {
+	view, err := passsvc.GetLedger(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(view)
+}
+
+// ReconcilePass rewrites a pass's benefit counters from its ledger
+func ReconcilePass(c *fiber.Ctx) error {
+	view, err := passsvc.Reconcile(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(view)
+}
@@ -0,0 +1,75 @@
+package adminpass
+
+import (
+	"strconv"
+	"ticpin-backend/models"
+	passsvc "ticpin-backend/services/pass"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListPassPlans lists every Ticpass plan, including inactive ones
+func ListPassPlans(c *fiber.Ctx) error {
+	plans, err := passsvc.ListPlans(c.Query("active") == "true")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(plans)
+}
+
+// CreatePassPlan adds a new Ticpass plan
+func CreatePassPlan(c *fiber.Ctx) error {
+	var plan models.PassPlan
+	if err := c.BodyParser(&plan); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+	if err := passsvc.CreatePlan(&plan); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(plan)
+}
+
+// UpdatePassPlan replaces a plan's terms. Passes already sold keep the
+// terms they were bought with until they renew.
+func UpdatePassPlan(c *fiber.Ctx) error {
+	var plan models.PassPlan
+	if err := c.BodyParser(&plan); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+	if err := passsvc.UpdatePlan(c.Params("id"), &plan); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(plan)
+}
+
+// DeletePassPlan withdraws a plan from sale
+func DeletePassPlan(c *fiber.Ctx) error {
+	if err := passsvc.DeactivatePlan(c.Params("id")); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "plan deactivated successfully"})
+}
+
+// ListPassGifts lists gift passes with their redemption status
+func ListPassGifts(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	gifts, total, err := passsvc.ListGifts(c.Query("status"), c.Query("search"), page, limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"gifts":      gifts,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + int64(limit) - 1) / int64(limit),
+	})
+}
@@ -0,0 +1,60 @@
+package adminreferral
+
+import (
+	"strconv"
+
+	"ticpin-backend/models"
+	referralsvc "ticpin-backend/services/referral"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetSettings(c *fiber.Ctx) error {
+	s, err := referralsvc.GetSettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(s)
+}
+
+func UpdateSettings(c *fiber.Ctx) error {
+	var s models.ReferralSettings
+	if err := c.BodyParser(&s); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := referralsvc.UpdateSettings(&s); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(s)
+}
+
+func ListReferrals(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	list, total, err := referralsvc.ListReferrals(c.Query("status"), c.Query("referrer_id"), page, limit)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"referrals":  list,
+		"total":      total,
+		"page":       page,
+		"limit":      limit,
+		"totalPages": (total + int64(limit) - 1) / int64(limit),
+	})
+}
+
+func GetStats(c *fiber.Ctx) error {
+	stats, err := referralsvc.GetStats()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(stats)
+}
@@ -0,0 +1,106 @@
+package adminroles
+
+import (
+	"ticpin-backend/models"
+	adminsvc "ticpin-backend/services/admin"
+	"ticpin-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ListPermissions(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"permissions": adminsvc.Permissions})
+}
+
+// GetMyPermissions lets the admin panel hide what the signed-in admin
+// cannot do.
+func GetMyPermissions(c *fiber.Ctx) error {
+	email, _ := c.Locals("email").(string)
+	perms, err := adminsvc.PermissionsFor(email)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"email": email, "permissions": perms})
+}
+
+func ListRoles(c *fiber.Ctx) error {
+	roles, err := adminsvc.ListRoles()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(roles)
+}
+
+func CreateRole(c *fiber.Ctx) error {
+	var input struct {
+		Name        string   `json:"name" validate:"required"`
+		Label       string   `json:"label"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions" validate:"required"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	role := models.AdminRole{
+		Name:        input.Name,
+		Label:       input.Label,
+		Description: input.Description,
+		Permissions: input.Permissions,
+	}
+	if err := adminsvc.CreateRole(&role); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(201).JSON(role)
+}
+
+func UpdateRole(c *fiber.Ctx) error {
+	var input struct {
+		Label       string   `json:"label"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions" validate:"required"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	role, err := adminsvc.UpdateRole(c.Params("id"), input.Label, input.Description, input.Permissions)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(role)
+}
+
+func DeleteRole(c *fiber.Ctx) error {
+	if err := adminsvc.DeleteRole(c.Params("id")); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "role deleted"})
+}
+
+func ListAdmins(c *fiber.Ctx) error {
+	admins, err := adminsvc.ListAdmins()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(admins)
+}
+
+// AssignRoles replaces an admin's roles. An empty list takes away admin
+// access.
+func AssignRoles(c *fiber.Ctx) error {
+	var input struct {
+		Email string   `json:"email" validate:"required,email"`
+		Roles []string `json:"roles"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	actor, _ := c.Locals("email").(string)
+	account, err := adminsvc.AssignRoles(input.Email, input.Roles, actor)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(account)
+}
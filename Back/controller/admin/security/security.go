@@ -0,0 +1,198 @@
+package adminsecurity
+
+import (
+	"errors"
+	"strconv"
+
+	adminsvc "ticpin-backend/services/admin"
+	sessionsvc "ticpin-backend/services/session"
+	"ticpin-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func codeError(c *fiber.Ctx, err error) error {
+	var locked *adminsvc.LockedError
+	if errors.As(err, &locked) {
+		seconds := int(locked.RetryAfter.Seconds())
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(429).JSON(fiber.Map{"error": locked.Error(), "retry_after": seconds})
+	}
+	return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+}
+
+// GetTwoFactorStatus shows the signed-in admin their own 2FA setup.
+func GetTwoFactorStatus(c *fiber.Ctx) error {
+	email, _ := c.Locals("email").(string)
+	status, err := adminsvc.GetTwoFactorStatus(email)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	mfa, _ := c.Locals("mfa").(bool)
+	return c.JSON(fiber.Map{
+		"enabled":             status.Enabled,
+		"enabled_at":          status.EnabledAt,
+		"recovery_codes_left": status.RecoveryCodesLeft,
+		"required":            status.Required,
+		"session_verified":    mfa,
+	})
+}
+
+// BeginTOTPSetup returns a new secret and the otpauth URL to show as a QR
+// code.
+func BeginTOTPSetup(c *fiber.Ctx) error {
+	email, _ := c.Locals("email").(string)
+	secret, url, err := adminsvc.BeginTOTPSetup(email)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"secret": secret, "otpauth_url": url})
+}
+
+// EnableTOTP confirms the setup with a code from the app. The recovery
+// codes in the response are only ever shown this once.
+func EnableTOTP(c *fiber.Ctx) error {
+	var input struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+	email, _ := c.Locals("email").(string)
+	codes, err := adminsvc.EnableTOTP(email, input.Code)
+	if err != nil {
+		return codeError(c, err)
+	}
+
+	// The code just proved the second factor for this session too
+	sessionID, _ := c.Locals("sessionId").(string)
+	if err := sessionsvc.MarkMFA(sessionID); err == nil {
+		_, _ = sessionsvc.ReissueOrganizer(c, sessionID)
+	}
+	return c.JSON(fiber.Map{"enabled": true, "recovery_codes": codes})
+}
+
+// VerifySession passes the second factor for the current session, e.g.
+// after signing in through the organizer login.
+func VerifySession(c *fiber.Ctx) error {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+	if input.Code == "" && input.RecoveryCode == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "code or recovery_code is required"})
+	}
+
+	email, _ := c.Locals("email").(string)
+	method, err := adminsvc.VerifySecondFactor(email, input.Code, input.RecoveryCode)
+	at := adminsvc.LoginAttempt{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent), DeviceID: c.Get("X-Device-ID")}
+	if err != nil {
+		adminsvc.RecordLoginEvent(email, "totp", "invalid_code", err.Error(), at, false)
+		return codeError(c, err)
+	}
+
+	sessionID, _ := c.Locals("sessionId").(string)
+	if err := sessionsvc.MarkMFA(sessionID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to update session"})
+	}
+	if _, err := sessionsvc.ReissueOrganizer(c, sessionID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to refresh session"})
+	}
+	adminsvc.RecordLoginEvent(email, method, "success", "session verified", at, false)
+	return c.JSON(fiber.Map{"verified": true})
+}
+
+func DisableTOTP(c *fiber.Ctx) error {
+	var input struct {
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+	email, _ := c.Locals("email").(string)
+	if err := adminsvc.DisableTOTP(email, input.Code, input.RecoveryCode); err != nil {
+		return codeError(c, err)
+	}
+	return c.JSON(fiber.Map{"enabled": false})
+}
+
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var input struct {
+		Code string `json:"code" validate:"required"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+	email, _ := c.Locals("email").(string)
+	codes, err := adminsvc.RegenerateRecoveryCodes(email, input.Code)
+	if err != nil {
+		return codeError(c, err)
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+func GetSettings(c *fiber.Ctx) error {
+	settings, err := adminsvc.GetSecuritySettings()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(settings)
+}
+
+func UpdateSettings(c *fiber.Ctx) error {
+	var input struct {
+		Require2FA *bool `json:"require_2fa" validate:"required"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+	email, _ := c.Locals("email").(string)
+	settings, err := adminsvc.UpdateSecuritySettings(*input.Require2FA, email)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(settings)
+}
+
+// ListLoginEvents returns the admin login audit trail, filtered by
+// ?email= and ?outcome=.
+func ListLoginEvents(c *fiber.Ctx) error {
+	limit, _ := strconv.ParseInt(c.Query("limit", "100"), 10, 64)
+	events, err := adminsvc.ListLoginEvents(c.Query("email"), c.Query("outcome"), limit)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(events)
+}
+
+// ResetAdminTOTP removes another admin's 2FA so they can set it up again.
+func ResetAdminTOTP(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+	actor, _ := c.Locals("email").(string)
+	if err := adminsvc.ResetTOTP(input.Email, actor); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "two-factor authentication reset"})
+}
+
+func UnlockAdmin(c *fiber.Ctx) error {
+	var input struct {
+		Email string `json:"email" validate:"required,email"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+	if err := adminsvc.Unlock(input.Email); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "admin unlocked"})
+}
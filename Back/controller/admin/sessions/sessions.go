@@ -0,0 +1,45 @@
+package adminsessions
+
+import (
+	"fmt"
+
+	sessionsvc "ticpin-backend/services/session"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func listSessions(c *fiber.Ctx, kind string) error {
+	list, err := sessionsvc.List(kind, c.Params("id"), "")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// forceLogout ends every session of a user or organizer. Their next request
+// is refused on every instance within half a minute.
+func forceLogout(c *fiber.Ctx, kind string) error {
+	admin, _ := c.Locals("email").(string)
+	n, err := sessionsvc.RevokeAll(kind, c.Params("id"), "", "admin_logout")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	fmt.Printf("DEBUG: Admin %s logged out %s %s (%d sessions)\n", admin, kind, c.Params("id"), n)
+	return c.JSON(fiber.Map{"message": "sessions revoked", "sessions_revoked": n})
+}
+
+func ListUserSessions(c *fiber.Ctx) error {
+	return listSessions(c, sessionsvc.KindUser)
+}
+
+func ForceLogoutUser(c *fiber.Ctx) error {
+	return forceLogout(c, sessionsvc.KindUser)
+}
+
+func ListOrganizerSessions(c *fiber.Ctx) error {
+	return listSessions(c, sessionsvc.KindOrganizer)
+}
+
+func ForceLogoutOrganizer(c *fiber.Ctx) error {
+	return forceLogout(c, sessionsvc.KindOrganizer)
+}
@@ -0,0 +1,51 @@
+package admintrash
+
+import (
+	"errors"
+	"strconv"
+
+	trashsvc "ticpin-backend/services/trash"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListDeleted lists soft deleted records of one kind with the date each
+// will be purged.
+func ListDeleted(kind string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		page, _ := strconv.ParseInt(c.Query("page", "1"), 10, 64)
+		limit, _ := strconv.ParseInt(c.Query("limit", "20"), 10, 64)
+		if page < 1 {
+			page = 1
+		}
+		if limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		items, total, err := trashsvc.ListDeleted(kind, page, limit)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{
+			"items":      items,
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + limit - 1) / limit,
+		})
+	}
+}
+
+// Restore brings back a soft deleted record. Restoring an organizer also
+// restores the listings deleted along with them.
+func Restore(kind string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := trashsvc.Restore(kind, c.Params("id")); err != nil {
+			if errors.Is(err, trashsvc.ErrOrganizerDeleted) {
+				return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{"message": "restored"})
+	}
+}
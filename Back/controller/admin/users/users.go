@@ -6,6 +6,8 @@ import (
 
 	"ticpin-backend/config"
 	"ticpin-backend/models"
+	sessionsvc "ticpin-backend/services/session"
+	trashsvc "ticpin-backend/services/trash"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
@@ -59,11 +61,15 @@ func DeleteUser(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid user id"})
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if _, err := config.GetDB().Collection("users").DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+	actor, _ := c.Locals("email").(string)
+	err = trashsvc.SoftDelete(trashsvc.KindUsers, id.Hex(), actor)
+	if err == trashsvc.ErrNotFound {
+		return c.Status(404).JSON(fiber.Map{"error": "user not found"})
+	}
+	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	_, _ = sessionsvc.RevokeAll(sessionsvc.KindUser, id.Hex(), "", "account_deleted")
 	return c.JSON(fiber.Map{"message": "user deleted"})
 }
 
@@ -0,0 +1,63 @@
+package adminwallet
+
+import (
+	"strconv"
+	"time"
+
+	walletsvc "ticpin-backend/services/wallet"
+	"ticpin-backend/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetUserWallet(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	statement, err := walletsvc.GetStatement(c.Params("id"), page, limit)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(statement)
+}
+
+// AdjustUserWallet credits or debits a user's wallet by hand. The reason is
+// kept on the ledger entry along with the admin who made the change.
+func AdjustUserWallet(c *fiber.Ctx) error {
+	var input struct {
+		Type          string  `json:"type" validate:"required,oneof=credit debit"`
+		Amount        float64 `json:"amount" validate:"required,gt=0"`
+		Reason        string  `json:"reason" validate:"required"`
+		ExpiresInDays int     `json:"expires_in_days"`
+	}
+	if err := utils.ParseAndValidate(c, &input); err != nil {
+		return err
+	}
+
+	adminEmail, _ := c.Locals("email").(string)
+	ref := walletsvc.Ref{CreatedBy: adminEmail}
+	userID := c.Params("id")
+
+	var err error
+	if input.Type == "credit" {
+		var expiresAt *time.Time
+		if input.ExpiresInDays > 0 {
+			t := time.Now().AddDate(0, 0, input.ExpiresInDays)
+			expiresAt = &t
+		}
+		_, err = walletsvc.Credit(userID, input.Amount, "admin", input.Reason, ref, expiresAt)
+	} else {
+		_, err = walletsvc.Debit(userID, input.Amount, "admin", input.Reason, ref)
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	balance, _ := walletsvc.GetBalance(userID)
+	return c.JSON(fiber.Map{"message": "wallet updated", "balance": balance})
+}
@@ -9,6 +9,7 @@ import (
 	couponsvc "ticpin-backend/services/coupon"
 	offersvc "ticpin-backend/services/offer"
 	passsvc "ticpin-backend/services/pass"
+	referralsvc "ticpin-backend/services/referral"
 	"ticpin-backend/utils"
 	"time"
 
@@ -43,12 +44,21 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 		PaymentGateway string  `json:"payment_gateway" validate:"required"`
 		Status         string  `json:"status"`
 		UseTicpass     bool    `json:"use_ticpass"`
+		WalletAmount   float64 `json:"wallet_amount"`
+		PaymentMethod  string  `json:"payment_method"` // "card", "upi", ... for payment-specific offers
+		CardBIN        string  `json:"card_bin"`
 	}
 
 	if err := utils.ParseAndValidate(c, &req); err != nil {
 		return err
 	}
 
+	// Wallet, offers and per-user rules apply to the signed-in user only
+	userID, _ := c.Locals("userId").(string)
+	if req.UserID != "" && req.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "user_id does not match the signed-in user"})
+	}
+
 	fmt.Printf("DEBUG: CreateDiningBooking - DiningID: %s, User: %s, PaymentID: %s\n",
 		req.DiningID, req.UserEmail, req.PaymentID)
 
@@ -87,6 +97,12 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 					},
 				}
 				_, _ = config.DiningBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, update)
+				if !settleWallet(ctx, config.DiningBookingsCol, existing.ID, req.PaymentID, existing.GrandTotal-existing.WalletAmount) {
+					return c.Status(409).JSON(fiber.Map{"error": "wallet balance used for this booking is no longer available, your payment will be refunded"})
+				}
+				_ = couponsvc.Commit(existing.ID.Hex())
+				_ = offersvc.Commit(existing.ID.Hex())
+				go referralsvc.CheckQualification(existing.UserID)
 				return c.Status(200).JSON(fiber.Map{
 					"message":         "dining booking confirmed",
 					"booking_id":      existing.BookingID,
@@ -151,7 +167,7 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 	defer cancel()
 
 	var dining models.Dining
-	if err := config.DiningsCol.FindOne(ctx, bson.M{"_id": diningObjID}).Decode(&dining); err != nil {
+	if err := config.DiningsCol.FindOne(ctx, bson.M{"_id": diningObjID, "deleted_at": bson.M{"$exists": false}}).Decode(&dining); err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "dining venue not found"})
 	}
 
@@ -172,28 +188,22 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid booking fee"})
 	}
 
-	var discountAmount float64
-	var appliedCouponCode string
-	var couponIDToIncrement primitive.ObjectID
-	var couponMaxUses int
-	if req.CouponCode != "" {
-		result, err := couponsvc.Validate(req.CouponCode, "dining", req.OrderAmount, req.UserID, req.UserEmail)
-		if err == nil {
-			discountAmount = result.DiscountAmount
-			appliedCouponCode = result.Coupon.Code
-			couponIDToIncrement = result.Coupon.ID
-			couponMaxUses = result.Coupon.MaxUses
-		}
-	}
-
-	var offerObjID primitive.ObjectID
-	if req.OfferID != "" {
-		offerResult, err := offersvc.ValidateOffer(req.OfferID, req.DiningID, req.OrderAmount)
-		if err == nil {
-			offerObjID = offerResult.Offer.ID
-			discountAmount += offerResult.DiscountAmount
-		}
-	}
+	discounts := bookingsvc.ApplyDiscounts(bookingsvc.Checkout{
+		Category:      "dining",
+		EntityID:      req.DiningID,
+		OrganizerID:   dining.OrganizerID,
+		City:          dining.City,
+		OrderAmount:   req.OrderAmount,
+		UserID:        userID,
+		UserEmail:     req.UserEmail,
+		OrderID:       req.OrderID,
+		CouponCode:    req.CouponCode,
+		OfferID:       req.OfferID,
+		PaymentMethod: req.PaymentMethod,
+		CardBIN:       req.CardBIN,
+		UseTicpass:    req.UseTicpass,
+	})
+	discountAmount := discounts.Amount
 
 	// Cap total discount to order subtotal
 	if discountAmount > req.OrderAmount {
@@ -207,29 +217,40 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 
 	// Check if user wants to use Ticpass dining benefits
 	var ticpassApplied bool
-	if req.UseTicpass && req.UserID != "" {
-		pass, err := passsvc.GetActiveByUserID(req.UserID)
-		if err == nil && pass != nil {
+	var voucherEntryID primitive.ObjectID
+	if req.UseTicpass && userID != "" {
+		pass, err := passsvc.GetActiveByUserID(userID)
+		if err == nil && pass != nil && passsvc.Covers(pass, "dining", dining.City) {
 			if pass.Benefits.DiningVouchers.Remaining > 0 {
-				// Dining Voucher Benefit: Deduct voucher value (e.g. 250)
-				voucherVal := float64(pass.Benefits.DiningVouchers.ValueEach)
-				if voucherVal <= 0 {
-					voucherVal = 250
-				} // Fallback
-
-				discountAmount += voucherVal
-				ticpassApplied = true
-
-				// Decrement the benefit usage in DB
-				_, err = passsvc.UseDiningVoucher(pass.ID.Hex())
+				// Redeem the voucher up front so two bookings cannot share it;
+				// the ledger entry is linked to the booking once it exists
+				_, entry, err := passsvc.Redeem(pass.ID.Hex(), passsvc.BenefitDining, "dining", "")
 				if err != nil {
-					fmt.Printf("ERROR: Failed to decrement Ticpass dining benefit: %v\n", err)
+					fmt.Printf("ERROR: Failed to redeem Ticpass dining benefit: %v\n", err)
 				} else {
-					fmt.Printf("DEBUG: Used 1 Ticpass dining voucher (₹%.2f) for user %s.\n", voucherVal, req.UserID)
+					// Dining Voucher Benefit: Deduct voucher value (e.g. 250)
+					voucherVal := entry.Value
+					if voucherVal <= 0 {
+						voucherVal = 250
+					} // Fallback
+
+					discountAmount += voucherVal
+					ticpassApplied = true
+					voucherEntryID = entry.ID
+					fmt.Printf("DEBUG: Used 1 Ticpass dining voucher (₹%.2f) for user %s.\n", voucherVal, userID)
 				}
 			}
 		}
 	}
+	releaseVoucher := func() {
+		discounts.Release("booking failed")
+		if voucherEntryID.IsZero() {
+			return
+		}
+		if _, err := passsvc.ReverseRedemption(voucherEntryID, "booking failed"); err != nil {
+			fmt.Printf("ERROR: Failed to release Ticpass dining voucher: %v\n", err)
+		}
+	}
 
 	// Re-calculate grand total with Ticpass
 	grandTotal = (req.OrderAmount + req.BookingFee) - discountAmount
@@ -237,11 +258,17 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 		grandTotal = 0
 	}
 
+	// Part of the total may be paid from the wallet; the gateway charges the rest
+	if err := discounts.HoldWallet(req.WalletAmount, grandTotal); err != nil {
+		releaseVoucher()
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	booking := &models.DiningBooking{
 		UserEmail:      req.UserEmail,
 		UserName:       req.UserName,
 		UserPhone:      req.UserPhone,
-		UserID:         req.UserID,
+		UserID:         userID,
 		Address:        req.Address,
 		City:           req.City,
 		State:          req.State,
@@ -255,14 +282,20 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 		OrderAmount:    req.OrderAmount,
 		BookingFee:     req.BookingFee,
 		DiscountAmount: discountAmount,
-		CouponCode:     appliedCouponCode,
-		OfferID:        offerObjID,
+		CouponCode:     discounts.CouponCode,
+		OfferID:        discounts.OfferID,
 		GrandTotal:     grandTotal,
+		WalletAmount:   discounts.WalletAmount,
+		OrderID:        req.OrderID,
 		PaymentID:      req.PaymentID,
 		PaymentGateway: req.PaymentGateway,
 		Status:         "booked",
 		TicpassApplied: ticpassApplied,
 	}
+	if req.Status != "" {
+		booking.Status = req.Status
+	}
+	booked := booking.Status == "booked" || booking.Status == "confirmed"
 
 	booking.ID = primitive.NewObjectID()
 	booking.BookingID = utils.HashObjectID(booking.ID)
@@ -280,6 +313,7 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 	// Try to insert lock - if fails, slot is already being booked by someone else
 	_, err = config.SlotLocksCol.InsertOne(ctx, lockDoc)
 	if err != nil {
+		releaseVoucher()
 		if mongo.IsDuplicateKeyError(err) {
 			return c.Status(400).JSON(fiber.Map{
 				"error": "This time slot was just booked by someone else. Please select a different time.",
@@ -291,13 +325,21 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 	if err := bookingsvc.CreateDining(booking); err != nil {
 		// Clean up the lock if booking fails
 		_, _ = config.SlotLocksCol.DeleteOne(ctx, bson.M{"booking_id": booking.ID})
+		releaseVoucher()
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	bookingIDStr := booking.ID.Hex()
 
-	if !couponIDToIncrement.IsZero() {
-		_ = couponsvc.IncrementUsage(couponIDToIncrement, couponMaxUses, req.UserID, req.UserEmail, bookingIDStr, grandTotal)
+	if !voucherEntryID.IsZero() {
+		if err := passsvc.LinkRedemption(voucherEntryID, bookingIDStr); err != nil {
+			fmt.Printf("ERROR: Failed to link Ticpass voucher to booking %s: %v\n", booking.BookingID, err)
+		}
+	}
+
+	discounts.Attach(bookingIDStr, booked)
+	if booked {
+		go referralsvc.CheckQualification(userID)
 	}
 
 	return c.Status(201).JSON(fiber.Map{
@@ -305,7 +347,9 @@ func CreateDiningBooking(c *fiber.Ctx) error {
 		"booking_id":      booking.BookingID,
 		"id":              booking.ID.Hex(),
 		"grand_total":     grandTotal,
+		"wallet_amount":   discounts.WalletAmount,
 		"discount_amount": discountAmount,
-		"status":          "booked",
+		"offer_id":        offerIDString(discounts.OfferID),
+		"status":          booking.Status,
 	})
 }
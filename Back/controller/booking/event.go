@@ -2,13 +2,17 @@ package bookingctrl
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"ticpin-backend/config"
 	"ticpin-backend/models"
 	bookingsvc "ticpin-backend/services/booking"
 	couponsvc "ticpin-backend/services/coupon"
+	eventsvc "ticpin-backend/services/event"
 	offersvc "ticpin-backend/services/offer"
 	passsvc "ticpin-backend/services/pass"
+	referralsvc "ticpin-backend/services/referral"
+	walletsvc "ticpin-backend/services/wallet"
 	"time"
 
 	"ticpin-backend/worker"
@@ -29,8 +33,11 @@ func CreateEventBooking(c *fiber.Ctx) error {
 		Pincode        string                 `json:"pincode"`
 		Nationality    string                 `json:"nationality"`
 		EventID        string                 `json:"event_id"`
+		ShowtimeID     string                 `json:"showtime_id"`
 		EventName      string                 `json:"event_name"`
 		Tickets        []models.BookingTicket `json:"tickets"`
+		SeatIDs        []string               `json:"seat_ids"`
+		HolderNames    []string               `json:"holder_names"`
 		OrderAmount    float64                `json:"order_amount"`
 		BookingFee     float64                `json:"booking_fee"`
 		CouponCode     string                 `json:"coupon_code"`
@@ -41,12 +48,21 @@ func CreateEventBooking(c *fiber.Ctx) error {
 		Status         string                 `json:"status"`
 		PaymentGateway string                 `json:"payment_gateway"`
 		UseTicpass     bool                   `json:"use_ticpass"` // New field for Ticpass discount
+		WalletAmount   float64                `json:"wallet_amount"`
+		PaymentMethod  string                 `json:"payment_method"` // "card", "upi", ... for payment-specific offers
+		CardBIN        string                 `json:"card_bin"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid request: " + err.Error()})
 	}
 
+	// Wallet, offers and per-user rules apply to the signed-in user only
+	userID, _ := c.Locals("userId").(string)
+	if req.UserID != "" && req.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "user_id does not match the signed-in user"})
+	}
+
 	fmt.Printf("DEBUG: CreateEventBooking - EventID: %s, OrderAmount: %.2f, PaymentGateway: %s\n",
 		req.EventID, req.OrderAmount, req.PaymentGateway)
 
@@ -78,6 +94,23 @@ func CreateEventBooking(c *fiber.Ctx) error {
 
 			// 2. If it exists as "pending" and we are now confirming it (status "booked" or empty)
 			if existing.Status == "pending" && (req.Status == "booked" || req.Status == "") {
+				// The event may have been cancelled, or the seats lost,
+				// while the payment was on its way; the payment webhook
+				// refunds what was paid
+				if err := bookingsvc.CheckConfirmable(ctx, &existing); err != nil {
+					if !bookingsvc.ConfirmRefused(err) {
+						return c.Status(500).JSON(fiber.Map{"error": "failed to confirm booking"})
+					}
+					_, _ = config.EventBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID, "status": "pending"}, bson.M{"$set": bson.M{
+						"status":        "cancelled",
+						"cancelled_at":  time.Now(),
+						"cancel_reason": err.Error(),
+					}})
+					_ = couponsvc.Release(existing.ID.Hex(), "booking_cancelled")
+					_ = walletsvc.Release(existing.ID.Hex(), "booking_cancelled")
+					_ = offersvc.Release(existing.ID.Hex(), "booking_cancelled")
+					return c.Status(409).JSON(fiber.Map{"error": err.Error() + ", any payment made will be refunded"})
+				}
 				update := bson.M{
 					"$set": bson.M{
 						"status":     "booked",
@@ -85,7 +118,32 @@ func CreateEventBooking(c *fiber.Ctx) error {
 						"booked_at":  time.Now(),
 					},
 				}
-				_, _ = config.EventBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, update)
+				res, err := config.EventBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID, "status": "pending"}, update)
+				if err != nil {
+					return c.Status(500).JSON(fiber.Map{"error": "failed to confirm booking"})
+				}
+				if res.MatchedCount == 0 {
+					// The payment webhook got there first, or the booking
+					// was cancelled meanwhile
+					var current models.Booking
+					if err := config.EventBookingsCol.FindOne(ctx, bson.M{"_id": existing.ID}).Decode(&current); err == nil && (current.Status == "booked" || current.Status == "confirmed") {
+						return c.Status(200).JSON(fiber.Map{
+							"message":         "booking already confirmed",
+							"booking_id":      current.BookingID,
+							"id":              current.ID.Hex(),
+							"grand_total":     current.GrandTotal,
+							"discount_amount": current.DiscountAmount,
+							"status":          current.Status,
+						})
+					}
+					return c.Status(409).JSON(fiber.Map{"error": "this booking can no longer be confirmed, any payment made will be refunded"})
+				}
+				if !settleWallet(ctx, config.EventBookingsCol, existing.ID, req.PaymentID, existing.GrandTotal-existing.WalletAmount) {
+					return c.Status(409).JSON(fiber.Map{"error": "wallet balance used for this booking is no longer available, your payment will be refunded"})
+				}
+				_ = couponsvc.Commit(existing.ID.Hex())
+				_ = offersvc.Commit(existing.ID.Hex())
+				go referralsvc.CheckQualification(existing.UserID)
 				return c.Status(200).JSON(fiber.Map{
 					"message":         "booking confirmed",
 					"booking_id":      existing.BookingID,
@@ -117,6 +175,9 @@ func CreateEventBooking(c *fiber.Ctx) error {
 					},
 				}
 				_, _ = config.EventBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, update)
+				_ = couponsvc.Release(existing.ID.Hex(), req.Status)
+				_ = walletsvc.Release(existing.ID.Hex(), req.Status)
+				_ = offersvc.Release(existing.ID.Hex(), req.Status)
 
 				return c.Status(200).JSON(fiber.Map{
 					"message": "event booking cancelled",
@@ -136,7 +197,7 @@ func CreateEventBooking(c *fiber.Ctx) error {
 	if req.EventID == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "event_id is required"})
 	}
-	if len(req.Tickets) == 0 {
+	if len(req.Tickets) == 0 && len(req.SeatIDs) == 0 {
 		return c.Status(400).JSON(fiber.Map{"error": "at least one ticket is required"})
 	}
 
@@ -150,16 +211,76 @@ func CreateEventBooking(c *fiber.Ctx) error {
 	defer cancel()
 
 	var event models.Event
-	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": eventObjID}).Decode(&event); err != nil {
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": eventObjID, "deleted_at": bson.M{"$exists": false}}).Decode(&event); err != nil {
 		return c.Status(404).JSON(fiber.Map{"error": "event not found"})
 	}
 
-	// Calculate current total tickets booked for this event
+	var showtimeObjID primitive.ObjectID
+	if req.ShowtimeID != "" {
+		if showtimeObjID, err = primitive.ObjectIDFromHex(req.ShowtimeID); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid showtime_id"})
+		}
+	}
+	_, ticketCategories, err := bookingsvc.ResolveShowtime(&event, showtimeObjID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Reserved seating: tickets are derived from the selected seats
+	if event.SeatMap != nil {
+		seatTickets, err := bookingsvc.ResolveSeatTickets(event.SeatMap, ticketCategories, req.SeatIDs)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		req.Tickets = seatTickets
+	} else {
+		req.SeatIDs = nil
+	}
+
+	// Merge repeated categories so limits and tiers see the whole order
+	merged := make([]models.BookingTicket, 0, len(req.Tickets))
+	lineIndex := map[string]int{}
+	for _, t := range req.Tickets {
+		if t.Quantity <= 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "ticket quantity must be positive"})
+		}
+		if i, ok := lineIndex[t.Category]; ok {
+			merged[i].Quantity += t.Quantity
+			continue
+		}
+		lineIndex[t.Category] = len(merged)
+		merged = append(merged, t)
+	}
+	req.Tickets = merged
+
+	// Anti-scalping: named tickets and per-buyer limits across all orders
+	buyerID, buyerPhone := userID, req.UserPhone
+	if phone, _ := c.Locals("phone").(string); phone != "" {
+		buyerPhone = phone
+	}
+	if event.PurchaseRules != nil {
+		holderNames, err := bookingsvc.ValidateHolderNames(event.PurchaseRules, req.Tickets, req.HolderNames)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		req.HolderNames = holderNames
+
+		if err := bookingsvc.CheckPurchaseLimits(ctx, &event, buyerID, buyerPhone, req.UserEmail, req.Tickets); err != nil {
+			if errors.Is(err, bookingsvc.ErrPurchaseLimit) {
+				return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": "failed to check purchase limits"})
+		}
+	}
+
+	// Calculate current total tickets held for this event, counting pending
+	// bookings that are still being paid for
+	availabilityMatch := bson.M{"event_id": eventObjID}
+	if !showtimeObjID.IsZero() {
+		availabilityMatch["showtime_id"] = showtimeObjID
+	}
 	pipeline := []bson.M{
-		{"$match": bson.M{
-			"event_id": eventObjID,
-			"status":   bson.M{"$in": []string{"booked", "confirmed"}},
-		}},
+		{"$match": bson.M{"$and": []bson.M{availabilityMatch, bookingsvc.HoldingTickets(time.Now())}}},
 		{"$unwind": "$tickets"},
 		{"$group": bson.M{
 			"_id":   "$tickets.category",
@@ -181,15 +302,20 @@ func CreateEventBooking(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to parse availability"})
 	}
 
-	// Check capacity for each ticket category being booked
+	// Check capacity, sale window and order limits for each category being booked
+	now := time.Now()
+	soldByCategory := map[string]int{}
 	for _, requestedTicket := range req.Tickets {
 		var categoryCapacity int
 		var currentBooked int
 
 		// Find capacity for this ticket category
-		for _, tc := range event.TicketCategories {
+		for _, tc := range ticketCategories {
 			if tc.Name == requestedTicket.Category {
 				categoryCapacity = tc.Capacity
+				if err := bookingsvc.CheckCategoryOnSale(tc, requestedTicket.Quantity, now); err != nil {
+					return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+				}
 				break
 			}
 		}
@@ -202,6 +328,8 @@ func CreateEventBooking(c *fiber.Ctx) error {
 			}
 		}
 
+		soldByCategory[requestedTicket.Category] = currentBooked
+
 		if currentBooked+requestedTicket.Quantity > categoryCapacity {
 			fmt.Printf("DEBUG: Capacity exceeded for category %s. Current: %d, Requested: %d, Capacity: %d\n",
 				requestedTicket.Category, currentBooked, requestedTicket.Quantity, categoryCapacity)
@@ -214,13 +342,19 @@ func CreateEventBooking(c *fiber.Ctx) error {
 
 	fmt.Printf("DEBUG: Capacity check passed for EventID: %s\n", req.EventID)
 
-	// 2. Verify subtotal (OrderAmount) against database prices
+	// 2. Verify subtotal (OrderAmount) against the currently active price tiers
 	var expectedSubtotal float64
+	var pricedTickets []models.BookingTicket
 	for _, reqTicket := range req.Tickets {
 		found := false
-		for _, tc := range event.TicketCategories {
+		for _, tc := range ticketCategories {
 			if tc.Name == reqTicket.Category {
-				expectedSubtotal += tc.Price * float64(reqTicket.Quantity)
+				lines, total, err := bookingsvc.PriceTickets(tc, soldByCategory[tc.Name], reqTicket.Quantity, now)
+				if err != nil {
+					return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+				}
+				pricedTickets = append(pricedTickets, lines...)
+				expectedSubtotal += total
 				found = true
 				break
 			}
@@ -229,6 +363,7 @@ func CreateEventBooking(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"error": "invalid ticket category: " + reqTicket.Category})
 		}
 	}
+	req.Tickets = pricedTickets
 
 	// Compare with tolerance for floating point
 	if req.OrderAmount < expectedSubtotal-1 || req.OrderAmount > expectedSubtotal+1 {
@@ -243,47 +378,35 @@ func CreateEventBooking(c *fiber.Ctx) error {
 		req.BookingFee = expectedFee // Force correct fee
 	}
 
-	var discountAmount float64
-	var appliedCouponCode string
-	var couponIDToIncrement primitive.ObjectID
-	var couponMaxUses int
-	if req.CouponCode != "" {
-		result, err := couponsvc.Validate(req.CouponCode, "event", req.OrderAmount, req.UserID, req.UserEmail)
-		if err == nil {
-			fmt.Printf("DEBUG: Coupon validation successful - Code: %s, Discount: %.2f\n",
-				result.Coupon.Code, result.DiscountAmount)
-			discountAmount = result.DiscountAmount
-			appliedCouponCode = result.Coupon.Code
-			couponIDToIncrement = result.Coupon.ID
-			couponMaxUses = result.Coupon.MaxUses
-		} else {
-			fmt.Printf("DEBUG: Coupon validation failed - %s\n", err.Error())
-		}
-	}
-
-	var offerObjID primitive.ObjectID
-	if req.OfferID != "" {
-		offerResult, err := offersvc.ValidateOffer(req.OfferID, req.EventID, req.OrderAmount)
-		if err != nil {
-			fmt.Printf("DEBUG: Offer validation failed - %s\n", err.Error())
-		} else {
-			offerObjID = offerResult.Offer.ID
-			discountAmount += offerResult.DiscountAmount
-		}
-	}
+	discounts := bookingsvc.ApplyDiscounts(bookingsvc.Checkout{
+		Category:      "event",
+		EntityID:      req.EventID,
+		OrganizerID:   event.OrganizerID,
+		City:          event.City,
+		OrderAmount:   req.OrderAmount,
+		UserID:        userID,
+		UserEmail:     req.UserEmail,
+		OrderID:       req.OrderID,
+		CouponCode:    req.CouponCode,
+		OfferID:       req.OfferID,
+		PaymentMethod: req.PaymentMethod,
+		CardBIN:       req.CardBIN,
+		UseTicpass:    req.UseTicpass,
+	})
+	discountAmount := discounts.Amount
 
 	// Check if user wants to use Ticpass benefits
 	var ticpassApplied bool
-	if req.UseTicpass && req.UserID != "" {
-		pass, err := passsvc.GetActiveByUserID(req.UserID)
-		if err == nil && pass != nil && pass.Benefits.EventsDiscountActive {
-			// Apply 10% discount for Ticpass holders
-			ticpassDiscount := req.OrderAmount * 0.10 // 10% discount
+	if req.UseTicpass && userID != "" {
+		pass, err := passsvc.GetActiveByUserID(userID)
+		if err == nil && pass != nil && pass.Benefits.EventsDiscountActive && passsvc.Covers(pass, "events", event.City) {
+			// Discount percentage and cap come from the pass's plan
+			ticpassDiscount := passsvc.EventDiscount(pass, req.OrderAmount)
 			discountAmount += ticpassDiscount
 			ticpassApplied = true
-			fmt.Printf("DEBUG: Applied Ticpass discount: %.2f for user %s\n", ticpassDiscount, req.UserID)
+			fmt.Printf("DEBUG: Applied Ticpass discount: %.2f for user %s\n", ticpassDiscount, userID)
 		} else {
-			fmt.Printf("DEBUG: No active Ticpass found for user %s\n", req.UserID)
+			fmt.Printf("DEBUG: No active Ticpass found for user %s\n", userID)
 		}
 	}
 
@@ -303,26 +426,36 @@ func CreateEventBooking(c *fiber.Ctx) error {
 	fmt.Printf("DEBUG: Final calculation - OrderAmount: %.2f, BookingFee: %.2f, DiscountAmount: %.2f, GrandTotal: %.2f\n",
 		req.OrderAmount, req.BookingFee, discountAmount, grandTotal)
 
+	// Part of the total may be paid from the wallet; the gateway charges the rest
+	if err := discounts.HoldWallet(req.WalletAmount, grandTotal); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	booking := &models.Booking{
 		UserEmail:      req.UserEmail,
 		UserName:       req.UserName,
 		UserPhone:      req.UserPhone,
-		UserID:         req.UserID,
+		UserID:         userID,
 		Address:        req.Address,
 		City:           req.City,
 		State:          req.State,
 		Pincode:        req.Pincode,
 		Nationality:    req.Nationality,
 		EventID:        eventObjID,
+		ShowtimeID:     showtimeObjID,
 		EventName:      req.EventName,
 		Tickets:        req.Tickets,
+		SeatIDs:        req.SeatIDs,
+		LockKey:        userID,
+		HolderNames:    req.HolderNames,
 		OrderAmount:    req.OrderAmount,
 		BookingFee:     req.BookingFee,
 		DiscountAmount: discountAmount,
-		CouponCode:     appliedCouponCode,
-		OfferID:        offerObjID,
+		CouponCode:     discounts.CouponCode,
+		OfferID:        discounts.OfferID,
 		OrderID:        req.OrderID, // Added OrderID support
 		GrandTotal:     grandTotal,
+		WalletAmount:   discounts.WalletAmount,
 		PaymentID:      req.PaymentID,
 		PaymentGateway: req.PaymentGateway,
 		Status:         "booked",
@@ -333,19 +466,33 @@ func CreateEventBooking(c *fiber.Ctx) error {
 		booking.Status = req.Status
 	}
 
-	if err := bookingsvc.Create(booking); err != nil {
+	err = bookingsvc.Create(booking)
+	if err == nil && event.PurchaseRules != nil {
+		err = bookingsvc.RecheckPurchaseLimits(ctx, &event, booking, buyerID, buyerPhone)
+	}
+	if err != nil {
+		discounts.Release("booking failed")
+		if errors.Is(err, bookingsvc.ErrPurchaseLimit) {
+			return c.Status(429).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	bookingID := booking.ID.Hex()
 
-	if !couponIDToIncrement.IsZero() {
-		_ = couponsvc.IncrementUsage(couponIDToIncrement, couponMaxUses, req.UserID, req.UserEmail, bookingID, grandTotal)
+	discounts.Attach(bookingID, booking.Status == "booked" || booking.Status == "confirmed")
+	if booking.Status == "booked" || booking.Status == "confirmed" {
+		go referralsvc.CheckQualification(userID)
 	}
 
 	bookingEventObjID := eventObjID
 	bookingUserEmail := req.UserEmail
 	bookingGrandTotal := grandTotal
+	worker.Submit(func() {
+		if err := eventsvc.RefreshPriceStartsFrom(bookingEventObjID); err != nil {
+			fmt.Printf("DEBUG: Failed to refresh price for event %s: %v\n", bookingEventObjID.Hex(), err)
+		}
+	})
 	worker.Submit(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -367,7 +514,9 @@ func CreateEventBooking(c *fiber.Ctx) error {
 		"booking_id":      booking.BookingID,
 		"id":              booking.ID.Hex(),
 		"grand_total":     grandTotal,
+		"wallet_amount":   discounts.WalletAmount,
 		"discount_amount": discountAmount,
+		"offer_id":        offerIDString(discounts.OfferID),
 		"status":          "booked",
 		"ticpass_applied": ticpassApplied,
 	})
@@ -375,9 +524,25 @@ func CreateEventBooking(c *fiber.Ctx) error {
 
 func GetEventAvailability(c *fiber.Ctx) error {
 	eventID := c.Params("id")
-	availability, err := bookingsvc.GetAvailability(eventID)
+	showtimeID := c.Query("showtime_id")
+	availability, err := bookingsvc.GetAvailability(eventID, showtimeID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(fiber.Map{"booked": availability})
+	seats, err := bookingsvc.GetSeatStatus(eventID, showtimeID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	resp := fiber.Map{"booked": availability}
+	if seats != nil {
+		resp["seats"] = seats
+	}
+	return c.JSON(resp)
+}
+
+func offerIDString(id primitive.ObjectID) string {
+	if id.IsZero() {
+		return ""
+	}
+	return id.Hex()
 }
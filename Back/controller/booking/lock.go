@@ -16,6 +16,9 @@ func CreateSlotLock(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body", "details": err.Error()})
 	}
 
+	// Locks are keyed by the signed-in user, not a key the client picks
+	req.LockKey, _ = c.Locals("userId").(string)
+
 	// Basic validation
 	if req.LockKey == "" || req.Type == "" || req.ReferenceID == "" || req.Date == "" || req.Slot == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Missing required fields for lock"})
@@ -29,6 +32,12 @@ func CreateSlotLock(c *fiber.Ctx) error {
 				"message": "This slot is currently locked by another user.",
 			})
 		}
+		if errors.Is(err, bookingService.ErrSeatUnavailable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "Seat Unavailable",
+				"message": "This seat has already been booked.",
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create slot lock",
 			"details": err.Error(),
@@ -47,6 +56,7 @@ func UnlockSlot(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
 	}
+	req.LockKey, _ = c.Locals("userId").(string)
 
 	if err := bookingService.UnlockSlot(c.Context(), req); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to remove slot lock"})
@@ -55,13 +65,13 @@ func UnlockSlot(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"success": true})
 }
 
-// GetUserActiveLocks retrieves locks assigned to the current LockKey
+// GetUserActiveLocks retrieves the signed-in user's locks
 func GetUserActiveLocks(c *fiber.Ctx) error {
-	lockKey := c.Query("lock_key")
+	lockKey, _ := c.Locals("userId").(string)
 	lockType := c.Query("type")
 
-	if lockKey == "" || lockType == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "lock_key and type query parameters are required"})
+	if lockType == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "type query parameter is required"})
 	}
 
 	locks, err := bookingService.GetUserActiveLocks(c.Context(), lockKey, lockType)
@@ -11,12 +11,15 @@ import (
 	offersvc "ticpin-backend/services/offer"
 	passsvc "ticpin-backend/services/pass"
 	playservice "ticpin-backend/services/play"
+	referralsvc "ticpin-backend/services/referral"
+	walletsvc "ticpin-backend/services/wallet"
 	"ticpin-backend/utils"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func CreatePlayBooking(c *fiber.Ctx) error {
@@ -45,13 +48,21 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 		PaymentGateway string                 `json:"payment_gateway" validate:"required"`
 		Status         string                 `json:"status"`
 		UseTicpass     bool                   `json:"use_ticpass"`
-		LockKey        string                 `json:"lock_key" validate:"omitempty"`
+		WalletAmount   float64                `json:"wallet_amount"`
+		PaymentMethod  string                 `json:"payment_method"` // "card", "upi", ... for payment-specific offers
+		CardBIN        string                 `json:"card_bin"`
 	}
 
 	if err := utils.ParseAndValidate(c, &req); err != nil {
 		return err
 	}
 
+	// Wallet, offers and per-user rules apply to the signed-in user only
+	userID, _ := c.Locals("userId").(string)
+	if req.UserID != "" && req.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "user_id does not match the signed-in user"})
+	}
+
 	fmt.Printf("DEBUG: CreatePlayBooking - PlayID: %s, User: %s, PaymentID: %s\n",
 		req.PlayID, req.UserEmail, req.PaymentID)
 
@@ -88,15 +99,15 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 			// 2. If it exists as "pending" and we are now confirming it (status "booked" or empty)
 			if existing.Status == "pending" && (req.Status == "booked" || req.Status == "") {
 				// Check if Ticpass should be applied and decremented for this pending booking confirmation
-				if req.UseTicpass && req.UserID != "" && !existing.TicpassApplied {
-					pass, err := passsvc.GetActiveByUserID(req.UserID)
+				if req.UseTicpass && userID != "" && !existing.TicpassApplied {
+					pass, err := passsvc.GetActiveByUserID(userID)
 					if err == nil && pass != nil && pass.Benefits.TurfBookings.Remaining > 0 {
 						// Decrement Ticpass for this pending booking confirmation
-						_, err = passsvc.UseTurfBooking(pass.ID.Hex())
+						_, err = passsvc.UseTurfBooking(pass.ID.Hex(), existing.ID.Hex())
 						if err != nil {
 							fmt.Printf("ERROR: Failed to decrement Ticpass for pending booking confirmation: %v\n", err)
 						} else {
-							fmt.Printf("DEBUG: Used 1 Ticpass turf benefit for user %s on pending booking confirmation. Booking ID: %s\n", req.UserID, existing.BookingID)
+							fmt.Printf("DEBUG: Used 1 Ticpass turf benefit for user %s on pending booking confirmation. Booking ID: %s\n", userID, existing.BookingID)
 							// Update the booking to reflect Ticpass usage
 							updateWithTicpass := bson.M{
 								"$set": bson.M{
@@ -109,6 +120,11 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 								},
 							}
 							_, _ = config.PlayBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, updateWithTicpass)
+							if !settleWallet(ctx, config.PlayBookingsCol, existing.ID, req.PaymentID, existing.GrandTotal-existing.WalletAmount) {
+								return c.Status(409).JSON(fiber.Map{"error": "wallet balance used for this booking is no longer available, your payment will be refunded"})
+							}
+							_ = couponsvc.Commit(existing.ID.Hex())
+							_ = offersvc.Commit(existing.ID.Hex())
 							// Trigger confirmation email in background
 							go func(id string) {
 								_ = bookingsvc.SendConfirmationEmail(id, "play")
@@ -134,6 +150,12 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 					},
 				}
 				_, _ = config.PlayBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, update)
+				if !settleWallet(ctx, config.PlayBookingsCol, existing.ID, req.PaymentID, existing.GrandTotal-existing.WalletAmount) {
+					return c.Status(409).JSON(fiber.Map{"error": "wallet balance used for this booking is no longer available, your payment will be refunded"})
+				}
+				_ = couponsvc.Commit(existing.ID.Hex())
+				_ = offersvc.Commit(existing.ID.Hex())
+				go referralsvc.CheckQualification(existing.UserID)
 
 				// Trigger confirmation email in background
 				go func(id string) {
@@ -183,6 +205,9 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 					},
 				}
 				_, _ = config.PlayBookingsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, update)
+				_ = couponsvc.Release(existing.ID.Hex(), req.Status)
+				_ = walletsvc.Release(existing.ID.Hex(), req.Status)
+				_ = offersvc.Release(existing.ID.Hex(), req.Status)
 
 				return c.Status(200).JSON(fiber.Map{
 					"message": "play booking cancelled and slot released",
@@ -283,28 +308,22 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 		req.BookingFee = expectedFee // Force correct fee
 	}
 
-	var discountAmount float64
-	var appliedCouponCode string
-	var couponIDToIncrement primitive.ObjectID
-	var couponMaxUses int
-	if req.CouponCode != "" {
-		result, err := couponsvc.Validate(req.CouponCode, "play", req.OrderAmount, req.UserID, req.UserEmail)
-		if err == nil {
-			discountAmount = result.DiscountAmount
-			appliedCouponCode = result.Coupon.Code
-			couponIDToIncrement = result.Coupon.ID
-			couponMaxUses = result.Coupon.MaxUses
-		}
-	}
-
-	var offerObjID primitive.ObjectID
-	if req.OfferID != "" {
-		offerResult, err := offersvc.ValidateOffer(req.OfferID, req.PlayID, req.OrderAmount)
-		if err == nil {
-			offerObjID = offerResult.Offer.ID
-			discountAmount += offerResult.DiscountAmount
-		}
-	}
+	discounts := bookingsvc.ApplyDiscounts(bookingsvc.Checkout{
+		Category:      "play",
+		EntityID:      req.PlayID,
+		OrganizerID:   play.OrganizerID,
+		City:          play.City,
+		OrderAmount:   req.OrderAmount,
+		UserID:        userID,
+		UserEmail:     req.UserEmail,
+		OrderID:       req.OrderID,
+		CouponCode:    req.CouponCode,
+		OfferID:       req.OfferID,
+		PaymentMethod: req.PaymentMethod,
+		CardBIN:       req.CardBIN,
+		UseTicpass:    req.UseTicpass,
+	})
+	discountAmount := discounts.Amount
 
 	// Cap total discount to order subtotal
 	if discountAmount > req.OrderAmount {
@@ -320,22 +339,30 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 	var ticpassApplied bool
 	var ticpassToDecrement bool
 	var passID string
-	if req.UseTicpass && req.UserID != "" {
-		pass, err := passsvc.GetActiveByUserID(req.UserID)
+	if req.UseTicpass && userID != "" {
+		pass, err := passsvc.GetActiveByUserID(userID)
 		if err == nil && pass != nil {
-			if pass.Benefits.TurfBookings.Remaining > 0 {
+			var venue models.Play
+			venueCtx, venueCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			_ = config.PlaysCol.FindOne(venueCtx, bson.M{"_id": playObjID}, options.FindOne().SetProjection(bson.M{"city": 1})).Decode(&venue)
+			venueCancel()
+			if !passsvc.Covers(pass, "play", venue.City) {
+				fmt.Printf("DEBUG: Ticpass plan does not cover play in %s for user %s\n", venue.City, userID)
+			} else if pass.Benefits.TurfBookings.Remaining > 0 {
 				// Free Turf Booking Benefit: 100% discount on order amount
 				discountAmount = req.OrderAmount
 				ticpassApplied = true
 				ticpassToDecrement = true
 				passID = pass.ID.Hex()
-				fmt.Printf("DEBUG: Ticpass will be applied for user %s if booking succeeds. Pass ID: %s\n", req.UserID, passID)
+				fmt.Printf("DEBUG: Ticpass will be applied for user %s if booking succeeds. Pass ID: %s\n", userID, passID)
 			} else {
-				// Fallback to 10% discount if all free bookings are used (optional, but keep it for goodwill)
-				ticpassDiscount := req.OrderAmount * 0.10
-				discountAmount += ticpassDiscount
-				ticpassApplied = true
-				fmt.Printf("DEBUG: Applied 10%% Ticpass discount: %.2f for user %s (no free bookings left)\n", ticpassDiscount, req.UserID)
+				// Fall back to the plan's play discount once the free bookings are used
+				ticpassDiscount := passsvc.PlayDiscount(pass, req.OrderAmount)
+				if ticpassDiscount > 0 {
+					discountAmount += ticpassDiscount
+					ticpassApplied = true
+					fmt.Printf("DEBUG: Applied Ticpass discount: %.2f for user %s (no free bookings left)\n", ticpassDiscount, userID)
+				}
 			}
 		}
 	}
@@ -346,6 +373,11 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 		grandTotal = 0
 	}
 
+	// Part of the total may be paid from the wallet; the gateway charges the rest
+	if err := discounts.HoldWallet(req.WalletAmount, grandTotal); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	duration := req.Duration
 	if duration <= 0 {
 		duration = 1
@@ -355,7 +387,7 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 		UserEmail:      req.UserEmail,
 		UserName:       req.UserName,
 		UserPhone:      req.UserPhone,
-		UserID:         req.UserID,
+		UserID:         userID,
 		Address:        req.Address,
 		City:           req.City,
 		State:          req.State,
@@ -370,32 +402,26 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 		OrderAmount:    req.OrderAmount,
 		BookingFee:     req.BookingFee,
 		DiscountAmount: discountAmount,
-		CouponCode:     appliedCouponCode,
-		OfferID:        offerObjID,
+		CouponCode:     discounts.CouponCode,
+		OfferID:        discounts.OfferID,
 		GrandTotal:     grandTotal,
+		WalletAmount:   discounts.WalletAmount,
 		OrderID:        req.OrderID,
 		PaymentID:      req.PaymentID,
 		PaymentGateway: req.PaymentGateway,
 		Status:         req.Status,
 		BookedAt:       time.Now(),
 		TicpassApplied: ticpassApplied,
-		LockKey:        req.LockKey,
+		LockKey:        userID,
 	}
 	if booking.Status == "" {
 		booking.Status = "booked"
 	}
 
 	if err := bookingsvc.CreatePlay(booking); err != nil {
-		// ROLLBACK: If booking fails, restore Ticpass if it was marked for decrement
-		if ticpassToDecrement && passID != "" {
-			fmt.Printf("INFO: Booking failed, attempting to restore Ticpass benefit for user %s\n", req.UserID)
-			_, restoreErr := passsvc.RestoreTurfBooking(passID)
-			if restoreErr != nil {
-				fmt.Printf("ERROR: Failed to restore Ticpass benefit: %v\n", restoreErr)
-			} else {
-				fmt.Printf("DEBUG: Successfully restored Ticpass benefit for user %s\n", req.UserID)
-			}
-		}
+		discounts.Release("booking failed")
+		// Nothing to roll back on the pass: the turf benefit is only redeemed
+		// once the booking exists.
 
 		// Clean up any slot locks that might have been created during this failed attempt
 		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -419,19 +445,20 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 
 	// Only decrement Ticpass benefit AFTER successful booking creation (for new bookings, not pending confirmations)
 	if ticpassToDecrement && passID != "" && (booking.Status == "booked" || booking.Status == "confirmed") {
-		_, err = passsvc.UseTurfBooking(passID)
+		_, err = passsvc.UseTurfBooking(passID, booking.ID.Hex())
 		if err != nil {
 			fmt.Printf("ERROR: Failed to decrement Ticpass turf benefit after successful booking: %v\n", err)
 			// Don't fail the booking since it's already created, but log the error
 		} else {
-			fmt.Printf("DEBUG: Successfully used 1 Ticpass turf benefit for user %s. Booking ID: %s\n", req.UserID, booking.BookingID)
+			fmt.Printf("DEBUG: Successfully used 1 Ticpass turf benefit for user %s. Booking ID: %s\n", userID, booking.BookingID)
 		}
 	}
 
 	bookingID := booking.ID.Hex()
 
-	if !couponIDToIncrement.IsZero() {
-		_ = couponsvc.IncrementUsage(couponIDToIncrement, couponMaxUses, req.UserID, req.UserEmail, bookingID, grandTotal)
+	discounts.Attach(bookingID, booking.Status == "booked" || booking.Status == "confirmed")
+	if booking.Status == "booked" || booking.Status == "confirmed" {
+		go referralsvc.CheckQualification(userID)
 	}
 
 	// Trigger confirmation email in background
@@ -444,7 +471,9 @@ func CreatePlayBooking(c *fiber.Ctx) error {
 		"booking_id":      booking.BookingID,
 		"id":              booking.ID.Hex(),
 		"grand_total":     grandTotal,
+		"wallet_amount":   discounts.WalletAmount,
 		"discount_amount": discountAmount,
+		"offer_id":        offerIDString(discounts.OfferID),
 		"status":          "booked",
 	})
 }
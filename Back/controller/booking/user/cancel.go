@@ -6,8 +6,12 @@ import (
 	"ticpin-backend/config"
 	"ticpin-backend/models"
 	bookingsvc "ticpin-backend/services/booking"
+	couponsvc "ticpin-backend/services/coupon"
+	offersvc "ticpin-backend/services/offer"
 	passsvc "ticpin-backend/services/pass"
 	paymentsvc "ticpin-backend/services/payment"
+	referralsvc "ticpin-backend/services/referral"
+	walletsvc "ticpin-backend/services/wallet"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -26,6 +30,7 @@ func CancelBooking(c *fiber.Ctx) error {
 	var requestBody struct {
 		Reason       string `json:"reason"`
 		CancelReason string `json:"cancel_reason"`
+		RefundTo     string `json:"refund_to"` // "source" (default) or "wallet"
 	}
 	if err := c.BodyParser(&requestBody); err != nil {
 		// If body parsing fails, continue without reason (backward compatibility)
@@ -41,6 +46,14 @@ func CancelBooking(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "booking id is required"})
 	}
 
+	refundTo := requestBody.RefundTo
+	if refundTo == "" {
+		refundTo = "source"
+	}
+	if refundTo != "source" && refundTo != "wallet" {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid refund_to: must be 'source' or 'wallet'"})
+	}
+
 	// FIX BUG5: Validate category parameter at start
 	validCategories := map[string]bool{"events": true, "event": true, "play": true, "dining": true}
 	if category != "" && !validCategories[category] {
@@ -161,7 +174,8 @@ func CancelBooking(c *fiber.Ctx) error {
 	case *models.Booking:
 		var event models.Event
 		if err := config.EventsCol.FindOne(ctx, bson.M{"_id": b.EventID}).Decode(&event); err == nil {
-			bookingDateStr = event.Date.Format("02 January, 2006")
+			showDate, _ := bookingsvc.BookingSchedule(&event, b)
+			bookingDateStr = showDate.Format("02 January, 2006")
 		}
 	case *models.PlayBooking:
 		bookingDateStr = b.Date
@@ -199,19 +213,26 @@ func CancelBooking(c *fiber.Ctx) error {
 
 	// REFUND SYNC: We now process refund FIRST, then update status only if refund succeeds (or no refund needed)
 	// Calculate refund details first
-	paymentID := ""
+	paymentID, orderID, gateway := "", "", ""
 	grandTotal := 0.0
+	walletAmount := 0.0
 	switch b := bookingFound.(type) {
 	case *models.Booking:
-		paymentID = b.PaymentID
+		paymentID, orderID, gateway = b.PaymentID, b.OrderID, b.PaymentGateway
 		grandTotal = b.GrandTotal
+		walletAmount = b.WalletAmount
 	case *models.PlayBooking:
-		paymentID = b.PaymentID
+		paymentID, orderID, gateway = b.PaymentID, b.OrderID, b.PaymentGateway
 		grandTotal = b.GrandTotal
+		walletAmount = b.WalletAmount
 	case *models.DiningBooking:
-		paymentID = b.PaymentID
+		paymentID, orderID, gateway = b.PaymentID, b.OrderID, b.PaymentGateway
 		grandTotal = b.GrandTotal
+		walletAmount = b.WalletAmount
 	}
+	// The wallet part of the payment always goes back to the wallet; only the
+	// rest was charged through the gateway.
+	grandTotal -= walletAmount
 
 	now := time.Now()
 	timeLeft := bTime.Sub(now)
@@ -238,7 +259,20 @@ func CancelBooking(c *fiber.Ctx) error {
 		}
 	}
 
-	if paymentID != "" && grandTotal > 0 && refundAmount > 0 {
+	if refundTo == "wallet" && refundAmount > 0 {
+		// Booking status and amounts can be influenced by the client, so only
+		// what the gateway really captured may become wallet credit. The
+		// wallet part of the payment is returned separately by Release.
+		captured, err := paymentsvc.CapturedAmount(gateway, orderID, paymentID)
+		if err != nil {
+			fmt.Printf("ERROR: Could not verify payment %s for booking %s: %v\n", paymentID, bookingIDStr, err)
+			return c.Status(400).JSON(fiber.Map{"error": "payment could not be verified for a wallet refund"})
+		}
+		if captured < refundAmount {
+			refundAmount = captured
+		}
+		fmt.Printf("INFO: Booking %s will be refunded to wallet, skipping Razorpay refund.\n", bookingIDStr)
+	} else if paymentID != "" && grandTotal > 0 && refundAmount > 0 {
 		// Razorpay minimum refund amount is ₹1.00
 		if refundAmount < 1.0 {
 			fmt.Printf("INFO: Refund skipped for booking %s because amount %.2f is less than Razorpay minimum of ₹1.00\n", bookingIDStr, refundAmount)
@@ -274,6 +308,7 @@ func CancelBooking(c *fiber.Ctx) error {
 		"refund_amount":  refundAmount,
 		"penalty_amount": penaltyAmount,
 		"refund_date":    time.Now(),
+		"refund_method":  refundTo,
 	}
 	// Add cancellation reason if provided
 	if cancellationReason != "" {
@@ -287,7 +322,7 @@ func CancelBooking(c *fiber.Ctx) error {
 	result, err := col.UpdateOne(ctx, bson.M{
 		"_id": bookingPrimitiveID,
 		"status": bson.M{
-			"$nin": []string{"cancelled", "failed", "refunded"},
+			"$nin": []string{"cancelled", "failed", "refunded", "refund_pending"},
 		},
 	}, update)
 
@@ -299,6 +334,25 @@ func CancelBooking(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "booking already cancelled or unavailable"})
 	}
 
+	// Give the coupon use back so it can be applied again
+	_ = couponsvc.Release(bookingPrimitiveID.Hex(), "cancelled")
+	_ = offersvc.Release(bookingPrimitiveID.Hex(), "cancelled")
+	go referralsvc.ClawBack(bookingPrimitiveID.Hex(), "cancelled")
+	// Wallet money spent on this booking goes straight back
+	if err := walletsvc.Release(bookingPrimitiveID.Hex(), "cancelled"); err != nil {
+		fmt.Printf("ERROR: Failed to return wallet payment for booking %s: %v\n", bookingIDStr, err)
+	}
+
+	walletCredited := 0.0
+	if refundTo == "wallet" && refundAmount > 0 {
+		if _, err := walletsvc.Credit(bookingUserID, refundAmount, "refund", "booking cancelled",
+			walletsvc.Ref{BookingType: category, BookingID: bookingPrimitiveID.Hex()}, nil); err != nil {
+			fmt.Printf("ERROR: Wallet refund failed for booking %s: %v\n", bookingIDStr, err)
+		} else {
+			walletCredited = refundAmount
+		}
+	}
+
 	if category == "play" || category == "dining" {
 		// FIX RC3 & BUG4: Properly handle lock cleanup with error tracking + context timeout
 		go func() {
@@ -321,20 +375,10 @@ func CancelBooking(c *fiber.Ctx) error {
 					refundCtx, refundCancel := context.WithTimeout(context.Background(), 5*time.Second)
 					defer refundCancel()
 
-					pass, err := passsvc.GetActiveByUserID(b.UserID)
+					// Give the benefit back to the pass that was charged for this booking
+					pass, err := passsvc.RefundTurfBooking(b.ID.Hex())
 					if err != nil {
-						fmt.Printf("ERROR: Could not find active pass for user %s during cancel: %v\n", b.UserID, err)
-						return
-					}
-
-					if pass == nil {
-						fmt.Printf("ERROR: Pass is nil for user %s during cancel\n", b.UserID)
-						return
-					}
-
-					_, err = passsvc.RefundTurfBooking(pass.ID.Hex())
-					if err != nil {
-						fmt.Printf("ERROR: Failed to refund Ticpass turf benefit for pass %s: %v\n", pass.ID.Hex(), err)
+						fmt.Printf("ERROR: Failed to refund Ticpass turf benefit for booking %s: %v\n", b.BookingID, err)
 					} else {
 						fmt.Printf("DEBUG: Ticpass turf booking refunded for pass %s\n", pass.ID.Hex())
 					}
@@ -348,20 +392,10 @@ func CancelBooking(c *fiber.Ctx) error {
 					refundCtx, refundCancel := context.WithTimeout(context.Background(), 5*time.Second)
 					defer refundCancel()
 
-					pass, err := passsvc.GetActiveByUserID(b.UserID)
-					if err != nil {
-						fmt.Printf("ERROR: Could not find active pass for user %s during cancel: %v\n", b.UserID, err)
-						return
-					}
-
-					if pass == nil {
-						fmt.Printf("ERROR: Pass is nil for user %s during cancel\n", b.UserID)
-						return
-					}
-
-					_, err = passsvc.RefundDiningVoucher(pass.ID.Hex())
+					// Give the voucher back to the pass that was charged for this booking
+					pass, err := passsvc.RefundDiningVoucher(b.ID.Hex())
 					if err != nil {
-						fmt.Printf("ERROR: Failed to refund Ticpass dining benefit for pass %s: %v\n", pass.ID.Hex(), err)
+						fmt.Printf("ERROR: Failed to refund Ticpass dining benefit for booking %s: %v\n", b.BookingID, err)
 					} else {
 						fmt.Printf("DEBUG: Ticpass dining voucher refunded for pass %s\n", pass.ID.Hex())
 					}
@@ -384,7 +418,8 @@ func CancelBooking(c *fiber.Ctx) error {
 			venueName = b.EventName
 			var event models.Event
 			if err := config.EventsCol.FindOne(emailCtx, bson.M{"_id": b.EventID}).Decode(&event); err == nil {
-				dateStr = fmt.Sprintf("%s (%s)", event.Date.Format("2006-01-02"), event.Time)
+				showDate, showTime := bookingsvc.BookingSchedule(&event, b)
+				dateStr = fmt.Sprintf("%s (%s)", showDate.Format("2006-01-02"), showTime)
 			} else {
 				dateStr = b.BookedAt.Format("2006-01-02")
 			}
@@ -411,9 +446,12 @@ func CancelBooking(c *fiber.Ctx) error {
 	}()
 
 	return c.JSON(fiber.Map{
-		"message":      "booking cancelled successfully",
-		"booking_id":   bookingIDStr,
-		"status":       "cancelled",
-		"cancelled_at": time.Now(),
+		"message":       "booking cancelled successfully",
+		"booking_id":    bookingIDStr,
+		"status":        "cancelled",
+		"cancelled_at":  time.Now(),
+		"refund_method": refundTo,
+		"refund_amount": refundAmount,
+		"wallet_refund": walletCredited + walletAmount,
 	})
 }
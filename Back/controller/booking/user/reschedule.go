@@ -0,0 +1,43 @@
+package bookinguser
+
+import (
+	"ticpin-backend/services/eventchange"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RespondToReschedule lets a booker keep their tickets for the new date or
+// ask for a full refund while the reschedule's refund window is open.
+func RespondToReschedule(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "booking id is required"})
+	}
+
+	authUserID, _ := c.Locals("userId").(string)
+	authPhone, _ := c.Locals("phone").(string)
+	if authUserID == "" && authPhone == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized: user session not found"})
+	}
+
+	var body struct {
+		Accept *bool `json:"accept"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Accept == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "accept (true or false) is required"})
+	}
+
+	rec, err := eventchange.RespondToReschedule(id, authUserID, authPhone, *body.Accept)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	message := "you will attend on the new date"
+	if !*body.Accept {
+		message = "booking cancelled, your refund is being processed"
+	}
+	return c.JSON(fiber.Map{
+		"message":  message,
+		"response": rec,
+	})
+}
@@ -0,0 +1,43 @@
+package bookinguser
+
+import (
+	bookingsvc "ticpin-backend/services/booking"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TransferTicket is the official flow for handing a named ticket to someone
+// else; holder names cannot be changed any other way.
+func TransferTicket(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "booking id is required"})
+	}
+
+	authUserID, _ := c.Locals("userId").(string)
+	authPhone, _ := c.Locals("phone").(string)
+	if authUserID == "" && authPhone == "" {
+		return c.Status(401).JSON(fiber.Map{"error": "unauthorized: user session not found"})
+	}
+
+	var body struct {
+		HolderIndex int    `json:"holder_index"`
+		ToName      string `json:"to_name"`
+		ToEmail     string `json:"to_email"`
+		ToPhone     string `json:"to_phone"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request: " + err.Error()})
+	}
+
+	booking, err := bookingsvc.TransferTicket(id, authUserID, authPhone, body.HolderIndex, body.ToName, body.ToEmail, body.ToPhone)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":      "ticket transferred successfully",
+		"holder_names": booking.HolderNames,
+		"transfers":    booking.Transfers,
+	})
+}
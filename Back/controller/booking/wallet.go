@@ -0,0 +1,46 @@
+package bookingctrl
+
+import (
+	"context"
+	"fmt"
+	couponsvc "ticpin-backend/services/coupon"
+	offersvc "ticpin-backend/services/offer"
+	walletsvc "ticpin-backend/services/wallet"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// settleWallet settles the wallet money held for a pending booking that was
+// just paid. When the hold was given back before the payment and the wallet
+// can no longer cover it, the booking is reversed: its coupon and offer are
+// given back and the gateway payment is refunded. It reports whether the
+// booking stands.
+func settleWallet(ctx context.Context, col *mongo.Collection, id primitive.ObjectID, paymentID string, paid float64) bool {
+	ref := id.Hex()
+	err := walletsvc.Settle(ref)
+	if err == nil {
+		return true
+	}
+	if err != walletsvc.ErrHoldLost {
+		fmt.Printf("ERROR: Failed to settle wallet hold for booking %s: %v\n", ref, err)
+		return true
+	}
+
+	fmt.Printf("DEBUG: Wallet hold for booking %s was released before payment, reversing booking\n", ref)
+	_ = couponsvc.Release(ref, "wallet_hold_lost")
+	_ = offersvc.Release(ref, "wallet_hold_lost")
+	filter := bson.M{"_id": id}
+	col.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"failed_at":      time.Now(),
+		"failure_reason": "wallet_hold_lost",
+	}})
+	if paymentID == "" || paid < 1.0 {
+		col.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"status": "failed"}})
+		return false
+	}
+	offersvc.QueueRefund(ctx, col, filter, paymentID, paid)
+	return false
+}
@@ -0,0 +1,24 @@
+package cronctrl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"ticpin-backend/services/jobs"
+)
+
+// RunJob runs one background job to completion, for schedulers that drive
+// the jobs from outside the process.
+func RunJob(c *fiber.Ctx) error {
+	job, ok := jobs.Find(c.Params("job"))
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "unknown job"})
+	}
+
+	started := time.Now()
+	job.Run()
+	fmt.Printf("DEBUG: Cron job %s finished in %s\n", job.Name, time.Since(started))
+	return c.JSON(fiber.Map{"success": true, "job": job.Name})
+}
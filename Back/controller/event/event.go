@@ -2,9 +2,11 @@ package event
 
 import (
 	"net/url"
+	bookingsvc "ticpin-backend/services/booking"
 	eventservice "ticpin-backend/services/event"
 
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func GetAllEvents(c *fiber.Ctx) error {
@@ -51,12 +53,49 @@ func GetEventAvailability(c *fiber.Ctx) error {
 		decodedId = eventId
 	}
 
+	event, err := eventservice.GetByID(decodedId, false)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event not found"})
+	}
+	eventID := event.ID.Hex()
+	showtimeID := c.Query("showtime_id")
+
+	categories := event.TicketCategories
+	if showtimeID != "" {
+		stID, err := primitive.ObjectIDFromHex(showtimeID)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid showtime_id"})
+		}
+		st := bookingsvc.FindShowtime(event, stID)
+		if st == nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Showtime not found"})
+		}
+		categories = st.TicketCategories
+	}
+
+	booked, err := bookingsvc.GetAvailability(eventID, showtimeID)
+	if err != nil {
+		booked = map[string]int{}
+	}
+	total := map[string]int{}
+	for _, tc := range categories {
+		total[tc.Name] = tc.Capacity
+	}
+
 	availability := map[string]interface{}{
-		"booked":  map[string]int{},
-		"total":   map[string]int{},
+		"booked":  booked,
+		"total":   total,
 		"eventId": decodedId,
 	}
 
+	if event.SeatMap != nil {
+		seats, err := bookingsvc.GetSeatStatus(eventID, showtimeID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+		availability["seats"] = seats
+	}
+
 	return c.Status(fiber.StatusOK).JSON(availability)
 }
 
@@ -3,17 +3,78 @@ package auth
 import (
 	"context"
 	"ticpin-backend/config"
+	sessionsvc "ticpin-backend/services/session"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
+// Logout ends the session on this device.
 func Logout(c *fiber.Ctx) error {
+	if id := sessionsvc.CurrentID(c, sessionsvc.KindOrganizer); id != "" {
+		_ = sessionsvc.Revoke(id, "", "logout")
+	}
 	config.ClearAuthCookies(c)
 	return c.JSON(fiber.Map{"message": "logged out successfully"})
 }
 
+// Refresh exchanges the refresh cookie for a new access token with claims
+// read fresh from the organizer record.
+func Refresh(c *fiber.Ctx) error {
+	claims, err := sessionsvc.RefreshOrganizer(c)
+	if err != nil {
+		config.ClearAuthCookies(c)
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{
+		"id":             claims.OrganizerID,
+		"email":          claims.Email,
+		"categoryStatus": claims.CategoryStatus,
+		"isAdmin":        claims.IsAdmin,
+		"memberId":       claims.MemberID,
+	})
+}
+
+// sessionSubject is whose sessions the signed-in organizer manages: the
+// owner's own, or a team member's own.
+func sessionSubject(c *fiber.Ctx) (string, string) {
+	if memberID, _ := c.Locals("memberId").(string); memberID != "" {
+		return sessionsvc.KindMember, memberID
+	}
+	organizerID, _ := c.Locals("organizerId").(string)
+	return sessionsvc.KindOrganizer, organizerID
+}
+
+// LogoutAll ends every session of the signed-in organizer, this one included.
+func LogoutAll(c *fiber.Ctx) error {
+	kind, subjectID := sessionSubject(c)
+	n, err := sessionsvc.RevokeAll(kind, subjectID, "", "logout_all")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	config.ClearAuthCookies(c)
+	return c.JSON(fiber.Map{"message": "logged out of all devices", "sessions_revoked": n})
+}
+
+func ListSessions(c *fiber.Ctx) error {
+	kind, subjectID := sessionSubject(c)
+	sessionID, _ := c.Locals("sessionId").(string)
+	list, err := sessionsvc.List(kind, subjectID, sessionID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func RevokeSession(c *fiber.Ctx) error {
+	_, subjectID := sessionSubject(c)
+	if err := sessionsvc.Revoke(c.Params("sessionId"), subjectID, "revoked_by_user"); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "session revoked"})
+}
+
 func CheckEmailExists(c *fiber.Ctx) error {
 	email := c.Query("email")
 	if email == "" {
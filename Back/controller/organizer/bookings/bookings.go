@@ -0,0 +1,45 @@
+package bookings
+
+import (
+	"errors"
+
+	organizersvc "ticpin-backend/services/organizer"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListBookings returns the organizer's bookings for one vertical
+// (?type=events|play|dining), optionally for one listing (?listing_id=).
+// Team members only see the listings they have access to.
+func ListBookings(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+	scope, _ := c.Locals("listingScope").([]string)
+
+	list, err := organizersvc.ListBookings(organizerID, c.Query("type", "events"), c.Query("listing_id"), scope)
+	if errors.Is(err, organizersvc.ErrListingDenied) {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// CheckIn marks a scanned ticket as used.
+func CheckIn(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+	email, _ := c.Locals("email").(string)
+	scope, _ := c.Locals("listingScope").([]string)
+
+	vertical, booking, err := organizersvc.CheckIn(organizerID, c.Params("bookingId"), scope, email)
+	if errors.Is(err, organizersvc.ErrBookingNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, organizersvc.ErrListingDenied) {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "checked in", "type": vertical, "booking": booking})
+}
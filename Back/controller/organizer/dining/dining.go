@@ -1,9 +1,9 @@
 package dining
 
 import (
-	"ticpin-backend/config"
 	"ticpin-backend/models"
 	organizersvc "ticpin-backend/services/organizer"
+	sessionsvc "ticpin-backend/services/session"
 	verifysvc "ticpin-backend/services/verification"
 
 	"github.com/gofiber/fiber/v2"
@@ -81,7 +81,7 @@ func VerifyOTP(c *fiber.Ctx) error {
 	if isAdmin {
 		role = "admin"
 	}
-	if err := config.SetAuthCookies(c, org.ID.Hex(), org.Email, role, "dining", isAdmin, org.CategoryStatus); err != nil {
+	if err := sessionsvc.StartOrganizer(c, org.ID.Hex(), org.Email, role, "dining", isAdmin, org.CategoryStatus); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "session error"})
 	}
 	return c.JSON(fiber.Map{
@@ -153,6 +153,7 @@ func DiningSetup(c *fiber.Ctx) error {
 		}
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
+	_ = sessionsvc.MarkStale(sessionsvc.KindOrganizer, setup.OrganizerID.Hex())
 	return c.JSON(fiber.Map{"message": "setup saved", "status": "pending"})
 }
 
@@ -190,16 +191,28 @@ func SubmitVerification(c *fiber.Ctx) error {
 }
 func GoogleAuth(c *fiber.Ctx) error {
 	var req struct {
-		Email string `json:"email"`
+		IDToken    string `json:"id_token"`
+		Credential string `json:"credential"` // field name used by Google Identity Services
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	if req.Email == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "email required"})
+	if req.IDToken == "" {
+		req.IDToken = req.Credential
+	}
+	if req.IDToken == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "id_token required"})
+	}
+
+	identity, err := organizersvc.VerifyGoogleIDToken(req.IDToken)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	org, err := organizersvc.GoogleAuth(req.Email)
+	org, err := organizersvc.GoogleAuth(identity.Email)
+	if err == organizersvc.ErrDeleted {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -209,7 +222,7 @@ func GoogleAuth(c *fiber.Ctx) error {
 	if isAdmin {
 		role = "admin"
 	}
-	if err := config.SetAuthCookies(c, org.ID.Hex(), org.Email, role, "dining", isAdmin, org.CategoryStatus); err != nil {
+	if err := sessionsvc.StartOrganizer(c, org.ID.Hex(), org.Email, role, "dining", isAdmin, org.CategoryStatus); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "session error"})
 	}
 
@@ -3,6 +3,7 @@ package dining
 import (
 	"ticpin-backend/models"
 	diningservice "ticpin-backend/services/dining"
+	organizersvc "ticpin-backend/services/organizer"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -66,6 +67,17 @@ func GetOrganizerDinings(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+
+	// Team members restricted to some listings only see those
+	if scope, _ := c.Locals("listingScope").([]string); len(scope) > 0 {
+		visible := []models.Dining{}
+		for _, l := range dinings {
+			if organizersvc.InScope(scope, l.ID.Hex()) {
+				visible = append(visible, l)
+			}
+		}
+		dinings = visible
+	}
 	return c.JSON(dinings)
 }
 
@@ -0,0 +1,91 @@
+package events
+
+import (
+	"ticpin-backend/services/eventchange"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func CancelOrganizerEvent(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req eventchange.CancelRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	change, err := eventchange.CancelEvent(c.Params("id"), authOrgID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "event cancelled, refunds are being processed",
+		"change":  change,
+	})
+}
+
+func RescheduleOrganizerEvent(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req eventchange.RescheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	change, err := eventchange.RescheduleEvent(c.Params("id"), authOrgID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "event rescheduled, bookers have been notified",
+		"change":  change,
+	})
+}
+
+func GetEventChanges(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	changes, err := eventchange.ListChanges(c.Params("id"), authOrgID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(changes)
+}
+
+func GetEventChangeBookings(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	recs, err := eventchange.GetChangeBookings(c.Params("id"), authOrgID, c.Params("changeId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(recs)
+}
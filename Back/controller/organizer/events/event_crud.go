@@ -3,6 +3,7 @@ package events
 import (
 	"ticpin-backend/models"
 	eventservice "ticpin-backend/services/event"
+	organizersvc "ticpin-backend/services/organizer"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -66,6 +67,17 @@ func GetOrganizerEvents(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+
+	// Team members restricted to some listings only see those
+	if scope, _ := c.Locals("listingScope").([]string); len(scope) > 0 {
+		visible := []models.Event{}
+		for _, l := range events {
+			if organizersvc.InScope(scope, l.ID.Hex()) {
+				visible = append(visible, l)
+			}
+		}
+		events = visible
+	}
 	return c.JSON(events)
 }
 
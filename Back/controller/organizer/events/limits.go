@@ -0,0 +1,23 @@
+package events
+
+import (
+	eventservice "ticpin-backend/services/event"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func GetPurchaseLimitReport(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	report, err := eventservice.GetLimitHitReport(c.Params("id"), authOrgID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(report)
+}
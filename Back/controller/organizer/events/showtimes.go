@@ -0,0 +1,84 @@
+package events
+
+import (
+	"ticpin-backend/models"
+	eventservice "ticpin-backend/services/event"
+	"ticpin-backend/services/eventchange"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AddShowtime(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var st models.Showtime
+	if err := c.BodyParser(&st); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	if err := eventservice.AddShowtime(c.Params("id"), authOrgID, &st); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message":  "showtime added successfully",
+		"showtime": st,
+	})
+}
+
+func RescheduleShowtime(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req eventchange.RescheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+	req.ShowtimeID = c.Params("showtimeId")
+
+	change, err := eventchange.RescheduleEvent(c.Params("id"), authOrgID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "showtime rescheduled successfully",
+		"change":  change,
+	})
+}
+
+func CancelShowtime(c *fiber.Ctx) error {
+	authOrgID, ok := c.Locals("organizerId").(string)
+	if !ok || authOrgID == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req eventchange.CancelRequest
+	_ = c.BodyParser(&req)
+	req.ShowtimeID = c.Params("showtimeId")
+
+	change, err := eventchange.CancelEvent(c.Params("id"), authOrgID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "showtime cancelled, refunds are being processed",
+		"change":  change,
+	})
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"ticpin-backend/config"
 	"ticpin-backend/models"
-	organizersvc "ticpin-backend/services/organizer"
+	sessionsvc "ticpin-backend/services/session"
 	"time"
 
 	"github.com/cloudinary/cloudinary-go/v2/api/uploader"
@@ -150,15 +150,15 @@ func GetOrganizerMe(c *fiber.Ctx) error {
 		return c.Status(404).JSON(fiber.Map{"error": "organizer not found"})
 	}
 
-	isAdmin := organizersvc.IsAdmin(org)
-	role := "organizer"
-	if isAdmin {
-		role = "admin"
+	sessionID, _ := c.Locals("sessionId").(string)
+	if _, err := sessionsvc.ReissueOrganizer(c, sessionID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to refresh session"})
 	}
 
-	err = config.SetAuthCookies(c, org.ID.Hex(), org.Email, role, "", isAdmin, org.CategoryStatus)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to refresh session"})
+	// Team members see the organizer they work for, without its documents
+	if memberID, _ := c.Locals("memberId").(string); memberID != "" {
+		org.PANCardURL = ""
+		org.PANCardPublicID = ""
 	}
 
 	return c.JSON(org)
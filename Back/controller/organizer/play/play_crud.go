@@ -2,6 +2,7 @@ package play
 
 import (
 	"ticpin-backend/models"
+	organizersvc "ticpin-backend/services/organizer"
 	playservice "ticpin-backend/services/play"
 
 	"github.com/gofiber/fiber/v2"
@@ -66,6 +67,17 @@ func GetOrganizerPlays(c *fiber.Ctx) error {
 			"error": err.Error(),
 		})
 	}
+
+	// Team members restricted to some listings only see those
+	if scope, _ := c.Locals("listingScope").([]string); len(scope) > 0 {
+		visible := []models.Play{}
+		for _, l := range plays {
+			if organizersvc.InScope(scope, l.ID.Hex()) {
+				visible = append(visible, l)
+			}
+		}
+		plays = visible
+	}
 	return c.JSON(plays)
 }
 
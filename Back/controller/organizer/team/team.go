@@ -0,0 +1,167 @@
+package team
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	organizersvc "ticpin-backend/services/organizer"
+	otpsvc "ticpin-backend/services/otp"
+	sessionsvc "ticpin-backend/services/session"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type memberRequest struct {
+	Email       string   `json:"email"`
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	ListingIDs  []string `json:"listing_ids"`
+}
+
+// ListPermissions returns the permissions an owner can give team members.
+func ListPermissions(c *fiber.Ctx) error {
+	return c.JSON(organizersvc.TeamPermissions)
+}
+
+func ListMembers(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+	list, err := organizersvc.ListMembers(organizerID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func InviteMember(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+	email, _ := c.Locals("email").(string)
+
+	var req memberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	m, err := organizersvc.InviteMember(organizerID, req.Email, req.Name, req.Permissions, req.ListingIDs, email)
+	if err != nil {
+		return membershipError(c, err)
+	}
+	return c.Status(201).JSON(fiber.Map{"message": "invitation sent", "member": m})
+}
+
+func UpdateMember(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+
+	var req memberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	m, err := organizersvc.UpdateMember(organizerID, c.Params("memberId"), req.Name, req.Permissions, req.ListingIDs)
+	if errors.Is(err, organizersvc.ErrMemberNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "team member updated", "member": m})
+}
+
+// RemoveMember takes someone off the team and signs them out everywhere.
+func RemoveMember(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+	memberID := c.Params("memberId")
+
+	if err := organizersvc.RemoveMember(organizerID, memberID); err != nil {
+		if errors.Is(err, organizersvc.ErrMemberNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if _, err := sessionsvc.RevokeAll(sessionsvc.KindMember, memberID, "", "member_removed"); err != nil {
+		fmt.Printf("ERROR: Failed to end sessions of removed team member %s: %v\n", memberID, err)
+	}
+	return c.JSON(fiber.Map{"message": "team member removed"})
+}
+
+// SendLoginOTP emails a team member their sign-in code. organizer_id is
+// only needed when the email is on more than one team.
+func SendLoginOTP(c *fiber.Ctx) error {
+	var req struct {
+		Email       string `json:"email"`
+		OrganizerID string `json:"organizer_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if err := organizersvc.SendMemberOTP(req.Email, req.OrganizerID); err != nil {
+		return membershipError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "otp sent"})
+}
+
+// VerifyLogin signs a team member in. The first sign-in accepts the
+// invitation.
+func VerifyLogin(c *fiber.Ctx) error {
+	var req struct {
+		Email       string `json:"email"`
+		OrganizerID string `json:"organizer_id"`
+		OTP         string `json:"otp"`
+		Vertical    string `json:"vertical"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	m, err := organizersvc.VerifyMemberOTP(req.Email, req.OrganizerID, req.OTP)
+	if err != nil {
+		return membershipError(c, err)
+	}
+	if err := sessionsvc.StartMember(c, m, req.Vertical); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "session error"})
+	}
+	return c.JSON(fiber.Map{
+		"id":          m.OrganizerID,
+		"memberId":    m.ID,
+		"email":       m.Email,
+		"permissions": m.Permissions,
+		"listing_ids": m.ListingIDs,
+	})
+}
+
+// GetMyAccess tells the signed-in organizer or member what they can do.
+func GetMyAccess(c *fiber.Ctx) error {
+	organizerID, _ := c.Locals("organizerId").(string)
+	memberID, _ := c.Locals("memberId").(string)
+	if memberID == "" {
+		return c.JSON(fiber.Map{
+			"organizer_id": organizerID,
+			"owner":        true,
+			"permissions":  organizersvc.TeamPermissions,
+			"listing_ids":  []string{},
+		})
+	}
+	perms, _ := c.Locals("teamPermissions").([]string)
+	scope, _ := c.Locals("listingScope").([]string)
+	return c.JSON(fiber.Map{
+		"organizer_id": organizerID,
+		"member_id":    memberID,
+		"owner":        false,
+		"permissions":  perms,
+		"listing_ids":  scope,
+	})
+}
+
+func membershipError(c *fiber.Ctx, err error) error {
+	var multi *organizersvc.MultipleTeamsError
+	if errors.As(err, &multi) {
+		return c.Status(409).JSON(fiber.Map{"error": err.Error(), "teams": multi.Teams})
+	}
+	if errors.Is(err, organizersvc.ErrMemberNotFound) {
+		return c.Status(404).JSON(fiber.Map{"error": "no team invitation found for this email"})
+	}
+	var limitErr *otpsvc.LimitError
+	if errors.As(err, &limitErr) {
+		seconds := int(limitErr.RetryAfter.Seconds())
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(429).JSON(fiber.Map{"error": limitErr.Message, "retry_after": seconds})
+	}
+	return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+}
@@ -2,144 +2,152 @@ package pass
 
 import (
 	"fmt"
-	"ticpin-backend/models"
 	passservice "ticpin-backend/services/pass"
-	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func CreatePass(c *fiber.Ctx) error {
-	var req struct {
-		UserID         string `json:"user_id"`
-		OrderID        string `json:"order_id"`
-		PaymentID      string `json:"payment_id"`
-		Amount         int    `json:"amount"`
-		DurationMonths int    `json:"duration_months"`
-		Phone          string `json:"phone"`
-	}
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
-	}
-	if req.UserID == "" || req.PaymentID == "" || req.OrderID == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "user_id, payment_id, and order_id required"})
+func GetPassByUser(c *fiber.Ctx) error {
+	p, err := passservice.GetActiveByUserID(c.Params("userId"))
+	if err != nil {
+		return c.Status(200).JSON(nil)
 	}
+	return c.JSON(p)
+}
 
-	// Convert string UserID to ObjectID
-	userObjID, err := primitive.ObjectIDFromHex(req.UserID)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "invalid user_id"})
-	}
-
-	// Create pass with 3 months validity
-	expiryDate := time.Now().AddDate(0, req.DurationMonths, 0)
-
-	passDetails := models.TicpinPass{
-		UserID:    userObjID,
-		PaymentID: req.PaymentID,
-		Price:     float64(req.Amount),
-		Status:    "active",
-		StartDate: time.Now(),
-		EndDate:   expiryDate,
-		Benefits: models.PassBenefits{
-			TurfBookings: models.BenefitCounter{
-				Total:     2,
-				Used:      0,
-				Remaining: 2,
-			},
-			DiningVouchers: models.DiningVoucherBenefit{
-				Total:     2,
-				Used:      0,
-				Remaining: 2,
-				ValueEach: 250.0,
-			},
-			EventsDiscountActive: true,
-		},
-		Renewals:  []models.RenewalRecord{},
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	p, err := passservice.Apply(passDetails.UserID.Hex(), req.PaymentID, req.Phone, req.OrderID, passDetails)
+func GetLatestPassByUser(c *fiber.Ctx) error {
+	fmt.Printf("DEBUG: GetLatestPassByUser called for User: %s\n", c.Params("userId"))
+	p, err := passservice.GetLatestByUserID(c.Params("userId"))
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		fmt.Printf("DEBUG: GetLatestPassByUser error: %v\n", err)
+		return c.Status(200).JSON(nil)
 	}
-	return c.Status(201).JSON(p)
+	return c.JSON(p)
 }
 
-func ApplyPass(c *fiber.Ctx) error {
+func RenewPass(c *fiber.Ctx) error {
 	var req struct {
-		UserID    string `json:"user_id"`
 		PaymentID string `json:"payment_id"`
 		OrderID   string `json:"order_id"`
-		Phone     string `json:"phone"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	if req.UserID == "" || req.PaymentID == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "user_id and payment_id required"})
+	if req.PaymentID == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "payment_id required"})
 	}
 
-	details := models.TicpinPass{}
-
-	p, err := passservice.Apply(req.UserID, req.PaymentID, req.Phone, req.OrderID, details)
+	p, err := passservice.Renew(c.Params("id"), req.PaymentID)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.Status(201).JSON(p)
+	return c.JSON(p)
 }
 
-func GetPassByUser(c *fiber.Ctx) error {
-	p, err := passservice.GetActiveByUserID(c.Params("userId"))
+func UseTurfBooking(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, err := passservice.RedeemOwned(c.Params("id"), userID, phone, passservice.BenefitTurf)
 	if err != nil {
-		return c.Status(200).JSON(nil)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(p)
 }
 
-func GetLatestPassByUser(c *fiber.Ctx) error {
-	fmt.Printf("DEBUG: GetLatestPassByUser called for User: %s\n", c.Params("userId"))
-	p, err := passservice.GetLatestByUserID(c.Params("userId"))
+func UseDiningVoucher(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, err := passservice.RedeemOwned(c.Params("id"), userID, phone, passservice.BenefitDining)
 	if err != nil {
-		fmt.Printf("DEBUG: GetLatestPassByUser error: %v\n", err)
-		return c.Status(200).JSON(nil)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(p)
 }
 
-func RenewPass(c *fiber.Ctx) error {
-	var req struct {
-		PaymentID string `json:"payment_id"`
-		OrderID   string `json:"order_id"`
+func ListPlans(c *fiber.Ctx) error {
+	plans, err := passservice.ListPlans(true)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	return c.JSON(plans)
+}
+
+// GetPassLedger shows the signed-in user the benefit history of their pass
+func GetPassLedger(c *fiber.Ctx) error {
+	view, err := passservice.GetLedger(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
 	}
-	if req.PaymentID == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "payment_id required"})
+
+	authUserID, _ := c.Locals("userId").(string)
+	authPhone, _ := c.Locals("phone").(string)
+	if view.Pass.UserID.Hex() != authUserID && (authPhone == "" || view.Pass.Phone != authPhone) {
+		return c.Status(403).JSON(fiber.Map{"error": "access denied"})
 	}
+	return c.JSON(view)
+}
 
-	p, err := passservice.Renew(c.Params("id"), req.PaymentID)
+func EnableAutoRenew(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, sub, err := passservice.EnableAutoRenew(c.Params("id"), userID, phone)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"pass": p, "subscription": sub})
+}
+
+func PauseAutoRenew(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, err := passservice.PauseAutoRenew(c.Params("id"), userID, phone)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(p)
 }
 
-func UseTurfBooking(c *fiber.Ctx) error {
-	p, err := passservice.UseTurfBooking(c.Params("id"))
+func ResumeAutoRenew(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, err := passservice.ResumeAutoRenew(c.Params("id"), userID, phone)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(p)
 }
 
-func UseDiningVoucher(c *fiber.Ctx) error {
-	p, err := passservice.UseDiningVoucher(c.Params("id"))
+func CancelAutoRenew(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, err := passservice.CancelAutoRenew(c.Params("id"), userID, phone)
 	if err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
 	return c.JSON(p)
 }
+
+func RedeemGift(c *fiber.Ctx) error {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Code == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "code required"})
+	}
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	p, gift, err := passservice.RedeemGift(req.Code, userID, phone)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"pass": p, "gift": gift})
+}
+
+func ListMyGifts(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	gifts, err := passservice.ListGiftsByBuyer(userID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(gifts)
+}
@@ -11,7 +11,10 @@ import (
 	"ticpin-backend/config"
 	"ticpin-backend/models"
 	bookingservice "ticpin-backend/services/booking"
+	couponsvc "ticpin-backend/services/coupon"
+	offersvc "ticpin-backend/services/offer"
 	passservice "ticpin-backend/services/pass"
+	walletsvc "ticpin-backend/services/wallet"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -48,8 +51,11 @@ func CashfreeWebhook(c *fiber.Ctx) error {
 				OrderID string `json:"order_id"`
 			} `json:"order"`
 			Payment struct {
-				CFPaymentID   interface{} `json:"cf_payment_id"`
-				PaymentStatus string      `json:"payment_status"`
+				CFPaymentID   interface{}            `json:"cf_payment_id"`
+				PaymentStatus string                 `json:"payment_status"`
+				PaymentAmount float64                `json:"payment_amount"`
+				PaymentGroup  string                 `json:"payment_group"`
+				PaymentMethod map[string]interface{} `json:"payment_method"`
 			} `json:"payment"`
 		} `json:"data"`
 	}
@@ -103,6 +109,14 @@ func CashfreeWebhook(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Offers tied to a payment method are checked against how the order was
+	// actually paid
+	offerOK := true
+	if newStatus == "booked" {
+		pm := cashfreePayment(payload.Data.Payment.PaymentGroup, payload.Data.Payment.PaymentMethod)
+		offerOK, _ = offersvc.VerifyPayment(orderID, pm)
+	}
+
 	// 2. Perform Atomic Multi-Collection Update (Just like Razorpay)
 	filter := bson.M{
 		"$or": []bson.M{
@@ -128,10 +142,38 @@ func CashfreeWebhook(c *fiber.Ctx) error {
 	}
 
 	for _, col := range collections {
-		result, err := col.UpdateMany(ctx, filter, update)
+		if !offerOK {
+			if reverseBooking(ctx, col, filter, []string{orderID}, paymentID, payload.Data.Payment.PaymentAmount, "offer_payment_mismatch") {
+				break
+			}
+			continue
+		}
+		bookable := filter
+		if newStatus == "booked" {
+			if reason := unconfirmable(ctx, col, filter); reason != "" {
+				if reverseBooking(ctx, col, filter, []string{orderID}, paymentID, payload.Data.Payment.PaymentAmount, reason) {
+					break
+				}
+				continue
+			}
+			bookable = bson.M{"$and": []bson.M{filter, {"status": bson.M{"$nin": []string{"cancelled", "refund_pending"}}}}}
+		}
+		result, err := col.UpdateMany(ctx, bookable, update)
 		if err == nil && result.ModifiedCount > 0 {
 			fmt.Printf("DEBUG: Cashfree Webhook processed successfully for col: %s\n", col.Name())
 
+			if newStatus == "booked" {
+				if !settleWallet(ctx, col, filter, []string{orderID}, paymentID, payload.Data.Payment.PaymentAmount) {
+					break
+				}
+				_ = couponsvc.Commit(orderID)
+				_ = offersvc.Commit(orderID)
+			} else {
+				_ = couponsvc.Release(orderID, "payment_failed")
+				_ = offersvc.Release(orderID, "payment_failed")
+				_ = walletsvc.Release(orderID, "payment_failed")
+			}
+
 			if newStatus == "booked" {
 				cat := "events"
 				if col.Name() == "play_bookings" {
@@ -0,0 +1,151 @@
+package paymentctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	bookingservice "ticpin-backend/services/booking"
+	couponsvc "ticpin-backend/services/coupon"
+	offersvc "ticpin-backend/services/offer"
+	walletsvc "ticpin-backend/services/wallet"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// razorpayPayment reads how an order was paid from the payment entity of a
+// Razorpay webhook. ok is false when the event carries no payment.
+func razorpayPayment(payload map[string]interface{}) (pm offersvc.PaymentMethod, paymentID string, amount float64, ok bool) {
+	paymentPayload, _ := payload["payment"].(map[string]interface{})
+	entity, _ := paymentPayload["entity"].(map[string]interface{})
+	if entity == nil {
+		return pm, "", 0, false
+	}
+	paymentID, _ = entity["id"].(string)
+	if paise, isNum := entity["amount"].(float64); isNum {
+		amount = paise / 100.0
+	}
+	pm.Method, _ = entity["method"].(string)
+	if card, isMap := entity["card"].(map[string]interface{}); isMap {
+		pm.CardNetwork, _ = card["network"].(string)
+		pm.Issuer, _ = card["issuer"].(string)
+		if iin, _ := card["iin"].(string); iin != "" {
+			pm.CardBIN = iin
+		} else {
+			pm.CardBIN, _ = card["token_iin"].(string)
+		}
+	}
+	if pm.Issuer == "" {
+		pm.Issuer, _ = entity["bank"].(string)
+	}
+	return pm, paymentID, amount, true
+}
+
+// cashfreePayment reads how an order was paid from a Cashfree webhook.
+func cashfreePayment(group string, method map[string]interface{}) offersvc.PaymentMethod {
+	pm := offersvc.PaymentMethod{Method: group}
+	if card, ok := method["card"].(map[string]interface{}); ok {
+		pm.CardNetwork, _ = card["card_network"].(string)
+		pm.Issuer, _ = card["card_bank_name"].(string)
+		// The masked card number keeps the BIN, e.g. 470613XXXXXX2123
+		if number, _ := card["card_number"].(string); len(number) >= 6 && strings.Trim(number[:6], "0123456789") == "" {
+			pm.CardBIN = number[:6]
+		}
+	}
+	if nb, ok := method["netbanking"].(map[string]interface{}); ok {
+		pm.Issuer, _ = nb["netbanking_bank_name"].(string)
+	}
+	return pm
+}
+
+// reverseBooking undoes a paid booking that cannot stand, e.g. because its
+// payment did not meet the payment condition of its offer: the payment is
+// refunded and the coupon and wallet money it held are given back. The
+// booking waits in "refund_pending" and only fails once the refund goes
+// through, retried until then. It reports whether a booking in col was
+// reversed; a booking reversed before is left alone.
+func reverseBooking(ctx context.Context, col *mongo.Collection, filter bson.M, refs []string, paymentID string, amount float64, reason string) bool {
+	now := time.Now()
+	pending := bson.M{"$and": []bson.M{filter, {"failure_reason": bson.M{"$ne": reason}}}}
+	result, err := col.UpdateMany(ctx, pending, bson.M{"$set": bson.M{
+		"failed_at":      now,
+		"failure_reason": reason,
+	}})
+	if err != nil || result.MatchedCount == 0 {
+		return false
+	}
+	fmt.Printf("DEBUG: Reversing booking for %v in collection: %s (%s)\n", refs, col.Name(), reason)
+
+	for _, ref := range refs {
+		_ = couponsvc.Release(ref, reason)
+		_ = walletsvc.Release(ref, reason)
+	}
+
+	if paymentID == "" || amount < 1.0 {
+		col.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"status": "failed"}})
+		return true
+	}
+	offersvc.QueueRefund(ctx, col, filter, paymentID, amount)
+	return true
+}
+
+// settleWallet settles the wallet money held for refs. When a hold was given
+// back before the payment and cannot be taken again, the booking is reversed
+// and settleWallet reports false.
+func settleWallet(ctx context.Context, col *mongo.Collection, filter bson.M, refs []string, paymentID string, amount float64) bool {
+	for _, ref := range refs {
+		if err := walletsvc.Settle(ref); err == walletsvc.ErrHoldLost {
+			for _, r := range refs {
+				_ = offersvc.Release(r, "wallet_hold_lost")
+			}
+			reverseBooking(ctx, col, filter, refs, paymentID, amount, "wallet_hold_lost")
+			return false
+		} else if err != nil {
+			fmt.Printf("ERROR: Failed to settle wallet hold for %s: %v\n", ref, err)
+		}
+	}
+	return true
+}
+
+// unconfirmable reports why a booking matched by filter in col cannot be
+// confirmed by the payment that just landed: it was cancelled while the
+// payment was on its way, its event or show was, or its seats went to
+// another buyer. It returns "" when the booking can stand or col holds no
+// such booking.
+func unconfirmable(ctx context.Context, col *mongo.Collection, filter bson.M) string {
+	var b struct {
+		ID         primitive.ObjectID `bson:"_id"`
+		Status     string             `bson:"status"`
+		EventID    primitive.ObjectID `bson:"event_id"`
+		ShowtimeID primitive.ObjectID `bson:"showtime_id"`
+		SeatIDs    []string           `bson:"seat_ids"`
+	}
+	if err := col.FindOne(ctx, filter).Decode(&b); err != nil {
+		return ""
+	}
+	switch b.Status {
+	case "booked", "confirmed", "refund_pending":
+		return ""
+	case "cancelled":
+		return "booking_cancelled"
+	}
+	if col != config.EventBookingsCol {
+		return ""
+	}
+	err := bookingservice.CheckConfirmable(ctx, &models.Booking{ID: b.ID, EventID: b.EventID, ShowtimeID: b.ShowtimeID, SeatIDs: b.SeatIDs})
+	switch {
+	case errors.Is(err, bookingservice.ErrSeatTaken):
+		return "seat_unavailable"
+	case bookingservice.ConfirmRefused(err):
+		return "event_cancelled"
+	case err != nil:
+		fmt.Printf("ERROR: Failed to check booking for order in collection %s: %v\n", col.Name(), err)
+	}
+	return ""
+}
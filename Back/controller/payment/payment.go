@@ -34,7 +34,8 @@ func CreateOrderHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "customer_phone is required"})
 	}
 
-	if req.Type == "pass" && req.CustomerID != "" {
+	isGift := req.Type == "pass" && req.Notes["gift"] == "true"
+	if req.Type == "pass" && req.CustomerID != "" && !isGift {
 		// PREVENT DUPLICATE PASS: Check if user already has an active pass
 		existingPass, err := passservice.GetActiveByUserID(req.CustomerID)
 		if err == nil && existingPass != nil {
@@ -54,6 +55,9 @@ func CreateOrderHandler(c *fiber.Ctx) error {
 		// Razorpay receipt limit is 40 chars.
 		// pass_ (5) + UserID (up to 30) + _ (1) + ShortTS (4) = 40
 		orderID = fmt.Sprintf("pass_%s_%d", req.CustomerID, time.Now().Unix()%10000)
+		if isGift {
+			orderID = fmt.Sprintf("gift_%s_%d", req.CustomerID, time.Now().Unix()%10000)
+		}
 	}
 
 	notes := req.Notes
@@ -63,6 +67,20 @@ func CreateOrderHandler(c *fiber.Ctx) error {
 	if req.Type != "" {
 		notes["booking_type"] = req.Type
 	}
+	if req.Type == "pass" {
+		// The webhook issues the pass or gift code from these when the
+		// client never reaches verify-pass, and both check the amount paid
+		// against this plan.
+		plan, err := passservice.ResolvePlan(req.Notes["plan_id"])
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !plan.ID.IsZero() {
+			notes["plan_id"] = plan.ID.Hex()
+		}
+		notes["user_id"] = req.CustomerID
+		notes["customer_phone"] = req.CustomerPhone
+	}
 
 	// Use alternating gateway for play bookings: Razorpay -> Cashfree -> Razorpay -> Cashfree
 	var gateway payment.GatewayType
@@ -110,16 +128,41 @@ func VerifyPassHandler(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"error": "invalid payment signature"})
 	}
 
-	// 2. Store pass in DB immediately (payment already collected by Razorpay)
-	p, err := passservice.Apply(req.UserID, req.RazorpayPaymentID, req.Phone, req.RazorpayOrderID, models.TicpinPass{
-		Price: 799,
+	// 2. The plan and price come from the order as Razorpay holds it, so a
+	// cheaper order cannot activate a dearer plan
+	userID, _ := c.Locals("userId").(string)
+	if req.UserID != "" && req.UserID != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "user_id does not match the signed-in user"})
+	}
+	order, err := payment.FetchRazorpayOrder(req.RazorpayOrderID)
+	if err != nil {
+		fmt.Printf("DEBUG: Failed to fetch pass order %s: %v\n", req.RazorpayOrderID, err)
+		return c.Status(502).JSON(fiber.Map{"error": "could not verify the payment order"})
+	}
+	if order.Notes["gift"] == "true" {
+		return c.Status(400).JSON(fiber.Map{"error": "this order is a gift purchase"})
+	}
+	if buyer := order.Notes["user_id"]; buyer != "" && buyer != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "this order belongs to another user"})
+	}
+	plan, err := passservice.PaidPlan(order.Notes["plan_id"], order.AmountPaid)
+	if err != nil {
+		fmt.Printf("DEBUG: Pass order %s refused: %v\n", req.RazorpayOrderID, err)
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// 3. Store pass in DB immediately (payment already collected by Razorpay)
+	p, err := passservice.Apply(userID, req.RazorpayPaymentID, req.Phone, req.RazorpayOrderID, models.TicpinPass{
+		PlanID: plan.ID,
+		Plan:   plan,
+		Price:  plan.Price,
 	})
 	if err != nil {
-		fmt.Printf("DEBUG: Failed to activate pass for user %s: %v\n", req.UserID, err)
+		fmt.Printf("DEBUG: Failed to activate pass for user %s: %v\n", userID, err)
 		return c.Status(500).JSON(fiber.Map{"error": "Failed to activate pass: " + err.Error()})
 	}
 
-	// 3. Send confirmation email in background (non-blocking — payment already done)
+	// 4. Send confirmation email in background (non-blocking — payment already done)
 	emailTo := req.Email
 	userPhone := req.Phone
 	orderID := req.RazorpayOrderID
@@ -146,3 +189,56 @@ func VerifyPassHandler(c *fiber.Ctx) error {
 		"pass":    p,
 	})
 }
+
+// VerifyPassGiftHandler confirms a gift pass payment and issues the gift
+// code for the buyer to share.
+func VerifyPassGiftHandler(c *fiber.Ctx) error {
+	var req struct {
+		RazorpayPaymentID string `json:"razorpay_payment_id"`
+		RazorpayOrderID   string `json:"razorpay_order_id"`
+		RazorpaySignature string `json:"razorpay_signature"`
+		Phone             string `json:"phone"`
+		passservice.GiftRequest
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid request"})
+	}
+
+	if !payment.VerifyRazorpaySignature(req.RazorpayOrderID, req.RazorpayPaymentID, req.RazorpaySignature) {
+		fmt.Printf("DEBUG: Invalid gift signature - order:%s payment:%s\n", req.RazorpayOrderID, req.RazorpayPaymentID)
+		return c.Status(400).JSON(fiber.Map{"error": "invalid payment signature"})
+	}
+
+	userID, _ := c.Locals("userId").(string)
+	phone, _ := c.Locals("phone").(string)
+	if phone == "" {
+		phone = req.Phone
+	}
+
+	// The plan and price come from the order as Razorpay holds it, not the
+	// request, so a cheaper or ordinary pass order cannot mint a gift
+	order, err := payment.FetchRazorpayOrder(req.RazorpayOrderID)
+	if err != nil {
+		fmt.Printf("DEBUG: Failed to fetch gift order %s: %v\n", req.RazorpayOrderID, err)
+		return c.Status(502).JSON(fiber.Map{"error": "could not verify the payment order"})
+	}
+	if order.Notes["gift"] != "true" {
+		return c.Status(400).JSON(fiber.Map{"error": "this order is not a gift purchase"})
+	}
+	if buyer := order.Notes["user_id"]; buyer != "" && buyer != userID {
+		return c.Status(403).JSON(fiber.Map{"error": "this order belongs to another user"})
+	}
+	req.PlanID = order.Notes["plan_id"]
+
+	gift, err := passservice.IssueGift(userID, phone, req.RazorpayPaymentID, req.RazorpayOrderID, order.AmountPaid, req.GiftRequest)
+	if err != nil {
+		fmt.Printf("DEBUG: Failed to issue gift pass for user %s: %v\n", userID, err)
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to issue gift: " + err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"gift":    gift,
+	})
+}
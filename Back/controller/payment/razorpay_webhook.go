@@ -7,11 +7,16 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strings"
 	"ticpin-backend/config"
 	"ticpin-backend/models"
 	bookingservice "ticpin-backend/services/booking"
+	couponsvc "ticpin-backend/services/coupon"
+	offersvc "ticpin-backend/services/offer"
 	passservice "ticpin-backend/services/pass"
 	profileservice "ticpin-backend/services/profile"
+	referralsvc "ticpin-backend/services/referral"
+	walletsvc "ticpin-backend/services/wallet"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -112,6 +117,32 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 			amountVal, _ := entity["amount"].(float64)
 			amount := amountVal / 100.0
 
+			if gift, _ := notes["gift"].(string); gift == "true" && userID != "" {
+				// Gifts are keyed by the Razorpay order so the verify call
+				// and this webhook issue a single code.
+				giftOrderID := orderID
+				if rzpOrderID, ok := entity["order_id"].(string); ok && rzpOrderID != "" {
+					giftOrderID = rzpOrderID
+				}
+				paymentID := ""
+				if strings.HasPrefix(orderID, "pay_") {
+					paymentID = orderID
+				}
+				planID, _ := notes["plan_id"].(string)
+				recipientName, _ := notes["recipient_name"].(string)
+				recipientPhone, _ := notes["recipient_phone"].(string)
+				message, _ := notes["message"].(string)
+				if _, err := passservice.IssueGift(userID, customerPhone, paymentID, giftOrderID, amount, passservice.GiftRequest{
+					PlanID:         planID,
+					RecipientName:  recipientName,
+					RecipientPhone: recipientPhone,
+					Message:        message,
+				}); err != nil {
+					fmt.Printf("DEBUG: Error issuing gift pass from webhook: %v\n", err)
+				}
+				return c.Status(200).JSON(fiber.Map{"status": "received", "message": "gift processed"})
+			}
+
 			if userID != "" {
 				if passID != "" {
 					_, err := passservice.Renew(passID, orderID)
@@ -121,10 +152,16 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 						fmt.Printf("DEBUG: Successfully renewed pass %s for User: %s via Razorpay\n", passID, userID)
 					}
 				} else {
-					_, err := passservice.Apply(userID, orderID, customerPhone, orderID, models.TicpinPass{
-						Status: "active",
-						Price:  amount,
-					})
+					planID, _ := notes["plan_id"].(string)
+					plan, err := passservice.PaidPlan(planID, amount)
+					if err == nil {
+						_, err = passservice.Apply(userID, orderID, customerPhone, orderID, models.TicpinPass{
+							Status: "active",
+							PlanID: plan.ID,
+							Plan:   plan,
+							Price:  plan.Price,
+						})
+					}
 					if err != nil {
 						fmt.Printf("DEBUG: Error creating pass from webhook: %v\n", err)
 					} else {
@@ -134,6 +171,9 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 			}
 			// Respond and exit
 			return c.Status(200).JSON(fiber.Map{"status": "received", "message": "pass processed"})
+		case "pass_subscription":
+			// Auto-renew charges are applied from subscription.charged
+			return c.Status(200).JSON(fiber.Map{"status": "received", "message": "handled by subscription events"})
 		default:
 			// Search across all if type is missing
 			targetCollections = []*mongo.Collection{
@@ -143,6 +183,22 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 			}
 		}
 
+		// Offers tied to a payment method are checked against how the
+		// order was actually paid
+		refs := []string{orderID}
+		if rzpOrderID, ok := entity["order_id"].(string); ok && rzpOrderID != "" && rzpOrderID != orderID {
+			refs = append(refs, rzpOrderID)
+		}
+		offerOK := true
+		pm, paymentID, paidAmount, hasPayment := razorpayPayment(event.Payload)
+		if hasPayment {
+			for _, ref := range refs {
+				if ok, _ := offersvc.VerifyPayment(ref, pm); !ok {
+					offerOK = false
+				}
+			}
+		}
+
 		for _, col := range targetCollections {
 			// Find by either payment_id or order_id
 			filter := bson.M{
@@ -151,7 +207,20 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 					{"order_id": orderID},
 				},
 			}
-			result, err := col.UpdateMany(ctx, filter, bson.M{
+			if !offerOK {
+				if reverseBooking(ctx, col, filter, refs, paymentID, paidAmount, "offer_payment_mismatch") {
+					break
+				}
+				continue
+			}
+			if reason := unconfirmable(ctx, col, filter); reason != "" {
+				if reverseBooking(ctx, col, filter, refs, paymentID, paidAmount, reason) {
+					break
+				}
+				continue
+			}
+			bookable := bson.M{"$and": []bson.M{filter, {"status": bson.M{"$nin": []string{"cancelled", "refund_pending"}}}}}
+			result, err := col.UpdateMany(ctx, bookable, bson.M{
 				"$set": bson.M{
 					"status":  "booked",
 					"paid_at": time.Now(),
@@ -160,6 +229,15 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 			if err == nil && result.ModifiedCount > 0 {
 				fmt.Printf("DEBUG: Successfully updated booking status for Order/Payment ID: %s in collection: %s\n", orderID, col.Name())
 
+				// The coupon, offer and wallet money held for this order are now used
+				if !settleWallet(ctx, col, filter, refs, paymentID, paidAmount) {
+					break
+				}
+				for _, ref := range refs {
+					_ = couponsvc.Commit(ref)
+					_ = offersvc.Commit(ref)
+				}
+
 				cat := "events"
 				if col.Name() == "play_bookings" {
 					cat = "play"
@@ -223,6 +301,9 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 			})
 			if err == nil && result.ModifiedCount > 0 {
 				fmt.Printf("DEBUG: Successfully updated booking status to 'failed' for Order/Payment ID: %s in collection: %s\n", orderID, col.Name())
+				_ = couponsvc.Release(orderID, "payment_failed")
+				_ = walletsvc.Release(orderID, "payment_failed")
+				_ = offersvc.Release(orderID, "payment_failed")
 				break
 			}
 		}
@@ -301,22 +382,19 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 						}
 					}
 
-					pass, err := passservice.GetActiveByUserID(userID)
-					if err == nil && pass != nil {
+					// The ledger knows which pass this booking was charged to;
+					// event bookings only took a discount, nothing to give back
+					if oid, ok := bookingID.(primitive.ObjectID); ok && col.Name() != "event_bookings" {
+						var err error
 						if col.Name() == "play_bookings" {
-							_, err = passservice.RefundTurfBooking(pass.ID.Hex())
-							if err != nil {
-								fmt.Printf("ERROR: Failed to refund Ticpass turf benefit for User: %s, Error: %v\n", userID, err)
-							} else {
-								fmt.Printf("DEBUG: Refunded Ticpass turf benefit for User: %s\n", userID)
-							}
-						} else if col.Name() == "dining_bookings" {
-							_, err = passservice.RefundDiningVoucher(pass.ID.Hex())
-							if err != nil {
-								fmt.Printf("ERROR: Failed to refund Ticpass dining benefit for User: %s, Error: %v\n", userID, err)
-							} else {
-								fmt.Printf("DEBUG: Refunded Ticpass dining benefit for User: %s\n", userID)
-							}
+							_, err = passservice.RefundTurfBooking(oid.Hex())
+						} else {
+							_, err = passservice.RefundDiningVoucher(oid.Hex())
+						}
+						if err != nil {
+							fmt.Printf("ERROR: Failed to refund Ticpass benefit for booking %s, User: %s, Error: %v\n", oid.Hex(), userID, err)
+						} else {
+							fmt.Printf("DEBUG: Refunded Ticpass benefit for booking %s, User: %s\n", oid.Hex(), userID)
 						}
 					}
 				}
@@ -330,10 +408,60 @@ func RazorpayWebhook(c *fiber.Ctx) error {
 			})
 			if err == nil && result.ModifiedCount > 0 {
 				fmt.Printf("DEBUG: Successfully updated booking status to 'refunded' for Order/Payment ID: %s in collection: %s\n", orderID, col.Name())
+				if oid, ok := bookingDoc["_id"].(primitive.ObjectID); ok {
+					go referralsvc.ClawBack(oid.Hex(), "refunded")
+				}
 				break
 			}
 		}
 
+	case "subscription.charged", "subscription.halted", "subscription.activated", "subscription.paused", "subscription.resumed", "subscription.cancelled", "subscription.completed":
+		// Ticpass auto-renew subscriptions
+		subscriptionID := ""
+		if subPayload, ok := event.Payload["subscription"].(map[string]interface{}); ok {
+			if entity, ok := subPayload["entity"].(map[string]interface{}); ok {
+				subscriptionID, _ = entity["id"].(string)
+			}
+		}
+		if subscriptionID == "" {
+			fmt.Printf("DEBUG: Subscription webhook without subscription id: %s\n", event.Event)
+			break
+		}
+
+		switch event.Event {
+		case "subscription.charged":
+			paymentID := ""
+			var charged float64
+			if paymentPayload, ok := event.Payload["payment"].(map[string]interface{}); ok {
+				if entity, ok := paymentPayload["entity"].(map[string]interface{}); ok {
+					paymentID, _ = entity["id"].(string)
+					if paise, ok := entity["amount"].(float64); ok {
+						charged = paise / 100
+					}
+				}
+			}
+			if _, err := passservice.HandleSubscriptionCharged(subscriptionID, paymentID, charged); err != nil {
+				fmt.Printf("ERROR: Failed to auto-renew pass for subscription %s: %v\n", subscriptionID, err)
+			} else {
+				fmt.Printf("DEBUG: Auto-renewed pass for subscription %s (payment %s)\n", subscriptionID, paymentID)
+			}
+		case "subscription.halted":
+			if err := passservice.HandleSubscriptionHalted(subscriptionID); err != nil {
+				fmt.Printf("ERROR: Failed to handle halted subscription %s: %v\n", subscriptionID, err)
+			}
+		default:
+			status := map[string]string{
+				"subscription.activated": "active",
+				"subscription.resumed":   "active",
+				"subscription.paused":    "paused",
+				"subscription.cancelled": "cancelled",
+				"subscription.completed": "cancelled",
+			}[event.Event]
+			if err := passservice.SyncSubscriptionStatus(subscriptionID, status); err != nil {
+				fmt.Printf("DEBUG: Failed to sync subscription %s status: %v\n", subscriptionID, err)
+			}
+		}
+
 	case "settlement.completed":
 		// Handle settlements (useful for accounting)
 		fmt.Printf("DEBUG: Settlement completed - useful for accounting\n")
@@ -1,9 +1,17 @@
 package user
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
 	"ticpin-backend/config"
 	"ticpin-backend/models"
+	otpsvc "ticpin-backend/services/otp"
+	referralsvc "ticpin-backend/services/referral"
+	sessionsvc "ticpin-backend/services/session"
+	"ticpin-backend/services/sms"
 	userservice "ticpin-backend/services/user"
+	walletsvc "ticpin-backend/services/wallet"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -20,26 +28,131 @@ func CreateUser(c *fiber.Ctx) error {
 	return c.JSON(u)
 }
 
-func LoginUser(c *fiber.Ctx) error {
+// SendLoginOTP texts a login code to the phone number.
+func SendLoginOTP(c *fiber.Ctx) error {
 	var req struct {
 		Phone string `json:"phone"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 	}
+	if req.Phone == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "phone is required"})
+	}
+
+	if err := otpsvc.SendPhoneOTP(req.Phone); err != nil {
+		return otpError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "otp sent successfully"})
+}
+
+func otpError(c *fiber.Ctx, err error) error {
+	var limitErr *otpsvc.LimitError
+	if errors.As(err, &limitErr) {
+		seconds := int(limitErr.RetryAfter.Seconds())
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+		return c.Status(429).JSON(fiber.Map{"error": limitErr.Message, "retry_after": seconds})
+	}
+	return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+}
+
+// LoginUser signs the user in once the OTP sent to their phone is verified.
+func LoginUser(c *fiber.Ctx) error {
+	var req struct {
+		Phone        string `json:"phone"`
+		OTP          string `json:"otp"`
+		ReferralCode string `json:"referral_code"`
+		DeviceID     string `json:"device_id"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if req.Phone == "" || req.OTP == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "phone and otp are required"})
+	}
+
+	phone, err := sms.NormalizePhone(req.Phone)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := otpsvc.VerifyPhoneOTP(phone, req.OTP); err != nil {
+		return otpError(c, err)
+	}
 
-	u, err := userservice.Login(req.Phone)
+	u, created, err := userservice.Login(phone)
+	if err == userservice.ErrDeleted {
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	if err := config.SetUserAuthCookies(c, u.ID.Hex(), u.Phone, ""); err != nil {
+	// A referral code only counts for a brand new account
+	if created && req.ReferralCode != "" {
+		deviceID := req.DeviceID
+		if deviceID == "" {
+			deviceID = c.Get("X-Device-ID")
+		}
+		if _, err := referralsvc.RecordSignup(u, req.ReferralCode, deviceID, c.IP()); err != nil {
+			fmt.Printf("DEBUG: Referral not recorded for user %s: %v\n", u.ID.Hex(), err)
+		}
+	}
+
+	if err := sessionsvc.StartUser(c, u.ID.Hex(), u.Phone, ""); err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": "failed to set session"})
 	}
 
 	return c.JSON(u)
 }
 
+// RefreshSession exchanges the refresh cookie for a new access token.
+func RefreshSession(c *fiber.Ctx) error {
+	claims, err := sessionsvc.RefreshUser(c)
+	if err != nil {
+		config.ClearUserAuthCookies(c)
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"id": claims.UserID, "phone": claims.Phone})
+}
+
+// Logout ends the session on this device.
+func Logout(c *fiber.Ctx) error {
+	if id := sessionsvc.CurrentID(c, sessionsvc.KindUser); id != "" {
+		_ = sessionsvc.Revoke(id, "", "logout")
+	}
+	config.ClearUserAuthCookies(c)
+	return c.JSON(fiber.Map{"message": "logged out successfully"})
+}
+
+// LogoutAll ends every session of the signed-in user, this one included.
+func LogoutAll(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	n, err := sessionsvc.RevokeAll(sessionsvc.KindUser, userID, "", "logout_all")
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	config.ClearUserAuthCookies(c)
+	return c.JSON(fiber.Map{"message": "logged out of all devices", "sessions_revoked": n})
+}
+
+func ListSessions(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	sessionID, _ := c.Locals("sessionId").(string)
+	list, err := sessionsvc.List(sessionsvc.KindUser, userID, sessionID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+func RevokeSession(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	if err := sessionsvc.Revoke(c.Params("sessionId"), userID, "revoked_by_user"); err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "session revoked"})
+}
+
 func GetUser(c *fiber.Ctx) error {
 	u, err := userservice.GetByID(c.Params("id"))
 	if err != nil {
@@ -47,3 +160,30 @@ func GetUser(c *fiber.Ctx) error {
 	}
 	return c.JSON(u)
 }
+
+// GetMyReferrals returns the signed-in user's referral code and invites.
+func GetMyReferrals(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	summary, err := referralsvc.GetSummary(userID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(summary)
+}
+
+func GetMyWallet(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	statement, err := walletsvc.GetStatement(userID, page, limit)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(statement)
+}
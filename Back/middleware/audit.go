@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"encoding/json"
+	"strings"
+
+	"ticpin-backend/models"
+	auditsvc "ticpin-backend/services/audit"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// auditTarget reads the entity and document id a request acts on from its
+// path, e.g. /api/admin/organizers/:id/status acts on organizers/:id.
+func auditTarget(path string) (string, string) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(path, "/api/admin/"), "/api/organizer/")
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	entity := parts[0]
+	for _, p := range parts[1:] {
+		if primitive.IsValidObjectID(p) {
+			return entity, p
+		}
+	}
+	return entity, ""
+}
+
+// auditAction names a change after its route: the entity, then any fixed
+// words after it, or the kind of change. PUT /organizers/:id/status is
+// organizers.status; DELETE /users/:id is users.delete.
+func auditAction(method, route string) string {
+	rest := strings.TrimPrefix(strings.TrimPrefix(route, "/api/admin/"), "/api/organizer/")
+	parts := strings.Split(strings.Trim(rest, "/"), "/")
+	words := []string{}
+	for _, p := range parts[1:] {
+		if p != "" && !strings.HasPrefix(p, ":") {
+			words = append(words, p)
+		}
+	}
+	if len(words) > 0 {
+		return parts[0] + "." + strings.Join(words, ".")
+	}
+	switch method {
+	case fiber.MethodPost:
+		return parts[0] + ".create"
+	case fiber.MethodDelete:
+		return parts[0] + ".delete"
+	default:
+		return parts[0] + ".update"
+	}
+}
+
+// bodyEmail reads the email field of a JSON request body.
+func bodyEmail(body []byte) string {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	return req.Email
+}
+
+// createdID finds the id of a newly created document in a JSON response.
+func createdID(body []byte) string {
+	var resp map[string]interface{}
+	if json.Unmarshal(body, &resp) != nil {
+		return ""
+	}
+	for _, key := range []string{"id", "_id"} {
+		if id, ok := resp[key].(string); ok && primitive.IsValidObjectID(id) {
+			return id
+		}
+	}
+	return ""
+}
+
+// auditMutation runs the rest of the chain and records any change an
+// admin or organizer makes: who, what, the fields that changed, and from
+// where. Reads are not recorded.
+func auditMutation(c *fiber.Ctx) error {
+	method := c.Method()
+	if method == fiber.MethodGet || method == fiber.MethodHead || method == fiber.MethodOptions {
+		return c.Next()
+	}
+
+	path := c.Path()
+	// Typing indicators change nothing
+	if strings.HasSuffix(path, "/typing") {
+		return c.Next()
+	}
+	entity, entityID := auditTarget(path)
+	// Routes under /admins name the admin they act on in the body
+	var targetEmail string
+	if entity == "admins" && entityID == "" {
+		targetEmail = bodyEmail(c.Body())
+		entityID = auditsvc.AdminID(targetEmail)
+	}
+	var before map[string]interface{}
+	if entityID != "" {
+		before = auditsvc.Snapshot(entity, entityID)
+	}
+
+	err := c.Next()
+
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		}
+	}
+
+	entry := &models.AuditLog{
+		ActorID:    stringLocal(c, "organizerId"),
+		ActorEmail: strings.ToLower(stringLocal(c, "email")),
+		MemberID:   stringLocal(c, "memberId"),
+		Action:     auditAction(method, c.Route().Path),
+		Method:     method,
+		Route:      c.Route().Path,
+		Path:       path,
+		Entity:     entity,
+		EntityID:   entityID,
+		Status:     status,
+		IP:         c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+	}
+	switch {
+	case entry.MemberID != "":
+		entry.ActorType = "organizer_member"
+	case strings.HasPrefix(path, "/api/admin/"):
+		entry.ActorType = "admin"
+	default:
+		entry.ActorType = "organizer"
+	}
+
+	if status < 400 {
+		if entityID == "" && targetEmail != "" {
+			// Assigning roles creates the admin account on first use
+			if id := auditsvc.AdminID(targetEmail); id != "" {
+				entry.EntityID = id
+				entry.After = auditsvc.Snapshot(entity, id)
+			}
+		} else if entityID == "" && method == fiber.MethodPost {
+			if id := createdID(c.Response().Body()); id != "" {
+				entry.EntityID = id
+				entry.After = auditsvc.Snapshot(entity, id)
+			}
+		} else if entityID != "" {
+			after := auditsvc.Snapshot(entity, entityID)
+			switch {
+			case before != nil && after == nil:
+				entry.Before = before
+			case before == nil && after != nil:
+				entry.After = after
+			default:
+				entry.Changes = auditsvc.Diff(before, after)
+			}
+		}
+	}
+
+	auditsvc.Record(entry)
+	return err
+}
+
+func stringLocal(c *fiber.Ctx, key string) string {
+	v, _ := c.Locals(key).(string)
+	return v
+}
@@ -3,30 +3,70 @@ package middleware
 import (
 	stdlog "log"
 	"ticpin-backend/config"
+	adminsvc "ticpin-backend/services/admin"
+	organizersvc "ticpin-backend/services/organizer"
+	sessionsvc "ticpin-backend/services/session"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func jwtSecret() []byte {
-	return config.JWTSecret()
+// RequireAuth accepts a valid organizer access token whose session is
+// still live. When the access token has expired the refresh cookie is
+// rotated and a new one issued, so clients never see the short lifetime.
+// Every change made behind it is written to the audit log.
+func RequireAuth(c *fiber.Ctx) error {
+	if ok, err := authOrganizer(c); !ok {
+		return err
+	}
+	return auditMutation(c)
 }
 
-func RequireAuth(c *fiber.Ctx) error {
-	tokenStr := c.Cookies("ticpin_token")
-	if tokenStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: missing token"})
+// authOrganizer checks the organizer token and fills in the locals. When
+// it reports false the error response has already been written.
+func authOrganizer(c *fiber.Ctx) (bool, error) {
+	claims, err := config.ParseOrganizerToken(c.Cookies("ticpin_token"))
+	if err == nil {
+		version, checkErr := sessionsvc.Check(claims.SessionID)
+		if checkErr == sessionsvc.ErrRevoked {
+			config.ClearAuthCookies(c)
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: session has been revoked"})
+		}
+		if checkErr == nil && version != claims.ClaimsVersion {
+			fresh, err := sessionsvc.ReissueOrganizer(c, claims.SessionID)
+			if err == sessionsvc.ErrRevoked {
+				config.ClearAuthCookies(c)
+				return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: session has been revoked"})
+			}
+			if err == nil {
+				claims = fresh
+			}
+		}
+	} else {
+		if c.Cookies("ticpin_token") == "" && c.Cookies("ticpin_refresh") == "" {
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: missing token"})
+		}
+		claims, err = sessionsvc.RefreshOrganizer(c)
+		if err != nil {
+			config.ClearAuthCookies(c)
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: invalid or expired token"})
+		}
 	}
 
-	claims := &config.OrganizerClaims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fiber.ErrUnauthorized
+	// Team members act as the organizer they belong to; what they may do is
+	// resolved from their membership, not the token.
+	if claims.MemberID != "" {
+		access, err := organizersvc.AccessFor(claims.MemberID)
+		if err == organizersvc.ErrMemberNotFound || (err == nil && access.OrganizerID != claims.OrganizerID) {
+			_ = sessionsvc.Revoke(claims.SessionID, "", "member_removed")
+			config.ClearAuthCookies(c)
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: you are no longer on this team"})
+		}
+		if err != nil {
+			return false, c.Status(500).JSON(fiber.Map{"error": "failed to load team permissions"})
 		}
-		return jwtSecret(), nil
-	})
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: invalid or expired token"})
+		c.Locals("memberId", access.MemberID)
+		c.Locals("teamPermissions", access.Permissions)
+		c.Locals("listingScope", access.ListingIDs)
 	}
 
 	c.Locals("organizerId", claims.OrganizerID)
@@ -34,10 +74,63 @@ func RequireAuth(c *fiber.Ctx) error {
 	c.Locals("role", claims.Role)
 	c.Locals("isAdmin", claims.IsAdmin)
 	c.Locals("approvals", claims.CategoryStatus)
+	c.Locals("sessionId", claims.SessionID)
+	c.Locals("mfa", claims.MFA)
+	return true, nil
+}
 
+// RequireOwner keeps team members out of account level routes such as the
+// profile, bank details and team management.
+func RequireOwner(c *fiber.Ctx) error {
+	if memberID, _ := c.Locals("memberId").(string); memberID != "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden: only the account owner can do this"})
+	}
 	return c.Next()
 }
 
+// RequireOrgPermission lets the organizer through, and team members only if
+// they were given perm. It runs after RequireAuth.
+func RequireOrgPermission(perm string) fiber.Handler {
+	return teamAccess(perm, "", false)
+}
+
+// RequireOrgListing is RequireOrgPermission for routes on one listing: a
+// member restricted to some listings must have the one named by param. An
+// empty perm only checks the listing.
+func RequireOrgListing(perm, param string) fiber.Handler {
+	return teamAccess(perm, param, false)
+}
+
+// RequireOrgAllListings is RequireOrgPermission for routes that span every
+// listing, such as creating one or the revenue figures, which members
+// restricted to some listings cannot use.
+func RequireOrgAllListings(perm string) fiber.Handler {
+	return teamAccess(perm, "", true)
+}
+
+func teamAccess(perm, param string, allListings bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if memberID, _ := c.Locals("memberId").(string); memberID == "" {
+			return c.Next()
+		}
+		perms, _ := c.Locals("teamPermissions").([]string)
+		if perm != "" && !organizersvc.HasTeamPermission(perms, perm) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":      "forbidden: missing permission " + perm,
+				"permission": perm,
+			})
+		}
+		scope, _ := c.Locals("listingScope").([]string)
+		if allListings && len(scope) > 0 {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden: you only have access to some listings"})
+		}
+		if param != "" && !organizersvc.InScope(scope, c.Params(param)) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "forbidden: you do not have access to this listing"})
+		}
+		return c.Next()
+	}
+}
+
 func RequireAdmin(c *fiber.Ctx) error {
 	role, ok := c.Locals("role").(string)
 	if !ok || role != "admin" {
@@ -63,32 +156,86 @@ func RequireSelfOrAdmin(c *fiber.Ctx) error {
 	return c.Next()
 }
 
-type UserClaims struct {
-	UserID string `json:"userId"`
-	Phone  string `json:"phone"`
-	jwt.RegisteredClaims
-}
-
+// RequireUserAuth is RequireAuth for users.
 func RequireUserAuth(c *fiber.Ctx) error {
-	tokenStr := c.Cookies("ticpin_user_token")
-	if tokenStr == "" {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: missing user token"})
+	if ok, err := authUser(c); !ok {
+		return err
 	}
+	return c.Next()
+}
 
-	claims := &UserClaims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fiber.ErrUnauthorized
+// authUser is authOrganizer for users.
+func authUser(c *fiber.Ctx) (bool, error) {
+	claims, err := config.ParseUserToken(c.Cookies("ticpin_user_token"))
+	if err == nil {
+		version, checkErr := sessionsvc.Check(claims.SessionID)
+		if checkErr == sessionsvc.ErrRevoked {
+			config.ClearUserAuthCookies(c)
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: session has been revoked"})
+		}
+		if checkErr == nil && version != claims.ClaimsVersion {
+			fresh, err := sessionsvc.ReissueUser(c, claims.SessionID)
+			if err == sessionsvc.ErrRevoked {
+				config.ClearUserAuthCookies(c)
+				return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: session has been revoked"})
+			}
+			if err == nil {
+				claims = fresh
+			}
+		}
+	} else {
+		if c.Cookies("ticpin_user_token") == "" && c.Cookies("ticpin_user_refresh") == "" {
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: missing user token"})
+		}
+		claims, err = sessionsvc.RefreshUser(c)
+		if err != nil {
+			config.ClearUserAuthCookies(c)
+			return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: invalid or expired user token"})
 		}
-		return jwtSecret(), nil
-	})
-
-	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized: invalid or expired user token"})
 	}
 
 	c.Locals("userId", claims.UserID)
 	c.Locals("phone", claims.Phone)
+	c.Locals("sessionId", claims.SessionID)
+	return true, nil
+}
+
+// RequireAccount accepts a user, organizer or admin, for routes shared by
+// all of them. A client signed in as more than one picks with the
+// X-Account-Type header, or the as query parameter where headers cannot be
+// set such as EventSource; otherwise an organizer token wins. It sets
+// accountType to "user", "organizer" or "admin". Nothing behind it is
+// written to the audit log.
+func RequireAccount(c *fiber.Ctx) error {
+	accountType := c.Get("X-Account-Type")
+	if accountType == "" {
+		accountType = c.Query("as")
+	}
+	if accountType == "" {
+		accountType = "user"
+		if c.Cookies("ticpin_token") != "" || c.Cookies("ticpin_refresh") != "" {
+			accountType = "organizer"
+		}
+	}
+
+	switch accountType {
+	case "user":
+		if ok, err := authUser(c); !ok {
+			return err
+		}
+	case "organizer", "admin":
+		if ok, err := authOrganizer(c); !ok {
+			return err
+		}
+		accountType = "organizer"
+		if role, _ := c.Locals("role").(string); role == "admin" {
+			accountType = "admin"
+		}
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid account type"})
+	}
+
+	c.Locals("accountType", accountType)
 	return c.Next()
 }
 
@@ -125,3 +272,51 @@ func RequireCategoryApproval(category string) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequirePermission lets an admin through only if one of their roles grants
+// perm. It runs after RequireAuth and RequireAdmin.
+func RequirePermission(perm string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		email, _ := c.Locals("email").(string)
+		perms, err := adminsvc.PermissionsFor(email)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "failed to load permissions"})
+		}
+		if !adminsvc.HasPermission(perms, perm) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":      "forbidden: missing permission " + perm,
+				"permission": perm,
+			})
+		}
+		if err := requireAdminMFA(c, email); err != nil {
+			return err
+		}
+		c.Locals("permissions", perms)
+		return c.Next()
+	}
+}
+
+// requireAdminMFA stops admins whose session has not passed two-factor
+// authentication, or who must set it up first.
+func requireAdminMFA(c *fiber.Ctx, email string) error {
+	state, err := adminsvc.MFAStateFor(email)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to check two-factor authentication"})
+	}
+	if !state.Required {
+		return nil
+	}
+	if !state.Enrolled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":              "two-factor authentication setup required",
+			"mfa_setup_required": true,
+		})
+	}
+	if mfa, _ := c.Locals("mfa").(bool); !mfa {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":        "two-factor verification required",
+			"mfa_required": true,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireCronSecret admits the scheduler that runs background jobs. Vercel
+// cron sends CRON_SECRET as a bearer token; the routes stay shut while it
+// is unset.
+func RequireCronSecret(c *fiber.Ctx) error {
+	secret := os.Getenv("CRON_SECRET")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(c.Get("Authorization")), []byte("Bearer "+secret)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	return c.Next()
+}
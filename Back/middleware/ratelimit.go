@@ -171,10 +171,17 @@ func RateLimitByPath(c *fiber.Ctx) error {
 
 	var category string
 	switch {
-	case path == "/api/organizer/login" ||
+	case path == "/api/admin/login" ||
+		path == "/api/admin/login/verify" ||
+		path == "/api/admin/login/phone-otp" ||
+		path == "/api/organizer/login" ||
 		path == "/api/organizer/verify-otp" ||
 		path == "/api/organizer/google-auth" ||
+		path == "/api/organizer/refresh" ||
+		path == "/api/organizer/team/send-otp" ||
+		path == "/api/organizer/team/verify-otp" ||
 		path == "/api/user/login" ||
+		path == "/api/user/refresh" ||
 		path == "/api/user/verify-otp":
 		category = "auth"
 	case path == "/api/play/book" ||
@@ -13,6 +13,58 @@ type Admin struct {
 	Phone     string             `bson:"phone" json:"phone"`
 	Name      string             `bson:"name" json:"name"`
 	IsSuper   bool               `bson:"isSuper" json:"isSuper"`
+	Roles     []string           `bson:"roles,omitempty" json:"roles"`
 	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+
+	// Two-factor authentication. Secrets are stored encrypted and recovery
+	// codes hashed.
+	TOTPEnabled       bool       `bson:"totp_enabled,omitempty" json:"totpEnabled"`
+	TOTPEnabledAt     *time.Time `bson:"totp_enabled_at,omitempty" json:"totpEnabledAt,omitempty"`
+	TOTPSecret        string     `bson:"totp_secret,omitempty" json:"-"`
+	TOTPPendingSecret string     `bson:"totp_pending_secret,omitempty" json:"-"`
+	TOTPLastStep      int64      `bson:"totp_last_step,omitempty" json:"-"`
+	RecoveryCodes     []string   `bson:"recovery_codes,omitempty" json:"-"`
+
+	// Login hardening
+	FailedLogins int        `bson:"failed_logins,omitempty" json:"-"`
+	LockedUntil  *time.Time `bson:"locked_until,omitempty" json:"lockedUntil,omitempty"`
+	KnownDevices []string   `bson:"known_devices,omitempty" json:"-"`
+	LastLoginAt  *time.Time `bson:"last_login_at,omitempty" json:"lastLoginAt,omitempty"`
+}
+
+// AdminLoginEvent is one admin sign-in attempt, kept for the audit trail.
+type AdminLoginEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email     string             `bson:"email" json:"email"`
+	Method    string             `bson:"method" json:"method"`   // password | phone | totp | recovery_code
+	Outcome   string             `bson:"outcome" json:"outcome"` // success | mfa_required | invalid_credentials | invalid_code | locked | no_access
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	Device    string             `bson:"device" json:"device"`
+	NewDevice bool               `bson:"new_device,omitempty" json:"new_device,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AdminSecuritySettings is the single document of admin wide security
+// settings.
+type AdminSecuritySettings struct {
+	ID         string    `bson:"_id" json:"-"`
+	Require2FA bool      `bson:"require_2fa" json:"require_2fa"`
+	UpdatedBy  string    `bson:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt  time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// AdminRole is a named set of admin permissions. System roles are defined
+// in code and kept in sync at startup; other roles are made by super admins.
+type AdminRole struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name        string             `bson:"name" json:"name"`
+	Label       string             `bson:"label" json:"label"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Permissions []string           `bson:"permissions" json:"permissions"`
+	System      bool               `bson:"system" json:"system"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLog records one change made by an admin or organizer: who made it,
+// to what, and the fields it changed.
+type AuditLog struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ActorType  string             `bson:"actor_type" json:"actor_type"` // admin | organizer | organizer_member
+	ActorID    string             `bson:"actor_id" json:"actor_id"`
+	ActorEmail string             `bson:"actor_email" json:"actor_email"`
+	MemberID   string             `bson:"member_id,omitempty" json:"member_id,omitempty"`
+	Action     string             `bson:"action" json:"action"` // e.g. organizers.update, events.status
+	Method     string             `bson:"method" json:"method"`
+	Route      string             `bson:"route" json:"route"`
+	Path       string             `bson:"path" json:"path"`
+	Entity     string             `bson:"entity" json:"entity"`
+	EntityID   string             `bson:"entity_id,omitempty" json:"entity_id,omitempty"`
+	Changes    []AuditChange      `bson:"changes,omitempty" json:"changes,omitempty"`
+
+	// Whole documents are kept only when one is created or deleted
+	Before map[string]interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After  map[string]interface{} `bson:"after,omitempty" json:"after,omitempty"`
+
+	Status    int       `bson:"status" json:"status"`
+	IP        string    `bson:"ip" json:"ip"`
+	UserAgent string    `bson:"user_agent" json:"user_agent"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// AuditChange is one field of an audited document, before and after.
+type AuditChange struct {
+	Field string      `bson:"field" json:"field"`
+	From  interface{} `bson:"from" json:"from"`
+	To    interface{} `bson:"to" json:"to"`
+}
@@ -25,9 +25,11 @@ type Booking struct {
 	Pincode        string             `bson:"pincode,omitempty" json:"pincode,omitempty"`
 	Nationality    string             `bson:"nationality,omitempty" json:"nationality,omitempty"`
 	EventID        primitive.ObjectID `bson:"event_id" json:"event_id"`
+	ShowtimeID     primitive.ObjectID `bson:"showtime_id,omitempty" json:"showtime_id,omitempty"`
 	OrganizerID    primitive.ObjectID `bson:"organizer_id" json:"organizer_id"`
 	EventName      string             `bson:"event_name" json:"event_name"`
 	Tickets        []BookingTicket    `bson:"tickets" json:"tickets"`
+	SeatIDs        []string           `bson:"seat_ids,omitempty" json:"seat_ids,omitempty"`
 	OrderAmount    float64            `bson:"order_amount" json:"order_amount"`
 	BookingFee     float64            `bson:"booking_fee" json:"booking_fee"`
 	DiscountAmount float64            `bson:"discount_amount" json:"discount_amount"`
@@ -35,11 +37,28 @@ type Booking struct {
 	OfferID        primitive.ObjectID `bson:"offer_id,omitempty" json:"offer_id,omitempty"`
 	OrderID        string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
 	GrandTotal     float64            `bson:"grand_total" json:"grand_total"`
+	WalletAmount   float64            `bson:"wallet_amount,omitempty" json:"wallet_amount,omitempty"` // part of GrandTotal paid from the wallet
 	PaymentID      string             `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
 	PaymentGateway string             `bson:"payment_gateway,omitempty" json:"payment_gateway,omitempty"`
 	Status         string             `bson:"status" json:"status"`
 	BookedAt       time.Time          `bson:"booked_at" json:"booked_at"`
 	TicpassApplied bool               `bson:"ticpass_applied,omitempty" json:"ticpass_applied,omitempty"`
+	LockKey        string             `bson:"lock_key,omitempty" json:"lock_key,omitempty"`
+	HolderNames    []string           `bson:"holder_names,omitempty" json:"holder_names,omitempty"`
+	Transfers      []TicketTransfer   `bson:"transfers,omitempty" json:"transfers,omitempty"`
+	CheckedInAt    *time.Time         `bson:"checked_in_at,omitempty" json:"checked_in_at,omitempty"`
+	CheckedInBy    string             `bson:"checked_in_by,omitempty" json:"checked_in_by,omitempty"`
+}
+
+// TicketTransfer records one ticket handed to a new holder through the
+// official transfer flow.
+type TicketTransfer struct {
+	HolderIndex   int       `bson:"holder_index" json:"holder_index"`
+	FromName      string    `bson:"from_name" json:"from_name"`
+	ToName        string    `bson:"to_name" json:"to_name"`
+	ToEmail       string    `bson:"to_email,omitempty" json:"to_email,omitempty"`
+	ToPhone       string    `bson:"to_phone,omitempty" json:"to_phone,omitempty"`
+	TransferredAt time.Time `bson:"transferred_at" json:"transferred_at"`
 }
 
 type PlayBooking struct {
@@ -68,12 +87,15 @@ type PlayBooking struct {
 	OfferID        primitive.ObjectID `bson:"offer_id,omitempty" json:"offer_id,omitempty"`
 	OrderID        string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
 	GrandTotal     float64            `bson:"grand_total" json:"grand_total"`
+	WalletAmount   float64            `bson:"wallet_amount,omitempty" json:"wallet_amount,omitempty"`
 	PaymentID      string             `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
 	PaymentGateway string             `bson:"payment_gateway,omitempty" json:"payment_gateway,omitempty"`
 	Status         string             `bson:"status" json:"status"`
 	BookedAt       time.Time          `bson:"booked_at" json:"booked_at"`
 	TicpassApplied bool               `bson:"ticpass_applied,omitempty" json:"ticpass_applied,omitempty"`
 	LockKey        string             `bson:"lock_key,omitempty" json:"lock_key,omitempty"`
+	CheckedInAt    *time.Time         `bson:"checked_in_at,omitempty" json:"checked_in_at,omitempty"`
+	CheckedInBy    string             `bson:"checked_in_by,omitempty" json:"checked_in_by,omitempty"`
 }
 
 type DiningBooking struct {
@@ -101,9 +123,12 @@ type DiningBooking struct {
 	OfferID        primitive.ObjectID `bson:"offer_id,omitempty" json:"offer_id,omitempty"`
 	OrderID        string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
 	GrandTotal     float64            `bson:"grand_total" json:"grand_total"`
+	WalletAmount   float64            `bson:"wallet_amount,omitempty" json:"wallet_amount,omitempty"`
 	PaymentID      string             `bson:"payment_id,omitempty" json:"payment_id,omitempty"`
 	PaymentGateway string             `bson:"payment_gateway,omitempty" json:"payment_gateway,omitempty"`
 	Status         string             `bson:"status" json:"status"`
 	BookedAt       time.Time          `bson:"booked_at" json:"booked_at"`
 	TicpassApplied bool               `bson:"ticpass_applied,omitempty" json:"ticpass_applied,omitempty"`
+	CheckedInAt    *time.Time         `bson:"checked_in_at,omitempty" json:"checked_in_at,omitempty"`
+	CheckedInBy    string             `bson:"checked_in_by,omitempty" json:"checked_in_by,omitempty"`
 }
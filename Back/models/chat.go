@@ -7,18 +7,19 @@ import (
 )
 
 type ChatMessage struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	SessionID string             `json:"sessionId" bson:"session_id"`
-	UserID    string             `json:"userId" bson:"user_id,omitempty"`
-	UserEmail string             `json:"userEmail" bson:"user_email,omitempty"`
-	UserType  string             `json:"userType" bson:"user_type"`
-	Category  string             `json:"category" bson:"category"`
-	Message   string             `json:"message" bson:"message"`
-	Sender    string             `json:"sender" bson:"sender"`
-	FileUrl   string             `json:"fileUrl" bson:"file_url,omitempty"`
-	FileType  string             `json:"fileType" bson:"file_type,omitempty"`
-	IsRead    bool               `json:"isRead" bson:"is_read"`
-	CreatedAt time.Time          `json:"createdAt" bson:"created_at"`
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	SessionID  string             `json:"sessionId" bson:"session_id"`
+	UserID     string             `json:"userId" bson:"user_id,omitempty"`
+	UserEmail  string             `json:"userEmail" bson:"user_email,omitempty"`
+	UserType   string             `json:"userType" bson:"user_type"`
+	Category   string             `json:"category" bson:"category"`
+	Message    string             `json:"message" bson:"message"`
+	Sender     string             `json:"sender" bson:"sender"`
+	AgentEmail string             `json:"agentEmail,omitempty" bson:"agent_email,omitempty"`
+	FileUrl    string             `json:"fileUrl" bson:"file_url,omitempty"`
+	FileType   string             `json:"fileType" bson:"file_type,omitempty"`
+	IsRead     bool               `json:"isRead" bson:"is_read"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"created_at"`
 }
 
 type ChatSession struct {
@@ -34,6 +35,19 @@ type ChatSession struct {
 	CreatedAt   time.Time          `json:"createdAt" bson:"created_at"`
 	UpdatedAt   time.Time          `json:"updatedAt" bson:"updated_at"`
 	ClosedAt    *time.Time         `json:"closedAt,omitempty" bson:"closed_at,omitempty"`
+	ClosedBy    string             `json:"closedBy,omitempty" bson:"closed_by,omitempty"`
+
+	// AssignedAgent is the email of the support agent handling the session.
+	// Assignments keeps every hand-over.
+	AssignedAgent string           `json:"assignedAgent,omitempty" bson:"assigned_agent,omitempty"`
+	AssignedAt    *time.Time       `json:"assignedAt,omitempty" bson:"assigned_at,omitempty"`
+	Assignments   []ChatAssignment `json:"assignments,omitempty" bson:"assignments,omitempty"`
+}
+
+type ChatAssignment struct {
+	AgentEmail string    `json:"agentEmail" bson:"agent_email"`
+	AssignedBy string    `json:"assignedBy" bson:"assigned_by"`
+	AssignedAt time.Time `json:"assignedAt" bson:"assigned_at"`
 }
 
 type ChatQuestion struct {
@@ -6,6 +6,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// CouponUsage is how usage was recorded before coupon_redemptions existed.
+// Entries on older coupons still count towards their limits.
 type CouponUsage struct {
 	UserID    string    `bson:"user_id" json:"user_id"`
 	UserEmail string    `bson:"user_email" json:"user_email"`
@@ -14,6 +16,24 @@ type CouponUsage struct {
 	Amount    float64   `bson:"amount" json:"amount"`
 }
 
+// CouponRules narrows when a coupon applies. Zero values leave a rule off.
+// Weekdays use 0 for Sunday and the time window is "HH:MM" in IST; a window
+// ending before it starts runs past midnight.
+type CouponRules struct {
+	MinOrderAmount   float64              `bson:"min_order_amount,omitempty" json:"min_order_amount,omitempty"`
+	MaxDiscount      float64              `bson:"max_discount,omitempty" json:"max_discount,omitempty"`
+	UsesPerUser      int                  `bson:"uses_per_user,omitempty" json:"uses_per_user,omitempty"`
+	FirstBookingOnly bool                 `bson:"first_booking_only,omitempty" json:"first_booking_only,omitempty"`
+	TargetIDs        []primitive.ObjectID `bson:"target_ids,omitempty" json:"target_ids,omitempty"` // event, play or dining IDs
+	OrganizerIDs     []primitive.ObjectID `bson:"organizer_ids,omitempty" json:"organizer_ids,omitempty"`
+	Cities           []string             `bson:"cities,omitempty" json:"cities,omitempty"`
+	Weekdays         []int                `bson:"weekdays,omitempty" json:"weekdays,omitempty"`
+	TimeFrom         string               `bson:"time_from,omitempty" json:"time_from,omitempty"`
+	TimeUntil        string               `bson:"time_until,omitempty" json:"time_until,omitempty"`
+	ExcludeOffers    bool                 `bson:"exclude_offers,omitempty" json:"exclude_offers,omitempty"`
+	ExcludeTicpass   bool                 `bson:"exclude_ticpass,omitempty" json:"exclude_ticpass,omitempty"`
+}
+
 type Coupon struct {
 	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
 	Code          string               `bson:"code" json:"code" validate:"required"`
@@ -29,5 +49,53 @@ type Coupon struct {
 	MaxUses       int                  `bson:"max_uses" json:"max_uses"`
 	UsedCount     int                  `bson:"used_count" json:"used_count"`
 	IsActive      bool                 `bson:"is_active" json:"is_active"`
+	Rules         *CouponRules         `bson:"rules,omitempty" json:"rules,omitempty"`
+	CampaignID    primitive.ObjectID   `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"`
 	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
 }
+
+// CouponCampaign groups generated single-use codes that share one discount
+// and rule set, e.g. codes handed to a partner. Each code is its own Coupon
+// carrying the campaign ID.
+type CouponCampaign struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name          string             `bson:"name" json:"name"`
+	Partner       string             `bson:"partner,omitempty" json:"partner,omitempty"`
+	Description   string             `bson:"description,omitempty" json:"description,omitempty"`
+	Category      string             `bson:"category" json:"category"`
+	DiscountType  string             `bson:"discount_type" json:"discount_type"`
+	DiscountValue float64            `bson:"discount_value" json:"discount_value"`
+	ValidFrom     time.Time          `bson:"valid_from" json:"valid_from"`
+	ValidUntil    time.Time          `bson:"valid_until" json:"valid_until"`
+	Rules         *CouponRules       `bson:"rules,omitempty" json:"rules,omitempty"`
+	Prefix        string             `bson:"prefix" json:"prefix"`
+	Alphabet      string             `bson:"alphabet" json:"alphabet"`
+	CodeLength    int                `bson:"code_length" json:"code_length"` // random part, excluding the prefix
+	IssuedCount   int                `bson:"issued_count" json:"issued_count"`
+	IsActive      bool               `bson:"is_active" json:"is_active"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// CouponRedemption is one use of a coupon. It is reserved when the booking
+// is staged, committed once payment lands and released on failure,
+// cancellation or when the reservation expires unpaid.
+type CouponRedemption struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	CouponID      primitive.ObjectID `bson:"coupon_id" json:"coupon_id"`
+	CampaignID    primitive.ObjectID `bson:"campaign_id,omitempty" json:"campaign_id,omitempty"`
+	Code          string             `bson:"code" json:"code"`
+	Category      string             `bson:"category" json:"category"`
+	UserID        string             `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	UserEmail     string             `bson:"user_email,omitempty" json:"user_email,omitempty"`
+	BookingID     string             `bson:"booking_id,omitempty" json:"booking_id,omitempty"`
+	OrderID       string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	Discount      float64            `bson:"discount" json:"discount"`
+	OrderAmount   float64            `bson:"order_amount" json:"order_amount"`
+	Status        string             `bson:"status" json:"status"` // "reserved", "committed", "released"
+	ReleaseReason string             `bson:"release_reason,omitempty" json:"release_reason,omitempty"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+	CommittedAt   *time.Time         `bson:"committed_at,omitempty" json:"committed_at,omitempty"`
+	ReleasedAt    *time.Time         `bson:"released_at,omitempty" json:"released_at,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -45,4 +45,6 @@ type Dining struct {
 	Status             string             `bson:"status" json:"status"`
 	CreatedAt          time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt          time.Time          `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt          *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy          string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
@@ -43,11 +43,72 @@ type Artist struct {
 }
 
 type TicketCategory struct {
-	Name     string  `bson:"name" json:"name"`
-	Price    float64 `bson:"price" json:"price"`
-	Capacity int     `bson:"capacity" json:"capacity"`
-	ImageURL string  `bson:"image_url" json:"image_url"`
-	HasImage bool    `bson:"has_image" json:"has_image"`
+	Name         string      `bson:"name" json:"name"`
+	Price        float64     `bson:"price" json:"price"`
+	Capacity     int         `bson:"capacity" json:"capacity"`
+	ImageURL     string      `bson:"image_url" json:"image_url"`
+	HasImage     bool        `bson:"has_image" json:"has_image"`
+	Tiers        []PriceTier `bson:"tiers,omitempty" json:"tiers,omitempty"`
+	SaleStartsAt *time.Time  `bson:"sale_starts_at,omitempty" json:"sale_starts_at,omitempty"`
+	SaleEndsAt   *time.Time  `bson:"sale_ends_at,omitempty" json:"sale_ends_at,omitempty"`
+	MinPerOrder  int         `bson:"min_per_order,omitempty" json:"min_per_order,omitempty"`
+	MaxPerOrder  int         `bson:"max_per_order,omitempty" json:"max_per_order,omitempty"`
+}
+
+// PurchaseRules limits how many tickets a single buyer can hold for an
+// event. A zero limit means no limit. CategoryMax applies per account and
+// per phone number. With OfficialTransferOnly, named tickets can be passed
+// on through the transfer endpoint and in no other way.
+type PurchaseRules struct {
+	MaxPerUser           int            `bson:"max_per_user,omitempty" json:"max_per_user,omitempty"`
+	MaxPerPhone          int            `bson:"max_per_phone,omitempty" json:"max_per_phone,omitempty"`
+	CategoryMax          map[string]int `bson:"category_max,omitempty" json:"category_max,omitempty"`
+	RequireHolderNames   bool           `bson:"require_holder_names,omitempty" json:"require_holder_names,omitempty"`
+	OfficialTransferOnly bool           `bson:"official_transfer_only,omitempty" json:"official_transfer_only,omitempty"`
+}
+
+// PriceTier is one pricing phase of a ticket category, e.g. early bird.
+// Tiers apply in order; a tier closes when its window ends or once its
+// quantity has sold (0 means unlimited).
+type PriceTier struct {
+	Name     string     `bson:"name" json:"name"`
+	Price    float64    `bson:"price" json:"price"`
+	Quantity int        `bson:"quantity,omitempty" json:"quantity,omitempty"`
+	StartsAt *time.Time `bson:"starts_at,omitempty" json:"starts_at,omitempty"`
+	EndsAt   *time.Time `bson:"ends_at,omitempty" json:"ends_at,omitempty"`
+}
+
+type Seat struct {
+	ID       string `bson:"id" json:"id"`
+	Number   string `bson:"number" json:"number"`
+	Category string `bson:"category" json:"category"`
+	Blocked  bool   `bson:"blocked" json:"blocked"`
+}
+
+type SeatRow struct {
+	Label string `bson:"label" json:"label"`
+	Seats []Seat `bson:"seats" json:"seats"`
+}
+
+type SeatSection struct {
+	Name string    `bson:"name" json:"name"`
+	Rows []SeatRow `bson:"rows" json:"rows"`
+}
+
+// SeatMap describes the venue layout for reserved-seating events. Events
+// without a seat map are sold as general admission.
+type SeatMap struct {
+	Sections []SeatSection `bson:"sections" json:"sections"`
+}
+
+// Showtime is a single performance of an event. Events with showtimes sell
+// inventory per showtime; Event.Date/Time mirror the earliest active show.
+type Showtime struct {
+	ID               primitive.ObjectID `bson:"id" json:"id"`
+	Date             time.Time          `bson:"date" json:"date"`
+	Time             string             `bson:"time" json:"time"`
+	TicketCategories []TicketCategory   `bson:"ticket_categories" json:"ticket_categories"`
+	Status           string             `bson:"status" json:"status"`
 }
 
 type PaymentDetails struct {
@@ -86,6 +147,9 @@ type Event struct {
 	ArtistImageURL     string             `bson:"artist_image_url" json:"artist_image_url"`
 	Artists            []Artist           `bson:"artists" json:"artists"`
 	TicketCategories   []TicketCategory   `bson:"ticket_categories" json:"ticket_categories"`
+	SeatMap            *SeatMap           `bson:"seat_map,omitempty" json:"seat_map,omitempty"`
+	Showtimes          []Showtime         `bson:"showtimes,omitempty" json:"showtimes,omitempty"`
+	PurchaseRules      *PurchaseRules     `bson:"purchase_rules,omitempty" json:"purchase_rules,omitempty"`
 	TicketsNeededFor   string             `bson:"tickets_needed_for" json:"tickets_needed_for"`
 	PriceStartsFrom    float64            `bson:"price_starts_from" json:"price_starts_from"`
 	Terms              string             `bson:"terms" json:"terms"`
@@ -95,4 +159,6 @@ type Event struct {
 	Status             string             `bson:"status" json:"status"`
 	CreatedAt          time.Time          `bson:"createdAt" json:"createdAt"`
 	UpdatedAt          time.Time          `bson:"updatedAt" json:"updatedAt"`
+	DeletedAt          *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy          string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
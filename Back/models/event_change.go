@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventChange records an organizer-initiated cancellation or reschedule of an
+// event (or a single showtime) and tracks the bulk refund it triggers.
+type EventChange struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventID          primitive.ObjectID `bson:"event_id" json:"event_id"`
+	ShowtimeID       primitive.ObjectID `bson:"showtime_id,omitempty" json:"showtime_id,omitempty"`
+	OrganizerID      primitive.ObjectID `bson:"organizer_id" json:"organizer_id"`
+	Type             string             `bson:"type" json:"type"` // "cancel", "reschedule"
+	Reason           string             `bson:"reason" json:"reason"`
+	OldDate          time.Time          `bson:"old_date" json:"old_date"`
+	OldTime          string             `bson:"old_time" json:"old_time"`
+	NewDate          time.Time          `bson:"new_date,omitempty" json:"new_date,omitempty"`
+	NewTime          string             `bson:"new_time,omitempty" json:"new_time,omitempty"`
+	RefundWindowEnds time.Time          `bson:"refund_window_ends,omitempty" json:"refund_window_ends,omitempty"`
+	Status           string             `bson:"status" json:"status"` // "processing", "open", "completed", "completed_with_errors"
+	TotalBookings    int                `bson:"total_bookings" json:"total_bookings"`
+	Refunded         int                `bson:"refunded" json:"refunded"`
+	Failed           int                `bson:"failed" json:"failed"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// EventChangeBooking is the per-booking progress record for an EventChange.
+type EventChangeBooking struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChangeID      primitive.ObjectID `bson:"change_id" json:"change_id"`
+	BookingID     primitive.ObjectID `bson:"booking_id" json:"booking_id"`
+	BookingRef    string             `bson:"booking_ref" json:"booking_ref"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	UserEmail     string             `bson:"user_email" json:"user_email"`
+	PaymentID     string             `bson:"payment_id" json:"payment_id"`
+	Amount        float64            `bson:"amount" json:"amount"`
+	Response      string             `bson:"response,omitempty" json:"response,omitempty"`           // reschedule: "pending", "accepted", "refund_requested"
+	RefundStatus  string             `bson:"refund_status,omitempty" json:"refund_status,omitempty"` // "pending", "processing", "refunded", "failed", "skipped"
+	RefundID      string             `bson:"refund_id,omitempty" json:"refund_id,omitempty"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	ClaimedAt     time.Time          `bson:"claimed_at,omitempty" json:"-"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+}
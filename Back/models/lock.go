@@ -8,7 +8,7 @@ import (
 
 type SlotLock struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	LockKey     string             `bson:"lock_key" json:"lock_key"` // user browser session ID
+	LockKey     string             `bson:"lock_key" json:"lock_key"` // ID of the signed-in user holding the lock
 	Type        string             `bson:"type" json:"type"`         // "play", "event", "dining"
 	ReferenceID primitive.ObjectID `bson:"reference_id" json:"reference_id"`
 
@@ -26,7 +26,7 @@ type SlotLock struct {
 }
 
 type LockRequest struct {
-	LockKey     string `json:"lock_key" validate:"required"`
+	LockKey     string `json:"-"` // set from the signed-in user
 	Type        string `json:"type" validate:"required"`
 	ReferenceID string `json:"reference_id" validate:"required"`
 	Date        string `json:"date" validate:"required"`
@@ -35,7 +35,7 @@ type LockRequest struct {
 }
 
 type UnlockRequest struct {
-	LockKey     string `json:"lock_key" validate:"required"`
+	LockKey     string `json:"-"` // set from the signed-in user
 	Type        string `json:"type" validate:"required"`
 	ReferenceID string `json:"reference_id" validate:"required"`
 	Date        string `json:"date" validate:"required"`
@@ -7,16 +7,58 @@ import (
 )
 
 type EventOffer struct {
-	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
-	Title         string               `bson:"title" json:"title" validate:"required"`
-	Description   string               `bson:"description" json:"description" validate:"required"`
-	Image         string               `bson:"image" json:"image"`
-	DiscountType  string               `bson:"discount_type" json:"discount_type" validate:"required,oneof=percent flat"`
-	DiscountValue float64              `bson:"discount_value" json:"discount_value" validate:"required,gt=0"`
-	AppliesTo     string               `bson:"applies_to" json:"applies_to"`
-	EntityIDs     []primitive.ObjectID `bson:"entity_ids" json:"entity_ids"`
-	ValidUntil    time.Time            `bson:"valid_until" json:"valid_until"`
-	IsActive      bool                 `bson:"is_active" json:"is_active"`
-	CreatedAt     time.Time            `bson:"created_at" json:"created_at"`
-	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	Title          string                 `bson:"title" json:"title" validate:"required"`
+	Description    string                 `bson:"description" json:"description" validate:"required"`
+	Image          string                 `bson:"image" json:"image"`
+	DiscountType   string                 `bson:"discount_type" json:"discount_type" validate:"required,oneof=percent flat"`
+	DiscountValue  float64                `bson:"discount_value" json:"discount_value" validate:"required,gt=0"`
+	AppliesTo      string                 `bson:"applies_to" json:"applies_to"`
+	EntityIDs      []primitive.ObjectID   `bson:"entity_ids" json:"entity_ids"`
+	StartsAt       time.Time              `bson:"starts_at,omitempty" json:"starts_at,omitempty"`
+	ValidUntil     time.Time              `bson:"valid_until" json:"valid_until"`
+	AutoApply      bool                   `bson:"auto_apply" json:"auto_apply"` // applied without the user picking it
+	MinOrderAmount float64                `bson:"min_order_amount,omitempty" json:"min_order_amount,omitempty"`
+	MaxDiscount    float64                `bson:"max_discount,omitempty" json:"max_discount,omitempty"` // cap for percent offers, 0 for none
+	DailyQuota     int                    `bson:"daily_quota,omitempty" json:"daily_quota,omitempty"`   // uses per IST day, 0 for no limit
+	Payment        *OfferPaymentCondition `bson:"payment,omitempty" json:"payment,omitempty"`
+	IsActive       bool                   `bson:"is_active" json:"is_active"`
+	CreatedAt      time.Time              `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time              `bson:"updated_at" json:"updated_at"`
+}
+
+// OfferPaymentCondition limits an offer to how the order is paid, e.g. UPI
+// only or cards from one bank's BIN range. Every non-empty list must match
+// the payment method the gateway reports in its webhook.
+type OfferPaymentCondition struct {
+	Methods      []string `bson:"methods,omitempty" json:"methods,omitempty"`             // "card", "upi", "netbanking", "wallet"
+	CardBINs     []string `bson:"card_bins,omitempty" json:"card_bins,omitempty"`         // first 6 digits of the card
+	CardNetworks []string `bson:"card_networks,omitempty" json:"card_networks,omitempty"` // "visa", "mastercard", "rupay"
+	Issuers      []string `bson:"issuers,omitempty" json:"issuers,omitempty"`             // issuing bank, e.g. "HDFC"
+}
+
+// OfferRedemption is one use of an offer by a booking. It is "reserved" at
+// checkout, "committed" once paid, "released" if the booking never goes
+// through and "reversed" when the payment did not meet the offer's
+// payment condition.
+type OfferRedemption struct {
+	ID            primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	OfferID       primitive.ObjectID     `bson:"offer_id" json:"offer_id"`
+	Category      string                 `bson:"category" json:"category"`
+	EntityID      string                 `bson:"entity_id" json:"entity_id"`
+	UserID        string                 `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	OrderID       string                 `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	BookingID     string                 `bson:"booking_id,omitempty" json:"booking_id,omitempty"`
+	Day           string                 `bson:"day" json:"day"` // IST date the quota is counted against
+	OrderAmount   float64                `bson:"order_amount" json:"order_amount"`
+	Discount      float64                `bson:"discount" json:"discount"`
+	AutoApplied   bool                   `bson:"auto_applied" json:"auto_applied"`
+	Payment       *OfferPaymentCondition `bson:"payment,omitempty" json:"payment,omitempty"` // condition as it was at checkout
+	Status        string                 `bson:"status" json:"status"`                       // "reserved", "committed", "released", "reversed"
+	ReleaseReason string                 `bson:"release_reason,omitempty" json:"release_reason,omitempty"`
+	PaymentMethod string                 `bson:"payment_method,omitempty" json:"payment_method,omitempty"` // as reported by the gateway
+	ExpiresAt     time.Time              `bson:"expires_at" json:"expires_at"`
+	CreatedAt     time.Time              `bson:"created_at" json:"created_at"`
+	CommittedAt   *time.Time             `bson:"committed_at,omitempty" json:"committed_at,omitempty"`
+	ReleasedAt    *time.Time             `bson:"released_at,omitempty" json:"released_at,omitempty"`
 }
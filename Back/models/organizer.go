@@ -24,6 +24,8 @@ type Organizer struct {
 	PANCardStatus     string             `bson:"pan_card_status" json:"pan_card_status"`
 	CreatedAt         time.Time          `bson:"createdAt" json:"createdAt"`
 	PANCardURL        string             `bson:"panCardUrl" json:"panCardUrl"`
+	DeletedAt         *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy         string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
 
 type OrganizerSetup struct {
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PhoneOTP is the pending login code for a phone number. Only a hash of
+// the code is stored.
+type PhoneOTP struct {
+	Phone       string     `bson:"_id"` // E.164
+	CodeHash    string     `bson:"code_hash"`
+	Attempts    int        `bson:"attempts"`
+	SendCount   int        `bson:"send_count"` // sends in the current window
+	WindowStart time.Time  `bson:"window_start"`
+	LastSentAt  time.Time  `bson:"last_sent_at"`
+	ExpiresAt   time.Time  `bson:"expires_at"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty"`
+	PurgeAt     time.Time  `bson:"purge_at"`
+}
@@ -26,16 +26,65 @@ type PassBenefits struct {
 }
 
 type RenewalRecord struct {
-	RenewedAt time.Time `bson:"renewed_at" json:"renewed_at"`
-	StartDate time.Time `bson:"start_date" json:"start_date"`
-	EndDate   time.Time `bson:"end_date" json:"end_date"`
-	PaymentID string    `bson:"payment_id" json:"payment_id"`
-	Price     float64   `bson:"price" json:"price"`
+	RenewedAt      time.Time `bson:"renewed_at" json:"renewed_at"`
+	StartDate      time.Time `bson:"start_date" json:"start_date"`
+	EndDate        time.Time `bson:"end_date" json:"end_date"`
+	PaymentID      string    `bson:"payment_id" json:"payment_id"`
+	Price          float64   `bson:"price" json:"price"`
+	Auto           bool      `bson:"auto,omitempty" json:"auto,omitempty"`
+	SubscriptionID string    `bson:"subscription_id,omitempty" json:"subscription_id,omitempty"`
+	GiftCode       string    `bson:"gift_code,omitempty" json:"gift_code,omitempty"`
+}
+
+// PassAutoRenew tracks the Razorpay subscription that renews a pass. While a
+// charge is being retried the pass stays usable until GraceEndsAt; GraceFrom
+// keeps the original expiry so the next term starts from it. Plan keeps the
+// terms the subscription was set up on, since Razorpay keeps charging the
+// same price after the plan is edited.
+type PassAutoRenew struct {
+	SubscriptionID string     `bson:"subscription_id" json:"subscription_id"`
+	RazorpayPlanID string     `bson:"razorpay_plan_id" json:"razorpay_plan_id"`
+	Status         string     `bson:"status" json:"status"` // "created", "active", "paused", "halted", "cancelled"
+	ShortURL       string     `bson:"short_url,omitempty" json:"short_url,omitempty"`
+	GraceFrom      *time.Time `bson:"grace_from,omitempty" json:"grace_from,omitempty"`
+	GraceEndsAt    *time.Time `bson:"grace_ends_at,omitempty" json:"grace_ends_at,omitempty"`
+	LastChargedAt  *time.Time `bson:"last_charged_at,omitempty" json:"last_charged_at,omitempty"`
+	Plan           *PassPlan  `bson:"plan,omitempty" json:"plan,omitempty"` // terms the subscription bills for
+	CreatedAt      time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time  `bson:"updated_at" json:"updated_at"`
+}
+
+// PassPlan is an admin-managed Ticpass tier (e.g. Silver, Gold). Each pass
+// keeps a copy of the plan it was sold under so later edits do not change
+// benefits mid-term.
+type PassPlan struct {
+	ID                    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code                  string             `bson:"code" json:"code"`
+	Name                  string             `bson:"name" json:"name"`
+	Description           string             `bson:"description,omitempty" json:"description,omitempty"`
+	Price                 float64            `bson:"price" json:"price"`
+	DurationMonths        int                `bson:"duration_months" json:"duration_months"`
+	TurfBookings          int                `bson:"turf_bookings" json:"turf_bookings"`
+	DiningVouchers        int                `bson:"dining_vouchers" json:"dining_vouchers"`
+	DiningVoucherValue    float64            `bson:"dining_voucher_value" json:"dining_voucher_value"`
+	EventsDiscountPercent float64            `bson:"events_discount_percent" json:"events_discount_percent"`
+	EventsDiscountCap     float64            `bson:"events_discount_cap,omitempty" json:"events_discount_cap,omitempty"`
+	PlayDiscountPercent   float64            `bson:"play_discount_percent" json:"play_discount_percent"`
+	PlayDiscountCap       float64            `bson:"play_discount_cap,omitempty" json:"play_discount_cap,omitempty"`
+	EligibleCities        []string           `bson:"eligible_cities,omitempty" json:"eligible_cities,omitempty"`
+	EligibleVerticals     []string           `bson:"eligible_verticals,omitempty" json:"eligible_verticals,omitempty"` // "events", "play", "dining"
+	RazorpayPlanID        string             `bson:"razorpay_plan_id,omitempty" json:"razorpay_plan_id,omitempty"`
+	IsDefault             bool               `bson:"is_default" json:"is_default"`
+	Active                bool               `bson:"active" json:"active"`
+	CreatedAt             time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt             time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
 type TicpinPass struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	PlanID    primitive.ObjectID `bson:"plan_id,omitempty" json:"plan_id,omitempty"`
+	Plan      *PassPlan          `bson:"plan,omitempty" json:"plan,omitempty"`
 	Phone     string             `bson:"phone" json:"phone"`
 	PaymentID string             `bson:"payment_id" json:"payment_id"`
 	OrderID   string             `bson:"order_id" json:"order_id"`
@@ -46,6 +95,54 @@ type TicpinPass struct {
 	EndDate   time.Time          `bson:"end_date" json:"end_date"`
 	Benefits  PassBenefits       `bson:"benefits" json:"benefits"`
 	Renewals  []RenewalRecord    `bson:"renewals" json:"renewals"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+	AutoRenew *PassAutoRenew     `bson:"auto_renew,omitempty" json:"auto_renew,omitempty"`
+	// ReminderSentFor is the expiry date the last renewal reminder was sent for.
+	ReminderSentFor *time.Time `bson:"reminder_sent_for,omitempty" json:"reminder_sent_for,omitempty"`
+	CreatedAt       time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt       time.Time  `bson:"updatedAt" json:"updatedAt"`
+}
+
+// PassLedgerEntry is one append-only movement of a pass benefit. Quantity is
+// negative for redemptions and positive for reversals; adjustments record
+// manual changes by admins. Term is the number of renewals the pass had
+// when the entry was written, so counters reset with each renewal.
+type PassLedgerEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PassID      primitive.ObjectID `bson:"pass_id" json:"pass_id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Term        int                `bson:"term" json:"term"`
+	Benefit     string             `bson:"benefit" json:"benefit"` // "turf", "dining"
+	Type        string             `bson:"type" json:"type"`       // "redeem", "reversal", "lapsed", "adjustment"
+	Quantity    int                `bson:"quantity" json:"quantity"`
+	Value       float64            `bson:"value,omitempty" json:"value,omitempty"`
+	BookingType string             `bson:"booking_type,omitempty" json:"booking_type,omitempty"`
+	BookingID   string             `bson:"booking_id,omitempty" json:"booking_id,omitempty"`
+	ReversalOf  primitive.ObjectID `bson:"reversal_of,omitempty" json:"reversal_of,omitempty"`
+	Note        string             `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// PassGift is a Ticpass bought for someone else. The buyer pays like any
+// pass order and the recipient redeems Code into their own pass before
+// ExpiresAt. Plan is the plan as it was when the gift was bought.
+type PassGift struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code           string             `bson:"code" json:"code"`
+	PlanID         primitive.ObjectID `bson:"plan_id,omitempty" json:"plan_id,omitempty"`
+	Plan           PassPlan           `bson:"plan" json:"plan"`
+	BuyerUserID    primitive.ObjectID `bson:"buyer_user_id" json:"buyer_user_id"`
+	BuyerPhone     string             `bson:"buyer_phone" json:"buyer_phone"`
+	RecipientName  string             `bson:"recipient_name,omitempty" json:"recipient_name,omitempty"`
+	RecipientPhone string             `bson:"recipient_phone,omitempty" json:"recipient_phone,omitempty"`
+	Message        string             `bson:"message,omitempty" json:"message,omitempty"`
+	Price          float64            `bson:"price" json:"price"`
+	PaymentID      string             `bson:"payment_id" json:"payment_id"`
+	OrderID        string             `bson:"order_id" json:"order_id"`
+	Status         string             `bson:"status" json:"status"` // "issued", "redeemed", "expired"
+	ExpiresAt      time.Time          `bson:"expires_at" json:"expires_at"`
+	RedeemedBy     primitive.ObjectID `bson:"redeemed_by,omitempty" json:"redeemed_by,omitempty"`
+	RedeemedPassID primitive.ObjectID `bson:"redeemed_pass_id,omitempty" json:"redeemed_pass_id,omitempty"`
+	Extended       bool               `bson:"extended,omitempty" json:"extended,omitempty"`
+	RedeemedAt     *time.Time         `bson:"redeemed_at,omitempty" json:"redeemed_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
 }
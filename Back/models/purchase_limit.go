@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PurchaseLimitHit is logged whenever a booking is refused because the buyer
+// would exceed one of the event's purchase rules.
+type PurchaseLimitHit struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventID   primitive.ObjectID `bson:"event_id" json:"event_id"`
+	UserID    string             `bson:"user_id" json:"user_id"`
+	UserPhone string             `bson:"user_phone" json:"user_phone"`
+	UserEmail string             `bson:"user_email" json:"user_email"`
+	Rule      string             `bson:"rule" json:"rule"` // "per_user", "per_phone", "category"
+	Category  string             `bson:"category,omitempty" json:"category,omitempty"`
+	Limit     int                `bson:"limit" json:"limit"`
+	Held      int                `bson:"held" json:"held"`
+	Requested int                `bson:"requested" json:"requested"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReferralRewardRule describes what one side of a referral earns. A
+// "coupon" reward issues a single-use coupon for the user; a
+// "pass_benefit" reward adds Quantity units of Benefit to their active pass;
+// a "wallet_credit" reward credits Amount to their wallet.
+type ReferralRewardRule struct {
+	Type          string  `bson:"type" json:"type"` // "coupon", "pass_benefit", "wallet_credit", "" for none
+	Category      string  `bson:"category,omitempty" json:"category,omitempty"`
+	DiscountType  string  `bson:"discount_type,omitempty" json:"discount_type,omitempty"`
+	DiscountValue float64 `bson:"discount_value,omitempty" json:"discount_value,omitempty"`
+	ValidDays     int     `bson:"valid_days,omitempty" json:"valid_days,omitempty"`
+	Benefit       string  `bson:"benefit,omitempty" json:"benefit,omitempty"` // "turf", "dining"
+	Quantity      int     `bson:"quantity,omitempty" json:"quantity,omitempty"`
+	Amount        float64 `bson:"amount,omitempty" json:"amount,omitempty"`
+}
+
+// ReferralSettings is the single admin-editable document that controls the
+// referral program.
+type ReferralSettings struct {
+	ID                  string             `bson:"_id" json:"-"`
+	Enabled             bool               `bson:"enabled" json:"enabled"`
+	ReferrerReward      ReferralRewardRule `bson:"referrer_reward" json:"referrer_reward"`
+	RefereeReward       ReferralRewardRule `bson:"referee_reward" json:"referee_reward"`
+	MinBookingAmount    float64            `bson:"min_booking_amount" json:"min_booking_amount"`
+	MaxReferralsPerUser int                `bson:"max_referrals_per_user" json:"max_referrals_per_user"` // 0 for no limit
+	QualifyWithinDays   int                `bson:"qualify_within_days" json:"qualify_within_days"`       // 0 for no limit
+	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ReferralGrant is the reward actually given to one side of a referral.
+// Pending grants are retried, e.g. a pass benefit for a user without an
+// active pass yet.
+type ReferralGrant struct {
+	Type       string     `bson:"type" json:"type"`
+	Status     string     `bson:"status" json:"status"` // "granted", "pending", "revoked"
+	CouponCode string     `bson:"coupon_code,omitempty" json:"coupon_code,omitempty"`
+	Benefit    string     `bson:"benefit,omitempty" json:"benefit,omitempty"`
+	Quantity   int        `bson:"quantity,omitempty" json:"quantity,omitempty"`
+	Amount     float64    `bson:"amount,omitempty" json:"amount,omitempty"`
+	Note       string     `bson:"note,omitempty" json:"note,omitempty"`
+	GrantedAt  *time.Time `bson:"granted_at,omitempty" json:"granted_at,omitempty"`
+}
+
+// Referral links a new user to the user whose code they signed up with.
+// It starts "pending" and becomes "rewarded" after the referee's first paid
+// booking, "expired" if that booking does not come in time, or "rejected"
+// by the fraud checks at signup. A rewarded referral whose booking is later
+// cancelled or refunded becomes "reversed".
+type Referral struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Code          string             `bson:"code" json:"code"`
+	ReferrerID    primitive.ObjectID `bson:"referrer_id" json:"referrer_id"`
+	ReferrerPhone string             `bson:"referrer_phone" json:"referrer_phone"`
+	RefereeID     primitive.ObjectID `bson:"referee_id" json:"referee_id"`
+	RefereePhone  string             `bson:"referee_phone" json:"referee_phone"`
+	DeviceID      string             `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	IP            string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	Status        string             `bson:"status" json:"status"`
+	RejectReason  string             `bson:"reject_reason,omitempty" json:"reject_reason,omitempty"`
+	BookingType   string             `bson:"booking_type,omitempty" json:"booking_type,omitempty"`
+	BookingID     string             `bson:"booking_id,omitempty" json:"booking_id,omitempty"`
+	BookingAmount float64            `bson:"booking_amount,omitempty" json:"booking_amount,omitempty"`
+	ReferrerGrant *ReferralGrant     `bson:"referrer_grant,omitempty" json:"referrer_grant,omitempty"`
+	RefereeGrant  *ReferralGrant     `bson:"referee_grant,omitempty" json:"referee_grant,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	QualifiedAt   *time.Time         `bson:"qualified_at,omitempty" json:"qualified_at,omitempty"`
+	ReversedAt    *time.Time         `bson:"reversed_at,omitempty" json:"reversed_at,omitempty"`
+	ReverseReason string             `bson:"reverse_reason,omitempty" json:"reverse_reason,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is a signed-in device of a user or organizer. Only hashes of the
+// refresh tokens are stored.
+type Session struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind            string             `bson:"kind" json:"kind"` // user | organizer | organizer_member
+	SubjectID       string             `bson:"subject_id" json:"subject_id"`
+	Vertical        string             `bson:"vertical,omitempty" json:"vertical,omitempty"`
+	RefreshHash     string             `bson:"refresh_hash" json:"-"`
+	PrevRefreshHash string             `bson:"prev_refresh_hash,omitempty" json:"-"`
+	ClaimsVersion   int                `bson:"claims_version" json:"-"`
+	DeviceID        string             `bson:"device_id,omitempty" json:"device_id,omitempty"`
+	Device          string             `bson:"device" json:"device"`
+	UserAgent       string             `bson:"user_agent" json:"user_agent"`
+	IP              string             `bson:"ip" json:"ip"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt      time.Time          `bson:"last_used_at" json:"last_used_at"`
+	RotatedAt       *time.Time         `bson:"rotated_at,omitempty" json:"-"`
+	ExpiresAt       time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt       *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokeReason    string             `bson:"revoke_reason,omitempty" json:"revoke_reason,omitempty"`
+	MFAVerifiedAt   *time.Time         `bson:"mfa_verified_at,omitempty" json:"mfa_verified_at,omitempty"`
+	Current         bool               `bson:"-" json:"current,omitempty"`
+}
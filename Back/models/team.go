@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OrganizerMember is a staff login on an organizer's team. Members sign in
+// with their own email and act for the owner within their permissions,
+// optionally only on the listings in ListingIDs (empty means all).
+type OrganizerMember struct {
+	ID            primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	OrganizerID   primitive.ObjectID   `bson:"organizer_id" json:"organizer_id"`
+	Email         string               `bson:"email" json:"email"`
+	Name          string               `bson:"name,omitempty" json:"name,omitempty"`
+	Permissions   []string             `bson:"permissions" json:"permissions"`
+	ListingIDs    []primitive.ObjectID `bson:"listing_ids,omitempty" json:"listing_ids,omitempty"`
+	Status        string               `bson:"status" json:"status"` // invited | active
+	OTPHash       string               `bson:"otp_hash,omitempty" json:"-"`
+	OTPExpiry     time.Time            `bson:"otp_expiry,omitempty" json:"-"`
+	OTPAttempts   int                  `bson:"otp_attempts,omitempty" json:"-"`
+	OTPSentAt     time.Time            `bson:"otp_sent_at,omitempty" json:"-"`
+	OTPSendCount  int                  `bson:"otp_send_count,omitempty" json:"-"` // codes sent since OTPWindowFrom
+	OTPWindowFrom time.Time            `bson:"otp_window_from,omitempty" json:"-"`
+	InvitedBy     string               `bson:"invited_by" json:"invited_by"`
+	InvitedAt     time.Time            `bson:"invited_at" json:"invited_at"`
+	JoinedAt      *time.Time           `bson:"joined_at,omitempty" json:"joined_at,omitempty"`
+	LastLoginAt   *time.Time           `bson:"last_login_at,omitempty" json:"last_login_at,omitempty"`
+	UpdatedAt     time.Time            `bson:"updated_at" json:"updated_at"`
+}
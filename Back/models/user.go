@@ -7,8 +7,12 @@ import (
 )
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name      string             `bson:"name" json:"name"`
-	Phone     string             `bson:"phone" json:"phone"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Phone        string             `bson:"phone" json:"phone"`
+	ReferralCode string             `bson:"referral_code,omitempty" json:"referral_code,omitempty"`
+	ReferredBy   primitive.ObjectID `bson:"referred_by,omitempty" json:"referred_by,omitempty"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+	DeletedAt    *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy    string             `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
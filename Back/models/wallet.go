@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Wallet is a user's Ticpin store credit. Balance is the sum of the unspent,
+// unexpired credits in the ledger.
+type Wallet struct {
+	UserID    primitive.ObjectID `bson:"_id" json:"user_id"`
+	Balance   float64            `bson:"balance" json:"balance"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// WalletDraw is the part of one credit a debit used up, so the debit can be
+// put back where it came from if it is released.
+type WalletDraw struct {
+	CreditID primitive.ObjectID `bson:"credit_id" json:"credit_id"`
+	Amount   float64            `bson:"amount" json:"amount"`
+}
+
+// WalletEntry is one append-only movement of store credit. Credits carry the
+// unspent Remaining and expire at ExpiresAt. Debits for bookings are "held"
+// until payment lands, then "settled", or "released" back to the wallet on
+// failure or cancellation. A hold released as expired is taken again
+// ("retaking") if its payment still arrives.
+type WalletEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type        string             `bson:"type" json:"type"`     // "credit", "debit"
+	Source      string             `bson:"source" json:"source"` // "refund", "referral", "admin", "booking", "expiry"
+	Amount      float64            `bson:"amount" json:"amount"`
+	Remaining   float64            `bson:"remaining,omitempty" json:"remaining,omitempty"`
+	Status      string             `bson:"status,omitempty" json:"status,omitempty"`
+	Reason      string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	BookingType string             `bson:"booking_type,omitempty" json:"booking_type,omitempty"`
+	BookingID   string             `bson:"booking_id,omitempty" json:"booking_id,omitempty"`
+	OrderID     string             `bson:"order_id,omitempty" json:"order_id,omitempty"`
+	Draws       []WalletDraw       `bson:"draws,omitempty" json:"-"`
+	CreatedBy   string             `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	ExpiresAt   *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	ReleasedAt  *time.Time         `bson:"released_at,omitempty" json:"released_at,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -3,6 +3,7 @@ package app
 import (
 	stdlog "log"
 	"os"
+	"strings"
 	"time"
 
 	"ticpin-backend/config"
@@ -10,6 +11,7 @@ import (
 	adminroutes "ticpin-backend/routes/admin"
 	bookingroutes "ticpin-backend/routes/booking"
 	"ticpin-backend/routes/cache"
+	cronroutes "ticpin-backend/routes/cron"
 	diningroutes "ticpin-backend/routes/dining"
 	eventroutes "ticpin-backend/routes/event"
 	mobileroutes "ticpin-backend/routes/mobile"
@@ -22,7 +24,9 @@ import (
 	playroutes "ticpin-backend/routes/play"
 	"ticpin-backend/routes/profile"
 	"ticpin-backend/routes/user"
+	adminsvc "ticpin-backend/services/admin"
 	"ticpin-backend/services/chat"
+	"ticpin-backend/services/jobs"
 	"ticpin-backend/worker"
 
 	"github.com/go-playground/validator/v10"
@@ -47,6 +51,10 @@ func InitApp() *fiber.App {
 	// Initialize dependencies
 	if err := config.ConnectDB(); err != nil {
 		stdlog.Println("MongoDB connection error:", err)
+	} else if err := adminsvc.EnsureDefaultRoles(); err != nil {
+		stdlog.Println("Admin roles setup error:", err)
+	} else if err := adminsvc.BackfillAdminRoles(); err != nil {
+		stdlog.Println("Admin roles backfill error:", err)
 	}
 
 	if err := config.InitCloudinary(); err != nil {
@@ -107,12 +115,18 @@ func InitApp() *fiber.App {
 	})
 
 	app.Use(fiberRecover.New())
-	app.Use(compress.New(compress.Config{Level: compress.LevelDefault}))
+	app.Use(compress.New(compress.Config{
+		// Compressing would buffer server-sent events
+		Next:  func(c *fiber.Ctx) bool { return strings.HasSuffix(c.Path(), "/stream") },
+		Level: compress.LevelDefault,
+	}))
 
-	// Background tasks only if NOT on Vercel
+	// Background tasks only if NOT on Vercel, where the jobs are run by
+	// the crons in vercel.json through /api/cron/:job
 	if os.Getenv("VERCEL") != "1" {
 		worker.Init(5, 100)
 		middleware.StartRateLimitCleanup()
+		jobs.Start()
 	}
 
 	app.Use(middleware.RateLimitByPath)
@@ -123,7 +137,7 @@ func InitApp() *fiber.App {
 	}
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     corsOrigins,
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Account-Type",
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
 		AllowCredentials: true,
 	}))
@@ -154,6 +168,7 @@ func InitApp() *fiber.App {
 	paymentroutes.PaymentRoutes(app)
 	chat.SetupRoutes(app)
 	cache.SetupCacheRoutes(app)
+	cronroutes.CronRoutes(app)
 
 	app.Get("/api/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "ok"})
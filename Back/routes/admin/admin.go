@@ -1,6 +1,7 @@
 package admin
 
 import (
+	adminaudit "ticpin-backend/controller/admin/audit"
 	adminauth "ticpin-backend/controller/admin/auth"
 	admincoupon "ticpin-backend/controller/admin/coupon"
 	adminlistings "ticpin-backend/controller/admin/listings"
@@ -9,75 +10,165 @@ import (
 	adminorgs "ticpin-backend/controller/admin/organizers"
 	panctrl "ticpin-backend/controller/admin/pan"
 	adminpass "ticpin-backend/controller/admin/pass"
+	adminreferral "ticpin-backend/controller/admin/referral"
+	adminroles "ticpin-backend/controller/admin/roles"
+	adminsecurity "ticpin-backend/controller/admin/security"
+	adminsessions "ticpin-backend/controller/admin/sessions"
 	adminstats "ticpin-backend/controller/admin/stats"
+	admintrash "ticpin-backend/controller/admin/trash"
 	adminusers "ticpin-backend/controller/admin/users"
+	adminwallet "ticpin-backend/controller/admin/wallet"
 	orgmedia "ticpin-backend/controller/organizer/media"
 	"ticpin-backend/middleware"
+	"ticpin-backend/services/chat"
+	trashsvc "ticpin-backend/services/trash"
 	"github.com/gofiber/fiber/v2"
 )
 
 func AdminRoutes(app *fiber.App) {
 	app.Post("/api/admin/login", adminauth.AdminLogin)
+	app.Post("/api/admin/login/verify", adminauth.VerifyAdminLogin)
+	app.Post("/api/admin/login/phone-otp", adminauth.SendAdminPhoneOTP)
 
 	admin := app.Group("/api/admin", middleware.RequireAuth, middleware.RequireAdmin)
 
-	admin.Get("/stats", adminstats.GetStats)
-
-	admin.Get("/organizers", adminorgs.ListOrganizers)
-	admin.Get("/organizers/:id", adminorgs.GetOrganizerDetail)
-	admin.Put("/organizers/:id/status", adminorgs.UpdateCategoryStatus)
-	admin.Put("/organizers/:id", adminorgs.UpdateOrganizer)
-	admin.Delete("/organizers/:id", adminorgs.DeleteOrganizer)
-
-	admin.Get("/events", adminlistings.ListAllEvents)
-	admin.Put("/events/:id/status", adminlistings.UpdateEventStatus)
-	admin.Put("/events/:id", adminlistings.UpdateEvent)
-	admin.Delete("/events/:id", adminlistings.DeleteEvent)
-
-	admin.Get("/dining", adminlistings.ListAllDining)
-	admin.Put("/dining/:id/status", adminlistings.UpdateDiningStatus)
-	admin.Put("/dining/:id", adminlistings.UpdateDining)
-	admin.Delete("/dining/:id", adminlistings.DeleteDining)
-
-	admin.Get("/play", adminlistings.ListAllPlay)
-	admin.Put("/play/:id/status", adminlistings.UpdatePlayStatus)
-	admin.Put("/play/:id", adminlistings.UpdatePlay)
-	admin.Delete("/play/:id", adminlistings.DeletePlay)
-
-	admin.Post("/coupons", admincoupon.CreateCoupon)
-	admin.Get("/coupons", admincoupon.ListCoupons)
-	admin.Put("/coupons/:id", admincoupon.UpdateCoupon)
-	admin.Delete("/coupons/:id", admincoupon.DeleteCoupon)
-	admin.Get("/users", admincoupon.ListUsers)
-	admin.Get("/users/:id", adminusers.GetUser)
-	admin.Get("/users/:id/details", adminusers.GetUserDetails)
-	admin.Get("/users/:id/stats", adminusers.GetUserStats)
-	admin.Get("/users/:id/bookings", adminusers.GetUserBookings)
-	admin.Put("/users/:id", adminusers.UpdateUser)
-	admin.Delete("/users/:id", adminusers.DeleteUser)
-
-	admin.Post("/offers", adminoffer.CreateOffer)
-	admin.Get("/offers", adminoffer.ListOffers)
-	admin.Put("/offers/:id", adminoffer.UpdateOffer)
-	admin.Delete("/offers/:id", adminoffer.DeleteOffer)
-
-	admin.Post("/notifications", adminnotification.SendNotification)
-	admin.Get("/notifications", adminnotification.ListNotifications)
+	admin.Get("/stats", middleware.RequirePermission("stats:read"), adminstats.GetStats)
+
+	admin.Get("/organizers", middleware.RequirePermission("organizers:read"), adminorgs.ListOrganizers)
+	admin.Get("/organizers/:id", middleware.RequirePermission("organizers:read"), adminorgs.GetOrganizerDetail)
+	admin.Put("/organizers/:id/status", middleware.RequirePermission("organizers:write"), adminorgs.UpdateCategoryStatus)
+	admin.Put("/organizers/:id", middleware.RequirePermission("organizers:write"), adminorgs.UpdateOrganizer)
+	admin.Delete("/organizers/:id", middleware.RequirePermission("organizers:delete"), adminorgs.DeleteOrganizer)
+	admin.Get("/organizers/:id/sessions", middleware.RequirePermission("sessions:read"), adminsessions.ListOrganizerSessions)
+	admin.Post("/organizers/:id/logout", middleware.RequirePermission("sessions:revoke"), adminsessions.ForceLogoutOrganizer)
+
+	admin.Get("/events", middleware.RequirePermission("listings:read"), adminlistings.ListAllEvents)
+	admin.Put("/events/:id/status", middleware.RequirePermission("listings:write"), adminlistings.UpdateEventStatus)
+	admin.Put("/events/:id", middleware.RequirePermission("listings:write"), adminlistings.UpdateEvent)
+	admin.Delete("/events/:id", middleware.RequirePermission("listings:delete"), adminlistings.DeleteEvent)
+
+	admin.Get("/dining", middleware.RequirePermission("listings:read"), adminlistings.ListAllDining)
+	admin.Put("/dining/:id/status", middleware.RequirePermission("listings:write"), adminlistings.UpdateDiningStatus)
+	admin.Put("/dining/:id", middleware.RequirePermission("listings:write"), adminlistings.UpdateDining)
+	admin.Delete("/dining/:id", middleware.RequirePermission("listings:delete"), adminlistings.DeleteDining)
+
+	admin.Get("/play", middleware.RequirePermission("listings:read"), adminlistings.ListAllPlay)
+	admin.Put("/play/:id/status", middleware.RequirePermission("listings:write"), adminlistings.UpdatePlayStatus)
+	admin.Put("/play/:id", middleware.RequirePermission("listings:write"), adminlistings.UpdatePlay)
+	admin.Delete("/play/:id", middleware.RequirePermission("listings:delete"), adminlistings.DeletePlay)
+
+	admin.Post("/coupons", middleware.RequirePermission("coupons:write"), admincoupon.CreateCoupon)
+	admin.Get("/coupons", middleware.RequirePermission("coupons:read"), admincoupon.ListCoupons)
+	admin.Put("/coupons/:id", middleware.RequirePermission("coupons:write"), admincoupon.UpdateCoupon)
+	admin.Delete("/coupons/:id", middleware.RequirePermission("coupons:write"), admincoupon.DeleteCoupon)
+	admin.Get("/coupons/:id/redemptions", middleware.RequirePermission("coupons:read"), admincoupon.ListCouponRedemptions)
+	admin.Post("/coupon-campaigns", middleware.RequirePermission("coupons:write"), admincoupon.CreateCampaign)
+	admin.Get("/coupon-campaigns", middleware.RequirePermission("coupons:read"), admincoupon.ListCampaigns)
+	admin.Put("/coupon-campaigns/:id", middleware.RequirePermission("coupons:write"), admincoupon.UpdateCampaign)
+	admin.Post("/coupon-campaigns/:id/codes", middleware.RequirePermission("coupons:write"), admincoupon.GenerateCampaignCodes)
+	admin.Get("/coupon-campaigns/:id/codes.csv", middleware.RequirePermission("coupons:read"), admincoupon.ExportCampaignCodes)
+	admin.Get("/coupon-campaigns/:id/report", middleware.RequirePermission("coupons:read"), admincoupon.GetCampaignReport)
+	admin.Get("/referrals", middleware.RequirePermission("referrals:read"), adminreferral.ListReferrals)
+	admin.Get("/referrals/stats", middleware.RequirePermission("referrals:read"), adminreferral.GetStats)
+	admin.Get("/referrals/settings", middleware.RequirePermission("referrals:read"), adminreferral.GetSettings)
+	admin.Put("/referrals/settings", middleware.RequirePermission("referrals:write"), adminreferral.UpdateSettings)
+	admin.Get("/users", middleware.RequirePermission("users:read"), admincoupon.ListUsers)
+	admin.Get("/users/:id", middleware.RequirePermission("users:read"), adminusers.GetUser)
+	admin.Get("/users/:id/details", middleware.RequirePermission("users:read"), adminusers.GetUserDetails)
+	admin.Get("/users/:id/stats", middleware.RequirePermission("users:read"), adminusers.GetUserStats)
+	admin.Get("/users/:id/bookings", middleware.RequirePermission("users:read"), adminusers.GetUserBookings)
+	admin.Get("/users/:id/wallet", middleware.RequirePermission("wallet:read"), adminwallet.GetUserWallet)
+	admin.Post("/users/:id/wallet/adjust", middleware.RequirePermission("wallet:write"), adminwallet.AdjustUserWallet)
+	admin.Get("/users/:id/sessions", middleware.RequirePermission("sessions:read"), adminsessions.ListUserSessions)
+	admin.Post("/users/:id/logout", middleware.RequirePermission("sessions:revoke"), adminsessions.ForceLogoutUser)
+	admin.Put("/users/:id", middleware.RequirePermission("users:write"), adminusers.UpdateUser)
+	admin.Delete("/users/:id", middleware.RequirePermission("users:delete"), adminusers.DeleteUser)
+
+	admin.Post("/offers", middleware.RequirePermission("offers:write"), adminoffer.CreateOffer)
+	admin.Get("/offers", middleware.RequirePermission("offers:read"), adminoffer.ListOffers)
+	admin.Put("/offers/:id", middleware.RequirePermission("offers:write"), adminoffer.UpdateOffer)
+	admin.Delete("/offers/:id", middleware.RequirePermission("offers:write"), adminoffer.DeleteOffer)
+	admin.Get("/offers/:id/redemptions", middleware.RequirePermission("offers:read"), adminoffer.ListOfferRedemptions)
+
+	admin.Post("/notifications", middleware.RequirePermission("notifications:send"), adminnotification.SendNotification)
+	admin.Get("/notifications", middleware.RequirePermission("notifications:read"), adminnotification.ListNotifications)
 
 	// Ticpin Pass Management
-	admin.Get("/passes", adminpass.ListAllPasses)
-	admin.Post("/passes", adminpass.CreateAdminPass)
-	admin.Put("/passes/:id", adminpass.UpdateAdminPass)
-	admin.Delete("/passes/:id", adminpass.DeleteAdminPass)
-	admin.Post("/passes/:id/renew", adminpass.RenewAdminPass)
-	admin.Get("/passes/eligible-users", adminpass.ListUsersForPass)
-	admin.Get("/passes/search-users", adminpass.GetUserBySearch)
+	admin.Get("/passes", middleware.RequirePermission("passes:read"), adminpass.ListAllPasses)
+	admin.Post("/passes", middleware.RequirePermission("passes:write"), adminpass.CreateAdminPass)
+	admin.Put("/passes/:id", middleware.RequirePermission("passes:write"), adminpass.UpdateAdminPass)
+	admin.Delete("/passes/:id", middleware.RequirePermission("passes:write"), adminpass.DeleteAdminPass)
+	admin.Post("/passes/:id/renew", middleware.RequirePermission("passes:write"), adminpass.RenewAdminPass)
+	admin.Get("/passes/:id/ledger", middleware.RequirePermission("passes:read"), adminpass.GetPassLedger)
+	admin.Post("/passes/:id/reconcile", middleware.RequirePermission("passes:write"), adminpass.ReconcilePass)
+	admin.Get("/passes/eligible-users", middleware.RequirePermission("passes:read"), adminpass.ListUsersForPass)
+	admin.Get("/passes/search-users", middleware.RequirePermission("passes:read"), adminpass.GetUserBySearch)
+
+	admin.Get("/pass-plans", middleware.RequirePermission("passes:read"), adminpass.ListPassPlans)
+	admin.Post("/pass-plans", middleware.RequirePermission("passes:write"), adminpass.CreatePassPlan)
+	admin.Put("/pass-plans/:id", middleware.RequirePermission("passes:write"), adminpass.UpdatePassPlan)
+	admin.Delete("/pass-plans/:id", middleware.RequirePermission("passes:write"), adminpass.DeletePassPlan)
+	admin.Get("/pass-gifts", middleware.RequirePermission("passes:read"), adminpass.ListPassGifts)
 
 	// PAN Card routes (admin only)
-	admin.Get("/organizers/:id/pan-card", panctrl.GetPANCard)
-	admin.Post("/organizers/:id/pan-card", panctrl.UploadPANCard)
-	admin.Delete("/organizers/:id/pan-card", panctrl.DeletePANCard)
-	admin.Post("/organizers/:id/pan-card/secure", panctrl.SecureLegacyPANCard)
+	admin.Get("/organizers/:id/pan-card", middleware.RequirePermission("pan:read"), panctrl.GetPANCard)
+	admin.Post("/organizers/:id/pan-card", middleware.RequirePermission("pan:write"), panctrl.UploadPANCard)
+	admin.Delete("/organizers/:id/pan-card", middleware.RequirePermission("pan:write"), panctrl.DeletePANCard)
+	admin.Post("/organizers/:id/pan-card/secure", middleware.RequirePermission("pan:write"), panctrl.SecureLegacyPANCard)
+
+	admin.Post("/upload-media", middleware.RequirePermission("media:upload"), orgmedia.UploadMedia)
+
+	// Roles and permissions
+	admin.Get("/me/permissions", adminroles.GetMyPermissions)
+	admin.Get("/permissions", middleware.RequirePermission("roles:manage"), adminroles.ListPermissions)
+	admin.Get("/roles", middleware.RequirePermission("roles:manage"), adminroles.ListRoles)
+	admin.Post("/roles", middleware.RequirePermission("roles:manage"), adminroles.CreateRole)
+	admin.Put("/roles/:id", middleware.RequirePermission("roles:manage"), adminroles.UpdateRole)
+	admin.Delete("/roles/:id", middleware.RequirePermission("roles:manage"), adminroles.DeleteRole)
+	admin.Get("/admins", middleware.RequirePermission("roles:manage"), adminroles.ListAdmins)
+	admin.Put("/admins/roles", middleware.RequirePermission("roles:manage"), adminroles.AssignRoles)
+
+	// Two-factor authentication of the signed-in admin. These skip the
+	// permission check so that an admin can set up or pass 2FA first.
+	admin.Get("/2fa", adminsecurity.GetTwoFactorStatus)
+	admin.Post("/2fa/setup", adminsecurity.BeginTOTPSetup)
+	admin.Post("/2fa/enable", adminsecurity.EnableTOTP)
+	admin.Post("/2fa/verify", adminsecurity.VerifySession)
+	admin.Post("/2fa/disable", adminsecurity.DisableTOTP)
+	admin.Post("/2fa/recovery-codes", adminsecurity.RegenerateRecoveryCodes)
+
+	// Admin security
+	admin.Get("/security/settings", middleware.RequirePermission("security:read"), adminsecurity.GetSettings)
+	admin.Put("/security/settings", middleware.RequirePermission("security:manage"), adminsecurity.UpdateSettings)
+	admin.Get("/security/login-events", middleware.RequirePermission("security:read"), adminsecurity.ListLoginEvents)
+	admin.Post("/admins/2fa/reset", middleware.RequirePermission("security:manage"), adminsecurity.ResetAdminTOTP)
+	admin.Post("/admins/unlock", middleware.RequirePermission("security:manage"), adminsecurity.UnlockAdmin)
+
+	// Audit log of admin and organizer changes
+	admin.Get("/audit-logs", middleware.RequirePermission("audit:read"), adminaudit.ListAuditLogs)
+
+	// Support chat for agents. Sessions are visible to the agent assigned to
+	// them, and to every agent while they wait in the queue.
+	admin.Get("/chat/sessions", middleware.RequirePermission("chat:support"), chat.ListAgentSessions)
+	admin.Get("/chat/sessions/:sessionId/messages", middleware.RequirePermission("chat:support"), chat.GetAgentMessages)
+	admin.Post("/chat/sessions/:sessionId/messages", middleware.RequirePermission("chat:support"), chat.SendAgentMessage)
+	admin.Put("/chat/sessions/:sessionId/read", middleware.RequirePermission("chat:support"), chat.MarkAgentRead)
+	admin.Post("/chat/sessions/:sessionId/accept", middleware.RequirePermission("chat:support"), chat.AcceptSession)
+	admin.Post("/chat/sessions/:sessionId/end", middleware.RequirePermission("chat:support"), chat.EndAgentSession)
+	admin.Post("/chat/sessions/:sessionId/assign", middleware.RequirePermission("chat:manage"), chat.AssignSession)
+	admin.Post("/chat/sessions/:sessionId/typing", middleware.RequirePermission("chat:support"), chat.AgentTyping)
+	admin.Get("/chat/sessions/:sessionId/stream", middleware.RequirePermission("chat:support"), chat.StreamAgentSession)
+	admin.Get("/chat/stream", middleware.RequirePermission("chat:support"), chat.StreamAgentQueue)
 
-	admin.Post("/upload-media", orgmedia.UploadMedia)
+	// Deleted items, restorable until the retention job purges them
+	admin.Get("/deleted/events", middleware.RequirePermission("listings:read"), admintrash.ListDeleted(trashsvc.KindEvents))
+	admin.Get("/deleted/dining", middleware.RequirePermission("listings:read"), admintrash.ListDeleted(trashsvc.KindDining))
+	admin.Get("/deleted/play", middleware.RequirePermission("listings:read"), admintrash.ListDeleted(trashsvc.KindPlay))
+	admin.Get("/deleted/organizers", middleware.RequirePermission("organizers:read"), admintrash.ListDeleted(trashsvc.KindOrganizers))
+	admin.Get("/deleted/users", middleware.RequirePermission("users:read"), admintrash.ListDeleted(trashsvc.KindUsers))
+	admin.Post("/events/:id/restore", middleware.RequirePermission("listings:delete"), admintrash.Restore(trashsvc.KindEvents))
+	admin.Post("/dining/:id/restore", middleware.RequirePermission("listings:delete"), admintrash.Restore(trashsvc.KindDining))
+	admin.Post("/play/:id/restore", middleware.RequirePermission("listings:delete"), admintrash.Restore(trashsvc.KindPlay))
+	admin.Post("/organizers/:id/restore", middleware.RequirePermission("organizers:delete"), admintrash.Restore(trashsvc.KindOrganizers))
+	admin.Post("/users/:id/restore", middleware.RequirePermission("users:delete"), admintrash.Restore(trashsvc.KindUsers))
 }
@@ -20,6 +20,8 @@ func BookingRoutes(app *fiber.App) {
 	app.Get("/api/bookings/:id", middleware.RequireUserAuth, bookingctrl.GetBookingDetails)
 	app.Get("/api/bookings/public/:id", bookingctrl.GetPublicBookingDetails)
 	app.Put("/api/bookings/:id/cancel", middleware.RequireUserAuth, bookinguser.CancelBooking)
+	app.Put("/api/bookings/:id/reschedule-response", middleware.RequireUserAuth, bookinguser.RespondToReschedule)
+	app.Put("/api/bookings/:id/transfer", middleware.RequireUserAuth, bookinguser.TransferTicket)
 	app.Get("/api/events/:id/availability", bookingctrl.GetEventAvailability)
 	app.Get("/api/play/:id/booked-slots", bookingctrl.GetPlaySlotAvailability)
 
@@ -27,10 +29,11 @@ func BookingRoutes(app *fiber.App) {
 	app.Get("/api/dining/:id/offers", adminoffer.GetDiningOffers)
 	app.Get("/api/play/:id/offers", adminoffer.GetPlayOffers)
 
-	// Unified Slot Locking APIs
-	app.Post("/api/booking/lock", bookingctrl.CreateSlotLock)
-	app.Post("/api/booking/unlock", bookingctrl.UnlockSlot)
-	app.Get("/api/booking/lock/status", bookingctrl.GetUserActiveLocks)
+	// Unified Slot Locking APIs. Locks belong to the signed-in user.
+	app.Post("/api/booking/lock", middleware.RequireUserAuth, bookingctrl.CreateSlotLock)
+	app.Post("/api/booking/unlock", middleware.RequireUserAuth, bookingctrl.UnlockSlot)
+	app.Get("/api/booking/lock/status", middleware.RequireUserAuth, bookingctrl.GetUserActiveLocks)
 
 	app.Post("/api/coupons/validate", middleware.RequireUserAuth, admincoupon.ValidateCoupon)
+	app.Post("/api/offers/best", middleware.RequireUserAuth, adminoffer.GetBestOffer)
 }
@@ -0,0 +1,14 @@
+package cron
+
+import (
+	cronctrl "ticpin-backend/controller/cron"
+	"ticpin-backend/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CronRoutes lets a scheduler such as Vercel cron run the background jobs
+// where the server does not stay up between requests.
+func CronRoutes(app *fiber.App) {
+	app.Get("/api/cron/:job", middleware.RequireCronSecret, cronctrl.RunJob)
+}
@@ -3,6 +3,7 @@ package dining
 import (
 	ctrl "ticpin-backend/controller/organizer/dining"
 	"ticpin-backend/middleware"
+	organizersvc "ticpin-backend/services/organizer"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,10 +17,10 @@ func DiningRoutes(app *fiber.App) {
 	dining.Post("/resend-otp", ctrl.ResendOTP)
 	dining.Post("/google-auth", ctrl.GoogleAuth)
 
-	dining.Post("/setup", middleware.RequireAuth, ctrl.DiningSetup)
-	dining.Post("/submit-verification", middleware.RequireAuth, ctrl.SubmitVerification)
-	dining.Post("/create", middleware.RequireAuth, middleware.RequireCategoryApproval("dining"), ctrl.CreateOrganizerDining)
+	dining.Post("/setup", middleware.RequireAuth, middleware.RequireOwner, ctrl.DiningSetup)
+	dining.Post("/submit-verification", middleware.RequireAuth, middleware.RequireOwner, ctrl.SubmitVerification)
+	dining.Post("/create", middleware.RequireAuth, middleware.RequireCategoryApproval("dining"), middleware.RequireOrgAllListings(organizersvc.PermEditListings), ctrl.CreateOrganizerDining)
 	dining.Get("/list", middleware.RequireAuth, ctrl.GetOrganizerDinings)
-	dining.Put("/:id", middleware.RequireAuth, ctrl.UpdateOrganizerDining)
-	dining.Delete("/:id", middleware.RequireAuth, ctrl.DeleteOrganizerDining)
+	dining.Put("/:id", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.UpdateOrganizerDining)
+	dining.Delete("/:id", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.DeleteOrganizerDining)
 }
@@ -3,6 +3,7 @@ package events
 import (
 	ctrl "ticpin-backend/controller/organizer/events"
 	"ticpin-backend/middleware"
+	organizersvc "ticpin-backend/services/organizer"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,10 +17,22 @@ func EventsRoutes(app *fiber.App) {
 	events.Post("/resend-otp", ctrl.ResendOTP)
 	events.Post("/google-auth", ctrl.GoogleAuth)
 
-	events.Post("/setup", middleware.RequireAuth, ctrl.EventsSetup)
-	events.Post("/submit-verification", middleware.RequireAuth, ctrl.SubmitVerification)
-	events.Post("/create", middleware.RequireAuth, middleware.RequireCategoryApproval("events"), ctrl.CreateOrganizerEvent)
+	events.Post("/setup", middleware.RequireAuth, middleware.RequireOwner, ctrl.EventsSetup)
+	events.Post("/submit-verification", middleware.RequireAuth, middleware.RequireOwner, ctrl.SubmitVerification)
+	events.Post("/create", middleware.RequireAuth, middleware.RequireCategoryApproval("events"), middleware.RequireOrgAllListings(organizersvc.PermEditListings), ctrl.CreateOrganizerEvent)
 	events.Get("/list", middleware.RequireAuth, ctrl.GetOrganizerEvents)
-	events.Put("/:id", middleware.RequireAuth, ctrl.UpdateOrganizerEvent)
-	events.Delete("/:id", middleware.RequireAuth, ctrl.DeleteOrganizerEvent)
+	events.Put("/:id", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.UpdateOrganizerEvent)
+	events.Delete("/:id", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.DeleteOrganizerEvent)
+
+	events.Post("/:id/showtimes", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.AddShowtime)
+	// Moving or cancelling shows refunds buyers, so members also need the
+	// payouts permission
+	events.Put("/:id/showtimes/:showtimeId", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), middleware.RequireOrgPermission(organizersvc.PermTriggerPayouts), ctrl.RescheduleShowtime)
+	events.Delete("/:id/showtimes/:showtimeId", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), middleware.RequireOrgPermission(organizersvc.PermTriggerPayouts), ctrl.CancelShowtime)
+
+	events.Post("/:id/cancel", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), middleware.RequireOrgPermission(organizersvc.PermTriggerPayouts), ctrl.CancelOrganizerEvent)
+	events.Post("/:id/reschedule", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), middleware.RequireOrgPermission(organizersvc.PermTriggerPayouts), ctrl.RescheduleOrganizerEvent)
+	events.Get("/:id/changes", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermViewBookings, "id"), ctrl.GetEventChanges)
+	events.Get("/:id/changes/:changeId", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermViewBookings, "id"), ctrl.GetEventChangeBookings)
+	events.Get("/:id/limit-hits", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermViewBookings, "id"), ctrl.GetPurchaseLimitReport)
 }
@@ -3,12 +3,15 @@ package organizer
 import (
 	morganalytics "ticpin-backend/controller/organizer/analytics"
 	orgauth "ticpin-backend/controller/organizer/auth"
+	orgbookings "ticpin-backend/controller/organizer/bookings"
 	orgmedia "ticpin-backend/controller/organizer/media"
 	morgpayouts "ticpin-backend/controller/organizer/payouts"
 	orgprofile "ticpin-backend/controller/organizer/profile"
+	orgteam "ticpin-backend/controller/organizer/team"
 	orgver "ticpin-backend/controller/organizer/verification"
 	orgotp "ticpin-backend/controller/otp"
 	"ticpin-backend/middleware"
+	organizersvc "ticpin-backend/services/organizer"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -19,33 +22,51 @@ func OrganizerRoutes(app *fiber.App) {
 	app.Get("/api/organizer/check-email", orgauth.CheckEmailExists)
 	app.Get("/api/organizer/me", middleware.RequireAuth, orgmedia.GetOrganizerMe)
 
-	app.Get("/api/organizer/me/existing-setup", middleware.RequireAuth, orgver.GetMyExistingSetup)
+	app.Get("/api/organizer/me/existing-setup", middleware.RequireAuth, middleware.RequireOwner, orgver.GetMyExistingSetup)
 	app.Get("/api/organizer/me/status", middleware.RequireAuth, orgver.GetMyStatus)
 
 	app.Get("/api/organizer/:id/status", middleware.RequireAuth, middleware.RequireSelfOrAdmin, orgver.GetCategoryStatus)
-	app.Get("/api/organizer/:id/existing-setup", middleware.RequireAuth, middleware.RequireSelfOrAdmin, orgver.GetExistingSetupHandler)
+	app.Get("/api/organizer/:id/existing-setup", middleware.RequireAuth, middleware.RequireOwner, middleware.RequireSelfOrAdmin, orgver.GetExistingSetupHandler)
 
 	profileGrp := app.Group("/api/organizer/profile", middleware.RequireAuth)
 	profileGrp.Get("", orgprofile.GetProfile)
-	profileGrp.Post("", orgprofile.CreateProfile)
-	profileGrp.Put("", orgprofile.UpdateProfile)
+	profileGrp.Post("", middleware.RequireOwner, orgprofile.CreateProfile)
+	profileGrp.Put("", middleware.RequireOwner, orgprofile.UpdateProfile)
 	profileGrp.Get("/:id", orgprofile.GetProfile)
-	profileGrp.Put("/:id", orgprofile.UpdateProfile)
+	profileGrp.Put("/:id", middleware.RequireOwner, orgprofile.UpdateProfile)
 
-	verGrp := app.Group("/api/organizer/verification", middleware.RequireAuth)
+	verGrp := app.Group("/api/organizer/verification", middleware.RequireAuth, middleware.RequireOwner)
 	verGrp.Get("/fetch-gst", orgver.FetchGSTHandler)
 	verGrp.Post("/verify-pan", orgver.VerifyPANHandler)
 	verGrp.Get("/:id", middleware.RequireSelfOrAdmin, orgver.GetVerificationStatus)
 
-	app.Post("/api/organizer/upload-pan", middleware.RequireAuth, orgmedia.UploadPANCard)
-	app.Post("/api/organizer/upload-media", middleware.RequireAuth, orgmedia.UploadMedia)
+	app.Post("/api/organizer/upload-pan", middleware.RequireAuth, middleware.RequireOwner, orgmedia.UploadPANCard)
+	app.Post("/api/organizer/upload-media", middleware.RequireAuth, middleware.RequireOrgPermission(organizersvc.PermEditListings), orgmedia.UploadMedia)
 
-	app.Post("/api/organizer/send-backup-otp", middleware.RequireAuth, orgver.SendBackupOTPHandler)
-	app.Post("/api/organizer/verify-backup-otp", middleware.RequireAuth, orgver.VerifyBackupOTPHandler)
+	app.Post("/api/organizer/send-backup-otp", middleware.RequireAuth, middleware.RequireOwner, orgver.SendBackupOTPHandler)
+	app.Post("/api/organizer/verify-backup-otp", middleware.RequireAuth, middleware.RequireOwner, orgver.VerifyBackupOTPHandler)
 	app.Post("/api/organizer/logout", orgauth.Logout)
+	app.Post("/api/organizer/refresh", orgauth.Refresh)
+	app.Post("/api/organizer/logout-all", middleware.RequireAuth, orgauth.LogoutAll)
+	app.Get("/api/organizer/sessions", middleware.RequireAuth, orgauth.ListSessions)
+	app.Delete("/api/organizer/sessions/:sessionId", middleware.RequireAuth, orgauth.RevokeSession)
 
 	// Analytics & Payouts
-	app.Get("/api/organizer/analytics", middleware.RequireAuth, morganalytics.GetOrganizerAnalytics)
-	app.Get("/api/organizer/payouts", middleware.RequireAuth, morgpayouts.GetPayoutsList)
-	app.Post("/api/organizer/payouts/trigger", middleware.RequireAuth, morgpayouts.TriggerPayout)
+	app.Get("/api/organizer/analytics", middleware.RequireAuth, middleware.RequireOrgAllListings(organizersvc.PermViewBookings), morganalytics.GetOrganizerAnalytics)
+	app.Get("/api/organizer/payouts", middleware.RequireAuth, middleware.RequireOrgAllListings(organizersvc.PermTriggerPayouts), morgpayouts.GetPayoutsList)
+	app.Post("/api/organizer/payouts/trigger", middleware.RequireAuth, middleware.RequireOrgAllListings(organizersvc.PermTriggerPayouts), morgpayouts.TriggerPayout)
+
+	// Bookings & gate check-in
+	app.Get("/api/organizer/bookings", middleware.RequireAuth, middleware.RequireOrgPermission(organizersvc.PermViewBookings), orgbookings.ListBookings)
+	app.Post("/api/organizer/bookings/:bookingId/check-in", middleware.RequireAuth, middleware.RequireOrgPermission(organizersvc.PermScanTickets), orgbookings.CheckIn)
+
+	// Team members
+	app.Post("/api/organizer/team/send-otp", orgteam.SendLoginOTP)
+	app.Post("/api/organizer/team/verify-otp", orgteam.VerifyLogin)
+	app.Get("/api/organizer/team/me", middleware.RequireAuth, orgteam.GetMyAccess)
+	app.Get("/api/organizer/team/permissions", middleware.RequireAuth, middleware.RequireOwner, orgteam.ListPermissions)
+	app.Get("/api/organizer/team", middleware.RequireAuth, middleware.RequireOwner, orgteam.ListMembers)
+	app.Post("/api/organizer/team/invite", middleware.RequireAuth, middleware.RequireOwner, orgteam.InviteMember)
+	app.Put("/api/organizer/team/:memberId", middleware.RequireAuth, middleware.RequireOwner, orgteam.UpdateMember)
+	app.Delete("/api/organizer/team/:memberId", middleware.RequireAuth, middleware.RequireOwner, orgteam.RemoveMember)
 }
@@ -3,6 +3,7 @@ package play
 import (
 	ctrl "ticpin-backend/controller/organizer/play"
 	"ticpin-backend/middleware"
+	organizersvc "ticpin-backend/services/organizer"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,12 +17,12 @@ func PlayRoutes(app *fiber.App) {
 	play.Post("/resend-otp", ctrl.ResendOTP)
 	play.Post("/google-auth", ctrl.GoogleAuth)
 
-	play.Post("/setup", middleware.RequireAuth, ctrl.PlaySetup)
-	play.Post("/submit-verification", middleware.RequireAuth, ctrl.SubmitVerification)
-	play.Post("/create", middleware.RequireAuth, middleware.RequireCategoryApproval("play"), ctrl.CreateOrganizerPlay)
+	play.Post("/setup", middleware.RequireAuth, middleware.RequireOwner, ctrl.PlaySetup)
+	play.Post("/submit-verification", middleware.RequireAuth, middleware.RequireOwner, ctrl.SubmitVerification)
+	play.Post("/create", middleware.RequireAuth, middleware.RequireCategoryApproval("play"), middleware.RequireOrgAllListings(organizersvc.PermEditListings), ctrl.CreateOrganizerPlay)
 	play.Get("/list", middleware.RequireAuth, ctrl.GetOrganizerPlays)
-	play.Get("/:id", middleware.RequireAuth, ctrl.GetOrganizerPlayByID)
-	play.Put("/:id", middleware.RequireAuth, ctrl.UpdateOrganizerPlay)
-	play.Delete("/:id", middleware.RequireAuth, ctrl.DeleteOrganizerPlay)
+	play.Get("/:id", middleware.RequireAuth, middleware.RequireOrgListing("", "id"), ctrl.GetOrganizerPlayByID)
+	play.Put("/:id", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.UpdateOrganizerPlay)
+	play.Delete("/:id", middleware.RequireAuth, middleware.RequireOrgListing(organizersvc.PermEditListings, "id"), ctrl.DeleteOrganizerPlay)
 	play.Get("/organizer/:id", middleware.RequireAuth, middleware.RequireSelfOrAdmin, ctrl.GetOrganizer)
 }
@@ -13,13 +13,19 @@ func PassRoutes(app *fiber.App) {
 		fmt.Printf("PASS REQUEST: %s %s\n", c.Method(), c.Path())
 		return c.Next()
 	})
-	pass.Post("/create", middleware.RequireUserAuth, ctrl.CreatePass)
-	pass.Post("/apply", middleware.RequireUserAuth, ctrl.ApplyPass)
+	pass.Get("/plans", ctrl.ListPlans)
 	pass.Get("/user/:userId", middleware.RequireUserAuth, middleware.RequireSelfUser, ctrl.GetPassByUser)
 	pass.Get("/user/:userId/latest", middleware.RequireUserAuth, middleware.RequireSelfUser, ctrl.GetLatestPassByUser)
+	pass.Get("/gifts", middleware.RequireUserAuth, ctrl.ListMyGifts)
+	pass.Post("/gifts/redeem", middleware.RequireUserAuth, ctrl.RedeemGift)
 	pass.Post("/:id/renew", middleware.RequireUserAuth, ctrl.RenewPass)
 	pass.Post("/:id/use-turf", middleware.RequireUserAuth, ctrl.UseTurfBooking)
 	pass.Post("/:id/use-dining", middleware.RequireUserAuth, ctrl.UseDiningVoucher)
+	pass.Get("/:id/ledger", middleware.RequireUserAuth, ctrl.GetPassLedger)
+	pass.Post("/:id/auto-renew", middleware.RequireUserAuth, ctrl.EnableAutoRenew)
+	pass.Post("/:id/auto-renew/pause", middleware.RequireUserAuth, ctrl.PauseAutoRenew)
+	pass.Post("/:id/auto-renew/resume", middleware.RequireUserAuth, ctrl.ResumeAutoRenew)
+	pass.Delete("/:id/auto-renew", middleware.RequireUserAuth, ctrl.CancelAutoRenew)
 
 	// Catch-all for /api/pass/*
 	pass.Use(func(c *fiber.Ctx) error {
@@ -11,6 +11,7 @@ func PaymentRoutes(app *fiber.App) {
 
 	app.Post("/api/payment/create-order", middleware.RequireUserAuth, paymentctrl.CreateOrderHandler)
 	app.Post("/api/payment/verify-pass", middleware.RequireUserAuth, paymentctrl.VerifyPassHandler)
+	app.Post("/api/payment/verify-pass-gift", middleware.RequireUserAuth, paymentctrl.VerifyPassGiftHandler)
 	app.Post("/api/payment/razorpay/webhook", paymentctrl.RazorpayWebhook)
 	app.Post("/api/payment/cashfree/webhook", paymentctrl.CashfreeWebhook)
 }
@@ -4,6 +4,7 @@ import (
 	admincoupon "ticpin-backend/controller/admin/coupon"
 	adminoffer "ticpin-backend/controller/admin/offer"
 	ctrl "ticpin-backend/controller/user"
+	"ticpin-backend/middleware"
 
 	"ticpin-backend/controller/otp"
 
@@ -15,7 +16,15 @@ func UserRoutes(app *fiber.App) {
 	user := api.Group("/user")
 
 	user.Post("", ctrl.CreateUser)
+	user.Post("/login/otp", ctrl.SendLoginOTP)
 	user.Post("/login", ctrl.LoginUser)
+	user.Post("/refresh", ctrl.RefreshSession)
+	user.Post("/logout", ctrl.Logout)
+	user.Post("/logout-all", middleware.RequireUserAuth, ctrl.LogoutAll)
+	user.Get("/sessions", middleware.RequireUserAuth, ctrl.ListSessions)
+	user.Delete("/sessions/:sessionId", middleware.RequireUserAuth, ctrl.RevokeSession)
+	user.Get("/referrals", middleware.RequireUserAuth, ctrl.GetMyReferrals)
+	user.Get("/wallet", middleware.RequireUserAuth, ctrl.GetMyWallet)
 	user.Get("/:id", ctrl.GetUser)
 
 	user.Post("/send-otp", otp.SendOTP)
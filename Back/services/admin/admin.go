@@ -35,6 +35,7 @@ func Create(email, password, name, phone string) (*models.Admin, error) {
 		Name:      name,
 		Phone:     phone,
 		IsSuper:   true,
+		Roles:     []string{RoleSuperAdmin},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -0,0 +1,551 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	organizersvc "ticpin-backend/services/organizer"
+	sessionsvc "ticpin-backend/services/session"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AllPermissions grants every permission, current and future.
+const AllPermissions = "*"
+
+const (
+	RoleSuperAdmin       = "super_admin"
+	RoleSupport          = "support"
+	RoleFinance          = "finance"
+	RoleContentModerator = "content_moderator"
+)
+
+// Permission is one action an admin can be allowed to take.
+type Permission struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+var Permissions = []Permission{
+	{"stats:read", "View dashboard statistics"},
+	{"organizers:read", "View organizers and their setups"},
+	{"organizers:write", "Edit organizers and approve or reject categories"},
+	{"organizers:delete", "Delete organizers"},
+	{"listings:read", "View events, dining and play listings"},
+	{"listings:write", "Edit and approve listings"},
+	{"listings:delete", "Delete listings"},
+	{"coupons:read", "View coupons, campaigns and redemptions"},
+	{"coupons:write", "Create and edit coupons and campaigns"},
+	{"offers:read", "View offers and redemptions"},
+	{"offers:write", "Create and edit offers"},
+	{"referrals:read", "View referrals"},
+	{"referrals:write", "Change referral settings"},
+	{"users:read", "View users and their bookings"},
+	{"users:write", "Edit users"},
+	{"users:delete", "Delete users"},
+	{"wallet:read", "View user wallets"},
+	{"wallet:write", "Credit or debit user wallets"},
+	{"sessions:read", "View signed-in devices of users and organizers"},
+	{"sessions:revoke", "Log users and organizers out"},
+	{"notifications:read", "View sent notifications"},
+	{"notifications:send", "Send notifications"},
+	{"passes:read", "View passes, plans and gifts"},
+	{"passes:write", "Issue, renew and edit passes and plans"},
+	{"pan:read", "View organizer PAN cards"},
+	{"pan:write", "Upload and delete organizer PAN cards"},
+	{"media:upload", "Upload media"},
+	{"roles:manage", "Manage admin roles and assignments"},
+	{"security:read", "View admin login history and security settings"},
+	{"security:manage", "Change admin security settings, reset 2FA and unlock admins"},
+	{"audit:read", "Search the audit log of admin and organizer changes"},
+	{"chat:support", "Answer support chats: accept, reply to and close their own sessions"},
+	{"chat:manage", "See every support chat and reassign sessions between agents"},
+}
+
+var defaultRoles = []models.AdminRole{
+	{
+		Name:        RoleSuperAdmin,
+		Label:       "Super admin",
+		Description: "Full access, including admin roles",
+		Permissions: []string{AllPermissions},
+	},
+	{
+		Name:        RoleSupport,
+		Label:       "Support",
+		Description: "Helps users and organizers; read access plus user edits and logouts",
+		Permissions: []string{
+			"stats:read", "organizers:read", "listings:read", "users:read", "users:write",
+			"wallet:read", "sessions:read", "sessions:revoke", "passes:read",
+			"coupons:read", "offers:read", "referrals:read", "notifications:read",
+			"chat:support",
+		},
+	},
+	{
+		Name:        RoleFinance,
+		Label:       "Finance",
+		Description: "Money movements: wallets, passes, coupons, offers and referrals",
+		Permissions: []string{
+			"stats:read", "users:read", "wallet:read", "wallet:write", "passes:read", "passes:write",
+			"coupons:read", "coupons:write", "offers:read", "offers:write",
+			"referrals:read", "referrals:write",
+		},
+	},
+	{
+		Name:        RoleContentModerator,
+		Label:       "Content moderator",
+		Description: "Reviews organizers and listings",
+		Permissions: []string{
+			"stats:read", "organizers:read", "organizers:write", "listings:read", "listings:write",
+			"listings:delete", "notifications:read", "notifications:send", "media:upload", "pan:read",
+		},
+	},
+}
+
+var roleNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{2,39}$`)
+
+func isKnownPermission(key string) bool {
+	if key == AllPermissions {
+		return true
+	}
+	for _, p := range Permissions {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether a permission set allows perm.
+func HasPermission(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == AllPermissions || p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsureDefaultRoles creates the system roles and keeps their permissions
+// in line with the code.
+func EnsureDefaultRoles() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	for _, r := range defaultRoles {
+		_, err := config.AdminRolesCol.UpdateOne(ctx, bson.M{"name": r.Name}, bson.M{
+			"$set": bson.M{
+				"label":       r.Label,
+				"description": r.Description,
+				"permissions": r.Permissions,
+				"system":      true,
+				"updated_at":  now,
+			},
+			"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "created_at": now},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+	invalidatePermissions()
+	return nil
+}
+
+// BackfillAdminRoles gives a role to admins from before roles existed, who
+// would otherwise hold no permissions. Admin records that never had roles
+// assigned, and organizers with admin access but no admin record, become
+// super admins. An empty role list set through AssignRoles is left alone.
+func BackfillAdminRoles() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	admins := config.GetDB().Collection("admins")
+	res, err := admins.UpdateMany(ctx, bson.M{"roles": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"roles": []string{RoleSuperAdmin}, "isSuper": true, "updatedAt": now},
+	})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount > 0 {
+		fmt.Printf("DEBUG: Gave %d admin(s) without roles the %s role\n", res.ModifiedCount, RoleSuperAdmin)
+	}
+
+	cursor, err := config.OrgsCol.Find(ctx, bson.M{"role": "admin"})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	var orgs []models.Organizer
+	if err := cursor.All(ctx, &orgs); err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		email := strings.ToLower(strings.TrimSpace(org.Email))
+		if email == "" || organizersvc.IsAdminByEmail(email) {
+			continue
+		}
+		res, err := admins.UpdateOne(ctx, bson.M{"email": email}, bson.M{
+			"$setOnInsert": bson.M{
+				"_id":       primitive.NewObjectID(),
+				"email":     email,
+				"name":      org.Name,
+				"roles":     []string{RoleSuperAdmin},
+				"isSuper":   true,
+				"createdAt": now,
+				"updatedAt": now,
+			},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		if res.UpsertedCount > 0 {
+			fmt.Printf("DEBUG: Gave organizer admin %s the %s role\n", email, RoleSuperAdmin)
+		}
+	}
+	invalidatePermissions()
+	return nil
+}
+
+type cachedPermissions struct {
+	perms     []string
+	checkedAt time.Time
+}
+
+var permissionCache sync.Map // email -> cachedPermissions
+
+const permissionCacheTTL = 30 * time.Second
+
+func invalidatePermissions() {
+	permissionCache.Range(func(k, _ interface{}) bool {
+		permissionCache.Delete(k)
+		return true
+	})
+}
+
+// PermissionsFor returns the permissions of the admin with this email. The
+// bootstrap admin emails always have every permission; other admins get the
+// union of their roles. Results are cached for a short while.
+func PermissionsFor(email string) ([]string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, nil
+	}
+	if v, ok := permissionCache.Load(email); ok {
+		cached := v.(cachedPermissions)
+		if time.Since(cached.checkedAt) < permissionCacheTTL {
+			return cached.perms, nil
+		}
+	}
+
+	perms, err := loadPermissions(email)
+	if err != nil {
+		return nil, err
+	}
+	permissionCache.Store(email, cachedPermissions{perms: perms, checkedAt: time.Now()})
+	return perms, nil
+}
+
+func loadPermissions(email string) ([]string, error) {
+	if organizersvc.IsAdminByEmail(email) {
+		return []string{AllPermissions}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var a models.Admin
+	err := config.GetDB().Collection("admins").FindOne(ctx, bson.M{"email": email}).Decode(&a)
+	if err == mongo.ErrNoDocuments {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	granted, err := permissionsOfRoles(ctx, a.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	perms := []string{}
+	for _, p := range granted {
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	sort.Strings(perms)
+	return perms, nil
+}
+
+func ListRoles() ([]models.AdminRole, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.AdminRolesCol.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "system", Value: -1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	roles := []models.AdminRole{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func validatePermissions(perms []string) error {
+	if len(perms) == 0 {
+		return errors.New("a role needs at least one permission")
+	}
+	for _, p := range perms {
+		if !isKnownPermission(p) {
+			return fmt.Errorf("unknown permission: %s", p)
+		}
+	}
+	return nil
+}
+
+// CreateRole adds a custom role.
+func CreateRole(r *models.AdminRole) error {
+	r.Name = strings.ToLower(strings.TrimSpace(r.Name))
+	if !roleNameRe.MatchString(r.Name) {
+		return errors.New("name must be 3-40 lowercase letters, digits or underscores")
+	}
+	if err := validatePermissions(r.Permissions); err != nil {
+		return err
+	}
+	if r.Label == "" {
+		r.Label = r.Name
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	r.ID = primitive.NewObjectID()
+	r.System = false
+	r.CreatedAt = now
+	r.UpdatedAt = now
+	if _, err := config.AdminRolesCol.InsertOne(ctx, r); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.New("a role with this name already exists")
+		}
+		return err
+	}
+	return nil
+}
+
+// UpdateRole changes the label, description or permissions of a custom
+// role. System roles are defined in code and cannot be changed.
+func UpdateRole(id string, label, description string, perms []string) (*models.AdminRole, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid role id")
+	}
+	if err := validatePermissions(perms); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"permissions": perms, "updated_at": time.Now()}
+	if label != "" {
+		set["label"] = label
+	}
+	set["description"] = description
+
+	var r models.AdminRole
+	err = config.AdminRolesCol.FindOneAndUpdate(ctx,
+		bson.M{"_id": objID, "system": false},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&r)
+	if err != nil {
+		return nil, errors.New("role not found or is a system role")
+	}
+	invalidatePermissions()
+	return &r, nil
+}
+
+// DeleteRole removes a custom role that no admin holds.
+func DeleteRole(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid role id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var r models.AdminRole
+	if err := config.AdminRolesCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&r); err != nil {
+		return errors.New("role not found")
+	}
+	if r.System {
+		return errors.New("system roles cannot be deleted")
+	}
+	holders, err := config.GetDB().Collection("admins").CountDocuments(ctx, bson.M{"roles": r.Name})
+	if err != nil {
+		return err
+	}
+	if holders > 0 {
+		return fmt.Errorf("role is assigned to %d admin(s)", holders)
+	}
+	if _, err := config.AdminRolesCol.DeleteOne(ctx, bson.M{"_id": objID}); err != nil {
+		return err
+	}
+	invalidatePermissions()
+	return nil
+}
+
+// AdminAccount is an admin with the roles and permissions they hold.
+type AdminAccount struct {
+	Email       string   `json:"email"`
+	Name        string   `json:"name"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Bootstrap   bool     `json:"bootstrap"`
+}
+
+func ListAdmins() ([]AdminAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.GetDB().Collection("admins").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "email", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var admins []models.Admin
+	if err := cursor.All(ctx, &admins); err != nil {
+		return nil, err
+	}
+
+	list := []AdminAccount{}
+	for _, a := range admins {
+		perms, err := PermissionsFor(a.Email)
+		if err != nil {
+			return nil, err
+		}
+		roles := a.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		list = append(list, AdminAccount{
+			Email:       a.Email,
+			Name:        a.Name,
+			Roles:       roles,
+			Permissions: perms,
+			Bootstrap:   organizersvc.IsAdminByEmail(a.Email),
+		})
+	}
+	return list, nil
+}
+
+// AssignRoles replaces the roles of the admin with this email, creating
+// the admin record if needed. The matching organizer account is given
+// admin access while it holds any role, and its sessions pick up the
+// change on their next request.
+func AssignRoles(email string, roles []string, actorEmail string) (*AdminAccount, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, errors.New("email required")
+	}
+	if organizersvc.IsAdminByEmail(email) {
+		return nil, errors.New("bootstrap admins always have full access")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if roles == nil {
+		roles = []string{}
+	}
+	if len(roles) > 0 {
+		count, err := config.AdminRolesCol.CountDocuments(ctx, bson.M{"name": bson.M{"$in": roles}})
+		if err != nil {
+			return nil, err
+		}
+		if int(count) != len(roles) {
+			return nil, errors.New("unknown role in list")
+		}
+	}
+
+	if strings.EqualFold(email, actorEmail) {
+		perms, err := permissionsOfRoles(ctx, roles)
+		if err != nil {
+			return nil, err
+		}
+		if !HasPermission(perms, "roles:manage") {
+			return nil, errors.New("you cannot remove your own access to role management")
+		}
+	}
+
+	now := time.Now()
+	superAdmin := false
+	for _, r := range roles {
+		if r == RoleSuperAdmin {
+			superAdmin = true
+		}
+	}
+	_, err := config.GetDB().Collection("admins").UpdateOne(ctx, bson.M{"email": email}, bson.M{
+		"$set":         bson.M{"roles": roles, "isSuper": superAdmin, "updatedAt": now},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "email": email, "createdAt": now},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	permissionCache.Delete(email)
+
+	var org models.Organizer
+	if err := config.OrgsCol.FindOne(ctx, bson.M{"email": email}).Decode(&org); err == nil {
+		role := "organizer"
+		if len(roles) > 0 {
+			role = "admin"
+		}
+		if org.Role != role {
+			config.OrgsCol.UpdateOne(ctx, bson.M{"_id": org.ID}, bson.M{"$set": bson.M{"role": role}})
+			_ = sessionsvc.MarkStale(sessionsvc.KindOrganizer, org.ID.Hex())
+		}
+	}
+
+	perms, err := PermissionsFor(email)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("DEBUG: Admin %s set roles of %s to %v\n", actorEmail, email, roles)
+	return &AdminAccount{Email: email, Roles: roles, Permissions: perms}, nil
+}
+
+func permissionsOfRoles(ctx context.Context, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return []string{}, nil
+	}
+	cursor, err := config.AdminRolesCol.Find(ctx, bson.M{"name": bson.M{"$in": names}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var roles []models.AdminRole
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	perms := []string{}
+	for _, r := range roles {
+		perms = append(perms, r.Permissions...)
+	}
+	return perms, nil
+}
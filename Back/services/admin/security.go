@@ -0,0 +1,670 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	sessionsvc "ticpin-backend/services/session"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// Failed attempts allowed before the account locks. Each failure after
+	// that doubles the lock, up to maxLockout.
+	freeLoginAttempts = 5
+	baseLockout       = time.Minute
+	maxLockout        = time.Hour
+
+	mfaTokenTTL        = 5 * time.Minute
+	mfaTokenPurpose    = "admin_mfa"
+	securitySettingsID = "security"
+	maxKnownDevices    = 20
+	mfaStateCacheTTL   = 30 * time.Second
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid admin credentials")
+	ErrInvalidCode        = errors.New("invalid authentication code")
+	ErrNoAdminAccess      = errors.New("this account has no admin access")
+)
+
+// dummyHash is compared against when an email is unknown, so that the
+// response time does not tell which admin emails exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("ticpin-admin-placeholder"), bcrypt.DefaultCost)
+
+// LockedError is returned while an admin account is locked after too many
+// failed attempts.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return "too many failed attempts, try again later"
+}
+
+// LoginAttempt describes where a sign-in attempt came from.
+type LoginAttempt struct {
+	IP        string
+	UserAgent string
+	DeviceID  string
+}
+
+func adminsCol() *mongo.Collection {
+	return config.GetDB().Collection("admins")
+}
+
+func lockFor(failures int) time.Duration {
+	if failures < freeLoginAttempts {
+		return 0
+	}
+	lock := baseLockout << uint(failures-freeLoginAttempts)
+	if lock > maxLockout || lock <= 0 {
+		lock = maxLockout
+	}
+	return lock
+}
+
+func checkLock(a *models.Admin) error {
+	if a.LockedUntil != nil && time.Now().Before(*a.LockedUntil) {
+		return &LockedError{RetryAfter: time.Until(*a.LockedUntil).Round(time.Second)}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt and locks the account once the
+// free attempts are used up. It returns the LockedError if it did.
+func recordFailure(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var a models.Admin
+	err := adminsCol().FindOneAndUpdate(ctx, bson.M{"email": email},
+		bson.M{"$inc": bson.M{"failed_logins": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&a)
+	if err != nil {
+		return nil
+	}
+	lock := lockFor(a.FailedLogins)
+	if lock == 0 {
+		return nil
+	}
+	until := time.Now().Add(lock)
+	adminsCol().UpdateOne(ctx, bson.M{"_id": a.ID}, bson.M{"$set": bson.M{"locked_until": until}})
+	fmt.Printf("DEBUG: Admin %s locked for %s after %d failed attempts\n", email, lock, a.FailedLogins)
+	return &LockedError{RetryAfter: lock}
+}
+
+// Authenticate checks an admin's email and password, honouring and
+// updating the lockout.
+func Authenticate(email, password string) (*models.Admin, error) {
+	a, err := GetByEmail(email)
+	if err != nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := checkLock(a); err != nil {
+		return a, err
+	}
+	if a.Password == "" || bcrypt.CompareHashAndPassword([]byte(a.Password), []byte(password)) != nil {
+		if lockErr := recordFailure(a.Email); lockErr != nil {
+			return a, lockErr
+		}
+		return a, ErrInvalidCredentials
+	}
+	return a, nil
+}
+
+// CheckPhoneLogin is Authenticate for the phone login, once the caller has
+// tried the phone code: codeErr is the result of that check.
+func CheckPhoneLogin(a *models.Admin, codeErr error) error {
+	if err := checkLock(a); err != nil {
+		return err
+	}
+	if codeErr != nil {
+		if lockErr := recordFailure(a.Email); lockErr != nil {
+			return lockErr
+		}
+		return codeErr
+	}
+	return nil
+}
+
+func deviceKey(at LoginAttempt) string {
+	id := at.DeviceID
+	if id == "" {
+		id = at.UserAgent
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:16])
+}
+
+// RecordLoginEvent adds an entry to the admin login audit trail.
+func RecordLoginEvent(email, method, outcome, reason string, at LoginAttempt, newDevice bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := config.AdminLoginEventsCol.InsertOne(ctx, models.AdminLoginEvent{
+		ID:        primitive.NewObjectID(),
+		Email:     strings.ToLower(email),
+		Method:    method,
+		Outcome:   outcome,
+		Reason:    reason,
+		IP:        at.IP,
+		UserAgent: at.UserAgent,
+		Device:    sessionsvc.DescribeDevice(at.UserAgent),
+		NewDevice: newDevice,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Printf("ERROR: Failed to record admin login event for %s: %v\n", email, err)
+	}
+}
+
+// adminOrganizer returns the organizer account an admin's session runs
+// on, creating it for admins who have never signed in as an organizer.
+func adminOrganizer(ctx context.Context, email string) (*models.Organizer, error) {
+	var org models.Organizer
+	err := config.OrgsCol.FindOne(ctx, bson.M{"email": email}).Decode(&org)
+	if err == mongo.ErrNoDocuments {
+		org = models.Organizer{
+			ID:                primitive.NewObjectID(),
+			Email:             email,
+			Role:              "admin",
+			OrganizerCategory: []string{},
+			CategoryStatus:    map[string]string{},
+			IsVerified:        true,
+			CreatedAt:         time.Now(),
+		}
+		if _, err := config.OrgsCol.InsertOne(ctx, org); err != nil {
+			return nil, err
+		}
+		return &org, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if org.Role != "admin" {
+		config.OrgsCol.UpdateOne(ctx, bson.M{"_id": org.ID}, bson.M{"$set": bson.M{"role": "admin"}})
+		org.Role = "admin"
+	}
+	return &org, nil
+}
+
+// CompleteLogin finishes a successful admin sign-in: it clears the failed
+// attempts, remembers the device, emails an alert when the device is new,
+// records the event and returns the organizer account to start the
+// session on.
+func CompleteLogin(a *models.Admin, method string, at LoginAttempt) (*models.Organizer, error) {
+	perms, err := PermissionsFor(a.Email)
+	if err != nil {
+		return nil, err
+	}
+	if len(perms) == 0 {
+		RecordLoginEvent(a.Email, method, "no_access", "", at, false)
+		return nil, ErrNoAdminAccess
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key := deviceKey(at)
+	known := false
+	for _, d := range a.KnownDevices {
+		if d == key {
+			known = true
+			break
+		}
+	}
+	now := time.Now()
+	update := bson.M{
+		"$set":   bson.M{"failed_logins": 0, "last_login_at": now},
+		"$unset": bson.M{"locked_until": ""},
+	}
+	if !known {
+		update["$push"] = bson.M{"known_devices": bson.M{"$each": []string{key}, "$slice": -maxKnownDevices}}
+	}
+	if _, err := adminsCol().UpdateOne(ctx, bson.M{"_id": a.ID}, update); err != nil {
+		return nil, err
+	}
+
+	org, err := adminOrganizer(ctx, a.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// The very first sign-in has nothing to compare against
+	newDevice := !known && len(a.KnownDevices) > 0
+	RecordLoginEvent(a.Email, method, "success", "", at, newDevice)
+	if newDevice {
+		device := sessionsvc.DescribeDevice(at.UserAgent)
+		go func(email string) {
+			if err := config.SendAdminLoginAlert(email, device, at.IP, now); err != nil {
+				fmt.Printf("ERROR: Failed to send new device alert to %s: %v\n", email, err)
+			}
+		}(a.Email)
+	}
+	return org, nil
+}
+
+type mfaClaims struct {
+	Purpose string `json:"purpose"`
+	Method  string `json:"method"`
+	jwt.RegisteredClaims
+}
+
+// IssueMFAToken returns the short-lived token that carries a password or
+// phone sign-in over to the second factor step.
+func IssueMFAToken(email, method string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, mfaClaims{
+		Purpose: mfaTokenPurpose,
+		Method:  method,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   email,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+		},
+	})
+	return token.SignedString(config.JWTSecret())
+}
+
+// ParseMFAToken returns the email and first factor of an MFA token.
+func ParseMFAToken(tokenStr string) (string, string, error) {
+	var claims mfaClaims
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
+		return config.JWTSecret(), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithExpirationRequired())
+	if err != nil || claims.Purpose != mfaTokenPurpose || claims.Subject == "" {
+		return "", "", errors.New("sign-in expired, please start again")
+	}
+	return claims.Subject, claims.Method, nil
+}
+
+// VerifySecondFactor checks a TOTP code, or a recovery code, which is used
+// up. Failures count towards the lockout. It returns the method used.
+func VerifySecondFactor(email, code, recoveryCode string) (string, error) {
+	a, err := GetByEmail(email)
+	if err != nil {
+		return "", ErrInvalidCode
+	}
+	if err := checkLock(a); err != nil {
+		return "", err
+	}
+	if !a.TOTPEnabled {
+		return "", errors.New("two-factor authentication is not enabled")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if recoveryCode != "" {
+		res, err := adminsCol().UpdateOne(ctx,
+			bson.M{"_id": a.ID, "recovery_codes": hashRecoveryCode(recoveryCode)},
+			bson.M{"$pull": bson.M{"recovery_codes": hashRecoveryCode(recoveryCode)}},
+		)
+		if err != nil {
+			return "", err
+		}
+		if res.ModifiedCount == 0 {
+			if lockErr := recordFailure(a.Email); lockErr != nil {
+				return "", lockErr
+			}
+			return "", ErrInvalidCode
+		}
+		fmt.Printf("DEBUG: Admin %s used a recovery code, %d left\n", a.Email, len(a.RecoveryCodes)-1)
+		return "recovery_code", nil
+	}
+
+	secret, err := openSecret(a.TOTPSecret)
+	if err != nil {
+		return "", err
+	}
+	step := matchTOTP(secret, code, time.Now())
+	if step == 0 || step <= a.TOTPLastStep {
+		if lockErr := recordFailure(a.Email); lockErr != nil {
+			return "", lockErr
+		}
+		return "", ErrInvalidCode
+	}
+	// A code can only be used once, even by two requests at the same time
+	res, err := adminsCol().UpdateOne(ctx,
+		bson.M{"_id": a.ID, "$or": []bson.M{{"totp_last_step": bson.M{"$exists": false}}, {"totp_last_step": bson.M{"$lt": step}}}},
+		bson.M{"$set": bson.M{"totp_last_step": step}},
+	)
+	if err != nil {
+		return "", err
+	}
+	if res.ModifiedCount == 0 {
+		return "", ErrInvalidCode
+	}
+	return "totp", nil
+}
+
+// TwoFactorStatus is an admin's own view of their two-factor setup.
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	Required          bool       `json:"required"`
+}
+
+func GetTwoFactorStatus(email string) (*TwoFactorStatus, error) {
+	settings, err := GetSecuritySettings()
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{Required: settings.Require2FA}
+	if a, err := GetByEmail(email); err == nil {
+		status.Enabled = a.TOTPEnabled
+		status.EnabledAt = a.TOTPEnabledAt
+		status.RecoveryCodesLeft = len(a.RecoveryCodes)
+	}
+	return status, nil
+}
+
+// BeginTOTPSetup makes a new secret for the admin to scan. It only takes
+// effect once EnableTOTP confirms a code from it.
+func BeginTOTPSetup(email string) (string, string, error) {
+	if a, err := GetByEmail(email); err == nil && a.TOTPEnabled {
+		return "", "", errors.New("two-factor authentication is already enabled")
+	}
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := sealSecret(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err = adminsCol().UpdateOne(ctx, bson.M{"email": email}, bson.M{
+		"$set":         bson.M{"totp_pending_secret": sealed, "updatedAt": now},
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID(), "email": email, "createdAt": now},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return "", "", err
+	}
+	return secret, otpauthURL(email, secret), nil
+}
+
+// EnableTOTP turns two-factor authentication on once the admin proves
+// their app has the pending secret, and returns fresh recovery codes.
+func EnableTOTP(email, code string) ([]string, error) {
+	a, err := GetByEmail(email)
+	if err != nil || a.TOTPPendingSecret == "" {
+		return nil, errors.New("start the two-factor setup first")
+	}
+	secret, err := openSecret(a.TOTPPendingSecret)
+	if err != nil {
+		return nil, err
+	}
+	step := matchTOTP(secret, code, time.Now())
+	if step == 0 {
+		return nil, ErrInvalidCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err = adminsCol().UpdateOne(ctx, bson.M{"_id": a.ID}, bson.M{
+		"$set": bson.M{
+			"totp_enabled":    true,
+			"totp_enabled_at": now,
+			"totp_secret":     a.TOTPPendingSecret,
+			"totp_last_step":  step,
+			"recovery_codes":  hashes,
+			"updatedAt":       now,
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	forgetMFAState(email)
+	fmt.Printf("DEBUG: Admin %s enabled two-factor authentication\n", email)
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off after a last check of
+// the second factor. It is refused while 2FA is required for all admins.
+func DisableTOTP(email, code, recoveryCode string) error {
+	settings, err := GetSecuritySettings()
+	if err != nil {
+		return err
+	}
+	if settings.Require2FA {
+		return errors.New("two-factor authentication is required for all admins")
+	}
+	if _, err := VerifySecondFactor(email, code, recoveryCode); err != nil {
+		return err
+	}
+	return clearTOTP(email)
+}
+
+func clearTOTP(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := adminsCol().UpdateOne(ctx, bson.M{"email": email}, bson.M{
+		"$set": bson.M{"totp_enabled": false, "updatedAt": time.Now()},
+		"$unset": bson.M{
+			"totp_enabled_at":     "",
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_last_step":      "",
+			"recovery_codes":      "",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("admin not found")
+	}
+	forgetMFAState(email)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the admin's recovery codes.
+func RegenerateRecoveryCodes(email, code string) ([]string, error) {
+	if _, err := VerifySecondFactor(email, code, ""); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = adminsCol().UpdateOne(ctx, bson.M{"email": email}, bson.M{"$set": bson.M{"recovery_codes": hashes}})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTOTP removes another admin's two-factor setup, e.g. after they
+// lose their phone. Their sessions are ended so they sign in again.
+func ResetTOTP(email, actorEmail string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if strings.EqualFold(email, actorEmail) {
+		return errors.New("use your own two-factor settings to change your setup")
+	}
+	if err := clearTOTP(email); err != nil {
+		return err
+	}
+	endAdminSessions(email, "mfa_reset")
+	fmt.Printf("DEBUG: Admin %s reset two-factor authentication of %s\n", actorEmail, email)
+	return nil
+}
+
+// Unlock clears an admin's failed attempts and lock.
+func Unlock(email string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := adminsCol().UpdateOne(ctx, bson.M{"email": strings.ToLower(strings.TrimSpace(email))}, bson.M{
+		"$set":   bson.M{"failed_logins": 0},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("admin not found")
+	}
+	return nil
+}
+
+func endAdminSessions(email, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var org models.Organizer
+	if err := config.OrgsCol.FindOne(ctx, bson.M{"email": email}).Decode(&org); err == nil {
+		_, _ = sessionsvc.RevokeAll(sessionsvc.KindOrganizer, org.ID.Hex(), "", reason)
+	}
+}
+
+func GetSecuritySettings() (*models.AdminSecuritySettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var s models.AdminSecuritySettings
+	err := config.AdminSettingsCol.FindOne(ctx, bson.M{"_id": securitySettingsID}).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return &models.AdminSecuritySettings{ID: securitySettingsID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateSecuritySettings changes whether every admin must use 2FA. An
+// admin can only require it once they use it themselves.
+func UpdateSecuritySettings(require2FA bool, actorEmail string) (*models.AdminSecuritySettings, error) {
+	if require2FA {
+		if a, err := GetByEmail(actorEmail); err != nil || !a.TOTPEnabled {
+			return nil, errors.New("enable two-factor authentication on your own account first")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s := models.AdminSecuritySettings{
+		ID:         securitySettingsID,
+		Require2FA: require2FA,
+		UpdatedBy:  actorEmail,
+		UpdatedAt:  time.Now(),
+	}
+	_, err := config.AdminSettingsCol.ReplaceOne(ctx, bson.M{"_id": securitySettingsID}, s, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	mfaStateCache.Range(func(k, _ interface{}) bool {
+		mfaStateCache.Delete(k)
+		return true
+	})
+	fmt.Printf("DEBUG: Admin %s set require_2fa to %v\n", actorEmail, require2FA)
+	return &s, nil
+}
+
+// MFAState is whether an admin has 2FA set up and whether their sessions
+// must pass it.
+type MFAState struct {
+	Enrolled bool
+	Required bool
+}
+
+type cachedMFAState struct {
+	state     MFAState
+	checkedAt time.Time
+}
+
+var mfaStateCache sync.Map // email -> cachedMFAState
+
+func forgetMFAState(email string) {
+	mfaStateCache.Delete(strings.ToLower(email))
+}
+
+// MFAStateFor reports an admin's two-factor state. Results are cached for a
+// short while.
+func MFAStateFor(email string) (MFAState, error) {
+	key := strings.ToLower(email)
+	if v, ok := mfaStateCache.Load(key); ok {
+		cached := v.(cachedMFAState)
+		if time.Since(cached.checkedAt) < mfaStateCacheTTL {
+			return cached.state, nil
+		}
+	}
+
+	settings, err := GetSecuritySettings()
+	if err != nil {
+		return MFAState{}, err
+	}
+	state := MFAState{Required: settings.Require2FA}
+	if a, err := GetByEmail(email); err == nil && a.TOTPEnabled {
+		state.Enrolled = true
+		state.Required = true
+	}
+	mfaStateCache.Store(key, cachedMFAState{state: state, checkedAt: time.Now()})
+	return state, nil
+}
+
+// ListLoginEvents returns the latest admin sign-in attempts, optionally
+// for one email or outcome.
+func ListLoginEvents(email, outcome string, limit int64) ([]models.AdminLoginEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	filter := bson.M{}
+	if email != "" {
+		filter["email"] = strings.ToLower(strings.TrimSpace(email))
+	}
+	if outcome != "" {
+		filter["outcome"] = outcome
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.AdminLoginEventsCol.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	list := []models.AdminLoginEvent{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
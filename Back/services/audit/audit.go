@@ -0,0 +1,298 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const redacted = "[redacted]"
+
+// entityCollections maps the resource name used in admin and organizer
+// routes to the collection its documents live in.
+func entityCollections() map[string]*mongo.Collection {
+	return map[string]*mongo.Collection{
+		"organizers":       config.OrgsCol,
+		"events":           config.EventsCol,
+		"play":             config.PlaysCol,
+		"dining":           config.DiningsCol,
+		"users":            config.UsersCol,
+		"coupons":          config.CouponsCol,
+		"coupon-campaigns": config.CouponCampaignsCol,
+		"offers":           config.OffersCol,
+		"passes":           config.PassesCol,
+		"pass-plans":       config.PassPlansCol,
+		"roles":            config.AdminRolesCol,
+		"team":             config.OrgMembersCol,
+		"admins":           config.GetDB().Collection("admins"),
+	}
+}
+
+// notSensitive lists settings whose names look like personal data but are
+// not.
+var notSensitive = map[string]bool{"max_per_phone": true}
+
+// sensitive reports whether a field must never be copied into the audit
+// log: password hashes, OTPs and secrets, and personal or financial details
+// such as PAN, bank accounts, GST numbers and phone numbers.
+func sensitive(key string) bool {
+	k := strings.ToLower(key)
+	if notSensitive[k] {
+		return false
+	}
+	if strings.HasPrefix(k, "pan") {
+		return true
+	}
+	for _, word := range []string{
+		"password", "otp", "secret", "token", "hash", "recovery_codes", "known_devices",
+		"bank", "ifsc", "account", "gst", "phone", "aadhaar", "upi",
+	} {
+		if strings.Contains(k, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact stands in for a sensitive value. It carries a short fingerprint so
+// that a change to the field still shows up without revealing either value.
+func redact(v interface{}) string {
+	if v == nil || v == "" {
+		return redacted
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprint(v)))
+	return redacted[:len(redacted)-1] + ":" + hex.EncodeToString(sum[:4]) + "]"
+}
+
+// scrub is plain with sensitive fields redacted at every depth, so details
+// nested in e.g. bank_details or gst_accounts stay out of the log too.
+func scrub(v interface{}) interface{} {
+	switch t := plain(v).(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if sensitive(k) {
+				t[k] = redact(e)
+			} else {
+				t[k] = scrub(e)
+			}
+		}
+		return t
+	case []interface{}:
+		for i, e := range t {
+			t[i] = scrub(e)
+		}
+		return t
+	default:
+		return t
+	}
+}
+
+// plain turns decoded BSON into maps and slices so that documents compare
+// and serialise cleanly.
+func plain(v interface{}) interface{} {
+	switch t := v.(type) {
+	case primitive.D:
+		m := make(map[string]interface{}, len(t))
+		for _, e := range t {
+			m[e.Key] = plain(e.Value)
+		}
+		return m
+	case primitive.M:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[k] = plain(e)
+		}
+		return m
+	case primitive.A:
+		a := make([]interface{}, len(t))
+		for i, e := range t {
+			a[i] = plain(e)
+		}
+		return a
+	default:
+		return v
+	}
+}
+
+// AdminID returns the id of the admin account with this email, or "" if
+// there is none. Admin routes name the admin they act on by email.
+func AdminID(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var doc struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := config.GetDB().Collection("admins").FindOne(ctx, bson.M{"email": email}).Decode(&doc); err != nil {
+		return ""
+	}
+	return doc.ID.Hex()
+}
+
+// Snapshot returns the current document of an entity with sensitive fields
+// redacted, or nil if the entity is not tracked or does not exist.
+func Snapshot(entity, id string) map[string]interface{} {
+	col, ok := entityCollections()[entity]
+	if !ok || col == nil {
+		return nil
+	}
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var doc bson.M
+	if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc); err != nil {
+		return nil
+	}
+	snap, _ := scrub(doc).(map[string]interface{})
+	return snap
+}
+
+// Diff lists the top-level fields that differ between two snapshots.
+func Diff(before, after map[string]interface{}) []models.AuditChange {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	fields := make([]string, 0, len(keys))
+	for k := range keys {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+
+	changes := []models.AuditChange{}
+	for _, f := range fields {
+		from, to := before[f], after[f]
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		if sensitive(f) {
+			from, to = redacted, redacted
+		}
+		changes = append(changes, models.AuditChange{Field: f, From: from, To: to})
+	}
+	return changes
+}
+
+// Record writes an entry to the audit log. Failing to write it never fails
+// the change itself.
+func Record(entry *models.AuditLog) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	entry.ID = primitive.NewObjectID()
+	entry.CreatedAt = time.Now()
+	if _, err := config.AuditLogsCol.InsertOne(ctx, entry); err != nil {
+		fmt.Printf("ERROR: Failed to write audit log for %s %s: %v\n", entry.Action, entry.EntityID, err)
+	}
+}
+
+// Filter narrows the audit log search. Actor matches an email or an
+// organizer id.
+type Filter struct {
+	Actor    string
+	Entity   string
+	EntityID string
+	Action   string
+	From     *time.Time
+	To       *time.Time
+	Page     int64
+	Limit    int64
+}
+
+// List returns matching audit entries, newest first, and the total count.
+func List(f Filter) ([]models.AuditLog, int64, error) {
+	if f.Page < 1 {
+		f.Page = 1
+	}
+	if f.Limit < 1 || f.Limit > 200 {
+		f.Limit = 50
+	}
+
+	filter := bson.M{}
+	if f.Actor != "" {
+		filter["$or"] = []bson.M{
+			{"actor_email": strings.ToLower(strings.TrimSpace(f.Actor))},
+			{"actor_id": f.Actor},
+			{"member_id": f.Actor},
+		}
+	}
+	if f.Entity != "" {
+		filter["entity"] = f.Entity
+	}
+	if f.EntityID != "" {
+		filter["entity_id"] = f.EntityID
+	}
+	if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if f.From != nil || f.To != nil {
+		created := bson.M{}
+		if f.From != nil {
+			created["$gte"] = *f.From
+		}
+		if f.To != nil {
+			created["$lt"] = *f.To
+		}
+		filter["created_at"] = created
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := config.AuditLogsCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := config.AuditLogsCol.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip((f.Page-1)*f.Limit).
+		SetLimit(f.Limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	list := []models.AuditLog{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, 0, err
+	}
+	// Nested values decode as BSON documents; make them plain JSON again
+	for i := range list {
+		for j := range list[i].Changes {
+			list[i].Changes[j].From = plain(list[i].Changes[j].From)
+			list[i].Changes[j].To = plain(list[i].Changes[j].To)
+		}
+		for k, v := range list[i].Before {
+			list[i].Before[k] = plain(v)
+		}
+		for k, v := range list[i].After {
+			list[i].After[k] = plain(v)
+		}
+	}
+	return list, total, nil
+}
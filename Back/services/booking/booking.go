@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"math/big"
 	"strconv"
 	"time"
@@ -61,9 +62,14 @@ func Create(b *models.Booking) error {
 	}
 	b.BookedAt = time.Now()
 
+	_, categories, err := ResolveShowtime(&event, b.ShowtimeID)
+	if err != nil {
+		return err
+	}
+
 	capacityMap := map[string]int{}
-	if event.TicketCategories != nil {
-		for _, cat := range event.TicketCategories {
+	if categories != nil {
+		for _, cat := range categories {
 			if cat.Capacity > 0 {
 				capacityMap[cat.Name] = cat.Capacity
 			}
@@ -71,19 +77,30 @@ func Create(b *models.Booking) error {
 	}
 
 	if len(capacityMap) > 0 && b.Tickets != nil {
+		// Tiered pricing can split a category across lines, so check totals
+		requested := map[string]int{}
 		for _, t := range b.Tickets {
-			if t.Category == "" {
+			requested[t.Category] += t.Quantity
+		}
+		checked := map[string]bool{}
+		for _, t := range b.Tickets {
+			if t.Category == "" || checked[t.Category] {
 				continue
 			}
+			checked[t.Category] = true
 			cap, hasCap := capacityMap[t.Category]
 			if !hasCap {
 				continue
 			}
+			match := bson.M{
+				"event_id": b.EventID,
+				"status":   bson.M{"$in": []string{"booked", "confirmed"}},
+			}
+			if !b.ShowtimeID.IsZero() {
+				match["showtime_id"] = b.ShowtimeID
+			}
 			pipeline := []bson.M{
-				{"$match": bson.M{
-					"event_id": b.EventID,
-					"status":   bson.M{"$in": []string{"booked", "confirmed"}},
-				}},
+				{"$match": match},
 				{"$unwind": "$tickets"},
 				{"$match": bson.M{"tickets.category": t.Category}},
 				{"$group": bson.M{
@@ -98,7 +115,7 @@ func Create(b *models.Booking) error {
 				}
 				if cursor.All(ctx, &results) == nil && len(results) > 0 {
 					alreadyBooked := results[0].Total
-					if alreadyBooked+t.Quantity > cap {
+					if alreadyBooked+requested[t.Category] > cap {
 						available := cap - alreadyBooked
 						if available <= 0 {
 							return errors.New("seats full for category: " + t.Category)
@@ -110,21 +127,75 @@ func Create(b *models.Booking) error {
 		}
 	}
 
+	if event.SeatMap != nil {
+		if len(b.SeatIDs) == 0 {
+			return errors.New("seat selection is required for this event")
+		}
+		if err := CheckSeatsAvailable(ctx, b.EventID, b.ShowtimeID, b.SeatIDs, b.LockKey); err != nil {
+			return err
+		}
+	}
+
 	_, err = col.InsertOne(ctx, b)
-	return err
+	if err != nil {
+		return err
+	}
+	if event.SeatMap != nil {
+		// The booking is stored first so that a rival claimant sees it as
+		// holding the seats
+		if err := claimSeats(ctx, b); err != nil {
+			if _, delErr := col.DeleteOne(ctx, bson.M{"_id": b.ID}); delErr != nil {
+				fmt.Printf("ERROR: Failed to remove booking %s after losing its seats: %v\n", b.BookingID, delErr)
+			}
+			return err
+		}
+	}
+	return attachSeatLocks(ctx, b)
 }
 
-func GetAvailability(eventID string) (map[string]int, error) {
+// CheckConfirmable reports why a pending event booking can no longer be
+// confirmed once its payment lands: the event or its show was cancelled in
+// the meantime, or its seats were held so long that they went to another
+// buyer. Seats still free are claimed again for the booking.
+func CheckConfirmable(ctx context.Context, b *models.Booking) error {
+	var event models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": b.EventID}).Decode(&event); err != nil {
+		return err
+	}
+	if _, _, err := ResolveShowtime(&event, b.ShowtimeID); err != nil {
+		return err
+	}
+	if event.SeatMap != nil && len(b.SeatIDs) > 0 {
+		return claimSeats(ctx, b)
+	}
+	return nil
+}
+
+// ConfirmRefused reports whether an error from CheckConfirmable means the
+// booking cannot stand, rather than that the check itself failed.
+func ConfirmRefused(err error) bool {
+	return errors.Is(err, ErrEventCancelled) || errors.Is(err, ErrShowCancelled) || errors.Is(err, ErrSeatTaken)
+}
+
+func GetAvailability(eventID string, showtimeID string) (map[string]int, error) {
 	objID, err := primitive.ObjectIDFromHex(eventID)
 	if err != nil {
 		return nil, err
 	}
+	match := bson.M{"event_id": objID, "status": bson.M{"$in": []string{"booked", "confirmed"}}}
+	if showtimeID != "" {
+		stID, err := primitive.ObjectIDFromHex(showtimeID)
+		if err != nil {
+			return nil, errors.New("invalid showtime_id")
+		}
+		match["showtime_id"] = stID
+	}
 	col := config.EventBookingsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	pipeline := []bson.M{
-		{"$match": bson.M{"event_id": objID, "status": bson.M{"$in": []string{"booked", "confirmed"}}}},
+		{"$match": match},
 		{"$unwind": "$tickets"},
 		{"$group": bson.M{
 			"_id":   "$tickets.category",
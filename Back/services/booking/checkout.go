@@ -0,0 +1,175 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"ticpin-backend/models"
+	couponsvc "ticpin-backend/services/coupon"
+	offersvc "ticpin-backend/services/offer"
+	walletsvc "ticpin-backend/services/wallet"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrOfferNeedsGateway = errors.New("this offer needs a gateway payment and cannot be paid from the wallet alone")
+
+// Checkout describes the order a booking's coupon, offer and wallet
+// payment are worked out for.
+type Checkout struct {
+	Category      string // "event", "play", "dining"
+	EntityID      string
+	OrganizerID   primitive.ObjectID
+	City          string
+	OrderAmount   float64
+	UserID        string
+	UserEmail     string
+	OrderID       string
+	CouponCode    string
+	OfferID       string
+	PaymentMethod string
+	CardBIN       string
+	UseTicpass    bool
+}
+
+// Discounts holds the coupon and offer uses and the wallet money a
+// checkout set aside until its booking is made.
+type Discounts struct {
+	Amount       float64
+	CouponCode   string
+	OfferID      primitive.ObjectID
+	WalletAmount float64
+
+	co           Checkout
+	offerPayment *models.OfferPaymentCondition
+	coupon       *models.CouponRedemption
+	offer        *models.OfferRedemption
+	wallet       *models.WalletEntry
+}
+
+// ApplyDiscounts reserves the coupon and then the offer for an order. A
+// coupon or offer that does not apply is left off rather than failing the
+// checkout.
+func ApplyDiscounts(co Checkout) *Discounts {
+	d := &Discounts{co: co}
+
+	var excludesOffers bool
+	if co.CouponCode != "" {
+		result, err := couponsvc.Validate(co.CouponCode, couponsvc.Checkout{
+			Category:    co.Category,
+			OrderAmount: co.OrderAmount,
+			UserID:      co.UserID,
+			UserEmail:   co.UserEmail,
+			TargetID:    co.EntityID,
+			OrganizerID: co.OrganizerID,
+			City:        co.City,
+			WithOffer:   co.OfferID != "",
+			WithTicpass: co.UseTicpass,
+		})
+		if err == nil {
+			// Hold the use now so the coupon cannot run out before payment lands
+			reservation, err := couponsvc.Reserve(result.Coupon, co.UserID, co.UserEmail, co.OrderID, co.OrderAmount, result.DiscountAmount)
+			if err != nil {
+				fmt.Printf("DEBUG: Coupon reservation failed - %s\n", err.Error())
+			} else {
+				d.Amount = result.DiscountAmount
+				d.CouponCode = result.Coupon.Code
+				d.coupon = reservation
+				excludesOffers = result.Coupon.Rules != nil && result.Coupon.Rules.ExcludeOffers
+			}
+		} else {
+			fmt.Printf("DEBUG: Coupon validation failed - %s\n", err.Error())
+		}
+	}
+
+	// The offer the user picked, or else the best auto-apply offer for the order
+	applied, err := offersvc.Apply(offersvc.Checkout{
+		Category:      co.Category,
+		EntityID:      co.EntityID,
+		OrderAmount:   co.OrderAmount,
+		UserID:        co.UserID,
+		OrderID:       co.OrderID,
+		PaymentMethod: co.PaymentMethod,
+		CardBIN:       co.CardBIN,
+		AutoApply:     !excludesOffers,
+	}, co.OfferID)
+	if err != nil {
+		fmt.Printf("DEBUG: Offer validation failed - %s\n", err.Error())
+	} else if applied != nil {
+		d.OfferID = applied.Offer.ID
+		d.offerPayment = applied.Offer.Payment
+		d.offer = applied.Redemption
+		d.Amount += applied.DiscountAmount
+	}
+	return d
+}
+
+// HoldWallet sets aside up to amount of the user's wallet towards
+// grandTotal; the gateway charges the rest. When it fails everything the
+// checkout reserved is released.
+func (d *Discounts) HoldWallet(amount, grandTotal float64) error {
+	// Payment conditions are checked against the gateway payment, so an
+	// order the gateway never sees cannot carry such an offer
+	if d.offer != nil && d.offerPayment != nil && grandTotal-math.Min(amount, grandTotal) < 0.01 {
+		d.Release("booking failed")
+		return ErrOfferNeedsGateway
+	}
+	if amount <= 0 || grandTotal <= 0 {
+		return nil
+	}
+	walletAmount := math.Min(amount, grandTotal)
+	hold, err := walletsvc.Hold(d.co.UserID, walletAmount, d.co.Category, d.co.OrderID)
+	if err != nil {
+		d.Release("booking failed")
+		return err
+	}
+	d.wallet = hold
+	d.WalletAmount = walletAmount
+	return nil
+}
+
+// Release frees the coupon, offer and wallet holds of a checkout whose
+// booking could not be made.
+func (d *Discounts) Release(reason string) {
+	if d.coupon != nil {
+		_ = couponsvc.ReleaseReservation(d.coupon.ID, reason)
+	}
+	if d.wallet != nil {
+		_ = walletsvc.ReleaseHold(d.wallet.ID, reason)
+	}
+	if d.offer != nil {
+		_ = offersvc.ReleaseReservation(d.offer.ID, reason)
+	}
+}
+
+// Attach links the holds to the booking once it exists, and commits them
+// right away when the booking is already paid.
+func (d *Discounts) Attach(bookingID string, paid bool) {
+	if d.coupon != nil {
+		if err := couponsvc.AttachBooking(d.coupon.ID, bookingID); err != nil {
+			fmt.Printf("ERROR: Failed to link coupon reservation to booking %s: %v\n", bookingID, err)
+		}
+		if paid {
+			_ = couponsvc.Commit(bookingID)
+		}
+	}
+	if d.wallet != nil {
+		if err := walletsvc.AttachBooking(d.wallet.ID, bookingID); err != nil {
+			fmt.Printf("ERROR: Failed to link wallet hold to booking %s: %v\n", bookingID, err)
+		}
+		if paid {
+			_ = walletsvc.Settle(bookingID)
+		}
+	}
+	if d.offer != nil {
+		if err := offersvc.AttachBooking(d.offer.ID, bookingID); err != nil {
+			fmt.Printf("ERROR: Failed to link offer reservation to booking %s: %v\n", bookingID, err)
+		}
+		// A pending booking's offer is committed by the payment webhook,
+		// once the payment condition has been checked
+		if paid {
+			_ = offersvc.Commit(bookingID)
+		}
+	}
+}
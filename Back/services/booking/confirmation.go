@@ -56,11 +56,17 @@ func SendConfirmationEmail(orderID string, category string) error {
 			}
 		}
 
+		// The email shows the day of the slot, not of the purchase
+		day, ok := ParseBookingDate(b.Date)
+		if !ok {
+			day = b.BookedAt
+		}
+
 		// Format data for email
 		data := config.BookingEmailData{
-			Day:          b.BookedAt.Format("Monday"),
-			Date:         b.BookedAt.Format("02"),
-			Month:        b.BookedAt.Format("January"),
+			Day:          day.Format("Monday"),
+			Date:         day.Format("02"),
+			Month:        day.Format("January"),
 			Time:         b.Slot,
 			PlayName:     b.VenueName,
 			VenueAddress: b.Address,
@@ -90,11 +96,17 @@ func SendConfirmationEmail(orderID string, category string) error {
 			}
 		}
 
+		// The email shows the day of the reservation, not of the purchase
+		day, ok := ParseBookingDate(b.Date)
+		if !ok {
+			day = b.BookedAt
+		}
+
 		// Format data for email
 		data := config.BookingEmailData{
-			Day:               b.BookedAt.Format("Monday"),
-			Date:              b.BookedAt.Format("02"),
-			Month:             b.BookedAt.Format("January"),
+			Day:               day.Format("Monday"),
+			Date:              day.Format("02"),
+			Month:             day.Format("January"),
 			Time:              b.TimeSlot,
 			RestaurantName:    b.VenueName,
 			RestaurantAddress: b.City,
@@ -125,6 +137,11 @@ func SendConfirmationEmail(orderID string, category string) error {
 			event.Time = "All Day"
 		}
 
+		showDate, showTime := BookingSchedule(&event, &b)
+		if showDate.IsZero() {
+			showDate = b.BookedAt
+		}
+
 		eventImageURL := event.PortraitImageURL
 		if eventImageURL == "" {
 			eventImageURL = event.LandscapeImageURL
@@ -132,10 +149,10 @@ func SendConfirmationEmail(orderID string, category string) error {
 
 		// Format data for email
 		data := config.BookingEmailData{
-			Day:           b.BookedAt.Format("Monday"),
-			Date:          b.BookedAt.Format("02"),
-			Month:         b.BookedAt.Format("January"),
-			Time:          event.Time,
+			Day:           showDate.Format("Monday"),
+			Date:          showDate.Format("02"),
+			Month:         showDate.Format("January"),
+			Time:          showTime,
 			EventName:     b.EventName,
 			Venue:         event.VenueName,
 			Location:      b.City,
@@ -0,0 +1,245 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrPurchaseLimit = errors.New("purchase limit reached")
+)
+
+// ValidatePurchaseRules rejects negative limits before an event is saved.
+func ValidatePurchaseRules(r *models.PurchaseRules) error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxPerUser < 0 || r.MaxPerPhone < 0 {
+		return errors.New("purchase limits cannot be negative")
+	}
+	for cat, max := range r.CategoryMax {
+		if max < 0 {
+			return fmt.Errorf("purchase limit for %s cannot be negative", cat)
+		}
+	}
+	return nil
+}
+
+// ValidateHolderNames makes sure every ticket in the order carries the name
+// of the person attending when the event requires it.
+func ValidateHolderNames(r *models.PurchaseRules, tickets []models.BookingTicket, names []string) ([]string, error) {
+	if r == nil || !r.RequireHolderNames {
+		return names, nil
+	}
+	total := 0
+	for _, t := range tickets {
+		total += t.Quantity
+	}
+	if len(names) != total {
+		return nil, fmt.Errorf("a holder name is required for each of the %d tickets", total)
+	}
+	cleaned := make([]string, len(names))
+	for i, n := range names {
+		n = strings.TrimSpace(n)
+		if len(n) < 3 {
+			return nil, fmt.Errorf("holder name %d must be at least 3 characters", i+1)
+		}
+		cleaned[i] = n
+	}
+	return cleaned, nil
+}
+
+func recordLimitHit(ctx context.Context, hit models.PurchaseLimitHit) {
+	hit.ID = primitive.NewObjectID()
+	hit.CreatedAt = time.Now()
+	if _, err := config.PurchaseLimitHitsCol.InsertOne(ctx, hit); err != nil {
+		fmt.Printf("DEBUG: Failed to record purchase limit hit: %v\n", err)
+	}
+}
+
+// CheckPurchaseLimits counts the tickets the buyer already holds for the
+// event, in confirmed bookings and pending ones still inside their hold,
+// and refuses orders that would take them past the event's purchase rules.
+// Refusals are logged for the organizer's report.
+func CheckPurchaseLimits(ctx context.Context, e *models.Event, userID, phone, email string, tickets []models.BookingTicket) error {
+	return checkPurchaseLimits(ctx, e, userID, phone, email, tickets, primitive.NilObjectID)
+}
+
+// RecheckPurchaseLimits settles orders from the same buyer that passed
+// CheckPurchaseLimits together. Once b is stored it is checked against the
+// bookings stored before it only, so the later of two racing orders gives
+// way; if it is over the limit it is removed along with its seat claims.
+func RecheckPurchaseLimits(ctx context.Context, e *models.Event, b *models.Booking, userID, phone string) error {
+	err := checkPurchaseLimits(ctx, e, userID, phone, b.UserEmail, b.Tickets, b.ID)
+	if !errors.Is(err, ErrPurchaseLimit) {
+		return err
+	}
+	releaseSeatClaims(ctx, b.ID, b.SeatIDs)
+	if _, delErr := config.EventBookingsCol.DeleteOne(ctx, bson.M{"_id": b.ID}); delErr != nil {
+		fmt.Printf("ERROR: Failed to remove booking %s over the purchase limit: %v\n", b.BookingID, delErr)
+	}
+	return err
+}
+
+// checkPurchaseLimits is CheckPurchaseLimits counting only bookings stored
+// before the booking before, when it is set.
+func checkPurchaseLimits(ctx context.Context, e *models.Event, userID, phone, email string, tickets []models.BookingTicket, before primitive.ObjectID) error {
+	r := e.PurchaseRules
+	if r == nil || (r.MaxPerUser == 0 && r.MaxPerPhone == 0 && len(r.CategoryMax) == 0) {
+		return nil
+	}
+
+	var owners []bson.M
+	if userID != "" {
+		owners = append(owners, bson.M{"user_id": userID})
+	}
+	if phone != "" {
+		owners = append(owners, bson.M{"user_phone": phone})
+	}
+	if len(owners) == 0 {
+		return nil
+	}
+
+	filter := bson.M{"event_id": e.ID, "$or": owners}
+	if !before.IsZero() {
+		filter["_id"] = bson.M{"$lt": before}
+	}
+	cursor, err := config.EventBookingsCol.Find(ctx, bson.M{"$and": []bson.M{filter, HoldingTickets(time.Now())}},
+		options.Find().SetProjection(bson.M{"tickets": 1, "user_id": 1, "user_phone": 1}))
+	if err != nil {
+		return err
+	}
+	var held []models.Booking
+	if err := cursor.All(ctx, &held); err != nil {
+		return err
+	}
+
+	var heldByUser, heldByPhone int
+	heldByCategory := map[string]int{}
+	for _, b := range held {
+		for _, t := range b.Tickets {
+			if userID != "" && b.UserID == userID {
+				heldByUser += t.Quantity
+			}
+			if phone != "" && b.UserPhone == phone {
+				heldByPhone += t.Quantity
+			}
+			heldByCategory[t.Category] += t.Quantity
+		}
+	}
+
+	requested := 0
+	requestedByCategory := map[string]int{}
+	for _, t := range tickets {
+		requested += t.Quantity
+		requestedByCategory[t.Category] += t.Quantity
+	}
+
+	hit := models.PurchaseLimitHit{
+		EventID:   e.ID,
+		UserID:    userID,
+		UserPhone: phone,
+		UserEmail: email,
+		Requested: requested,
+	}
+
+	if r.MaxPerUser > 0 && userID != "" && heldByUser+requested > r.MaxPerUser {
+		hit.Rule, hit.Limit, hit.Held = "per_user", r.MaxPerUser, heldByUser
+		recordLimitHit(ctx, hit)
+		return fmt.Errorf("%w: you can hold at most %d tickets for this event", ErrPurchaseLimit, r.MaxPerUser)
+	}
+	if r.MaxPerPhone > 0 && phone != "" && heldByPhone+requested > r.MaxPerPhone {
+		hit.Rule, hit.Limit, hit.Held = "per_phone", r.MaxPerPhone, heldByPhone
+		recordLimitHit(ctx, hit)
+		return fmt.Errorf("%w: at most %d tickets can be booked per phone number for this event", ErrPurchaseLimit, r.MaxPerPhone)
+	}
+	for cat, qty := range requestedByCategory {
+		max := r.CategoryMax[cat]
+		if max > 0 && heldByCategory[cat]+qty > max {
+			hit.Rule, hit.Category, hit.Limit, hit.Held, hit.Requested = "category", cat, max, heldByCategory[cat], qty
+			recordLimitHit(ctx, hit)
+			return fmt.Errorf("%w: you can hold at most %d %s tickets", ErrPurchaseLimit, max, cat)
+		}
+	}
+	return nil
+}
+
+// TransferTicket hands one ticket of a booking to a new holder. It is the
+// only way to change a holder name once the booking is made.
+func TransferTicket(bookingID, userID, phone string, holderIndex int, toName, toEmail, toPhone string) (*models.Booking, error) {
+	toName = strings.TrimSpace(toName)
+	if len(toName) < 3 {
+		return nil, errors.New("recipient name must be at least 3 characters")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lookup := bson.M{"booking_id": bookingID}
+	if objID, err := primitive.ObjectIDFromHex(bookingID); err == nil {
+		lookup = bson.M{"$or": []bson.M{{"_id": objID}, {"booking_id": bookingID}}}
+	}
+	var b models.Booking
+	if err := config.EventBookingsCol.FindOne(ctx, lookup).Decode(&b); err != nil {
+		return nil, errors.New("booking not found")
+	}
+	if (userID == "" || userID != b.UserID) && (phone == "" || phone != b.UserPhone) {
+		return nil, errors.New("access denied: you do not own this booking")
+	}
+	if b.Status != "booked" && b.Status != "confirmed" {
+		return nil, errors.New("only confirmed bookings can be transferred")
+	}
+	if holderIndex < 0 || holderIndex >= len(b.HolderNames) {
+		return nil, errors.New("invalid ticket holder")
+	}
+
+	var e models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": b.EventID}, options.FindOne().SetProjection(bson.M{"status": 1, "purchase_rules": 1})).Decode(&e); err != nil {
+		return nil, errors.New("event not found")
+	}
+	if e.PurchaseRules == nil || !e.PurchaseRules.OfficialTransferOnly {
+		return nil, errors.New("tickets for this event are not transferable")
+	}
+	if e.Status == "cancelled" {
+		return nil, errors.New("tickets for a cancelled event cannot be transferred")
+	}
+
+	transfer := models.TicketTransfer{
+		HolderIndex:   holderIndex,
+		FromName:      b.HolderNames[holderIndex],
+		ToName:        toName,
+		ToEmail:       toEmail,
+		ToPhone:       toPhone,
+		TransferredAt: time.Now(),
+	}
+	field := fmt.Sprintf("holder_names.%d", holderIndex)
+	// The holder and status are matched again so that a transfer or
+	// cancellation made in the meantime is not overwritten
+	res, err := config.EventBookingsCol.UpdateOne(ctx, bson.M{
+		"_id":    b.ID,
+		field:    transfer.FromName,
+		"status": bson.M{"$in": []string{"booked", "confirmed"}},
+	}, bson.M{
+		"$set":  bson.M{field: toName},
+		"$push": bson.M{"transfers": transfer},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, errors.New("this ticket changed while it was being transferred, please try again")
+	}
+
+	b.HolderNames[holderIndex] = toName
+	b.Transfers = append(b.Transfers, transfer)
+	return &b, nil
+}
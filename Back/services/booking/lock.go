@@ -26,6 +26,12 @@ func CreateSlotLock(ctx context.Context, req models.LockRequest) (*models.SlotLo
 		return nil, fmt.Errorf("invalid reference id: %w", err)
 	}
 
+	if req.Type == "event" {
+		if err := validateEventSeatLock(ctx, refID, req.Date, req.Slot); err != nil {
+			return nil, err
+		}
+	}
+
 	// 1. Check if this exact slot is already locked by SOMEONE ELSE
 	conflictFilter := bson.M{
 		"type":         req.Type,
@@ -0,0 +1,130 @@
+package booking
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"ticpin-backend/models"
+)
+
+// inWindow reports whether now falls between the optional start and end.
+func inWindow(now time.Time, start, end *time.Time) bool {
+	if start != nil && now.Before(*start) {
+		return false
+	}
+	if end != nil && !now.Before(*end) {
+		return false
+	}
+	return true
+}
+
+// UnitPrice returns the price of the next ticket in a category given how
+// many have already sold. Tier quantities are cumulative: with tiers of 200
+// and then unlimited, tickets 1-200 sell at the first price and the rest at
+// the second. ok is false when no tier is currently selling.
+func UnitPrice(tc models.TicketCategory, sold int, now time.Time) (price float64, tier string, ok bool) {
+	if len(tc.Tiers) == 0 {
+		return tc.Price, "", true
+	}
+	ceiling := 0
+	for _, t := range tc.Tiers {
+		unlimited := t.Quantity <= 0
+		ceiling += t.Quantity
+		if !inWindow(now, t.StartsAt, t.EndsAt) {
+			continue
+		}
+		if unlimited || sold < ceiling {
+			return t.Price, t.Name, true
+		}
+	}
+	return 0, "", false
+}
+
+// CheckCategoryOnSale enforces the category's sale window and per-order
+// quantity limits.
+func CheckCategoryOnSale(tc models.TicketCategory, quantity int, now time.Time) error {
+	if tc.SaleStartsAt != nil && now.Before(*tc.SaleStartsAt) {
+		return fmt.Errorf("sales for %s open on %s", tc.Name, tc.SaleStartsAt.Format("02 Jan 2006, 03:04 PM"))
+	}
+	if tc.SaleEndsAt != nil && !now.Before(*tc.SaleEndsAt) {
+		return fmt.Errorf("sales for %s have closed", tc.Name)
+	}
+	if tc.MinPerOrder > 0 && quantity < tc.MinPerOrder {
+		return fmt.Errorf("a minimum of %d tickets is required for %s", tc.MinPerOrder, tc.Name)
+	}
+	if tc.MaxPerOrder > 0 && quantity > tc.MaxPerOrder {
+		return fmt.Errorf("a maximum of %d tickets is allowed per order for %s", tc.MaxPerOrder, tc.Name)
+	}
+	return nil
+}
+
+// PriceTickets prices quantity tickets of a category one by one, so an order
+// that straddles a tier boundary pays each tier's price for its share. The
+// returned lines carry one entry per price point.
+func PriceTickets(tc models.TicketCategory, sold, quantity int, now time.Time) ([]models.BookingTicket, float64, error) {
+	var lines []models.BookingTicket
+	var total float64
+	for i := 0; i < quantity; i++ {
+		price, _, ok := UnitPrice(tc, sold+i, now)
+		if !ok {
+			return nil, 0, fmt.Errorf("no tickets currently on sale for %s", tc.Name)
+		}
+		if n := len(lines); n > 0 && lines[n-1].Price == price {
+			lines[n-1].Quantity++
+		} else {
+			lines = append(lines, models.BookingTicket{Category: tc.Name, Price: price, Quantity: 1})
+		}
+		total += price
+	}
+	return lines, total, nil
+}
+
+// ValidateTicketCategories checks sale windows, order limits and tiers
+// before an event is saved.
+func ValidateTicketCategories(categories []models.TicketCategory) error {
+	for _, tc := range categories {
+		if tc.SaleStartsAt != nil && tc.SaleEndsAt != nil && !tc.SaleEndsAt.After(*tc.SaleStartsAt) {
+			return fmt.Errorf("sale end must be after sale start for %s", tc.Name)
+		}
+		if tc.MinPerOrder < 0 || tc.MaxPerOrder < 0 {
+			return fmt.Errorf("order limits for %s cannot be negative", tc.Name)
+		}
+		if tc.MaxPerOrder > 0 && tc.MinPerOrder > tc.MaxPerOrder {
+			return fmt.Errorf("minimum per order exceeds maximum for %s", tc.Name)
+		}
+		for i, t := range tc.Tiers {
+			if t.Price < 0 {
+				return fmt.Errorf("tier %d of %s has a negative price", i+1, tc.Name)
+			}
+			if t.Quantity < 0 {
+				return fmt.Errorf("tier %d of %s has a negative quantity", i+1, tc.Name)
+			}
+			if t.StartsAt != nil && t.EndsAt != nil && !t.EndsAt.After(*t.StartsAt) {
+				return fmt.Errorf("tier %d of %s ends before it starts", i+1, tc.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// StartingPrice is the cheapest ticket currently on sale across the given
+// categories, using sold counts to pick each category's active tier.
+func StartingPrice(categories []models.TicketCategory, sold map[string]int, now time.Time) (float64, error) {
+	found := false
+	var min float64
+	for _, tc := range categories {
+		price, _, ok := UnitPrice(tc, sold[tc.Name], now)
+		if !ok {
+			continue
+		}
+		if !found || price < min {
+			min = price
+			found = true
+		}
+	}
+	if !found {
+		return 0, errors.New("no tickets on sale")
+	}
+	return min, nil
+}
@@ -0,0 +1,383 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSeatUnavailable = errors.New("seat is not available")
+	// ErrSeatTaken means another booking holds a seat claim
+	ErrSeatTaken = errors.New("seat is no longer available")
+)
+
+const (
+	SeatAvailable = "available"
+	SeatLocked    = "locked"
+	SeatBooked    = "booked"
+	SeatBlocked   = "blocked"
+
+	// A pending booking keeps its seats this long while it is being paid for
+	pendingSeatHold = 20 * time.Minute
+)
+
+// SeatIndex flattens an event seat map into a lookup keyed by seat ID.
+func SeatIndex(sm *models.SeatMap) map[string]models.Seat {
+	seats := map[string]models.Seat{}
+	if sm == nil {
+		return seats
+	}
+	for _, section := range sm.Sections {
+		for _, row := range section.Rows {
+			for _, seat := range row.Seats {
+				seats[seat.ID] = seat
+			}
+		}
+	}
+	return seats
+}
+
+// ResolveSeatTickets turns the selected seats into ticket lines grouped by
+// category, priced from the given ticket categories.
+func ResolveSeatTickets(seatMap *models.SeatMap, categories []models.TicketCategory, seatIDs []string) ([]models.BookingTicket, error) {
+	if seatMap == nil {
+		return nil, errors.New("event does not have reserved seating")
+	}
+	if len(seatIDs) == 0 {
+		return nil, errors.New("at least one seat is required")
+	}
+
+	prices := map[string]float64{}
+	for _, tc := range categories {
+		prices[tc.Name] = tc.Price
+	}
+
+	index := SeatIndex(seatMap)
+	seen := map[string]bool{}
+	quantities := map[string]int{}
+	var order []string
+	for _, id := range seatIDs {
+		if seen[id] {
+			return nil, fmt.Errorf("seat %s selected more than once", id)
+		}
+		seen[id] = true
+
+		seat, ok := index[id]
+		if !ok {
+			return nil, fmt.Errorf("seat %s does not exist", id)
+		}
+		if seat.Blocked {
+			return nil, fmt.Errorf("seat %s is not available", id)
+		}
+		if _, ok := prices[seat.Category]; !ok {
+			return nil, fmt.Errorf("seat %s has an unknown category %q", id, seat.Category)
+		}
+		if quantities[seat.Category] == 0 {
+			order = append(order, seat.Category)
+		}
+		quantities[seat.Category]++
+	}
+
+	tickets := make([]models.BookingTicket, 0, len(order))
+	for _, cat := range order {
+		tickets = append(tickets, models.BookingTicket{
+			Category: cat,
+			Price:    prices[cat],
+			Quantity: quantities[cat],
+		})
+	}
+	return tickets, nil
+}
+
+func bookedSeatIDs(ctx context.Context, eventID, showtimeID primitive.ObjectID) (map[string]bool, error) {
+	filter := bson.M{
+		"event_id": eventID,
+		"status":   bson.M{"$in": []string{"booked", "confirmed"}},
+		"seat_ids": bson.M{"$exists": true},
+	}
+	if !showtimeID.IsZero() {
+		filter["showtime_id"] = showtimeID
+	}
+	cursor, err := config.EventBookingsCol.Find(ctx, filter, options.Find().SetProjection(bson.M{"seat_ids": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		SeatIDs []string `bson:"seat_ids"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	booked := map[string]bool{}
+	for _, r := range rows {
+		for _, id := range r.SeatIDs {
+			booked[id] = true
+		}
+	}
+	return booked, nil
+}
+
+// Seat locks for a showtime carry the showtime ID in the lock's date field.
+func lockedSeatIDs(ctx context.Context, eventID, showtimeID primitive.ObjectID, excludeLockKey string) (map[string]bool, error) {
+	filter := bson.M{
+		"type":         "event",
+		"reference_id": eventID,
+		"expires_at":   bson.M{"$gt": time.Now()},
+	}
+	if !showtimeID.IsZero() {
+		filter["date"] = showtimeID.Hex()
+	}
+	if excludeLockKey != "" {
+		filter["lock_key"] = bson.M{"$ne": excludeLockKey}
+	}
+
+	cursor, err := config.SlotLocksCol.Find(ctx, filter, options.Find().SetProjection(bson.M{"slot": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var locks []models.SlotLock
+	if err := cursor.All(ctx, &locks); err != nil {
+		return nil, err
+	}
+
+	locked := map[string]bool{}
+	for _, l := range locks {
+		locked[l.Slot] = true
+	}
+	return locked, nil
+}
+
+// CheckSeatsAvailable fails if any seat is already booked or is held by a
+// lock belonging to someone other than lockKey.
+func CheckSeatsAvailable(ctx context.Context, eventID, showtimeID primitive.ObjectID, seatIDs []string, lockKey string) error {
+	booked, err := bookedSeatIDs(ctx, eventID, showtimeID)
+	if err != nil {
+		return err
+	}
+	locked, err := lockedSeatIDs(ctx, eventID, showtimeID, lockKey)
+	if err != nil {
+		return err
+	}
+	for _, id := range seatIDs {
+		if booked[id] || locked[id] {
+			return fmt.Errorf("seat %s is no longer available", id)
+		}
+	}
+	return nil
+}
+
+// seatHeld reports whether the booking holding a seat claim still needs the
+// seat. Cancelled, failed and abandoned bookings give their seats up.
+func seatHeld(ctx context.Context, bookingID primitive.ObjectID) bool {
+	var b models.Booking
+	err := config.EventBookingsCol.FindOne(ctx, bson.M{"_id": bookingID},
+		options.FindOne().SetProjection(bson.M{"status": 1, "booked_at": 1})).Decode(&b)
+	if err != nil {
+		return false
+	}
+	switch b.Status {
+	case "booked", "confirmed":
+		return true
+	case "pending":
+		return time.Since(b.BookedAt) < pendingSeatHold
+	}
+	return false
+}
+
+// HoldingTickets matches the bookings whose tickets count against
+// capacity: sold ones, and pending ones still inside their hold.
+func HoldingTickets(now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"status": bson.M{"$in": []string{"booked", "confirmed"}}},
+		{"status": "pending", "booked_at": bson.M{"$gt": now.Add(-pendingSeatHold)}},
+	}}
+}
+
+// claimSeat records b as the holder of a seat. The unique index on the
+// claims makes this the point where two bookings racing for the same seat
+// are told apart; a claim left by a booking that no longer holds the seat is
+// taken over with a conditional update.
+func claimSeat(ctx context.Context, b *models.Booking, seatID string) error {
+	key := bson.M{"event_id": b.EventID, "showtime_id": b.ShowtimeID, "seat_id": seatID}
+	now := time.Now()
+	_, err := config.SeatClaimsCol.InsertOne(ctx, bson.M{
+		"event_id":    b.EventID,
+		"showtime_id": b.ShowtimeID,
+		"seat_id":     seatID,
+		"booking_id":  b.ID,
+		"claimed_at":  now,
+	})
+	if err == nil {
+		return nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	var existing struct {
+		BookingID primitive.ObjectID `bson:"booking_id"`
+	}
+	if err := config.SeatClaimsCol.FindOne(ctx, key).Decode(&existing); err != nil {
+		return err
+	}
+	if existing.BookingID == b.ID {
+		return nil
+	}
+	if seatHeld(ctx, existing.BookingID) {
+		return fmt.Errorf("%w: %s", ErrSeatTaken, seatID)
+	}
+	key["booking_id"] = existing.BookingID
+	res, err := config.SeatClaimsCol.UpdateOne(ctx, key, bson.M{"$set": bson.M{
+		"booking_id": b.ID,
+		"claimed_at": now,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: %s", ErrSeatTaken, seatID)
+	}
+	return nil
+}
+
+// claimSeats claims every seat of the booking, or none of them.
+func claimSeats(ctx context.Context, b *models.Booking) error {
+	for i, id := range b.SeatIDs {
+		if err := claimSeat(ctx, b, id); err != nil {
+			releaseSeatClaims(ctx, b.ID, b.SeatIDs[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func releaseSeatClaims(ctx context.Context, bookingID primitive.ObjectID, seatIDs []string) {
+	if len(seatIDs) == 0 {
+		return
+	}
+	if _, err := config.SeatClaimsCol.DeleteMany(ctx, bson.M{
+		"booking_id": bookingID,
+		"seat_id":    bson.M{"$in": seatIDs},
+	}); err != nil {
+		fmt.Printf("ERROR: Failed to release seat claims for booking %s: %v\n", bookingID.Hex(), err)
+	}
+}
+
+// validateEventSeatLock makes sure a seat lock targets a real, sellable seat
+// for reserved-seating events. General admission locks pass through. For
+// events with showtimes, date carries the showtime ID.
+func validateEventSeatLock(ctx context.Context, eventID primitive.ObjectID, date, seatID string) error {
+	var event models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": eventID}, options.FindOne().SetProjection(bson.M{"seat_map": 1, "showtimes": 1})).Decode(&event); err != nil {
+		return errors.New("event not found")
+	}
+	if event.SeatMap == nil {
+		return nil
+	}
+
+	var showtimeID primitive.ObjectID
+	if len(event.Showtimes) > 0 {
+		stID, err := primitive.ObjectIDFromHex(date)
+		if err != nil {
+			return errors.New("date must be the showtime id for this event")
+		}
+		if _, _, err := ResolveShowtime(&event, stID); err != nil {
+			return err
+		}
+		showtimeID = stID
+	}
+
+	seat, ok := SeatIndex(event.SeatMap)[seatID]
+	if !ok || seat.Blocked {
+		return ErrSeatUnavailable
+	}
+
+	booked, err := bookedSeatIDs(ctx, eventID, showtimeID)
+	if err != nil {
+		return err
+	}
+	if booked[seatID] {
+		return ErrSeatUnavailable
+	}
+	return nil
+}
+
+// GetSeatStatus returns the current status of every seat on the event's map,
+// scoped to a showtime when the event has them.
+func GetSeatStatus(eventID, showtimeID string) (map[string]string, error) {
+	objID, err := primitive.ObjectIDFromHex(eventID)
+	if err != nil {
+		return nil, err
+	}
+	var stID primitive.ObjectID
+	if showtimeID != "" {
+		if stID, err = primitive.ObjectIDFromHex(showtimeID); err != nil {
+			return nil, errors.New("invalid showtime_id")
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var event models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": objID}, options.FindOne().SetProjection(bson.M{"seat_map": 1})).Decode(&event); err != nil {
+		return nil, errors.New("event not found")
+	}
+	if event.SeatMap == nil {
+		return nil, nil
+	}
+
+	booked, err := bookedSeatIDs(ctx, objID, stID)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := lockedSeatIDs(ctx, objID, stID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	status := map[string]string{}
+	for id, seat := range SeatIndex(event.SeatMap) {
+		switch {
+		case seat.Blocked:
+			status[id] = SeatBlocked
+		case booked[id]:
+			status[id] = SeatBooked
+		case locked[id]:
+			status[id] = SeatLocked
+		default:
+			status[id] = SeatAvailable
+		}
+	}
+	return status, nil
+}
+
+// attachSeatLocks marks the caller's seat locks as consumed by the booking.
+func attachSeatLocks(ctx context.Context, b *models.Booking) error {
+	if b.LockKey == "" || len(b.SeatIDs) == 0 {
+		return nil
+	}
+	_, err := config.SlotLocksCol.UpdateMany(ctx, bson.M{
+		"lock_key":     b.LockKey,
+		"type":         "event",
+		"reference_id": b.EventID,
+		"slot":         bson.M{"$in": b.SeatIDs},
+	}, bson.M{
+		"$set": bson.M{"booking_id": b.ID},
+	})
+	return err
+}
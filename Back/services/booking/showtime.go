@@ -0,0 +1,72 @@
+package booking
+
+import (
+	"errors"
+	"time"
+
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FindShowtime returns the showtime with the given ID, or nil.
+func FindShowtime(e *models.Event, id primitive.ObjectID) *models.Showtime {
+	for i := range e.Showtimes {
+		if e.Showtimes[i].ID == id {
+			return &e.Showtimes[i]
+		}
+	}
+	return nil
+}
+
+var (
+	ErrEventCancelled = errors.New("this event has been cancelled")
+	ErrShowCancelled  = errors.New("this show has been cancelled")
+)
+
+// ResolveShowtime picks the inventory a booking draws from. Events without
+// showtimes sell from the event-level ticket categories.
+func ResolveShowtime(e *models.Event, showtimeID primitive.ObjectID) (*models.Showtime, []models.TicketCategory, error) {
+	if e.Status == "cancelled" {
+		return nil, nil, ErrEventCancelled
+	}
+	if len(e.Showtimes) == 0 {
+		if !showtimeID.IsZero() {
+			return nil, nil, errors.New("event does not have showtimes")
+		}
+		return nil, e.TicketCategories, nil
+	}
+	if showtimeID.IsZero() {
+		return nil, nil, errors.New("showtime_id is required for this event")
+	}
+	st := FindShowtime(e, showtimeID)
+	if st == nil {
+		return nil, nil, errors.New("showtime not found")
+	}
+	if st.Status == "cancelled" {
+		return nil, nil, ErrShowCancelled
+	}
+	return st, st.TicketCategories, nil
+}
+
+// BookingSchedule returns the date and time a booking is for, preferring
+// the booked showtime over the event-level date.
+func BookingSchedule(e *models.Event, b *models.Booking) (time.Time, string) {
+	if !b.ShowtimeID.IsZero() {
+		if st := FindShowtime(e, b.ShowtimeID); st != nil {
+			return st.Date, st.Time
+		}
+	}
+	return e.Date, e.Time
+}
+
+// ParseBookingDate reads the date a play or dining booking is for, in any
+// of the formats the booking pages have sent.
+func ParseBookingDate(date string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "02 January, 2006", "January 02, 2006"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
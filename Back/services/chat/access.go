@@ -0,0 +1,123 @@
+package chat
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	adminsvc "ticpin-backend/services/admin"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	statusPending = "pending"
+	statusActive  = "active"
+	statusClosed  = "closed"
+)
+
+// caller is whoever is using the chat, taken from their session token and
+// never from the request body.
+type caller struct {
+	// Type and ID identify the owner of the sessions a user or organizer
+	// raises; admins raising a ticket for themselves own it as organizers.
+	Type  string
+	ID    string
+	Email string
+
+	// Agent is set on the support agent routes. Manager agents may see and
+	// reassign every session.
+	Agent   bool
+	Manager bool
+}
+
+func ownerOf(c *fiber.Ctx) caller {
+	if accountType, _ := c.Locals("accountType").(string); accountType == "user" {
+		id, _ := c.Locals("userId").(string)
+		return caller{Type: "user", ID: id}
+	}
+	id, _ := c.Locals("organizerId").(string)
+	email, _ := c.Locals("email").(string)
+	return caller{Type: "organizer", ID: id, Email: email}
+}
+
+func agentOf(c *fiber.Ctx) caller {
+	email, _ := c.Locals("email").(string)
+	perms, _ := c.Locals("permissions").([]string)
+	return caller{
+		Type:    "admin",
+		Email:   email,
+		Agent:   true,
+		Manager: adminsvc.HasPermission(perms, "chat:manage"),
+	}
+}
+
+// owns reports whether the session was raised by the caller.
+func (w caller) owns(s *models.ChatSession) bool {
+	return !w.Agent && w.ID != "" && s.UserType == w.Type && s.UserID == w.ID
+}
+
+// canView reports whether the caller may read a session: its owner, the
+// agent assigned to it, any agent while it waits unassigned in the queue,
+// and managers.
+func (w caller) canView(s *models.ChatSession) bool {
+	if !w.Agent {
+		return w.owns(s)
+	}
+	if w.Manager || s.AssignedAgent == w.Email {
+		return true
+	}
+	return s.Status == statusPending && s.AssignedAgent == ""
+}
+
+// canReply reports whether the caller may post to a session. Owners can
+// write until it is closed; agents only once they have accepted it.
+func (w caller) canReply(s *models.ChatSession) bool {
+	if s.Status == statusClosed {
+		return false
+	}
+	if !w.Agent {
+		return w.owns(s)
+	}
+	return s.Status == statusActive && s.AssignedAgent == w.Email
+}
+
+// canClose reports whether the caller may end a session: its owner, the
+// assigned agent or a manager.
+func (w caller) canClose(s *models.ChatSession) bool {
+	if s.Status == statusClosed {
+		return false
+	}
+	if !w.Agent {
+		return w.owns(s)
+	}
+	return w.Manager || (s.AssignedAgent != "" && s.AssignedAgent == w.Email)
+}
+
+func findSession(sessionID string) (*models.ChatSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var session models.ChatSession
+	if err := config.ChatSessionsCol.FindOne(ctx, bson.M{"session_id": sessionID}).Decode(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// visibleSession loads a session the caller may read. Sessions the caller
+// may not see are reported as not found.
+func visibleSession(c *fiber.Ctx, w caller) (*models.ChatSession, error) {
+	sessionID := c.Params("sessionId")
+	if sessionID == "" {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session ID required"})
+	}
+	session, err := findSession(sessionID)
+	if err != nil || !w.canView(session) {
+		return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Session not found"})
+	}
+	return session, nil
+}
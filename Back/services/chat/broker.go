@@ -0,0 +1,148 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"ticpin-backend/models"
+)
+
+// Event is something that happened in a chat, pushed to the clients
+// watching it. Clients that miss events catch up through the REST routes.
+type Event struct {
+	Type      string              `json:"type"`
+	SessionID string              `json:"sessionId,omitempty"`
+	Message   *models.ChatMessage `json:"message,omitempty"`
+	Session   *models.ChatSession `json:"session,omitempty"`
+	// Reader is the side that read the other's messages, "user" or "admin"
+	Reader string `json:"reader,omitempty"`
+	Agent  string `json:"agent,omitempty"`
+	Online bool   `json:"online,omitempty"`
+	Typing bool   `json:"typing,omitempty"`
+	// From is the side that is typing, "user" or "admin"
+	From string    `json:"from,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+const (
+	EventMessage  = "message"
+	EventRead     = "read"
+	EventCreated  = "created"
+	EventAccepted = "accepted"
+	EventAssigned = "assigned"
+	EventClosed   = "closed"
+	EventPresence = "presence"
+	EventTyping   = "typing"
+)
+
+// agentsTopic carries queue changes and agent presence to every agent.
+const agentsTopic = "agents"
+
+func sessionTopic(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// Broker fans events out to subscribers of a topic. The in-process broker
+// only reaches clients connected to this instance; running several
+// instances needs one backed by a shared pub/sub, set with SetBroker.
+type Broker interface {
+	Publish(topic string, e Event)
+	// Subscribe returns the events of a topic and a func that stops them.
+	Subscribe(topic string) (<-chan Event, func())
+}
+
+var broker Broker = newMemoryBroker()
+
+// SetBroker replaces the in-process broker, e.g. with a Redis backed one.
+func SetBroker(b Broker) {
+	broker = b
+}
+
+func publish(topic string, e Event) {
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	broker.Publish(topic, e)
+}
+
+// subscriberBuffer is how many events a slow client may fall behind
+// before it misses some.
+const subscriberBuffer = 32
+
+type memoryBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[chan Event]struct{}
+}
+
+func newMemoryBroker() *memoryBroker {
+	return &memoryBroker{topics: map[string]map[chan Event]struct{}{}}
+}
+
+func (b *memoryBroker) Publish(topic string, e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.topics[topic] {
+		select {
+		case ch <- e:
+		default:
+			// Never block the publisher on a slow client
+		}
+	}
+}
+
+func (b *memoryBroker) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = map[chan Event]struct{}{}
+	}
+	b.topics[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.topics[topic], ch)
+			if len(b.topics[topic]) == 0 {
+				delete(b.topics, topic)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// presence counts the open agent streams of each agent on this instance.
+var presence = struct {
+	sync.Mutex
+	conns map[string]int
+}{conns: map[string]int{}}
+
+// agentOnline reports whether an agent has a live stream open.
+func agentOnline(email string) bool {
+	presence.Lock()
+	defer presence.Unlock()
+	return presence.conns[email] > 0
+}
+
+// agentConnected records a stream opening or closing and announces the
+// agent going online or offline.
+func agentConnected(email string, connected bool) {
+	presence.Lock()
+	before := presence.conns[email]
+	if connected {
+		presence.conns[email]++
+	} else if before > 0 {
+		presence.conns[email]--
+	}
+	after := presence.conns[email]
+	if after == 0 {
+		delete(presence.conns, email)
+	}
+	presence.Unlock()
+
+	if (before == 0) != (after == 0) {
+		announcePresence(email, after > 0)
+	}
+}
@@ -1,18 +1,24 @@
 package chat
 
 import (
+	"ticpin-backend/middleware"
+
 	"github.com/gofiber/fiber/v2"
 )
 
+// SetupRoutes registers the chat routes for users and organizers. Support
+// agents use the /api/admin/chat routes, registered with the admin routes.
 func SetupRoutes(app *fiber.App) {
-	api := app.Group("/api/chat")
+	app.Get("/api/chat/questions", getQuestions)
+
+	api := app.Group("/api/chat", middleware.RequireAccount)
 
-	api.Get("/questions", getQuestions)
 	api.Get("/sessions", getSessions)
 	api.Get("/sessions/:sessionId/messages", getMessages)
 	api.Post("/sessions", createSession)
 	api.Post("/sessions/:sessionId/messages", sendMessage)
-	api.Post("/sessions/:sessionId/accept", acceptSession)
 	api.Post("/sessions/:sessionId/end", endSession)
 	api.Put("/sessions/:sessionId/read", markAsRead)
+	api.Post("/sessions/:sessionId/typing", typing)
+	api.Get("/sessions/:sessionId/stream", streamMySession)
 }
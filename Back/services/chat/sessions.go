@@ -10,134 +10,171 @@ import (
 
 	"ticpin-backend/config"
 	"ticpin-backend/models"
+	adminsvc "ticpin-backend/services/admin"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func getSessions(c *fiber.Ctx) error {
-	userType := c.Query("userType", "")
-	userID := c.Query("userId", "")
-	category := c.Query("category", "")
-	dateFilter := c.Query("dateFilter", "")
-	limitStr := c.Query("limit", "10")
-	pageStr := c.Query("page", "1")
-	limit, _ := strconv.Atoi(limitStr)
-	page, _ := strconv.Atoi(pageStr)
+func pageOf(c *fiber.Ctx) (int, int) {
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	page, _ := strconv.Atoi(c.Query("page", "1"))
 	if limit <= 0 {
 		limit = 10
 	}
 	if page <= 0 {
 		page = 1
 	}
-	skip := (page - 1) * limit
-
-	isAdmin := c.Query("admin", "false") == "true"
-	filter := bson.M{}
-
-	if isAdmin {
-		if category != "" {
-			filter["category"] = category
-		}
-		if userType != "" {
-			filter["user_type"] = userType
-		}
-
-		if dateFilter != "" && dateFilter != "all" {
-			now := time.Now()
-			var startDate time.Time
-
-			switch dateFilter {
-			case "yesterday":
-				startDate = now.AddDate(0, 0, -1)
-				startDate = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
-				endDate := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 23, 59, 59, 0, startDate.Location())
-				filter["created_at"] = bson.M{"$gte": startDate, "$lte": endDate}
-			case "3days":
-				startDate = now.AddDate(0, 0, -3)
-				filter["created_at"] = bson.M{"$gte": startDate}
-			case "week":
-				startDate = now.AddDate(0, 0, -7)
-				filter["created_at"] = bson.M{"$gte": startDate}
-			case "2weeks":
-				startDate = now.AddDate(0, 0, -14)
-				filter["created_at"] = bson.M{"$gte": startDate}
-			}
-		}
-
-		filter["status"] = bson.M{"$in": []string{"pending", "active"}}
-	} else {
-		if userType != "" {
-			filter["user_type"] = userType
-		}
-		if userID != "" {
-			filter["user_id"] = userID
-		}
-		if category != "" {
-			filter["category"] = category
-		}
-	}
+	return page, limit
+}
 
+func findSessions(filter bson.M, page, limit int) ([]models.ChatSession, int64, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	count, _ := config.ChatSessionsCol.CountDocuments(ctx, filter)
 	totalPages := (count + int64(limit) - 1) / int64(limit)
 
-	opts := options.Find().SetSkip(int64(skip)).SetLimit(int64(limit)).SetSort(bson.M{"updated_at": -1})
+	opts := options.Find().SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit)).SetSort(bson.M{"updated_at": -1})
 	cursor, err := config.ChatSessionsCol.Find(ctx, filter, opts)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch sessions")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch sessions"})
+		return nil, 0, err
 	}
-	defer cursor.Close(context.Background())
+	defer cursor.Close(ctx)
 
 	sessions := []models.ChatSession{}
-	if err := cursor.All(context.Background(), &sessions); err != nil {
-		log.Error().Err(err).Msg("Failed to decode sessions")
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, 0, err
+	}
+	return sessions, totalPages, nil
+}
+
+// getSessions lists the caller's own sessions.
+func getSessions(c *fiber.Ctx) error {
+	who := ownerOf(c)
+	page, limit := pageOf(c)
+
+	filter := bson.M{"user_type": who.Type, "user_id": who.ID}
+	if category := c.Query("category", ""); category != "" {
+		filter["category"] = category
+	}
+	if status := c.Query("status", ""); status != "" {
+		filter["status"] = status
+	}
+
+	sessions, totalPages, err := findSessions(filter, page, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch sessions")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch sessions"})
 	}
 
-	if isAdmin {
-		type SessionWithUnread struct {
-			models.ChatSession
-			UnreadCount int64 `json:"unreadCount" bson:"unreadCount"`
+	return c.JSON(fiber.Map{
+		"sessions":    sessions,
+		"totalPages":  totalPages,
+		"currentPage": page,
+	})
+}
+
+// ListAgentSessions is the support queue. ?view=queue shows pending
+// sessions nobody has taken, ?view=mine the agent's own, and ?view=all
+// (managers only) every open session. By default an agent sees the queue
+// and their own sessions together.
+func ListAgentSessions(c *fiber.Ctx) error {
+	who := agentOf(c)
+	page, limit := pageOf(c)
+	category := c.Query("category", "")
+	userType := c.Query("userType", "")
+	dateFilter := c.Query("dateFilter", "")
+
+	filter := bson.M{}
+	if category != "" {
+		filter["category"] = category
+	}
+	if userType != "" {
+		filter["user_type"] = userType
+	}
+
+	if dateFilter != "" && dateFilter != "all" {
+		now := time.Now()
+		var startDate time.Time
+
+		switch dateFilter {
+		case "yesterday":
+			startDate = now.AddDate(0, 0, -1)
+			startDate = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, startDate.Location())
+			endDate := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 23, 59, 59, 0, startDate.Location())
+			filter["created_at"] = bson.M{"$gte": startDate, "$lte": endDate}
+		case "3days":
+			startDate = now.AddDate(0, 0, -3)
+			filter["created_at"] = bson.M{"$gte": startDate}
+		case "week":
+			startDate = now.AddDate(0, 0, -7)
+			filter["created_at"] = bson.M{"$gte": startDate}
+		case "2weeks":
+			startDate = now.AddDate(0, 0, -14)
+			filter["created_at"] = bson.M{"$gte": startDate}
 		}
-		sessionsWithUnread := []SessionWithUnread{}
-
-		for _, session := range sessions {
-			unreadCount, _ := config.ChatMessagesCol.CountDocuments(context.Background(), bson.M{
-				"session_id": session.SessionID,
-				"sender":     "user",
-				"is_read":    false,
-			})
-			sessionsWithUnread = append(sessionsWithUnread, SessionWithUnread{
-				ChatSession: session,
-				UnreadCount: unreadCount,
-			})
+	}
+
+	unassigned := bson.M{"status": statusPending, "assigned_agent": bson.M{"$in": []interface{}{nil, ""}}}
+	mine := bson.M{"assigned_agent": who.Email, "status": bson.M{"$in": []string{statusPending, statusActive}}}
+	switch c.Query("view", "") {
+	case "queue":
+		for k, v := range unassigned {
+			filter[k] = v
 		}
-		return c.JSON(fiber.Map{
-			"sessions":    sessionsWithUnread,
-			"totalPages":  totalPages,
-			"currentPage": page,
-		})
+	case "mine":
+		for k, v := range mine {
+			filter[k] = v
+		}
+	case "all":
+		if !who.Manager {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "forbidden: missing permission chat:manage", "permission": "chat:manage"})
+		}
+		filter["status"] = bson.M{"$in": []string{statusPending, statusActive}}
+	default:
+		filter["$or"] = []bson.M{unassigned, mine}
+	}
+
+	sessions, totalPages, err := findSessions(filter, page, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch sessions")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch sessions"})
 	}
 
+	type SessionWithUnread struct {
+		models.ChatSession
+		UnreadCount int64 `json:"unreadCount" bson:"unreadCount"`
+	}
+	sessionsWithUnread := []SessionWithUnread{}
+
+	for _, session := range sessions {
+		unreadCount, _ := config.ChatMessagesCol.CountDocuments(context.Background(), bson.M{
+			"session_id": session.SessionID,
+			"sender":     "user",
+			"is_read":    false,
+		})
+		sessionsWithUnread = append(sessionsWithUnread, SessionWithUnread{
+			ChatSession: session,
+			UnreadCount: unreadCount,
+		})
+	}
 	return c.JSON(fiber.Map{
-		"sessions":    sessions,
+		"sessions":    sessionsWithUnread,
 		"totalPages":  totalPages,
 		"currentPage": page,
 	})
 }
 
+// createSession raises a ticket for the caller, or returns their open one
+// in the same category.
 func createSession(c *fiber.Ctx) error {
 	var input struct {
-		UserID    string `json:"userId"`
 		UserEmail string `json:"userEmail"`
 		UserName  string `json:"userName"`
-		UserType  string `json:"userType"`
 		Category  string `json:"category"`
 	}
 
@@ -145,30 +182,24 @@ func createSession(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	if strings.TrimSpace(input.UserID) == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "User ID is required"})
+	who := ownerOf(c)
+	input.UserEmail = strings.TrimSpace(input.UserEmail)
+	input.UserName = strings.TrimSpace(input.UserName)
+	input.Category = strings.TrimSpace(input.Category)
+	if who.Email != "" {
+		input.UserEmail = who.Email
 	}
-	if strings.TrimSpace(input.UserEmail) == "" {
+
+	if input.UserEmail == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "User email is required"})
 	}
-	if strings.TrimSpace(input.UserName) == "" {
+	if input.UserName == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "User name is required"})
 	}
-	if strings.TrimSpace(input.UserType) == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "User type is required"})
-	}
-	if strings.TrimSpace(input.Category) == "" {
+	if input.Category == "" {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Category is required"})
 	}
 
-	validUserTypes := map[string]bool{
-		"user":      true,
-		"organizer": true,
-	}
-	if !validUserTypes[input.UserType] {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user type. Must be 'user' or 'organizer'"})
-	}
-
 	validCategories := map[string]bool{
 		"dining": true,
 		"event":  true,
@@ -178,21 +209,16 @@ func createSession(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid category. Must be 'dining', 'event', or 'play'"})
 	}
 
-	input.UserID = strings.TrimSpace(input.UserID)
-	input.UserEmail = strings.TrimSpace(input.UserEmail)
-	input.UserName = strings.TrimSpace(input.UserName)
-	input.UserType = strings.TrimSpace(input.UserType)
-	input.Category = strings.TrimSpace(input.Category)
-
 	var existingSession models.ChatSession
 	existingFilter := bson.M{
-		"user_id":  input.UserID,
-		"category": input.Category,
-		"status":   bson.M{"$in": []string{"pending", "active"}},
+		"user_type": who.Type,
+		"user_id":   who.ID,
+		"category":  input.Category,
+		"status":    bson.M{"$in": []string{statusPending, statusActive}},
 	}
 	findErr := config.ChatSessionsCol.FindOne(context.Background(), existingFilter).Decode(&existingSession)
 	if findErr == nil {
-		log.Info().Str("session_id", existingSession.SessionID).Str("user_id", input.UserID).Str("category", input.Category).Msg("Returning existing session")
+		log.Info().Str("session_id", existingSession.SessionID).Str("user_id", who.ID).Str("category", input.Category).Msg("Returning existing session")
 		return c.Status(http.StatusOK).JSON(existingSession)
 	}
 
@@ -201,12 +227,12 @@ func createSession(c *fiber.Ctx) error {
 
 	session := models.ChatSession{
 		SessionID: sessionID,
-		UserID:    input.UserID,
+		UserID:    who.ID,
 		UserEmail: input.UserEmail,
 		UserName:  input.UserName,
-		UserType:  input.UserType,
+		UserType:  who.Type,
 		Category:  input.Category,
-		Status:    "pending",
+		Status:    statusPending,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
@@ -216,15 +242,15 @@ func createSession(c *fiber.Ctx) error {
 
 	_, err := config.ChatSessionsCol.InsertOne(ctx, session)
 	if err != nil {
-		log.Error().Err(err).Str("user_id", input.UserID).Str("user_type", input.UserType).Str("category", input.Category).Msg("Failed to create session")
+		log.Error().Err(err).Str("user_id", who.ID).Str("user_type", who.Type).Str("category", input.Category).Msg("Failed to create session")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create session"})
 	}
 
 	ticketMsg := models.ChatMessage{
 		SessionID: sessionID,
-		UserID:    input.UserID,
+		UserID:    who.ID,
 		UserEmail: input.UserEmail,
-		UserType:  input.UserType,
+		UserType:  who.Type,
 		Category:  input.Category,
 		Message:   fmt.Sprintf("New ticket raised by %s (%s) for %s support", input.UserName, input.UserEmail, input.Category),
 		Sender:    "system",
@@ -232,58 +258,85 @@ func createSession(c *fiber.Ctx) error {
 		CreatedAt: now,
 	}
 	config.ChatMessagesCol.InsertOne(context.Background(), ticketMsg)
+	publish(agentsTopic, Event{Type: EventCreated, SessionID: sessionID, Session: &session})
 
-	log.Info().Str("session_id", sessionID).Str("user_id", input.UserID).Str("user_type", input.UserType).Str("category", input.Category).Msg("Chat ticket raised")
+	log.Info().Str("session_id", sessionID).Str("user_id", who.ID).Str("user_type", who.Type).Str("category", input.Category).Msg("Chat ticket raised")
 
 	return c.Status(http.StatusCreated).JSON(session)
 }
 
-func endSession(c *fiber.Ctx) error {
-	sessionID := c.Params("sessionId")
-	if sessionID == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session ID required"})
-	}
-
-	var session models.ChatSession
-	err := config.ChatSessionsCol.FindOne(context.Background(), bson.M{"session_id": sessionID}).Decode(&session)
+// systemMessage adds a note from Ticpin to a session's conversation.
+func systemMessage(session *models.ChatSession, text, sender, agentEmail string, at time.Time) {
+	msg := models.ChatMessage{
+		SessionID:  session.SessionID,
+		UserID:     session.UserID,
+		UserEmail:  session.UserEmail,
+		UserType:   session.UserType,
+		Category:   session.Category,
+		Message:    text,
+		Sender:     sender,
+		AgentEmail: agentEmail,
+		IsRead:     sender == "system",
+		CreatedAt:  at,
+	}
+	res, err := config.ChatMessagesCol.InsertOne(context.Background(), msg)
 	if err != nil {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Session not found"})
+		return
+	}
+	if id, ok := res.InsertedID.(primitive.ObjectID); ok {
+		msg.ID = id
 	}
+	publish(sessionTopic(session.SessionID), Event{Type: EventMessage, SessionID: session.SessionID, Message: &msg})
+}
 
-	if session.Status == "closed" {
+func closeSession(c *fiber.Ctx, who caller) error {
+	session, err := visibleSession(c, who)
+	if session == nil {
+		return err
+	}
+	if session.Status == statusClosed {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session is already closed"})
 	}
+	if !who.canClose(session) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Only the assigned agent can end this session"})
+	}
 
 	now := time.Now()
-
-	updateData := bson.M{
-		"$set": bson.M{
-			"status":     "closed",
-			"closed_at":  now,
-			"updated_at": now,
-		},
+	closedBy := session.UserType
+	if who.Agent {
+		closedBy = who.Email
 	}
 
-	_, err = config.ChatSessionsCol.UpdateOne(context.Background(), bson.M{"session_id": sessionID}, updateData)
+	// Matching the state we checked keeps a close from racing an accept or
+	// a reassignment
+	filter := bson.M{"session_id": session.SessionID, "status": session.Status}
+	if session.AssignedAgent != "" {
+		filter["assigned_agent"] = session.AssignedAgent
+	} else {
+		filter["assigned_agent"] = bson.M{"$in": []interface{}{nil, ""}}
+	}
+	res, err := config.ChatSessionsCol.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{
+		"status":     statusClosed,
+		"closed_at":  now,
+		"closed_by":  closedBy,
+		"updated_at": now,
+	}})
 	if err != nil {
-		log.Error().Err(err).Str("session_id", sessionID).Msg("Failed to end session")
+		log.Error().Err(err).Str("session_id", session.SessionID).Msg("Failed to end session")
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to end session"})
 	}
+	if res.MatchedCount == 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Session changed, please reload it"})
+	}
 
-	systemMsg := models.ChatMessage{
-		SessionID: sessionID,
-		UserID:    session.UserID,
-		UserEmail: session.UserEmail,
-		UserType:  session.UserType,
-		Category:  session.Category,
-		Message:   "This chat has been ended by the administrator. You can view this conversation in history. Please raise a new ticket if you need further assistance.",
-		Sender:    "system",
-		IsRead:    true,
-		CreatedAt: now,
+	text := "This chat has been ended by the administrator. You can view this conversation in history. Please raise a new ticket if you need further assistance."
+	if !who.Agent {
+		text = "This chat has been closed. You can view this conversation in history. Please raise a new ticket if you need further assistance."
 	}
-	config.ChatMessagesCol.InsertOne(context.Background(), systemMsg)
+	systemMessage(session, text, "system", "", now)
+	publishSession(Event{Type: EventClosed, SessionID: session.SessionID})
 
-	log.Info().Str("session_id", sessionID).Msg("Session closed by admin")
+	log.Info().Str("session_id", session.SessionID).Str("closed_by", closedBy).Msg("Session closed")
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -291,66 +344,148 @@ func endSession(c *fiber.Ctx) error {
 	})
 }
 
-func acceptSession(c *fiber.Ctx) error {
-	sessionID := c.Params("sessionId")
-	if sessionID == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session ID required"})
+// endSession lets the owner close their own session.
+func endSession(c *fiber.Ctx) error {
+	return closeSession(c, ownerOf(c))
+}
+
+// EndAgentSession lets the assigned agent, or a manager, close a session.
+func EndAgentSession(c *fiber.Ctx) error {
+	return closeSession(c, agentOf(c))
+}
+
+// AcceptSession takes a pending session off the queue and assigns it to
+// the calling agent. A session already assigned to someone else cannot be
+// accepted.
+func AcceptSession(c *fiber.Ctx) error {
+	who := agentOf(c)
+	session, err := visibleSession(c, who)
+	if session == nil {
+		return err
+	}
+	if session.Status != statusPending {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session is not pending approval"})
+	}
+	if session.AssignedAgent != "" && session.AssignedAgent != who.Email {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Session is assigned to another agent"})
 	}
 
-	var session models.ChatSession
-	err := config.ChatSessionsCol.FindOne(context.Background(), bson.M{"session_id": sessionID}).Decode(&session)
+	now := time.Now()
+	set := bson.M{"status": statusActive, "updated_at": now}
+	update := bson.M{"$set": set}
+	if session.AssignedAgent == "" {
+		set["assigned_agent"] = who.Email
+		set["assigned_at"] = now
+		update["$push"] = bson.M{"assignments": models.ChatAssignment{AgentEmail: who.Email, AssignedBy: who.Email, AssignedAt: now}}
+	}
+
+	res, err := config.ChatSessionsCol.UpdateOne(context.Background(), bson.M{
+		"session_id": session.SessionID,
+		"status":     statusPending,
+		"$or":        []bson.M{{"assigned_agent": bson.M{"$in": []interface{}{nil, ""}}}, {"assigned_agent": who.Email}},
+	}, update)
 	if err != nil {
-		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Session not found"})
+		log.Error().Err(err).Str("session_id", session.SessionID).Msg("Failed to accept session")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to accept session"})
+	}
+	if res.MatchedCount == 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Session was taken by another agent"})
 	}
 
-	if session.Status != "pending" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session is not pending approval"})
+	publishSession(Event{Type: EventAccepted, SessionID: session.SessionID, Agent: who.Email, Online: agentOnline(who.Email)})
+	systemMessage(session, "Hello! Welcome to Ticpin support. Your ticket has been accepted. How can I help you today?", "admin", who.Email, now)
+
+	log.Info().Str("session_id", session.SessionID).Str("agent", who.Email).Msg("Session accepted")
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Session accepted successfully",
+	})
+}
+
+// AssignSession hands an open session to another support agent.
+func AssignSession(c *fiber.Ctx) error {
+	who := agentOf(c)
+	var input struct {
+		AgentEmail string `json:"agentEmail"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	agentEmail := strings.ToLower(strings.TrimSpace(input.AgentEmail))
+	if agentEmail == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Agent email is required"})
 	}
 
-	now := time.Now()
+	session, err := visibleSession(c, who)
+	if session == nil {
+		return err
+	}
+	if session.Status == statusClosed {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session is closed"})
+	}
+	if session.AssignedAgent == agentEmail {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session is already assigned to this agent"})
+	}
 
-	updateData := bson.M{
-		"$set": bson.M{
-			"status":     "active",
-			"updated_at": now,
-		},
+	perms, err := adminsvc.PermissionsFor(agentEmail)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check agent permissions"})
+	}
+	if !adminsvc.HasPermission(perms, "chat:support") {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "This admin cannot answer support chats"})
 	}
 
-	_, err = config.ChatSessionsCol.UpdateOne(context.Background(), bson.M{"session_id": sessionID}, updateData)
+	now := time.Now()
+	res, err := config.ChatSessionsCol.UpdateOne(context.Background(),
+		bson.M{"session_id": session.SessionID, "status": session.Status},
+		bson.M{
+			"$set": bson.M{"assigned_agent": agentEmail, "assigned_at": now, "updated_at": now},
+			"$push": bson.M{"assignments": models.ChatAssignment{
+				AgentEmail: agentEmail,
+				AssignedBy: who.Email,
+				AssignedAt: now,
+			}},
+		},
+	)
 	if err != nil {
-		log.Error().Err(err).Str("session_id", sessionID).Msg("Failed to accept session")
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to accept session"})
+		log.Error().Err(err).Str("session_id", session.SessionID).Msg("Failed to assign session")
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to assign session"})
+	}
+	if res.MatchedCount == 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Session changed, please reload it"})
 	}
 
-	welcomeMsg := models.ChatMessage{
-		SessionID: sessionID,
-		UserID:    session.UserID,
-		UserEmail: session.UserEmail,
-		UserType:  session.UserType,
-		Category:  session.Category,
-		Message:   "Hello! Welcome to Ticpin support. Your ticket has been accepted. How can I help you today?",
-		Sender:    "admin",
-		IsRead:    false,
-		CreatedAt: now,
+	publishSession(Event{Type: EventAssigned, SessionID: session.SessionID, Agent: agentEmail, Online: agentOnline(agentEmail)})
+	if session.Status == statusActive {
+		systemMessage(session, "Your chat has been transferred to another support agent.", "system", "", now)
 	}
-	config.ChatMessagesCol.InsertOne(context.Background(), welcomeMsg)
 
-	log.Info().Str("session_id", sessionID).Msg("Session accepted by admin")
+	log.Info().Str("session_id", session.SessionID).Str("agent", agentEmail).Str("by", who.Email).Msg("Session assigned")
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"message": "Session accepted successfully",
+		"success":       true,
+		"assignedAgent": agentEmail,
 	})
 }
 
-func markAsRead(c *fiber.Ctx) error {
-	sessionID := c.Params("sessionId")
-	if sessionID == "" {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Session ID required"})
+func readSession(c *fiber.Ctx, who caller) error {
+	session, err := visibleSession(c, who)
+	if session == nil {
+		return err
 	}
 
-	_, err := config.ChatMessagesCol.UpdateMany(context.Background(),
-		bson.M{"session_id": sessionID, "sender": "user", "is_read": false},
+	// Each side marks what the other side wrote
+	sender := "admin"
+	if who.Agent {
+		if session.AssignedAgent != who.Email {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Only the assigned agent can mark messages as read"})
+		}
+		sender = "user"
+	}
+
+	_, err = config.ChatMessagesCol.UpdateMany(context.Background(),
+		bson.M{"session_id": session.SessionID, "sender": sender, "is_read": false},
 		bson.M{"$set": bson.M{"is_read": true}},
 	)
 
@@ -359,5 +494,19 @@ func markAsRead(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to mark as read"})
 	}
 
+	reader := "user"
+	if who.Agent {
+		reader = "admin"
+	}
+	publishSession(Event{Type: EventRead, SessionID: session.SessionID, Reader: reader})
+
 	return c.JSON(fiber.Map{"success": true})
 }
+
+func markAsRead(c *fiber.Ctx) error {
+	return readSession(c, ownerOf(c))
+}
+
+func MarkAgentRead(c *fiber.Ctx) error {
+	return readSession(c, agentOf(c))
+}
@@ -0,0 +1,261 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	heartbeatInterval = 25 * time.Second
+	// Streams end after a while so that clients reconnect with a fresh
+	// check of their session; EventSource does this on its own.
+	streamLifetime = 30 * time.Minute
+)
+
+// announcePresence tells every agent, and the owners of the agent's open
+// sessions, that the agent came online or went offline.
+func announcePresence(email string, online bool) {
+	e := Event{Type: EventPresence, Agent: email, Online: online}
+	publish(agentsTopic, e)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cursor, err := config.ChatSessionsCol.Find(ctx, bson.M{
+		"assigned_agent": email,
+		"status":         bson.M{"$in": []string{statusPending, statusActive}},
+	}, options.Find().SetProjection(bson.M{"session_id": 1}))
+	if err != nil {
+		return
+	}
+	defer cursor.Close(ctx)
+	var sessions []models.ChatSession
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return
+	}
+	for _, s := range sessions {
+		e.SessionID = s.SessionID
+		publish(sessionTopic(s.SessionID), e)
+	}
+}
+
+// publishSession sends an event to the session's participants and to the
+// agents, whose queue and unread counts follow every session. Every agent
+// hears about every session, so the queue copy carries who sent a message
+// but never the message itself.
+func publishSession(e Event) {
+	publish(sessionTopic(e.SessionID), e)
+
+	queued := e
+	if e.Message != nil {
+		queued.Message = nil
+		queued.From = e.Message.Sender
+	}
+	publish(agentsTopic, queued)
+}
+
+func writeEvent(w *bufio.Writer, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+	return w.Flush()
+}
+
+// stream sends events to the client as server-sent events until it goes
+// away. first is written as soon as the stream opens. A non-empty agent is
+// shown online for as long as the stream is actually open. When keep is set
+// it sees every event before it is sent and ends the stream by returning
+// false.
+func stream(c *fiber.Ctx, topics []string, first []Event, agent string, keep func(Event) bool) error {
+	// Serverless deployments cannot hold a connection open; clients fall
+	// back to polling the REST routes
+	if os.Getenv("VERCEL") == "1" {
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{"error": "Live updates are not available, use polling"})
+	}
+
+	events := make(chan Event, subscriberBuffer)
+	stops := make([]func(), 0, len(topics))
+	done := make(chan struct{})
+	for _, topic := range topics {
+		ch, stop := broker.Subscribe(topic)
+		stops = append(stops, stop)
+		go func(ch <-chan Event) {
+			for e := range ch {
+				select {
+				case events <- e:
+				case <-done:
+					return
+				}
+			}
+		}(ch)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if agent != "" {
+			agentConnected(agent, true)
+		}
+		defer func() {
+			for _, stop := range stops {
+				stop()
+			}
+			close(done)
+			if agent != "" {
+				agentConnected(agent, false)
+			}
+		}()
+
+		fmt.Fprintf(w, "retry: 3000\n\n")
+		for _, e := range first {
+			if e.At.IsZero() {
+				e.At = time.Now()
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		lifetime := time.NewTimer(streamLifetime)
+		defer lifetime.Stop()
+
+		for {
+			select {
+			case e := <-events:
+				if keep != nil && !keep(e) {
+					return
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprintf(w, ": ping\n\n")
+				if err := w.Flush(); err != nil {
+					return
+				}
+			case <-lifetime.C:
+				return
+			}
+		}
+	})
+	return nil
+}
+
+// streamSession pushes a session's messages, read receipts, status changes,
+// typing and the presence of its agent.
+func streamSession(c *fiber.Ctx, who caller) error {
+	session, err := visibleSession(c, who)
+	if session == nil {
+		return err
+	}
+
+	var first []Event
+	if session.AssignedAgent != "" {
+		first = append(first, Event{
+			Type:      EventPresence,
+			SessionID: session.SessionID,
+			Agent:     session.AssignedAgent,
+			Online:    agentOnline(session.AssignedAgent),
+		})
+	}
+
+	var agent string
+	var keep func(Event) bool
+	if who.Agent {
+		agent = who.Email
+
+		// An agent watching a queued session loses it once another agent
+		// takes it over, so access is checked again whenever it changes hands
+		sessionID := session.SessionID
+		keep = func(e Event) bool {
+			switch e.Type {
+			case EventAccepted, EventAssigned, EventClosed:
+				s, err := findSession(sessionID)
+				return err == nil && who.canView(s)
+			}
+			return true
+		}
+	}
+
+	log.Debug().Str("session_id", session.SessionID).Bool("agent", who.Agent).Msg("Chat stream opened")
+	return stream(c, []string{sessionTopic(session.SessionID)}, first, agent, keep)
+}
+
+func streamMySession(c *fiber.Ctx) error {
+	return streamSession(c, ownerOf(c))
+}
+
+func StreamAgentSession(c *fiber.Ctx) error {
+	return streamSession(c, agentOf(c))
+}
+
+// StreamAgentQueue pushes queue changes and the presence of other agents to
+// a support agent, and marks them online while it is open.
+func StreamAgentQueue(c *fiber.Ctx) error {
+	who := agentOf(c)
+
+	presence.Lock()
+	first := make([]Event, 0, len(presence.conns))
+	for email := range presence.conns {
+		first = append(first, Event{Type: EventPresence, Agent: email, Online: true})
+	}
+	presence.Unlock()
+
+	return stream(c, []string{agentsTopic}, first, who.Email, nil)
+}
+
+// sendTyping tells the other side of a session that the caller started or
+// stopped typing. Nothing is stored.
+func sendTyping(c *fiber.Ctx, who caller) error {
+	session, err := visibleSession(c, who)
+	if session == nil {
+		return err
+	}
+	if !who.canReply(session) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You cannot reply to this session"})
+	}
+	var input struct {
+		Typing bool `json:"typing"`
+	}
+	if err := c.BodyParser(&input); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	e := Event{Type: EventTyping, SessionID: session.SessionID, Typing: input.Typing, From: "user"}
+	if who.Agent {
+		e.From = "admin"
+		e.Agent = who.Email
+	}
+	publish(sessionTopic(session.SessionID), e)
+	return c.SendStatus(http.StatusNoContent)
+}
+
+func typing(c *fiber.Ctx) error {
+	return sendTyping(c, ownerOf(c))
+}
+
+func AgentTyping(c *fiber.Ctx) error {
+	return sendTyping(c, agentOf(c))
+}
@@ -0,0 +1,387 @@
+package coupon
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	defaultCodeLength   = 8
+	maxCodesPerRequest  = 10000
+	codeInsertBatch     = 1000
+)
+
+func randomCode(prefix, alphabet string, length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		b[i] = alphabet[n.Int64()]
+	}
+	return prefix + string(b)
+}
+
+// uniqueChars drops repeated characters so each is equally likely.
+func uniqueChars(s string) string {
+	seen := map[rune]bool{}
+	var b strings.Builder
+	for _, r := range s {
+		if !seen[r] {
+			seen[r] = true
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func CreateCampaign(cc *models.CouponCampaign) error {
+	cc.Name = strings.TrimSpace(cc.Name)
+	if cc.Name == "" {
+		return errors.New("campaign name is required")
+	}
+	if cc.Category != "event" && cc.Category != "play" && cc.Category != "dining" {
+		return errors.New("category must be event, play or dining")
+	}
+	if cc.DiscountType != "percent" && cc.DiscountType != "flat" {
+		return errors.New("discount_type must be percent or flat")
+	}
+	if cc.DiscountValue <= 0 {
+		return errors.New("discount_value must be greater than 0")
+	}
+	if err := ValidateRules(cc.Rules); err != nil {
+		return err
+	}
+
+	cc.Prefix = strings.ToUpper(strings.TrimSpace(cc.Prefix))
+	cc.Alphabet = uniqueChars(strings.ToUpper(strings.TrimSpace(cc.Alphabet)))
+	if cc.Alphabet == "" {
+		cc.Alphabet = defaultCodeAlphabet
+	}
+	for _, r := range cc.Alphabet {
+		if !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') {
+			return errors.New("alphabet may only contain letters and digits")
+		}
+	}
+	if len(cc.Alphabet) < 2 {
+		return errors.New("alphabet needs at least 2 characters")
+	}
+	if cc.CodeLength == 0 {
+		cc.CodeLength = defaultCodeLength
+	}
+	if cc.CodeLength < 4 || cc.CodeLength > 24 {
+		return errors.New("code_length must be between 4 and 24")
+	}
+
+	now := time.Now()
+	cc.ID = primitive.NewObjectID()
+	cc.IssuedCount = 0
+	cc.IsActive = true
+	cc.CreatedAt = now
+	cc.UpdatedAt = now
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := config.CouponCampaignsCol.InsertOne(ctx, cc)
+	return err
+}
+
+func getCampaign(ctx context.Context, id string) (*models.CouponCampaign, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid campaign id")
+	}
+	var cc models.CouponCampaign
+	if err := config.CouponCampaignsCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&cc); err != nil {
+		return nil, errors.New("campaign not found")
+	}
+	return &cc, nil
+}
+
+func GetCampaign(id string) (*models.CouponCampaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return getCampaign(ctx, id)
+}
+
+func ListCampaigns() ([]models.CouponCampaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.CouponCampaignsCol.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	list := []models.CouponCampaign{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// SetCampaignActive switches a campaign and every code under it on or off.
+func SetCampaignActive(id string, active bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cc, err := getCampaign(ctx, id)
+	if err != nil {
+		return err
+	}
+	if _, err := config.CouponCampaignsCol.UpdateOne(ctx, bson.M{"_id": cc.ID}, bson.M{"$set": bson.M{
+		"is_active":  active,
+		"updated_at": time.Now(),
+	}}); err != nil {
+		return err
+	}
+	_, err = config.CouponsCol.UpdateMany(ctx, bson.M{"campaign_id": cc.ID}, bson.M{"$set": bson.M{"is_active": active}})
+	return err
+}
+
+// insertCodes writes a batch of codes and returns how many were stored.
+// Codes that collide with an existing coupon are skipped.
+func insertCodes(ctx context.Context, docs []interface{}) (int, error) {
+	_, err := config.CouponsCol.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err == nil {
+		return len(docs), nil
+	}
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) {
+		return 0, err
+	}
+	for _, we := range bulkErr.WriteErrors {
+		if we.Code != 11000 {
+			return len(docs) - len(bulkErr.WriteErrors), err
+		}
+	}
+	return len(docs) - len(bulkErr.WriteErrors), nil
+}
+
+// GenerateCodes issues count new single-use codes under a campaign. Each
+// code copies the campaign's discount and rules at the time it is issued.
+func GenerateCodes(campaignID string, count int) (int, error) {
+	if count <= 0 || count > maxCodesPerRequest {
+		return 0, errors.New("count must be between 1 and 10000")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	cc, err := getCampaign(ctx, campaignID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Refuse to fill more than a small share of the code space, otherwise
+	// collisions make generation slow and codes easy to guess.
+	space := math.Pow(float64(len(cc.Alphabet)), float64(cc.CodeLength))
+	if float64(cc.IssuedCount+count)*1000 > space {
+		return 0, errors.New("code space too small; use a longer code_length or larger alphabet")
+	}
+
+	issued, collisions := 0, 0
+	for issued < count && collisions < 5 {
+		n := count - issued
+		if n > codeInsertBatch {
+			n = codeInsertBatch
+		}
+		now := time.Now()
+		seen := map[string]bool{}
+		docs := make([]interface{}, 0, n)
+		for len(docs) < n {
+			code := randomCode(cc.Prefix, cc.Alphabet, cc.CodeLength)
+			if seen[code] {
+				continue
+			}
+			seen[code] = true
+			docs = append(docs, models.Coupon{
+				ID:            primitive.NewObjectID(),
+				Code:          code,
+				Description:   cc.Description,
+				Category:      cc.Category,
+				DiscountType:  cc.DiscountType,
+				DiscountValue: cc.DiscountValue,
+				ValidFrom:     cc.ValidFrom,
+				ValidUntil:    cc.ValidUntil,
+				MaxUses:       1,
+				IsActive:      cc.IsActive,
+				Rules:         cc.Rules,
+				CampaignID:    cc.ID,
+				CreatedAt:     now,
+			})
+		}
+		stored, err := insertCodes(ctx, docs)
+		issued += stored
+		if err != nil {
+			config.CouponCampaignsCol.UpdateOne(ctx, bson.M{"_id": cc.ID}, bson.M{"$inc": bson.M{"issued_count": issued}})
+			return issued, err
+		}
+		if stored < len(docs) {
+			// Some codes collided with existing ones; the next round tops up
+			collisions++
+		}
+	}
+
+	_, err = config.CouponCampaignsCol.UpdateOne(ctx, bson.M{"_id": cc.ID}, bson.M{
+		"$inc": bson.M{"issued_count": issued},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err == nil && issued < count {
+		err = errors.New("could not generate all codes; try again")
+	}
+	return issued, err
+}
+
+// CampaignCode is one exported code with its redemption state.
+type CampaignCode struct {
+	Code       string     `json:"code"`
+	Status     string     `json:"status"` // "unused", "reserved", "redeemed"
+	UserID     string     `json:"user_id,omitempty"`
+	BookingID  string     `json:"booking_id,omitempty"`
+	RedeemedAt *time.Time `json:"redeemed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ListCampaignCodes returns every code in a campaign, oldest first.
+func ListCampaignCodes(campaignID string) (*models.CouponCampaign, []CampaignCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cc, err := getCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	used := map[primitive.ObjectID]models.CouponRedemption{}
+	cursor, err := config.CouponRedemptionsCol.Find(ctx, bson.M{
+		"campaign_id": cc.ID,
+		"$or": []bson.M{
+			{"status": "committed"},
+			{"status": "reserved", "expires_at": bson.M{"$gt": now}},
+		},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for cursor.Next(ctx) {
+		var r models.CouponRedemption
+		if cursor.Decode(&r) != nil {
+			continue
+		}
+		if prev, ok := used[r.CouponID]; ok && prev.Status == "committed" {
+			continue
+		}
+		used[r.CouponID] = r
+	}
+	cursor.Close(ctx)
+
+	opts := options.Find().
+		SetSort(bson.M{"_id": 1}).
+		SetProjection(bson.M{"code": 1, "created_at": 1})
+	cursor, err = config.CouponsCol.Find(ctx, bson.M{"campaign_id": cc.ID}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	codes := []CampaignCode{}
+	for cursor.Next(ctx) {
+		var c models.Coupon
+		if cursor.Decode(&c) != nil {
+			continue
+		}
+		row := CampaignCode{Code: c.Code, Status: "unused", CreatedAt: c.CreatedAt}
+		if r, ok := used[c.ID]; ok {
+			row.Status = "reserved"
+			row.UserID = r.UserID
+			row.BookingID = r.BookingID
+			if r.Status == "committed" {
+				row.Status = "redeemed"
+				row.RedeemedAt = r.CommittedAt
+			}
+		}
+		codes = append(codes, row)
+	}
+	return cc, codes, nil
+}
+
+type CampaignReport struct {
+	Campaign      *models.CouponCampaign `json:"campaign"`
+	Issued        int64                  `json:"issued"`
+	Reserved      int64                  `json:"reserved"`
+	Redeemed      int64                  `json:"redeemed"`
+	RedemptionPct float64                `json:"redemption_pct"`
+	OrderValue    float64                `json:"order_value"`
+	DiscountGiven float64                `json:"discount_given"`
+	Revenue       float64                `json:"revenue"` // order value after the coupon discount
+}
+
+// GetCampaignReport totals issued and redeemed codes and the booking value
+// the campaign brought in.
+func GetCampaignReport(campaignID string) (*CampaignReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cc, err := getCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	report := &CampaignReport{Campaign: cc}
+
+	if report.Issued, err = config.CouponsCol.CountDocuments(ctx, bson.M{"campaign_id": cc.ID}); err != nil {
+		return nil, err
+	}
+	if report.Reserved, err = config.CouponRedemptionsCol.CountDocuments(ctx, bson.M{
+		"campaign_id": cc.ID,
+		"status":      "reserved",
+		"expires_at":  bson.M{"$gt": time.Now()},
+	}); err != nil {
+		return nil, err
+	}
+
+	cursor, err := config.CouponRedemptionsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"campaign_id": cc.ID, "status": "committed"}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":          nil,
+			"count":        bson.M{"$sum": 1},
+			"order_amount": bson.M{"$sum": "$order_amount"},
+			"discount":     bson.M{"$sum": "$discount"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Count       int64   `bson:"count"`
+		OrderAmount float64 `bson:"order_amount"`
+		Discount    float64 `bson:"discount"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		report.Redeemed = totals[0].Count
+		report.OrderValue = totals[0].OrderAmount
+		report.DiscountGiven = totals[0].Discount
+		report.Revenue = totals[0].OrderAmount - totals[0].Discount
+	}
+	if report.Issued > 0 {
+		report.RedemptionPct = math.Round(float64(report.Redeemed)/float64(report.Issued)*10000) / 100
+	}
+	return report, nil
+}
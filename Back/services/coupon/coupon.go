@@ -21,6 +21,9 @@ func Create(c *models.Coupon) error {
 	if c.Code == "" {
 		return errors.New("coupon code is required")
 	}
+	if err := ValidateRules(c.Rules); err != nil {
+		return err
+	}
 	c.CreatedAt = time.Now()
 	c.UsedCount = 0
 
@@ -43,7 +46,8 @@ func GetAll(limit int, after string) ([]models.Coupon, string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{}
+	// Campaign codes are listed per campaign rather than here
+	filter := bson.M{"campaign_id": bson.M{"$exists": false}}
 	if after != "" {
 		if oid, err := primitive.ObjectIDFromHex(after); err == nil {
 			filter["_id"] = bson.M{"$gt": oid}
@@ -86,7 +90,7 @@ func GetAll(limit int, after string) ([]models.Coupon, string, error) {
 	return coupons, nextCursor, nil
 }
 
-func GetByCategory(category string, userID string) ([]models.Coupon, error) {
+func GetByCategory(category string, userID string, scope ListingScope) ([]models.Coupon, error) {
 	col := config.GetDB().Collection("coupons")
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -97,6 +101,7 @@ func GetByCategory(category string, userID string) ([]models.Coupon, error) {
 		"is_active":   true,
 		"valid_from":  bson.M{"$lte": now},
 		"valid_until": bson.M{"$gte": now},
+		"campaign_id": bson.M{"$exists": false},
 	}
 
 	usageFilter := bson.M{
@@ -138,11 +143,11 @@ func GetByCategory(category string, userID string) ([]models.Coupon, error) {
 	}
 
 	filter := bson.M{
-		"$and": bson.A{
+		"$and": append(bson.A{
 			base,
 			usageFilter,
 			userFilter,
-		},
+		}, scopeFilters(scope)...),
 	}
 
 	cursor, err := col.Find(ctx, filter)
@@ -162,13 +167,15 @@ type ValidateResult struct {
 	DiscountAmount float64
 }
 
-func Validate(code string, category string, orderAmount float64, userID string, userEmail string) (*ValidateResult, error) {
+// Validate checks a coupon code against an order. A rejected coupon returns
+// a *Rejection naming the rule that failed.
+func Validate(code string, co Checkout) (*ValidateResult, error) {
 	code = strings.ToUpper(strings.TrimSpace(code))
 	if code == "" {
-		return nil, errors.New("coupon code is required")
+		return nil, reject(RuleCode, "coupon code is required")
 	}
 
-	fmt.Printf("DEBUG: Validate coupon - Code: %s, Category: %s, Amount: %.2f, UserID: %s\n", code, category, orderAmount, userID)
+	fmt.Printf("DEBUG: Validate coupon - Code: %s, Category: %s, Amount: %.2f, UserID: %s\n", code, co.Category, co.OrderAmount, co.UserID)
 
 	col := config.CouponsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -176,96 +183,47 @@ func Validate(code string, category string, orderAmount float64, userID string,
 
 	var c models.Coupon
 	if err := col.FindOne(ctx, bson.M{"code": code}).Decode(&c); err != nil {
-		return nil, errors.New("invalid coupon code")
+		return nil, reject(RuleCode, "invalid coupon code")
 	}
 
 	fmt.Printf("DEBUG: Found coupon - Category: %s, IsActive: %t, IsPublic: %t, ValidFrom: %v, ValidUntil: %v\n", c.Category, c.IsActive, c.IsPublic, c.ValidFrom, c.ValidUntil)
 	
-	if category != "" && c.Category != category {
-		return nil, fmt.Errorf("this coupon is only valid for %s bookings", c.Category)
+	if co.Category != "" && c.Category != co.Category {
+		return nil, reject(RuleCategory, "this coupon is only valid for %s bookings", c.Category)
 	}
 
 	if !c.IsActive {
-		return nil, errors.New("coupon is not active")
+		return nil, reject(RuleActive, "coupon is not active")
 	}
 	now := time.Now()
 	if now.Before(c.ValidFrom) {
-		return nil, errors.New("coupon is not yet valid")
+		return nil, reject(RuleValidFrom, "coupon is not yet valid")
 	}
 	if now.After(c.ValidUntil) {
-		return nil, errors.New("coupon has expired")
+		return nil, reject(RuleValidUntil, "coupon has expired")
 	}
-	if c.MaxUses > 0 && c.UsedCount >= c.MaxUses {
-		return nil, errors.New("coupon usage limit reached")
+	used, usedByUser, err := usageCounts(ctx, &c, co.UserID)
+	if err != nil {
+		return nil, err
 	}
-
-	if !c.IsPublic && len(c.UserIDs) > 0 {
-		if userID == "" {
-			return nil, errors.New("this coupon is restricted and requires a logged-in user")
-		}
-		userObjID, err := primitive.ObjectIDFromHex(userID)
-		if err != nil {
-			return nil, errors.New("invalid user id")
-		}
-		found := false
-		for _, uid := range c.UserIDs {
-			if uid == userObjID {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return nil, errors.New("coupon is not valid for this user")
-		}
+	if c.MaxUses > 0 && used >= int64(c.MaxUses) {
+		return nil, reject(RuleMaxUses, "coupon usage limit reached")
 	}
 
-	var discount float64
-	if c.DiscountType == "percent" {
-		discount = orderAmount * c.DiscountValue / 100
-	} else {
-		discount = c.DiscountValue
-		if discount > orderAmount {
-			discount = orderAmount
+	if !c.IsPublic && len(c.UserIDs) > 0 {
+		if co.UserID == "" {
+			return nil, reject(RuleUser, "this coupon is restricted and requires a logged-in user")
 		}
-	}
-
-	return &ValidateResult{Coupon: &c, DiscountAmount: discount}, nil
-}
-
-func IncrementUsage(couponID primitive.ObjectID, maxUses int, userID string, userEmail string, bookingID string, amount float64) error {
-	col := config.CouponsCol
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	usage := models.CouponUsage{
-		UserID:    userID,
-		UserEmail: userEmail,
-		UsedAt:    time.Now(),
-		BookingID: bookingID,
-		Amount:    amount,
-	}
-
-	filter := bson.M{"_id": couponID}
-	if maxUses > 0 {
-		filter["$or"] = bson.A{
-			bson.M{"used_count": bson.M{"$exists": false}},
-			bson.M{"$expr": bson.M{"$lt": bson.A{"$used_count", maxUses}}},
+		if !containsID(c.UserIDs, co.UserID) {
+			return nil, reject(RuleUser, "coupon is not valid for this user")
 		}
 	}
 
-	update := bson.M{
-		"$inc":  bson.M{"used_count": 1},
-		"$push": bson.M{"used_by_users": usage},
+	if rejection := checkRules(ctx, &c, co, usedByUser); rejection != nil {
+		return nil, rejection
 	}
 
-	res, err := col.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return err
-	}
-	if res.ModifiedCount == 0 {
-		return errors.New("coupon limit reached or invalid coupon")
-	}
-	return nil
+	return &ValidateResult{Coupon: &c, DiscountAmount: discountFor(&c, co.OrderAmount)}, nil
 }
 
 func Update(id string, c *models.Coupon) error {
@@ -273,6 +231,9 @@ func Update(id string, c *models.Coupon) error {
 	if err != nil {
 		return err
 	}
+	if err := ValidateRules(c.Rules); err != nil {
+		return err
+	}
 	col := config.GetDB().Collection("coupons")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -290,6 +251,7 @@ func Update(id string, c *models.Coupon) error {
 			"valid_until":    c.ValidUntil,
 			"max_uses":       c.MaxUses,
 			"is_active":      c.IsActive,
+			"rules":          c.Rules,
 		},
 	}
 
@@ -0,0 +1,193 @@
+package coupon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	"ticpin-backend/services/redemption"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func ledger() *redemption.Ledger {
+	return &redemption.Ledger{Col: config.CouponRedemptionsCol, Parent: "coupon_id", OnChange: refreshUsedCount}
+}
+
+// reservationTTL matches the payment link expiry; a reservation still
+// unpaid after it is released.
+const reservationTTL = 30 * time.Minute
+
+// activeFilter matches redemptions that count against a coupon's limits:
+// committed ones and reservations that have not expired.
+func activeFilter(couponID primitive.ObjectID, now time.Time) bson.M {
+	return bson.M{
+		"coupon_id": couponID,
+		"$or": []bson.M{
+			{"status": "committed"},
+			{"status": "reserved", "expires_at": bson.M{"$gt": now}},
+		},
+	}
+}
+
+func legacyUses(c *models.Coupon, userID string) (total, byUser int) {
+	for _, u := range c.UsedByUsers {
+		total++
+		if userID != "" && u.UserID == userID {
+			byUser++
+		}
+	}
+	return total, byUser
+}
+
+// usageCounts returns how many times a coupon is in use overall and by one
+// user, including usage recorded before redemptions were tracked.
+func usageCounts(ctx context.Context, c *models.Coupon, userID string) (total, byUser int64, err error) {
+	now := time.Now()
+	total, err = config.CouponRedemptionsCol.CountDocuments(ctx, activeFilter(c.ID, now))
+	if err != nil {
+		return 0, 0, err
+	}
+	if userID != "" {
+		filter := activeFilter(c.ID, now)
+		filter["user_id"] = userID
+		if byUser, err = config.CouponRedemptionsCol.CountDocuments(ctx, filter); err != nil {
+			return 0, 0, err
+		}
+	}
+	legacyTotal, legacyByUser := legacyUses(c, userID)
+	return total + int64(legacyTotal), byUser + int64(legacyByUser), nil
+}
+
+// refreshUsedCount caches the committed count on the coupon for listings.
+func refreshUsedCount(ctx context.Context, couponID primitive.ObjectID) {
+	var c models.Coupon
+	if err := config.CouponsCol.FindOne(ctx, bson.M{"_id": couponID}).Decode(&c); err != nil {
+		return
+	}
+	committed, err := config.CouponRedemptionsCol.CountDocuments(ctx, bson.M{"coupon_id": couponID, "status": "committed"})
+	if err != nil {
+		return
+	}
+	legacyTotal, _ := legacyUses(&c, "")
+	config.CouponsCol.UpdateOne(ctx, bson.M{"_id": couponID}, bson.M{"$set": bson.M{"used_count": committed + int64(legacyTotal)}})
+}
+
+// Reserve holds one use of a validated coupon for an order. The reservation
+// is re-checked after it is written so that concurrent checkouts cannot
+// take a coupon past its limits.
+func Reserve(c *models.Coupon, userID, userEmail, orderID string, orderAmount, discount float64) (*models.CouponRedemption, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	r := &models.CouponRedemption{
+		ID:          primitive.NewObjectID(),
+		CouponID:    c.ID,
+		CampaignID:  c.CampaignID,
+		Code:        c.Code,
+		Category:    c.Category,
+		UserID:      userID,
+		UserEmail:   userEmail,
+		OrderID:     orderID,
+		Discount:    discount,
+		OrderAmount: orderAmount,
+		Status:      "reserved",
+		ExpiresAt:   now.Add(reservationTTL),
+		CreatedAt:   now,
+	}
+	if _, err := config.CouponRedemptionsCol.InsertOne(ctx, r); err != nil {
+		return nil, err
+	}
+
+	usesPerUser := 0
+	if c.Rules != nil {
+		usesPerUser = c.Rules.UsesPerUser
+	}
+	if c.MaxUses > 0 || usesPerUser > 0 {
+		filter := activeFilter(c.ID, now)
+		ahead, err := ledger().Ahead(ctx, filter, r.ID)
+		if err != nil {
+			return nil, err
+		}
+		legacyTotal, legacyByUser := legacyUses(c, userID)
+		if c.MaxUses > 0 && ahead+int64(legacyTotal) > int64(c.MaxUses) {
+			_ = ledger().ReleaseReservation(r.ID, "limit_reached")
+			return nil, reject(RuleMaxUses, "coupon usage limit reached")
+		}
+		if usesPerUser > 0 && userID != "" {
+			filter["user_id"] = userID
+			aheadByUser, err := ledger().Ahead(ctx, filter, r.ID)
+			if err != nil {
+				return nil, err
+			}
+			if aheadByUser+int64(legacyByUser) > int64(usesPerUser) {
+				_ = ledger().ReleaseReservation(r.ID, "limit_reached")
+				return nil, reject(RuleUsesPerUser, "you have already used this coupon %d time(s)", usesPerUser)
+			}
+		}
+	}
+	return r, nil
+}
+
+// AttachBooking records the booking a reservation was made for once the
+// booking exists.
+func AttachBooking(redemptionID primitive.ObjectID, bookingID string) error {
+	return ledger().AttachBooking(redemptionID, bookingID)
+}
+
+// Commit marks the redemption for a booking or payment order as used.
+func Commit(ref string) error {
+	return ledger().Commit(ref)
+}
+
+// Release frees the coupon use held by a booking or payment order, e.g.
+// when the payment fails or the booking is cancelled.
+func Release(ref, reason string) error {
+	return ledger().Release(ref, reason)
+}
+
+// ReleaseReservation frees a reservation whose booking could not be made.
+func ReleaseReservation(redemptionID primitive.ObjectID, reason string) error {
+	return ledger().ReleaseReservation(redemptionID, reason)
+}
+
+// ReleaseExpired releases reservations whose payment never arrived.
+func ReleaseExpired() (int64, error) {
+	return ledger().ReleaseExpired()
+}
+
+// ExpireReservations releases coupon reservations whose checkout was
+// abandoned.
+func ExpireReservations() {
+	if n, err := ReleaseExpired(); err != nil {
+		fmt.Printf("DEBUG: Failed to release expired coupon reservations: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("DEBUG: Released %d expired coupon reservations\n", n)
+	}
+}
+
+// ListRedemptions returns a coupon's redemptions, newest first.
+func ListRedemptions(couponID string) ([]models.CouponRedemption, error) {
+	objID, err := primitive.ObjectIDFromHex(couponID)
+	if err != nil {
+		return nil, errors.New("invalid coupon id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.CouponRedemptionsCol.Find(ctx, bson.M{"coupon_id": objID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	list := []models.CouponRedemption{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
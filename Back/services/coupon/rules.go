@@ -0,0 +1,295 @@
+package coupon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Rule names reported in a Rejection.
+const (
+	RuleCode           = "code"
+	RuleCategory       = "category"
+	RuleActive         = "active"
+	RuleValidFrom      = "valid_from"
+	RuleValidUntil     = "valid_until"
+	RuleMaxUses        = "max_uses"
+	RuleUser           = "user"
+	RuleMinOrderAmount = "min_order_amount"
+	RuleUsesPerUser    = "uses_per_user"
+	RuleFirstBooking   = "first_booking_only"
+	RuleTarget         = "target"
+	RuleOrganizer      = "organizer"
+	RuleCity           = "city"
+	RuleWeekday        = "weekday"
+	RuleTimeWindow     = "time_window"
+	RuleExcludeOffers  = "exclude_offers"
+	RuleExcludeTicpass = "exclude_ticpass"
+)
+
+// Rejection explains which rule stopped a coupon from applying.
+type Rejection struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (r *Rejection) Error() string { return r.Message }
+
+func reject(rule, format string, args ...interface{}) *Rejection {
+	return &Rejection{Rule: rule, Message: fmt.Sprintf(format, args...)}
+}
+
+// Checkout describes the order a coupon is being applied to. TargetID is the
+// event, play or dining ID; WithOffer and WithTicpass say what else the
+// order is combining with the coupon.
+type Checkout struct {
+	Category    string
+	OrderAmount float64
+	UserID      string
+	UserEmail   string
+	TargetID    string
+	OrganizerID primitive.ObjectID
+	City        string
+	WithOffer   bool
+	WithTicpass bool
+	At          time.Time
+}
+
+var ist = time.FixedZone("IST", 5*3600+30*60)
+
+func bookingsColFor(category string) *mongo.Collection {
+	switch category {
+	case "play":
+		return config.PlayBookingsCol
+	case "dining":
+		return config.DiningBookingsCol
+	default:
+		return config.EventBookingsCol
+	}
+}
+
+func containsID(ids []primitive.ObjectID, hex string) bool {
+	oid, err := primitive.ObjectIDFromHex(hex)
+	if err != nil {
+		return false
+	}
+	for _, id := range ids {
+		if id == oid {
+			return true
+		}
+	}
+	return false
+}
+
+// fillTarget loads the organizer and city of the listing being booked when
+// the caller only knows its ID.
+func fillTarget(ctx context.Context, co *Checkout) {
+	if co.TargetID == "" || (!co.OrganizerID.IsZero() && co.City != "") {
+		return
+	}
+	oid, err := primitive.ObjectIDFromHex(co.TargetID)
+	if err != nil {
+		return
+	}
+	col := config.EventsCol
+	switch co.Category {
+	case "play":
+		col = config.PlaysCol
+	case "dining":
+		col = config.DiningsCol
+	}
+	var target struct {
+		OrganizerID primitive.ObjectID `bson:"organizer_id"`
+		City        string             `bson:"city"`
+	}
+	if err := col.FindOne(ctx, bson.M{"_id": oid}, options.FindOne().SetProjection(bson.M{"organizer_id": 1, "city": 1})).Decode(&target); err != nil {
+		return
+	}
+	if co.OrganizerID.IsZero() {
+		co.OrganizerID = target.OrganizerID
+	}
+	if co.City == "" {
+		co.City = target.City
+	}
+}
+
+// minutesOf parses "HH:MM" into minutes after midnight.
+func minutesOf(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ValidateRules rejects malformed rules before a coupon is saved.
+func ValidateRules(r *models.CouponRules) error {
+	if r == nil {
+		return nil
+	}
+	if r.MinOrderAmount < 0 || r.MaxDiscount < 0 || r.UsesPerUser < 0 {
+		return errors.New("coupon limits cannot be negative")
+	}
+	for _, d := range r.Weekdays {
+		if d < 0 || d > 6 {
+			return errors.New("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if (r.TimeFrom == "") != (r.TimeUntil == "") {
+		return errors.New("time_from and time_until must be set together")
+	}
+	if r.TimeFrom != "" {
+		if _, err := minutesOf(r.TimeFrom); err != nil {
+			return errors.New("time_from must be HH:MM")
+		}
+		if _, err := minutesOf(r.TimeUntil); err != nil {
+			return errors.New("time_until must be HH:MM")
+		}
+	}
+	return nil
+}
+
+// checkRules applies the coupon's rule set to an order. usedByUser is how
+// many uses the user already holds. It returns nil when every rule passes.
+func checkRules(ctx context.Context, c *models.Coupon, co Checkout, usedByUser int64) *Rejection {
+	r := c.Rules
+	if r == nil {
+		return nil
+	}
+	fillTarget(ctx, &co)
+
+	if r.MinOrderAmount > 0 && co.OrderAmount < r.MinOrderAmount {
+		return reject(RuleMinOrderAmount, "add items worth ₹%.0f more to use this coupon", r.MinOrderAmount-co.OrderAmount)
+	}
+
+	if len(r.TargetIDs) > 0 && !containsID(r.TargetIDs, co.TargetID) {
+		return reject(RuleTarget, "this coupon is not valid for this %s", c.Category)
+	}
+	if len(r.OrganizerIDs) > 0 && !containsID(r.OrganizerIDs, co.OrganizerID.Hex()) {
+		return reject(RuleOrganizer, "this coupon is not valid for this organizer")
+	}
+	if len(r.Cities) > 0 {
+		found := false
+		for _, city := range r.Cities {
+			if strings.EqualFold(city, co.City) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reject(RuleCity, "this coupon is only valid in %s", strings.Join(r.Cities, ", "))
+		}
+	}
+
+	at := co.At
+	if at.IsZero() {
+		at = time.Now()
+	}
+	local := at.In(ist)
+	if len(r.Weekdays) > 0 {
+		found := false
+		for _, d := range r.Weekdays {
+			if time.Weekday(d) == local.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return reject(RuleWeekday, "this coupon cannot be used on %s", local.Weekday())
+		}
+	}
+	if r.TimeFrom != "" && r.TimeUntil != "" {
+		from, _ := minutesOf(r.TimeFrom)
+		until, _ := minutesOf(r.TimeUntil)
+		now := local.Hour()*60 + local.Minute()
+		inside := now >= from && now < until
+		if until <= from {
+			inside = now >= from || now < until
+		}
+		if !inside {
+			return reject(RuleTimeWindow, "this coupon is only valid between %s and %s", r.TimeFrom, r.TimeUntil)
+		}
+	}
+
+	if r.ExcludeOffers && co.WithOffer {
+		return reject(RuleExcludeOffers, "this coupon cannot be combined with offers")
+	}
+	if r.ExcludeTicpass && co.WithTicpass {
+		return reject(RuleExcludeTicpass, "this coupon cannot be combined with Ticpass benefits")
+	}
+
+	if (r.UsesPerUser > 0 || r.FirstBookingOnly) && co.UserID == "" {
+		return reject(RuleUser, "log in to use this coupon")
+	}
+	if r.UsesPerUser > 0 && usedByUser >= int64(r.UsesPerUser) {
+		return reject(RuleUsesPerUser, "you have already used this coupon %d time(s)", usedByUser)
+	}
+	if r.FirstBookingOnly {
+		n, err := bookingsColFor(c.Category).CountDocuments(ctx, bson.M{
+			"user_id": co.UserID,
+			"status":  bson.M{"$in": []string{"booked", "confirmed", "completed"}},
+		})
+		if err == nil && n > 0 {
+			return reject(RuleFirstBooking, "this coupon is only valid on your first %s booking", c.Category)
+		}
+	}
+	return nil
+}
+
+// discountFor computes the coupon's discount on an order amount, honouring
+// the max discount cap.
+func discountFor(c *models.Coupon, orderAmount float64) float64 {
+	var discount float64
+	if c.DiscountType == "percent" {
+		discount = orderAmount * c.DiscountValue / 100
+	} else {
+		discount = c.DiscountValue
+	}
+	if c.Rules != nil && c.Rules.MaxDiscount > 0 && discount > c.Rules.MaxDiscount {
+		discount = c.Rules.MaxDiscount
+	}
+	if discount > orderAmount {
+		discount = orderAmount
+	}
+	return discount
+}
+
+// ListingScope narrows coupon listings to what applies on a page. An empty
+// TargetID hides coupons restricted to specific listings.
+type ListingScope struct {
+	TargetID string
+	City     string
+}
+
+func scopeFilters(scope ListingScope) bson.A {
+	filters := bson.A{}
+
+	noTargets := bson.A{
+		bson.M{"rules.target_ids": bson.M{"$exists": false}},
+		bson.M{"rules.target_ids": bson.M{"$size": 0}},
+	}
+	if oid, err := primitive.ObjectIDFromHex(scope.TargetID); err == nil {
+		noTargets = append(noTargets, bson.M{"rules.target_ids": oid})
+	}
+	filters = append(filters, bson.M{"$or": noTargets})
+
+	if scope.City != "" {
+		filters = append(filters, bson.M{"$or": bson.A{
+			bson.M{"rules.cities": bson.M{"$exists": false}},
+			bson.M{"rules.cities": bson.M{"$size": 0}},
+			bson.M{"rules.cities": bson.M{"$regex": "^" + regexp.QuoteMeta(scope.City) + "$", "$options": "i"}},
+		}})
+	}
+	return filters
+}
@@ -60,7 +60,7 @@ func GetAll(category string, limit int, after string) ([]models.Dining, string,
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{"status": "approved"}
+	filter := bson.M{"status": "approved", "deleted_at": bson.M{"$exists": false}}
 	if category != "" && category != "all" {
 		filter["category"] = category
 	}
@@ -107,7 +107,7 @@ func GetAllForAdmin(category string, limit int, after string) ([]models.Dining,
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{}
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
 	if category != "" {
 		filter["category"] = category
 	}
@@ -167,7 +167,7 @@ func GetByID(id string, bypassCache bool) (*models.Dining, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var d models.Dining
-	if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&d); err != nil {
+	if err := col.FindOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}}).Decode(&d); err != nil {
 		return nil, err
 	}
 
@@ -181,7 +181,7 @@ func GetByName(name string) (*models.Dining, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var d models.Dining
-	if err := col.FindOne(ctx, bson.M{"name": name}).Decode(&d); err != nil {
+	if err := col.FindOne(ctx, bson.M{"name": name, "deleted_at": bson.M{"$exists": false}}).Decode(&d); err != nil {
 		return nil, err
 	}
 	return &d, nil
@@ -195,7 +195,7 @@ func GetByOrganizer(organizerID string) ([]models.Dining, error) {
 	col := config.DiningsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := col.Find(ctx, bson.M{"organizer_id": objID})
+	cursor, err := col.Find(ctx, bson.M{"organizer_id": objID, "deleted_at": bson.M{"$exists": false}})
 	if err != nil {
 		return nil, err
 	}
@@ -220,7 +220,7 @@ func Update(id string, organizerID string, update *models.Dining) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var original models.Dining
-	if err := col.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID}).Decode(&original); err != nil {
+	if err := col.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID, "deleted_at": bson.M{"$exists": false}}).Decode(&original); err != nil {
 		return errors.New("dining not found or not owned by this organizer")
 	}
 
@@ -345,11 +345,13 @@ func Delete(id string, organizerID string) error {
 	col := config.DiningsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	res, err := col.DeleteOne(ctx, bson.M{"_id": objID, "organizer_id": orgID})
+	res, err := col.UpdateOne(ctx, bson.M{"_id": objID, "organizer_id": orgID, "deleted_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"deleted_at": time.Now(), "deleted_by": organizerID},
+	})
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return errors.New("dining not found or not owned by this organizer")
 	}
 
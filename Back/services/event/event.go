@@ -9,16 +9,22 @@ import (
 	"ticpin-backend/cache"
 	"ticpin-backend/config"
 	"ticpin-backend/models"
+	bookingsvc "ticpin-backend/services/booking"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func calculateMinPrice(categories []models.TicketCategory) float64 {
+// calculateMinPrice returns the cheapest price currently on sale, taking
+// each category's active tier into account. sold may be nil for new events.
+func calculateMinPrice(categories []models.TicketCategory, sold map[string]int) float64 {
 	if len(categories) == 0 {
 		return 0
 	}
+	if min, err := bookingsvc.StartingPrice(categories, sold, time.Now()); err == nil {
+		return min
+	}
 	min := categories[0].Price
 	for _, cat := range categories {
 		if cat.Price < min {
@@ -28,6 +34,44 @@ func calculateMinPrice(categories []models.TicketCategory) float64 {
 	return min
 }
 
+// applySeatMap validates a reserved-seating layout against the ticket
+// categories and sizes each category to the number of seats mapped to it.
+func applySeatMap(sm *models.SeatMap, categories []models.TicketCategory) error {
+	counts := map[string]int{}
+	for _, tc := range categories {
+		counts[tc.Name] = 0
+	}
+
+	seen := map[string]bool{}
+	for _, section := range sm.Sections {
+		for _, row := range section.Rows {
+			for _, seat := range row.Seats {
+				if seat.ID == "" {
+					return fmt.Errorf("seat in section %q row %q is missing an id", section.Name, row.Label)
+				}
+				if seen[seat.ID] {
+					return fmt.Errorf("duplicate seat id %q", seat.ID)
+				}
+				seen[seat.ID] = true
+				if _, ok := counts[seat.Category]; !ok {
+					return fmt.Errorf("seat %q references unknown ticket category %q", seat.ID, seat.Category)
+				}
+				if !seat.Blocked {
+					counts[seat.Category]++
+				}
+			}
+		}
+	}
+	if len(seen) == 0 {
+		return errors.New("seat map has no seats")
+	}
+
+	for i := range categories {
+		categories[i].Capacity = counts[categories[i].Name]
+	}
+	return nil
+}
+
 func Create(e *models.Event) error {
 	orgCol := config.OrgsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -43,8 +87,26 @@ func Create(e *models.Event) error {
 		return errors.New("organizer is not approved for the events category")
 	}
 
+	if err := bookingsvc.ValidateTicketCategories(e.TicketCategories); err != nil {
+		return err
+	}
+	if err := bookingsvc.ValidatePurchaseRules(e.PurchaseRules); err != nil {
+		return err
+	}
+	if e.SeatMap != nil {
+		if err := applySeatMap(e.SeatMap, e.TicketCategories); err != nil {
+			return err
+		}
+	}
+
 	if len(e.TicketCategories) > 0 {
-		e.PriceStartsFrom = calculateMinPrice(e.TicketCategories)
+		e.PriceStartsFrom = calculateMinPrice(e.TicketCategories, nil)
+	}
+
+	if len(e.Showtimes) > 0 {
+		if err := normalizeShowtimes(e); err != nil {
+			return err
+		}
 	}
 
 	e.ID = primitive.NewObjectID()
@@ -79,7 +141,7 @@ func GetAll(category string, artist string, limit int, after string) ([]models.E
 		fmt.Printf("DEBUG: GetAll - Collection test count: %d\n", testCount)
 	}
 
-	filter := bson.M{}
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
 	if category != "" && category != "all" {
 		filter["category"] = category
 		fmt.Printf("DEBUG: GetAll - Applied category filter: %s\n", category)
@@ -192,7 +254,7 @@ func GetAllForAdmin(category string, limit int, after string) ([]models.Event, s
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{}
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
 
 	if category != "" && category != "all" {
 		filter["category"] = category
@@ -253,7 +315,7 @@ func GetByID(id string, bypassCache bool) (*models.Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var e models.Event
-	if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&e); err != nil {
+	if err := col.FindOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}}).Decode(&e); err != nil {
 		return nil, err
 	}
 
@@ -281,7 +343,7 @@ func GetEventOffers(eventIdentifier string) ([]models.EventOffer, error) {
 	validOffers := []models.EventOffer{}
 	now := time.Now()
 	for _, offer := range offers {
-		if offer.ValidUntil.After(now) {
+		if offer.ValidUntil.After(now) && (offer.StartsAt.IsZero() || !offer.StartsAt.After(now)) {
 			validOffers = append(validOffers, offer)
 		}
 	}
@@ -294,7 +356,7 @@ func GetByName(name string) (*models.Event, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var e models.Event
-	if err := col.FindOne(ctx, bson.M{"name": name}).Decode(&e); err != nil {
+	if err := col.FindOne(ctx, bson.M{"name": name, "deleted_at": bson.M{"$exists": false}}).Decode(&e); err != nil {
 		return nil, err
 	}
 	return &e, nil
@@ -308,7 +370,7 @@ func GetByOrganizer(organizerID string) ([]models.Event, error) {
 	col := config.EventsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := col.Find(ctx, bson.M{"organizer_id": objID})
+	cursor, err := col.Find(ctx, bson.M{"organizer_id": objID, "deleted_at": bson.M{"$exists": false}})
 	if err != nil {
 		return nil, err
 	}
@@ -333,7 +395,7 @@ func Update(id string, organizerID string, update *models.Event) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var original models.Event
-	if err := col.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID}).Decode(&original); err != nil {
+	if err := col.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID, "deleted_at": bson.M{"$exists": false}}).Decode(&original); err != nil {
 		return errors.New("event not found or not owned by this organizer")
 	}
 
@@ -426,8 +488,55 @@ func Update(id string, organizerID string, update *models.Event) error {
 		updateDoc["artists"] = update.Artists
 	}
 	if len(update.TicketCategories) > 0 {
+		if err := bookingsvc.ValidateTicketCategories(update.TicketCategories); err != nil {
+			return err
+		}
 		updateDoc["ticket_categories"] = update.TicketCategories
-		updateDoc["price_starts_from"] = calculateMinPrice(update.TicketCategories)
+		sold, err := soldByCategory(ctx, &original)
+		if err != nil {
+			return err
+		}
+		updateDoc["price_starts_from"] = calculateMinPrice(update.TicketCategories, sold[""])
+	}
+	if update.SeatMap != nil {
+		categories := update.TicketCategories
+		if len(categories) == 0 {
+			categories = original.TicketCategories
+		}
+		if err := applySeatMap(update.SeatMap, categories); err != nil {
+			return err
+		}
+		updateDoc["seat_map"] = update.SeatMap
+		updateDoc["ticket_categories"] = categories
+	}
+	if update.PurchaseRules != nil {
+		if err := bookingsvc.ValidatePurchaseRules(update.PurchaseRules); err != nil {
+			return err
+		}
+		updateDoc["purchase_rules"] = update.PurchaseRules
+	}
+	if len(update.Showtimes) > 0 {
+		if err := checkShowtimeEdits(original.Showtimes, update.Showtimes); err != nil {
+			return err
+		}
+		scheduled := &models.Event{
+			TicketCategories: original.TicketCategories,
+			SeatMap:          original.SeatMap,
+			Showtimes:        update.Showtimes,
+		}
+		if categories, ok := updateDoc["ticket_categories"].([]models.TicketCategory); ok {
+			scheduled.TicketCategories = categories
+		}
+		if update.SeatMap != nil {
+			scheduled.SeatMap = update.SeatMap
+		}
+		if err := normalizeShowtimes(scheduled); err != nil {
+			return err
+		}
+		updateDoc["showtimes"] = scheduled.Showtimes
+		updateDoc["date"] = scheduled.Date
+		updateDoc["time"] = scheduled.Time
+		updateDoc["price_starts_from"] = scheduled.PriceStartsFrom
 	}
 	if update.Payment.OrganizerName != "" {
 		updateDoc["payment.organizer_name"] = update.Payment.OrganizerName
@@ -463,6 +572,14 @@ func Update(id string, organizerID string, update *models.Event) error {
 		cacheManager := cache.NewCacheManager()
 		cacheManager.DeleteEntity("event", id)
 		cacheManager.DeleteList("event")
+
+		// Showtime prices are summarised without sales, so the listing
+		// price is settled against what has sold
+		if len(update.Showtimes) > 0 {
+			if err := RefreshPriceStartsFrom(objID); err != nil {
+				fmt.Printf("ERROR: Failed to refresh starting price of event %s: %v\n", id, err)
+			}
+		}
 	}
 	return err
 }
@@ -479,11 +596,13 @@ func Delete(id string, organizerID string) error {
 	col := config.EventsCol
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	res, err := col.DeleteOne(ctx, bson.M{"_id": objID, "organizer_id": orgID})
+	res, err := col.UpdateOne(ctx, bson.M{"_id": objID, "organizer_id": orgID, "deleted_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"deleted_at": time.Now(), "deleted_by": organizerID},
+	})
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return errors.New("event not found or not owned by this organizer")
 	}
 
@@ -0,0 +1,60 @@
+package event
+
+import (
+	"context"
+	"time"
+
+	"ticpin-backend/config"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// LimitHitAccount summarises how often one buyer ran into an event's
+// purchase rules.
+type LimitHitAccount struct {
+	UserID     string    `bson:"user_id" json:"user_id"`
+	UserPhone  string    `bson:"user_phone" json:"user_phone"`
+	UserEmail  string    `bson:"user_email" json:"user_email"`
+	Hits       int       `bson:"hits" json:"hits"`
+	Rules      []string  `bson:"rules" json:"rules"`
+	MaxHeld    int       `bson:"max_held" json:"max_held"`
+	LastHitAt  time.Time `bson:"last_hit_at" json:"last_hit_at"`
+	FirstHitAt time.Time `bson:"first_hit_at" json:"first_hit_at"`
+}
+
+// GetLimitHitReport lists the accounts that hit the event's purchase
+// limits, most frequent first.
+func GetLimitHitReport(id string, organizerID string) ([]LimitHitAccount, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, id, organizerID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := config.PurchaseLimitHitsCol.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"event_id": e.ID}},
+		{"$group": bson.M{
+			"_id":          bson.M{"user_id": "$user_id", "user_phone": "$user_phone"},
+			"user_email":   bson.M{"$last": "$user_email"},
+			"hits":         bson.M{"$sum": 1},
+			"rules":        bson.M{"$addToSet": "$rule"},
+			"max_held":     bson.M{"$max": "$held"},
+			"last_hit_at":  bson.M{"$max": "$created_at"},
+			"first_hit_at": bson.M{"$min": "$created_at"},
+		}},
+		{"$addFields": bson.M{"user_id": "$_id.user_id", "user_phone": "$_id.user_phone"}},
+		{"$sort": bson.M{"hits": -1, "last_hit_at": -1}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	report := []LimitHitAccount{}
+	if err := cursor.All(ctx, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,138 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ticpin-backend/cache"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	bookingsvc "ticpin-backend/services/booking"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// soldByCategory counts live tickets per category, keyed by showtime ID hex
+// (empty for events without showtimes). Pending bookings inside their hold
+// count, as their tickets are spoken for.
+func soldByCategory(ctx context.Context, e *models.Event) (map[string]map[string]int, error) {
+	groupID := bson.M{"category": "$tickets.category"}
+	if len(e.Showtimes) > 0 {
+		groupID["showtime"] = "$showtime_id"
+	}
+	cursor, err := config.EventBookingsCol.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"$and": []bson.M{
+			{"event_id": e.ID},
+			bookingsvc.HoldingTickets(time.Now()),
+		}}},
+		{"$unwind": "$tickets"},
+		{"$group": bson.M{
+			"_id":   groupID,
+			"count": bson.M{"$sum": "$tickets.quantity"},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		ID struct {
+			Category string             `bson:"category"`
+			Showtime primitive.ObjectID `bson:"showtime"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	sold := map[string]map[string]int{}
+	for _, r := range rows {
+		key := ""
+		if !r.ID.Showtime.IsZero() {
+			key = r.ID.Showtime.Hex()
+		}
+		if sold[key] == nil {
+			sold[key] = map[string]int{}
+		}
+		sold[key][r.ID.Category] += r.Count
+	}
+	return sold, nil
+}
+
+// RefreshPriceStartsFrom recomputes the listing price from live sales, so
+// that quantity-based tiers move the "starts from" price as they sell out.
+func RefreshPriceStartsFrom(eventID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var e models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": eventID}).Decode(&e); err != nil {
+		return err
+	}
+	sold, err := soldByCategory(ctx, &e)
+	if err != nil {
+		return err
+	}
+
+	price := calculateMinPrice(e.TicketCategories, sold[""])
+	if len(e.Showtimes) > 0 {
+		first := true
+		for _, st := range e.Showtimes {
+			if st.Status == "cancelled" || len(st.TicketCategories) == 0 {
+				continue
+			}
+			if min := calculateMinPrice(st.TicketCategories, sold[st.ID.Hex()]); first || min < price {
+				price = min
+				first = false
+			}
+		}
+	}
+	if price == e.PriceStartsFrom {
+		return nil
+	}
+
+	if _, err := config.EventsCol.UpdateOne(ctx, bson.M{"_id": eventID}, bson.M{"$set": bson.M{
+		"price_starts_from": price,
+	}}); err != nil {
+		return err
+	}
+	cacheManager := cache.NewCacheManager()
+	cacheManager.DeleteEntity("event", eventID.Hex())
+	cacheManager.DeleteList("event")
+	return nil
+}
+
+// RefreshScheduledPrices recomputes the listing price of events whose tiers
+// open or close on a date, since no sale marks that moment.
+func RefreshScheduledPrices() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := config.EventsCol.Find(ctx, bson.M{
+		"deleted_at": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"ticket_categories.tiers.starts_at": bson.M{"$exists": true}},
+			{"ticket_categories.tiers.ends_at": bson.M{"$exists": true}},
+			{"showtimes.ticket_categories.tiers.starts_at": bson.M{"$exists": true}},
+			{"showtimes.ticket_categories.tiers.ends_at": bson.M{"$exists": true}},
+		},
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load events with dated price tiers: %v\n", err)
+		return
+	}
+	var events []models.Event
+	if err := cursor.All(ctx, &events); err != nil {
+		return
+	}
+	for _, e := range events {
+		if err := RefreshPriceStartsFrom(e.ID); err != nil {
+			fmt.Printf("ERROR: Failed to refresh starting price of event %s: %v\n", e.ID.Hex(), err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"ticpin-backend/cache"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	bookingsvc "ticpin-backend/services/booking"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// normalizeShowtimes fills in IDs, status and inventory for each showtime and
+// refreshes the event-level summary fields derived from them.
+func normalizeShowtimes(e *models.Event) error {
+	for i := range e.Showtimes {
+		st := &e.Showtimes[i]
+		if st.Date.IsZero() {
+			return errors.New("showtime date is required")
+		}
+		if st.ID.IsZero() {
+			st.ID = primitive.NewObjectID()
+		}
+		if st.Status == "" {
+			st.Status = "scheduled"
+		}
+		if len(st.TicketCategories) == 0 {
+			st.TicketCategories = append([]models.TicketCategory(nil), e.TicketCategories...)
+		}
+		if err := bookingsvc.ValidateTicketCategories(st.TicketCategories); err != nil {
+			return err
+		}
+		if e.SeatMap != nil {
+			if err := applySeatMap(e.SeatMap, st.TicketCategories); err != nil {
+				return err
+			}
+		}
+	}
+	syncShowtimeSummary(e)
+	return nil
+}
+
+// syncShowtimeSummary keeps Date, Time and PriceStartsFrom pointing at the
+// earliest show that is still scheduled, so listings need no changes.
+func syncShowtimeSummary(e *models.Event) {
+	sort.SliceStable(e.Showtimes, func(i, j int) bool {
+		return e.Showtimes[i].Date.Before(e.Showtimes[j].Date)
+	})
+
+	first := true
+	for _, st := range e.Showtimes {
+		if st.Status == "cancelled" {
+			continue
+		}
+		if first {
+			e.Date = st.Date
+			e.Time = st.Time
+			e.PriceStartsFrom = calculateMinPrice(st.TicketCategories, nil)
+			first = false
+			continue
+		}
+		if len(st.TicketCategories) > 0 {
+			if min := calculateMinPrice(st.TicketCategories, nil); min < e.PriceStartsFrom {
+				e.PriceStartsFrom = min
+			}
+		}
+	}
+}
+
+func loadOwnedEvent(ctx context.Context, id string, organizerID string) (*models.Event, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	orgID, err := primitive.ObjectIDFromHex(organizerID)
+	if err != nil {
+		return nil, err
+	}
+	var e models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID}).Decode(&e); err != nil {
+		return nil, errors.New("event not found or not owned by this organizer")
+	}
+	return &e, nil
+}
+
+func saveShowtimes(ctx context.Context, e *models.Event) error {
+	_, err := config.EventsCol.UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{"$set": bson.M{
+		"showtimes":         e.Showtimes,
+		"date":              e.Date,
+		"time":              e.Time,
+		"price_starts_from": e.PriceStartsFrom,
+		"updatedAt":         time.Now(),
+	}})
+	if err == nil {
+		cacheManager := cache.NewCacheManager()
+		cacheManager.DeleteEntity("event", e.ID.Hex())
+		cacheManager.DeleteList("event")
+		if err := RefreshPriceStartsFrom(e.ID); err != nil {
+			fmt.Printf("ERROR: Failed to refresh starting price of event %s: %v\n", e.ID.Hex(), err)
+		}
+	}
+	return err
+}
+
+func findShowtime(e *models.Event, showtimeID string) (*models.Showtime, error) {
+	stID, err := primitive.ObjectIDFromHex(showtimeID)
+	if err != nil {
+		return nil, errors.New("invalid showtime id")
+	}
+	for i := range e.Showtimes {
+		if e.Showtimes[i].ID == stID {
+			return &e.Showtimes[i], nil
+		}
+	}
+	return nil, errors.New("showtime not found")
+}
+
+// checkShowtimeEdits lets a full event update add shows but not change the
+// ones already on sale, since buyers hold tickets for them. Those go through
+// the showtime, cancel and reschedule routes, which tell the buyers.
+func checkShowtimeEdits(existing []models.Showtime, updated []models.Showtime) error {
+	byID := make(map[primitive.ObjectID]models.Showtime, len(updated))
+	for i := range updated {
+		st := &updated[i]
+		if st.ID.IsZero() {
+			st.Status = "scheduled"
+			continue
+		}
+		byID[st.ID] = *st
+	}
+
+	known := make(map[primitive.ObjectID]bool, len(existing))
+	for _, old := range existing {
+		known[old.ID] = true
+		st, ok := byID[old.ID]
+		if !ok {
+			return fmt.Errorf("showtime %s cannot be removed here; cancel it through the showtime or event cancel route", old.ID.Hex())
+		}
+		if !st.Date.Equal(old.Date) || st.Time != old.Time {
+			return fmt.Errorf("showtime %s cannot be moved here; use the showtime or event reschedule route", old.ID.Hex())
+		}
+		if st.Status != old.Status {
+			return fmt.Errorf("showtime %s status cannot be changed here; use the showtime or event cancel route", old.ID.Hex())
+		}
+	}
+	for id := range byID {
+		if !known[id] {
+			return fmt.Errorf("showtime %s not found; leave the id empty to add a show", id.Hex())
+		}
+	}
+	return nil
+}
+
+func AddShowtime(id string, organizerID string, st *models.Showtime) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, id, organizerID)
+	if err != nil {
+		return err
+	}
+
+	st.ID = primitive.NewObjectID()
+	st.Status = "scheduled"
+	e.Showtimes = append(e.Showtimes, *st)
+	if err := normalizeShowtimes(e); err != nil {
+		return err
+	}
+	if added, err := findShowtime(e, st.ID.Hex()); err == nil {
+		*st = *added
+	}
+	return saveShowtimes(ctx, e)
+}
+
+func RescheduleShowtime(id string, organizerID string, showtimeID string, date time.Time, timeStr string) error {
+	if date.IsZero() {
+		return errors.New("date is required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, id, organizerID)
+	if err != nil {
+		return err
+	}
+	st, err := findShowtime(e, showtimeID)
+	if err != nil {
+		return err
+	}
+	if st.Status == "cancelled" {
+		return errors.New("cannot reschedule a cancelled show")
+	}
+
+	st.Date = date
+	if timeStr != "" {
+		st.Time = timeStr
+	}
+	syncShowtimeSummary(e)
+	return saveShowtimes(ctx, e)
+}
+
+func CancelShowtime(id string, organizerID string, showtimeID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, id, organizerID)
+	if err != nil {
+		return err
+	}
+	st, err := findShowtime(e, showtimeID)
+	if err != nil {
+		return err
+	}
+	if st.Status == "cancelled" {
+		return errors.New("show is already cancelled")
+	}
+
+	st.Status = "cancelled"
+	syncShowtimeSummary(e)
+	return saveShowtimes(ctx, e)
+}
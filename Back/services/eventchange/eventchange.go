@@ -0,0 +1,682 @@
+package eventchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"ticpin-backend/cache"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	bookingsvc "ticpin-backend/services/booking"
+	couponsvc "ticpin-backend/services/coupon"
+	eventsvc "ticpin-backend/services/event"
+	offersvc "ticpin-backend/services/offer"
+	paymentsvc "ticpin-backend/services/payment"
+	referralsvc "ticpin-backend/services/referral"
+	walletsvc "ticpin-backend/services/wallet"
+	"ticpin-backend/worker"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxRefundAttempts       = 5
+	defaultRefundWindowDays = 7
+	// A refund claimed longer ago than this is assumed to belong to a run
+	// that died and is picked up again
+	claimTimeout = 15 * time.Minute
+)
+
+type CancelRequest struct {
+	Reason     string `json:"reason"`
+	ShowtimeID string `json:"showtime_id"`
+}
+
+type RescheduleRequest struct {
+	Date             time.Time `json:"date"`
+	Time             string    `json:"time"`
+	ShowtimeID       string    `json:"showtime_id"`
+	Reason           string    `json:"reason"`
+	RefundWindowDays int       `json:"refund_window_days"`
+}
+
+func loadOwnedEvent(ctx context.Context, eventID, organizerID string) (*models.Event, error) {
+	objID, err := primitive.ObjectIDFromHex(eventID)
+	if err != nil {
+		return nil, errors.New("invalid event id")
+	}
+	orgID, err := primitive.ObjectIDFromHex(organizerID)
+	if err != nil {
+		return nil, errors.New("invalid organizer id")
+	}
+	var e models.Event
+	if err := config.EventsCol.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID}).Decode(&e); err != nil {
+		return nil, errors.New("event not found or not owned by this organizer")
+	}
+	return &e, nil
+}
+
+// resolveTarget returns the showtime being changed (nil for the whole event)
+// along with its current date and time.
+func resolveTarget(e *models.Event, showtimeID string) (primitive.ObjectID, time.Time, string, error) {
+	if showtimeID == "" {
+		return primitive.NilObjectID, e.Date, e.Time, nil
+	}
+	stID, err := primitive.ObjectIDFromHex(showtimeID)
+	if err != nil {
+		return primitive.NilObjectID, time.Time{}, "", errors.New("invalid showtime id")
+	}
+	st := bookingsvc.FindShowtime(e, stID)
+	if st == nil {
+		return primitive.NilObjectID, time.Time{}, "", errors.New("showtime not found")
+	}
+	return stID, st.Date, st.Time, nil
+}
+
+func affectedBookings(ctx context.Context, eventID, showtimeID primitive.ObjectID) ([]models.Booking, error) {
+	filter := bson.M{
+		"event_id": eventID,
+		"status":   bson.M{"$in": []string{"booked", "confirmed"}},
+	}
+	if !showtimeID.IsZero() {
+		filter["showtime_id"] = showtimeID
+	}
+	cursor, err := config.EventBookingsCol.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bookings []models.Booking
+	if err := cursor.All(ctx, &bookings); err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func newChangeBooking(changeID primitive.ObjectID, b *models.Booking) models.EventChangeBooking {
+	now := time.Now()
+	return models.EventChangeBooking{
+		ID:         primitive.NewObjectID(),
+		ChangeID:   changeID,
+		BookingID:  b.ID,
+		BookingRef: b.BookingID,
+		UserID:     b.UserID,
+		UserEmail:  b.UserEmail,
+		PaymentID:  b.PaymentID,
+		Amount:     b.GrandTotal - b.WalletAmount, // the wallet part goes back to the wallet
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// cancelBooking voids a booking that is still live. Bookings that are
+// already cancelled are left as they are.
+func cancelBooking(ctx context.Context, bookingID primitive.ObjectID, reason string) error {
+	_, err := config.EventBookingsCol.UpdateOne(ctx, bson.M{
+		"_id":    bookingID,
+		"status": bson.M{"$in": []string{"booked", "confirmed"}},
+	}, bson.M{"$set": bson.M{
+		"status":        "cancelled",
+		"cancelled_at":  time.Now(),
+		"cancel_reason": reason,
+	}})
+	return err
+}
+
+// cancelPending voids bookings still waiting on their payment and gives back
+// what they held. They have nothing to refund yet; a payment that lands
+// later is refunded by the payment webhook.
+func cancelPending(ctx context.Context, eventID, showtimeID primitive.ObjectID) {
+	filter := bson.M{"event_id": eventID, "status": "pending"}
+	if !showtimeID.IsZero() {
+		filter["showtime_id"] = showtimeID
+	}
+	cursor, err := config.EventBookingsCol.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load pending bookings of event %s: %v\n", eventID.Hex(), err)
+		return
+	}
+	var pending []models.Booking
+	if err := cursor.All(ctx, &pending); err != nil {
+		return
+	}
+	for _, b := range pending {
+		res, err := config.EventBookingsCol.UpdateOne(ctx, bson.M{"_id": b.ID, "status": "pending"}, bson.M{"$set": bson.M{
+			"status":        "cancelled",
+			"cancelled_at":  time.Now(),
+			"cancel_reason": "event_cancelled",
+		}})
+		if err != nil || res.ModifiedCount == 0 {
+			continue
+		}
+		_ = couponsvc.Release(b.ID.Hex(), "event_cancelled")
+		_ = walletsvc.Release(b.ID.Hex(), "event_cancelled")
+		_ = offersvc.Release(b.ID.Hex(), "event_cancelled")
+	}
+}
+
+func cancelReason(change *models.EventChange) string {
+	if change.Type == "reschedule" {
+		return "event_rescheduled"
+	}
+	return "event_cancelled"
+}
+
+func invalidateEventCache(eventID string) {
+	cacheManager := cache.NewCacheManager()
+	cacheManager.DeleteEntity("event", eventID)
+	cacheManager.DeleteList("event")
+}
+
+// CancelEvent cancels an event (or one showtime), voids every live booking
+// and queues a full refund for each of them. Bookings still waiting on their
+// payment are voided too.
+func CancelEvent(eventID, organizerID string, req CancelRequest) (*models.EventChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	showtimeID, oldDate, oldTime, err := resolveTarget(e, req.ShowtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if showtimeID.IsZero() {
+		if e.Status == "cancelled" {
+			return nil, errors.New("event is already cancelled")
+		}
+		if _, err := config.EventsCol.UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{"$set": bson.M{
+			"status":    "cancelled",
+			"updatedAt": time.Now(),
+		}}); err != nil {
+			return nil, err
+		}
+		invalidateEventCache(eventID)
+	} else if err := eventsvc.CancelShowtime(eventID, organizerID, req.ShowtimeID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	change := &models.EventChange{
+		ID:          primitive.NewObjectID(),
+		EventID:     e.ID,
+		ShowtimeID:  showtimeID,
+		OrganizerID: e.OrganizerID,
+		Type:        "cancel",
+		Reason:      req.Reason,
+		OldDate:     oldDate,
+		OldTime:     oldTime,
+		Status:      "processing",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	bookings, err := affectedBookings(ctx, e.ID, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+	change.TotalBookings = len(bookings)
+	if _, err := config.EventChangesCol.InsertOne(ctx, change); err != nil {
+		return nil, err
+	}
+
+	for i := range bookings {
+		b := &bookings[i]
+		rec := newChangeBooking(change.ID, b)
+		rec.RefundStatus = "pending"
+		if _, err := config.EventChangeBookingsCol.InsertOne(ctx, rec); err != nil {
+			fmt.Printf("ERROR: Failed to queue refund for booking %s: %v\n", b.BookingID, err)
+			continue
+		}
+		if err := cancelBooking(ctx, b.ID, "event_cancelled"); err != nil {
+			// The refund run cancels the booking again before paying out
+			fmt.Printf("ERROR: Failed to cancel booking %s: %v\n", b.BookingID, err)
+		}
+		_ = couponsvc.Release(b.ID.Hex(), "event_cancelled")
+		_ = walletsvc.Release(b.ID.Hex(), "event_cancelled")
+		_ = offersvc.Release(b.ID.Hex(), "event_cancelled")
+	}
+	cancelPending(ctx, e.ID, showtimeID)
+
+	changeID := change.ID
+	queueRefunds(changeID)
+	return change, nil
+}
+
+// RescheduleEvent moves an event (or one showtime) to a new date, notifies
+// every booker and opens a window in which they may ask for a refund.
+func RescheduleEvent(eventID, organizerID string, req RescheduleRequest) (*models.EventChange, error) {
+	if req.Date.IsZero() {
+		return nil, errors.New("date is required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	showtimeID, oldDate, oldTime, err := resolveTarget(e, req.ShowtimeID)
+	if err != nil {
+		return nil, err
+	}
+
+	newTime := req.Time
+	if newTime == "" {
+		newTime = oldTime
+	}
+
+	if showtimeID.IsZero() {
+		if e.Status == "cancelled" {
+			return nil, errors.New("cannot reschedule a cancelled event")
+		}
+		if len(e.Showtimes) > 0 {
+			return nil, errors.New("showtime_id is required for events with showtimes")
+		}
+		if _, err := config.EventsCol.UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{"$set": bson.M{
+			"date":      req.Date,
+			"time":      newTime,
+			"updatedAt": time.Now(),
+		}}); err != nil {
+			return nil, err
+		}
+		invalidateEventCache(eventID)
+	} else if err := eventsvc.RescheduleShowtime(eventID, organizerID, req.ShowtimeID, req.Date, newTime); err != nil {
+		return nil, err
+	}
+
+	windowDays := req.RefundWindowDays
+	if windowDays <= 0 {
+		windowDays = defaultRefundWindowDays
+	}
+
+	now := time.Now()
+	change := &models.EventChange{
+		ID:               primitive.NewObjectID(),
+		EventID:          e.ID,
+		ShowtimeID:       showtimeID,
+		OrganizerID:      e.OrganizerID,
+		Type:             "reschedule",
+		Reason:           req.Reason,
+		OldDate:          oldDate,
+		OldTime:          oldTime,
+		NewDate:          req.Date,
+		NewTime:          newTime,
+		RefundWindowEnds: now.AddDate(0, 0, windowDays),
+		Status:           "open",
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	bookings, err := affectedBookings(ctx, e.ID, showtimeID)
+	if err != nil {
+		return nil, err
+	}
+	change.TotalBookings = len(bookings)
+	if _, err := config.EventChangesCol.InsertOne(ctx, change); err != nil {
+		return nil, err
+	}
+
+	oldLabel := fmt.Sprintf("%s (%s)", oldDate.Format("2006-01-02"), oldTime)
+	newLabel := fmt.Sprintf("%s (%s)", req.Date.Format("2006-01-02"), newTime)
+	respondBy := change.RefundWindowEnds.Format("02 January, 2006")
+	for i := range bookings {
+		b := bookings[i]
+		rec := newChangeBooking(change.ID, &b)
+		rec.Response = "pending"
+		if _, err := config.EventChangeBookingsCol.InsertOne(ctx, rec); err != nil {
+			fmt.Printf("ERROR: Failed to record reschedule for booking %s: %v\n", b.BookingID, err)
+			continue
+		}
+		worker.Submit(func() {
+			if err := config.SendEventRescheduledEmail(b.UserEmail, b.BookingID, b.EventName, oldLabel, newLabel, respondBy); err != nil {
+				fmt.Printf("ERROR: Failed to send reschedule email to %s: %v\n", b.UserEmail, err)
+			}
+		})
+	}
+
+	return change, nil
+}
+
+// RespondToReschedule records a booker's choice for a rescheduled event.
+// Declining cancels the booking and queues a full refund.
+func RespondToReschedule(bookingID string, userID string, phone string, accept bool) (*models.EventChangeBooking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lookup := bson.M{"booking_id": bookingID}
+	if objID, err := primitive.ObjectIDFromHex(bookingID); err == nil {
+		lookup = bson.M{"$or": []bson.M{{"_id": objID}, {"booking_id": bookingID}}}
+	}
+	var b models.Booking
+	if err := config.EventBookingsCol.FindOne(ctx, lookup).Decode(&b); err != nil {
+		return nil, errors.New("booking not found")
+	}
+	if (userID == "" || userID != b.UserID) && (phone == "" || phone != b.UserPhone) {
+		return nil, errors.New("access denied: you do not own this booking")
+	}
+
+	var rec models.EventChangeBooking
+	if err := config.EventChangeBookingsCol.FindOne(ctx, bson.M{
+		"booking_id": b.ID,
+		"response":   "pending",
+	}, options.FindOne().SetSort(bson.M{"created_at": -1})).Decode(&rec); err != nil {
+		return nil, errors.New("no pending reschedule for this booking")
+	}
+
+	var change models.EventChange
+	if err := config.EventChangesCol.FindOne(ctx, bson.M{"_id": rec.ChangeID}).Decode(&change); err != nil {
+		return nil, errors.New("reschedule not found")
+	}
+	if time.Now().After(change.RefundWindowEnds) {
+		return nil, errors.New("the refund window for this reschedule has closed")
+	}
+
+	update := bson.M{"updated_at": time.Now()}
+	if accept {
+		rec.Response = "accepted"
+	} else {
+		rec.Response = "refund_requested"
+		rec.RefundStatus = "pending"
+		update["refund_status"] = rec.RefundStatus
+	}
+	update["response"] = rec.Response
+
+	res, err := config.EventChangeBookingsCol.UpdateOne(ctx, bson.M{"_id": rec.ID, "response": "pending"}, bson.M{"$set": update})
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, errors.New("response already recorded")
+	}
+
+	if !accept {
+		if err := cancelBooking(ctx, b.ID, "event_rescheduled"); err != nil {
+			fmt.Printf("ERROR: Failed to cancel booking %s: %v\n", b.BookingID, err)
+		}
+		_ = couponsvc.Release(b.ID.Hex(), "event_rescheduled")
+		_ = walletsvc.Release(b.ID.Hex(), "event_rescheduled")
+		_ = offersvc.Release(b.ID.Hex(), "event_rescheduled")
+		changeID := change.ID
+		queueRefunds(changeID)
+	}
+	return &rec, nil
+}
+
+func refundBackoff(attempts int) time.Duration {
+	return time.Duration(attempts*attempts) * 5 * time.Minute
+}
+
+// dueRefunds matches refunds that are ready to be attempted: new ones,
+// failed ones whose backoff has elapsed and claims left behind by a run that
+// never finished.
+func dueRefunds(now time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"refund_status": "pending"},
+		{
+			"refund_status":   "failed",
+			"attempts":        bson.M{"$lt": maxRefundAttempts},
+			"next_attempt_at": bson.M{"$lte": now},
+		},
+		{
+			"refund_status": "processing",
+			"claimed_at":    bson.M{"$lt": now.Add(-claimTimeout)},
+		},
+	}}
+}
+
+// queueRefunds starts the refunds of a change in the background. Without
+// a worker pool, as on Vercel, they are left to the event-refunds job so
+// the request that cancelled the event is not held up sending them.
+func queueRefunds(changeID primitive.ObjectID) {
+	if !worker.Running() {
+		return
+	}
+	worker.Submit(func() { processRefunds(changeID) })
+}
+
+// processRefunds works through every refund of a change that is due,
+// then refreshes the change's progress counters. Each refund is claimed
+// before it is sent so that concurrent runs never pay the same booking
+// twice.
+func processRefunds(changeID primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var change models.EventChange
+	if err := config.EventChangesCol.FindOne(ctx, bson.M{"_id": changeID}).Decode(&change); err != nil {
+		fmt.Printf("ERROR: Event change %s not found: %v\n", changeID.Hex(), err)
+		return
+	}
+
+	for {
+		now := time.Now()
+		filter := dueRefunds(now)
+		filter["change_id"] = changeID
+
+		var rec models.EventChangeBooking
+		err := config.EventChangeBookingsCol.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{
+			"refund_status": "processing",
+			"claimed_at":    now,
+			"updated_at":    now,
+		}}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&rec)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			fmt.Printf("ERROR: Failed to claim a refund for change %s: %v\n", changeID.Hex(), err)
+			break
+		}
+		refundOne(ctx, &change, &rec)
+	}
+	refreshProgress(ctx, &change)
+}
+
+// refundOne sends the refund for a claimed record and records the outcome.
+// When the last attempt fails the booker and the admins are told so that it
+// can be paid by hand.
+func refundOne(ctx context.Context, change *models.EventChange, rec *models.EventChangeBooking) {
+	now := time.Now()
+	set := bson.M{"updated_at": now}
+
+	fail := func(err error) {
+		rec.Attempts++
+		rec.RefundStatus = "failed"
+		set["attempts"] = rec.Attempts
+		set["last_error"] = err.Error()
+		set["next_attempt_at"] = now.Add(refundBackoff(rec.Attempts))
+		fmt.Printf("ERROR: Refund attempt %d failed for booking %s: %v\n", rec.Attempts, rec.BookingRef, err)
+	}
+
+	switch {
+	case rec.PaymentID == "" || rec.Amount < 1.0:
+		// Free or fully discounted bookings have nothing to send back
+		rec.RefundStatus = "skipped"
+	default:
+		// Never pay out for a booking that is still live
+		if err := cancelBooking(ctx, rec.BookingID, cancelReason(change)); err != nil {
+			fail(err)
+			break
+		}
+		notes := map[string]string{
+			"booking_id":   rec.BookingRef,
+			"booking_type": "events",
+			"reason":       "event_" + change.Type,
+			"change_id":    change.ID.Hex(),
+		}
+		rid, err := paymentsvc.CreateRefund(rec.PaymentID, rec.Amount, notes)
+		if err != nil {
+			fail(err)
+		} else {
+			rec.RefundStatus = "refunded"
+			rec.RefundID = rid
+			set["refund_id"] = rid
+		}
+	}
+	set["refund_status"] = rec.RefundStatus
+
+	if _, err := config.EventChangeBookingsCol.UpdateOne(ctx, bson.M{"_id": rec.ID, "refund_status": "processing"}, bson.M{"$set": set}); err != nil {
+		fmt.Printf("ERROR: Failed to record refund progress for booking %s: %v\n", rec.BookingRef, err)
+	}
+
+	var b models.Booking
+	if err := config.EventBookingsCol.FindOne(ctx, bson.M{"_id": rec.BookingID}).Decode(&b); err != nil {
+		fmt.Printf("ERROR: Booking %s not found after refund: %v\n", rec.BookingRef, err)
+		return
+	}
+
+	if rec.RefundStatus == "failed" {
+		if rec.Attempts < maxRefundAttempts {
+			return
+		}
+		amount := rec.Amount
+		lastErr, _ := set["last_error"].(string)
+		worker.Submit(func() {
+			if err := config.SendRefundFailedAlert(rec.BookingRef, rec.PaymentID, amount, lastErr); err != nil {
+				fmt.Printf("ERROR: Failed to send refund alert for booking %s: %v\n", rec.BookingRef, err)
+			}
+			if b.UserEmail == "" {
+				return
+			}
+			if err := config.SendRefundDelayedEmail(b.UserEmail, b.BookingID, b.EventName, amount); err != nil {
+				fmt.Printf("ERROR: Failed to send refund delay email to %s: %v\n", b.UserEmail, err)
+			}
+		})
+		return
+	}
+
+	referralsvc.ClawBack(rec.BookingID.Hex(), cancelReason(change))
+
+	refundAmount := rec.Amount
+	if rec.RefundStatus == "skipped" {
+		refundAmount = 0
+	}
+	if _, err := config.EventBookingsCol.UpdateOne(ctx, bson.M{"_id": rec.BookingID}, bson.M{"$set": bson.M{
+		"refund_id":     rec.RefundID,
+		"refund_amount": refundAmount,
+		"refund_date":   now,
+	}}); err != nil {
+		fmt.Printf("ERROR: Failed to record refund on booking %s: %v\n", rec.BookingRef, err)
+	}
+
+	if b.UserEmail == "" {
+		return
+	}
+	dateStr := fmt.Sprintf("%s (%s)", change.OldDate.Format("2006-01-02"), change.OldTime)
+	totalStr := fmt.Sprintf("%.2f", refundAmount)
+	worker.Submit(func() {
+		if err := config.SendCancellationEmail(b.UserEmail, b.BookingID, "events", b.EventName, dateStr, totalStr); err != nil {
+			fmt.Printf("ERROR: Failed to send cancellation email to %s: %v\n", b.UserEmail, err)
+		}
+	})
+}
+
+func refreshProgress(ctx context.Context, change *models.EventChange) {
+	col := config.EventChangeBookingsCol
+	refunded, _ := col.CountDocuments(ctx, bson.M{
+		"change_id":     change.ID,
+		"refund_status": bson.M{"$in": []string{"refunded", "skipped"}},
+	})
+	failed, _ := col.CountDocuments(ctx, bson.M{
+		"change_id":     change.ID,
+		"refund_status": "failed",
+		"attempts":      bson.M{"$gte": maxRefundAttempts},
+	})
+	outstanding, _ := col.CountDocuments(ctx, bson.M{
+		"change_id":     change.ID,
+		"refund_status": bson.M{"$in": []string{"pending", "processing", "failed"}},
+		"attempts":      bson.M{"$lt": maxRefundAttempts},
+	})
+
+	set := bson.M{
+		"refunded":   int(refunded),
+		"failed":     int(failed),
+		"updated_at": time.Now(),
+	}
+	if change.Type == "cancel" && outstanding == 0 {
+		if failed > 0 {
+			set["status"] = "completed_with_errors"
+		} else {
+			set["status"] = "completed"
+		}
+	}
+	_, _ = config.EventChangesCol.UpdateOne(ctx, bson.M{"_id": change.ID}, bson.M{"$set": set})
+}
+
+// RetryFailedRefunds re-runs every change with a refund that is due: failed
+// ones whose backoff has elapsed, and pending or claimed ones whose run was
+// lost to a restart.
+func RetryFailedRefunds() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ids, err := config.EventChangeBookingsCol.Distinct(ctx, "change_id", dueRefunds(time.Now()))
+	if err != nil {
+		fmt.Printf("ERROR: Failed to look up refunds to retry: %v\n", err)
+		return
+	}
+	for _, id := range ids {
+		if changeID, ok := id.(primitive.ObjectID); ok {
+			processRefunds(changeID)
+		}
+	}
+}
+
+func ListChanges(eventID, organizerID string) ([]models.EventChange, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := config.EventChangesCol.Find(ctx, bson.M{"event_id": e.ID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	changes := []models.EventChange{}
+	if err := cursor.All(ctx, &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func GetChangeBookings(eventID, organizerID, changeID string) ([]models.EventChangeBooking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	e, err := loadOwnedEvent(ctx, eventID, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	cID, err := primitive.ObjectIDFromHex(changeID)
+	if err != nil {
+		return nil, errors.New("invalid change id")
+	}
+	if n, _ := config.EventChangesCol.CountDocuments(ctx, bson.M{"_id": cID, "event_id": e.ID}); n == 0 {
+		return nil, errors.New("change not found")
+	}
+
+	cursor, err := config.EventChangeBookingsCol.Find(ctx, bson.M{"change_id": cID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	recs := []models.EventChangeBooking{}
+	if err := cursor.All(ctx, &recs); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
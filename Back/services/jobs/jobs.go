@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"time"
+
+	couponsvc "ticpin-backend/services/coupon"
+	eventsvc "ticpin-backend/services/event"
+	"ticpin-backend/services/eventchange"
+	offersvc "ticpin-backend/services/offer"
+	passservice "ticpin-backend/services/pass"
+	referralsvc "ticpin-backend/services/referral"
+	trashsvc "ticpin-backend/services/trash"
+	walletsvc "ticpin-backend/services/wallet"
+)
+
+// Job is background work that has to run every so often. A long-lived
+// server runs each job on its own ticker. On Vercel nothing outlives a
+// request, so the crons in vercel.json call the cron route instead.
+type Job struct {
+	Name  string
+	Every time.Duration
+	Run   func()
+}
+
+var All = []Job{
+	{"event-refunds", 10 * time.Minute, eventchange.RetryFailedRefunds},
+	{"booking-refunds", 10 * time.Minute, offersvc.RetryRefunds},
+	{"pass-renewals", time.Hour, passservice.ProcessRenewals},
+	{"coupon-reservations", 5 * time.Minute, couponsvc.ExpireReservations},
+	{"offer-reservations", 5 * time.Minute, offersvc.ExpireReservations},
+	{"referrals", 10 * time.Minute, referralsvc.ProcessPending},
+	{"wallet-expiry", 10 * time.Minute, walletsvc.ProcessExpiry},
+	{"trash-retention", 6 * time.Hour, trashsvc.PurgeExpired},
+	{"event-prices", 15 * time.Minute, eventsvc.RefreshScheduledPrices},
+}
+
+// Find returns the job with this name.
+func Find(name string) (Job, bool) {
+	for _, j := range All {
+		if j.Name == name {
+			return j, true
+		}
+	}
+	return Job{}, false
+}
+
+// Start runs every job on its ticker for as long as the process lives.
+func Start() {
+	for _, j := range All {
+		go func(j Job) {
+			ticker := time.NewTicker(j.Every)
+			defer ticker.Stop()
+
+			for range ticker.C {
+				j.Run()
+			}
+		}(j)
+	}
+}
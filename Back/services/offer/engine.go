@@ -0,0 +1,463 @@
+package offer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	"ticpin-backend/services/redemption"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func ledger() *redemption.Ledger {
+	return &redemption.Ledger{Col: config.OfferRedemptionsCol}
+}
+
+// reservationTTL matches the payment link expiry; a reservation still
+// unpaid after it is released.
+const reservationTTL = 30 * time.Minute
+
+// Daily quotas reset at midnight IST.
+var ist = time.FixedZone("IST", 5*3600+30*60)
+
+var ErrQuotaReached = errors.New("offer's daily quota is used up")
+
+// Checkout describes the order an offer is applied to. PaymentMethod and
+// CardBIN are the client's hint of how it will pay: offers with a payment
+// condition are only auto-applied when the hint matches, and every payment
+// condition is checked again against the gateway webhook.
+type Checkout struct {
+	Category      string // "event", "play", "dining"
+	EntityID      string
+	OrderAmount   float64
+	UserID        string
+	OrderID       string
+	PaymentMethod string
+	CardBIN       string
+	AutoApply     bool // consider auto-apply offers when no offer was picked
+}
+
+// PaymentMethod is how an order was actually paid, as reported by the
+// gateway.
+type PaymentMethod struct {
+	Method      string
+	CardBIN     string
+	CardNetwork string
+	Issuer      string
+}
+
+// Applied is the offer put on an order along with the use held for it.
+type Applied struct {
+	Offer          models.EventOffer
+	DiscountAmount float64
+	Redemption     *models.OfferRedemption
+}
+
+func normalizeMethod(m string) string {
+	m = strings.ToLower(strings.TrimSpace(m))
+	switch {
+	case strings.Contains(m, "card"):
+		return "card"
+	case m == "net_banking" || m == "nb":
+		return "netbanking"
+	case m == "app":
+		return "wallet"
+	}
+	return m
+}
+
+func containsFold(list []string, v string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// binMatches accepts BIN ranges given as 6 or 8 digit prefixes.
+func binMatches(bins []string, bin string) bool {
+	if bin == "" {
+		return false
+	}
+	for _, b := range bins {
+		if b = strings.TrimSpace(b); b != "" && strings.HasPrefix(bin, b) {
+			return true
+		}
+	}
+	return false
+}
+
+func paymentMatches(cond *models.OfferPaymentCondition, pm PaymentMethod) bool {
+	if cond == nil {
+		return true
+	}
+	if len(cond.Methods) > 0 && !containsFold(cond.Methods, normalizeMethod(pm.Method)) {
+		return false
+	}
+	if len(cond.CardBINs) > 0 && !binMatches(cond.CardBINs, pm.CardBIN) {
+		return false
+	}
+	if len(cond.CardNetworks) > 0 && !containsFold(cond.CardNetworks, pm.CardNetwork) {
+		return false
+	}
+	if len(cond.Issuers) > 0 && !containsFold(cond.Issuers, pm.Issuer) {
+		return false
+	}
+	return true
+}
+
+// hintMatches checks the parts of a payment condition the client can tell
+// us before paying. Card network and issuer are only known from the webhook.
+func hintMatches(cond *models.OfferPaymentCondition, co Checkout) bool {
+	if cond == nil {
+		return true
+	}
+	if co.PaymentMethod == "" {
+		return false
+	}
+	if len(cond.Methods) > 0 && !containsFold(cond.Methods, normalizeMethod(co.PaymentMethod)) {
+		return false
+	}
+	if len(cond.CardBINs) > 0 && !binMatches(cond.CardBINs, co.CardBIN) {
+		return false
+	}
+	return true
+}
+
+func describePayment(pm PaymentMethod) string {
+	parts := []string{normalizeMethod(pm.Method)}
+	for _, p := range []string{pm.CardNetwork, pm.Issuer, pm.CardBIN} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func checkOffer(o *models.EventOffer, co Checkout, now time.Time) error {
+	if !o.IsActive {
+		return errors.New("offer is not active")
+	}
+	if !o.StartsAt.IsZero() && now.Before(o.StartsAt) {
+		return errors.New("offer has not started yet")
+	}
+	if now.After(o.ValidUntil) {
+		return errors.New("offer has expired")
+	}
+	if o.AppliesTo != "" && co.Category != "" && o.AppliesTo != co.Category {
+		return errors.New("offer does not apply to this category")
+	}
+
+	entityObjID, err := primitive.ObjectIDFromHex(co.EntityID)
+	if err != nil {
+		return errors.New("invalid entity ID")
+	}
+	applies := false
+	for _, id := range o.EntityIDs {
+		if id == entityObjID {
+			applies = true
+			break
+		}
+	}
+	if !applies {
+		return errors.New("offer does not apply to this entity")
+	}
+
+	if o.MinOrderAmount > 0 && co.OrderAmount < o.MinOrderAmount {
+		return fmt.Errorf("offer needs a minimum order of ₹%.2f", o.MinOrderAmount)
+	}
+	return nil
+}
+
+func discountFor(o *models.EventOffer, orderAmount float64) float64 {
+	var discount float64
+	if o.DiscountType == "percent" {
+		discount = orderAmount * (o.DiscountValue / 100)
+	} else {
+		discount = o.DiscountValue
+	}
+	if o.MaxDiscount > 0 && discount > o.MaxDiscount {
+		discount = o.MaxDiscount
+	}
+	if discount > orderAmount {
+		discount = orderAmount
+	}
+	return math.Round(discount*100) / 100
+}
+
+func quotaDay(now time.Time) string {
+	return now.In(ist).Format("2006-01-02")
+}
+
+// activeFilter matches the uses that count against an offer's quota for a
+// day: committed ones and reservations that have not expired.
+func activeFilter(offerID primitive.ObjectID, day string, now time.Time) bson.M {
+	return bson.M{
+		"offer_id": offerID,
+		"day":      day,
+		"$or": []bson.M{
+			{"status": "committed"},
+			{"status": "reserved", "expires_at": bson.M{"$gt": now}},
+		},
+	}
+}
+
+func quotaLeft(ctx context.Context, o *models.EventOffer, now time.Time) (bool, error) {
+	if o.DailyQuota <= 0 {
+		return true, nil
+	}
+	used, err := config.OfferRedemptionsCol.CountDocuments(ctx, activeFilter(o.ID, quotaDay(now), now))
+	if err != nil {
+		return false, err
+	}
+	return used < int64(o.DailyQuota), nil
+}
+
+// ranked returns the offers that can go on the order, biggest discount
+// first. An offer the user picked is the only candidate and its rejection
+// is returned as an error.
+func ranked(ctx context.Context, co Checkout, offerID string, now time.Time) ([]ValidationResult, error) {
+	if offerID != "" {
+		objID, err := primitive.ObjectIDFromHex(offerID)
+		if err != nil {
+			return nil, errors.New("invalid offer ID")
+		}
+		var o models.EventOffer
+		if err := config.OffersCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&o); err != nil {
+			return nil, errors.New("offer not found")
+		}
+		if err := checkOffer(&o, co, now); err != nil {
+			return nil, err
+		}
+		ok, err := quotaLeft(ctx, &o, now)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrQuotaReached
+		}
+		return []ValidationResult{{Offer: o, DiscountAmount: discountFor(&o, co.OrderAmount)}}, nil
+	}
+
+	if !co.AutoApply {
+		return nil, nil
+	}
+	entityObjID, err := primitive.ObjectIDFromHex(co.EntityID)
+	if err != nil {
+		return nil, errors.New("invalid entity ID")
+	}
+	cursor, err := config.OffersCol.Find(ctx, bson.M{
+		"applies_to":  co.Category,
+		"auto_apply":  true,
+		"is_active":   true,
+		"valid_until": bson.M{"$gt": now},
+		"entity_ids":  entityObjID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var offers []models.EventOffer
+	if err := cursor.All(ctx, &offers); err != nil {
+		return nil, err
+	}
+
+	list := []ValidationResult{}
+	for i := range offers {
+		o := &offers[i]
+		if checkOffer(o, co, now) != nil || !hintMatches(o.Payment, co) {
+			continue
+		}
+		discount := discountFor(o, co.OrderAmount)
+		if discount <= 0 {
+			continue
+		}
+		if ok, err := quotaLeft(ctx, o, now); err != nil || !ok {
+			continue
+		}
+		list = append(list, ValidationResult{Offer: *o, DiscountAmount: discount})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].DiscountAmount > list[j].DiscountAmount })
+	return list, nil
+}
+
+// Best previews the offer Apply would put on the order, or nil if none
+// applies.
+func Best(co Checkout, offerID string) (*ValidationResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	list, err := ranked(ctx, co, offerID, time.Now())
+	if err != nil || len(list) == 0 {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// reserve holds one use of an offer for an order. The quota is re-checked
+// after the write so that concurrent checkouts cannot go past it.
+func reserve(ctx context.Context, o *models.EventOffer, co Checkout, discount float64, auto bool, now time.Time) (*models.OfferRedemption, error) {
+	r := &models.OfferRedemption{
+		ID:          primitive.NewObjectID(),
+		OfferID:     o.ID,
+		Category:    co.Category,
+		EntityID:    co.EntityID,
+		UserID:      co.UserID,
+		OrderID:     co.OrderID,
+		Day:         quotaDay(now),
+		OrderAmount: co.OrderAmount,
+		Discount:    discount,
+		AutoApplied: auto,
+		Payment:     o.Payment,
+		Status:      "reserved",
+		ExpiresAt:   now.Add(reservationTTL),
+		CreatedAt:   now,
+	}
+	if _, err := config.OfferRedemptionsCol.InsertOne(ctx, r); err != nil {
+		return nil, err
+	}
+	if o.DailyQuota > 0 {
+		ahead, err := ledger().Ahead(ctx, activeFilter(o.ID, r.Day, now), r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if ahead > int64(o.DailyQuota) {
+			_ = ledger().ReleaseReservation(r.ID, "quota_reached")
+			return nil, ErrQuotaReached
+		}
+	}
+	return r, nil
+}
+
+// Apply puts an offer on the order and holds its use until payment. With
+// no offerID it picks the best eligible auto-apply offer, returning nil if
+// there is none.
+func Apply(co Checkout, offerID string) (*Applied, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	list, err := ranked(ctx, co, offerID, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range list {
+		r, err := reserve(ctx, &v.Offer, co, v.DiscountAmount, offerID == "", now)
+		if err != nil {
+			if offerID != "" {
+				return nil, err
+			}
+			// Lost the race for this one; try the next best
+			continue
+		}
+		return &Applied{Offer: v.Offer, DiscountAmount: v.DiscountAmount, Redemption: r}, nil
+	}
+	return nil, nil
+}
+
+// AttachBooking records the booking a reservation was made for once the
+// booking exists.
+func AttachBooking(redemptionID primitive.ObjectID, bookingID string) error {
+	return ledger().AttachBooking(redemptionID, bookingID)
+}
+
+// Commit marks the offer use for a booking or payment order as used.
+func Commit(ref string) error {
+	return ledger().Commit(ref)
+}
+
+// Release frees the offer use held by a booking or payment order, e.g.
+// when the payment fails or the booking is cancelled.
+func Release(ref, reason string) error {
+	return ledger().Release(ref, reason)
+}
+
+// ReleaseReservation frees a reservation whose booking could not be made.
+func ReleaseReservation(redemptionID primitive.ObjectID, reason string) error {
+	return ledger().ReleaseReservation(redemptionID, reason)
+}
+
+// VerifyPayment checks how an order was paid against the payment condition
+// its offer had at checkout. When the condition is not met the use is
+// marked "reversed" and false is returned, also for webhook retries after
+// that; orders without a conditional offer always pass.
+func VerifyPayment(ref string, pm PaymentMethod) (bool, error) {
+	if ref == "" {
+		return true, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var r models.OfferRedemption
+	err := config.OfferRedemptionsCol.FindOne(ctx, bson.M{"$and": []bson.M{
+		redemption.RefFilter(ref),
+		{"payment": bson.M{"$ne": nil}},
+		{"$or": []bson.M{
+			{"status": bson.M{"$in": []string{"reserved", "committed", "reversed"}}},
+			{"status": "released", "release_reason": "expired"},
+		}},
+	}}).Decode(&r)
+	if err != nil {
+		return true, nil
+	}
+	if r.Status == "reversed" {
+		return false, nil
+	}
+
+	set := bson.M{"payment_method": describePayment(pm)}
+	ok := paymentMatches(r.Payment, pm)
+	if !ok {
+		now := time.Now()
+		set["status"] = "reversed"
+		set["release_reason"] = "payment_condition_not_met"
+		set["released_at"] = now
+	}
+	if _, err := config.OfferRedemptionsCol.UpdateOne(ctx, bson.M{"_id": r.ID}, bson.M{"$set": set}); err != nil {
+		return true, err
+	}
+	return ok, nil
+}
+
+// ReleaseExpired releases reservations whose payment never arrived.
+func ReleaseExpired() (int64, error) {
+	return ledger().ReleaseExpired()
+}
+
+// ExpireReservations releases offer reservations whose checkout was
+// abandoned.
+func ExpireReservations() {
+	if n, err := ReleaseExpired(); err != nil {
+		fmt.Printf("DEBUG: Failed to release expired offer reservations: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("DEBUG: Released %d expired offer reservations\n", n)
+	}
+}
+
+// ListRedemptions returns an offer's redemptions, newest first.
+func ListRedemptions(offerID string) ([]models.OfferRedemption, error) {
+	objID, err := primitive.ObjectIDFromHex(offerID)
+	if err != nil {
+		return nil, errors.New("invalid offer id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := config.OfferRedemptionsCol.Find(ctx, bson.M{"offer_id": objID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	list := []models.OfferRedemption{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
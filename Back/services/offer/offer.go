@@ -2,7 +2,6 @@ package offer
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"time"
 
@@ -28,64 +27,13 @@ func Create(o *models.EventOffer) error {
 	return err
 }
 
-func ValidateOffer(offerID string, entityID string, orderAmount float64) (*ValidationResult, error) {
-	col := config.GetDB().Collection("offers")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	objID, err := primitive.ObjectIDFromHex(offerID)
-	if err != nil {
-		return nil, errors.New("invalid offer ID")
-	}
-
-	var offer models.EventOffer
-	err = col.FindOne(ctx, bson.M{"_id": objID}).Decode(&offer)
-	if err != nil {
-		return nil, errors.New("offer not found")
-	}
-
-	if !offer.IsActive {
-		return nil, errors.New("offer is not active")
-	}
-
-	if time.Now().After(offer.ValidUntil) {
-		return nil, errors.New("offer has expired")
+// startedFilter matches offers without a start date or whose start date
+// has passed.
+func startedFilter() []bson.M {
+	return []bson.M{
+		{"starts_at": bson.M{"$exists": false}},
+		{"starts_at": bson.M{"$lte": time.Now()}},
 	}
-
-	entityObjID, err := primitive.ObjectIDFromHex(entityID)
-	if err != nil {
-		return nil, errors.New("invalid entity ID")
-	}
-
-	applies := false
-	if offer.EntityIDs != nil {
-		for _, id := range offer.EntityIDs {
-			if id == entityObjID {
-				applies = true
-				break
-			}
-		}
-	}
-
-	if !applies {
-		return nil, errors.New("offer does not apply to this entity")
-	}
-
-	var discountAmount float64
-	if offer.DiscountType == "percent" {
-		discountAmount = orderAmount * (offer.DiscountValue / 100)
-	} else {
-		discountAmount = offer.DiscountValue
-	}
-
-	if discountAmount > orderAmount {
-		discountAmount = orderAmount
-	}
-
-	return &ValidationResult{
-		Offer:          offer,
-		DiscountAmount: discountAmount,
-	}, nil
 }
 
 func GetAll() ([]models.EventOffer, error) {
@@ -118,6 +66,7 @@ func GetForEntity(entityType string, entityID string) ([]models.EventOffer, erro
 		"is_active":   true,
 		"valid_until": bson.M{"$gt": time.Now()},
 		"entity_ids":  bson.M{"$elemMatch": bson.M{"$eq": objID}},
+		"$or":         startedFilter(),
 	}
 
 	fmt.Printf("DEBUG: GetForEntity filter - entityType: %s, entityID: %s\n", entityType, entityID)
@@ -148,6 +97,7 @@ func GetByCategory(category string) ([]models.EventOffer, error) {
 		"applies_to":  category,
 		"is_active":   true,
 		"valid_until": bson.M{"$gt": time.Now()},
+		"$or":         startedFilter(),
 	}
 	cursor, err := col.Find(ctx, filter)
 	if err != nil {
@@ -171,15 +121,21 @@ func Update(id string, o *models.EventOffer) error {
 	defer cancel()
 
 	updateFields := bson.M{
-		"title":          o.Title,
-		"description":    o.Description,
-		"discount_type":  o.DiscountType,
-		"discount_value": o.DiscountValue,
-		"applies_to":     o.AppliesTo,
-		"entity_ids":     o.EntityIDs,
-		"valid_until":    o.ValidUntil,
-		"is_active":      o.IsActive,
-		"updated_at":     time.Now(),
+		"title":            o.Title,
+		"description":      o.Description,
+		"discount_type":    o.DiscountType,
+		"discount_value":   o.DiscountValue,
+		"applies_to":       o.AppliesTo,
+		"entity_ids":       o.EntityIDs,
+		"starts_at":        o.StartsAt,
+		"valid_until":      o.ValidUntil,
+		"auto_apply":       o.AutoApply,
+		"min_order_amount": o.MinOrderAmount,
+		"max_discount":     o.MaxDiscount,
+		"daily_quota":      o.DailyQuota,
+		"payment":          o.Payment,
+		"is_active":        o.IsActive,
+		"updated_at":       time.Now(),
 	}
 
 	if o.Image != "" {
@@ -0,0 +1,124 @@
+package offer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ticpin-backend/config"
+	paymentsvc "ticpin-backend/services/payment"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A booking reversed after payment, e.g. because its payment missed its
+// offer's payment condition, stays "refund_pending" until the gateway accepts the refund,
+// and only then fails. Refunds that keep failing are retried with backoff
+// and, after maxRefundAttempts, left for an admin with an alert.
+const maxRefundAttempts = 6
+
+// A claim older than claimTimeout was abandoned, e.g. by a restart, and is
+// picked up again.
+const claimTimeout = 15 * time.Minute
+
+func refundBackoff(attempts int) time.Duration {
+	return time.Duration(attempts*attempts) * 5 * time.Minute
+}
+
+func bookingCollections() []*mongo.Collection {
+	return []*mongo.Collection{config.EventBookingsCol, config.PlayBookingsCol, config.DiningBookingsCol}
+}
+
+// QueueRefund marks the reversed bookings matched by filter as waiting for
+// a refund of amount on paymentID, and tries it straight away.
+func QueueRefund(ctx context.Context, col *mongo.Collection, filter bson.M, paymentID string, amount float64) {
+	now := time.Now()
+	res, err := col.UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"status":          "refund_pending",
+		"refund_status":   "pending",
+		"refund_amount":   amount,
+		"refund_attempts": 0,
+		"next_refund_at":  now,
+		"refund_payment":  paymentID,
+	}})
+	if err != nil || res.MatchedCount == 0 {
+		return
+	}
+	refundDue(ctx, col, filter)
+}
+
+// refundDue claims each due refund matched by filter before calling the
+// gateway, so the webhook and the retry loop never refund twice.
+func refundDue(ctx context.Context, col *mongo.Collection, filter bson.M) {
+	for {
+		now := time.Now()
+		var b struct {
+			ID        primitive.ObjectID `bson:"_id"`
+			BookingID string             `bson:"booking_id"`
+			PaymentID string             `bson:"refund_payment"`
+			Amount    float64            `bson:"refund_amount"`
+			Attempts  int                `bson:"refund_attempts"`
+			Reason    string             `bson:"failure_reason"`
+		}
+		err := col.FindOneAndUpdate(ctx, bson.M{"$and": []bson.M{filter, {
+			"status":          "refund_pending",
+			"refund_attempts": bson.M{"$lt": maxRefundAttempts},
+			"$or": []bson.M{
+				{"refund_status": "pending", "next_refund_at": bson.M{"$lte": now}},
+				{"refund_status": "processing", "refund_claimed_at": bson.M{"$lte": now.Add(-claimTimeout)}},
+			},
+		}}}, bson.M{"$set": bson.M{"refund_status": "processing", "refund_claimed_at": now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&b)
+		if err != nil {
+			return
+		}
+
+		rid, err := paymentsvc.CreateRefund(b.PaymentID, b.Amount, map[string]string{
+			"reason":     b.Reason,
+			"payment_id": b.PaymentID,
+			"booking_id": b.BookingID,
+		})
+		if err == nil {
+			col.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": bson.M{
+				"status":        "failed",
+				"refund_status": "initiated",
+				"refund_id":     rid,
+				"refund_date":   time.Now(),
+			}})
+			continue
+		}
+
+		attempts := b.Attempts + 1
+		fmt.Printf("ERROR: Refund attempt %d failed for reversed booking %s: %v\n", attempts, b.BookingID, err)
+		set := bson.M{
+			"refund_status":   "pending",
+			"refund_attempts": attempts,
+			"refund_error":    err.Error(),
+			"next_refund_at":  now.Add(refundBackoff(attempts)),
+		}
+		if attempts >= maxRefundAttempts {
+			set["refund_status"] = "failed"
+			bookingID, paymentID, amount, lastErr := b.BookingID, b.PaymentID, b.Amount, err.Error()
+			go func() {
+				if err := config.SendRefundFailedAlert(bookingID, paymentID, amount, lastErr); err != nil {
+					fmt.Printf("ERROR: Failed to send refund alert for booking %s: %v\n", bookingID, err)
+				}
+			}()
+		}
+		col.UpdateOne(ctx, bson.M{"_id": b.ID}, bson.M{"$set": set})
+	}
+}
+
+// RetryRefunds retries every reversed booking refund whose backoff has
+// elapsed.
+func RetryRefunds() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for _, col := range bookingCollections() {
+		refundDue(ctx, col, bson.M{})
+	}
+}
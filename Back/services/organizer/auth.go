@@ -15,6 +15,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrDeleted is returned when signing in to an account an admin deleted.
+var ErrDeleted = errors.New("this account has been deleted")
+
 func LoginOrCreate(email, password string) (*models.Organizer, bool, error) {
 	collection := config.GetDB().Collection("organizers")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -46,6 +49,9 @@ func LoginOrCreate(email, password string) (*models.Organizer, bool, error) {
 		_ = verifysvc.CreateOrganizerVerification(org.ID)
 		return &org, true, nil
 	}
+	if org.DeletedAt != nil {
+		return nil, false, ErrDeleted
+	}
 	if err := bcrypt.CompareHashAndPassword([]byte(org.Password), []byte(password)); err != nil {
 		return nil, false, errors.New("invalid password")
 	}
@@ -62,6 +68,9 @@ func Login(email, password string) (*models.Organizer, error) {
 		var org models.Organizer
 		err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&org)
 		if err == nil {
+			if org.DeletedAt != nil {
+				return nil, ErrDeleted
+			}
 			return &org, nil
 		}
 		hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -85,6 +94,9 @@ func Login(email, password string) (*models.Organizer, error) {
 	if err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&org); err != nil {
 		return nil, errors.New("user_not_found")
 	}
+	if org.DeletedAt != nil {
+		return nil, ErrDeleted
+	}
 	if err := bcrypt.CompareHashAndPassword([]byte(org.Password), []byte(password)); err != nil {
 		return nil, errors.New("invalid_password")
 	}
@@ -156,6 +168,10 @@ func GoogleAuth(email string) (*models.Organizer, error) {
 		return &org, nil
 	}
 
+	if org.DeletedAt != nil {
+		return nil, ErrDeleted
+	}
+
 	if !org.IsVerified {
 		_, _ = collection.UpdateOne(ctx, bson.M{"_id": org.ID}, bson.M{"$set": bson.M{"isVerified": true}})
 		org.IsVerified = true
@@ -0,0 +1,151 @@
+package organizer
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxBookingsListed = 500
+
+var (
+	ErrBookingNotFound = errors.New("booking not found")
+	ErrListingDenied   = errors.New("you do not have access to this listing")
+)
+
+type bookingSource struct {
+	col          *mongo.Collection
+	listingField string
+}
+
+func bookingSources() map[string]bookingSource {
+	return map[string]bookingSource{
+		"events": {config.EventBookingsCol, "event_id"},
+		"play":   {config.PlayBookingsCol, "play_id"},
+		"dining": {config.DiningBookingsCol, "dining_id"},
+	}
+}
+
+func scopeFilter(filter bson.M, field, listingID string, scope []string) error {
+	if listingID != "" {
+		if !InScope(scope, listingID) {
+			return ErrListingDenied
+		}
+		objID, err := primitive.ObjectIDFromHex(listingID)
+		if err != nil {
+			return errors.New("invalid listing id")
+		}
+		filter[field] = objID
+		return nil
+	}
+	if len(scope) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(scope))
+		for _, id := range scope {
+			if objID, err := primitive.ObjectIDFromHex(id); err == nil {
+				ids = append(ids, objID)
+			}
+		}
+		filter[field] = bson.M{"$in": ids}
+	}
+	return nil
+}
+
+// ListBookings returns the latest bookings of one vertical for the
+// organizer, optionally for one listing, within a member's listing scope.
+func ListBookings(ownerID, vertical, listingID string, scope []string) (interface{}, error) {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return nil, errors.New("invalid organizer id")
+	}
+	src, ok := bookingSources()[vertical]
+	if !ok {
+		return nil, errors.New("type must be events, play or dining")
+	}
+	filter := bson.M{"organizer_id": ownerObjID}
+	if err := scopeFilter(filter, src.listingField, listingID, scope); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := src.col.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "booked_at", Value: -1}}).
+		SetLimit(maxBookingsListed))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	switch vertical {
+	case "events":
+		list := []models.Booking{}
+		err = cursor.All(ctx, &list)
+		return list, err
+	case "play":
+		list := []models.PlayBooking{}
+		err = cursor.All(ctx, &list)
+		return list, err
+	default:
+		list := []models.DiningBooking{}
+		err = cursor.All(ctx, &list)
+		return list, err
+	}
+}
+
+// CheckIn marks a booking as used at the gate. bookingID is the booking
+// code shown on the ticket. Each booking can be checked in once.
+func CheckIn(ownerID, bookingID string, scope []string, by string) (string, bson.M, error) {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return "", nil, errors.New("invalid organizer id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for vertical, src := range bookingSources() {
+		var b bson.M
+		err := src.col.FindOne(ctx, bson.M{"booking_id": bookingID, "organizer_id": ownerObjID}).Decode(&b)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		listingID, _ := b[src.listingField].(primitive.ObjectID)
+		if !InScope(scope, listingID.Hex()) {
+			return "", nil, ErrListingDenied
+		}
+		if status, _ := b["status"].(string); status != "booked" {
+			return "", nil, errors.New("booking is " + status + ", it cannot be checked in")
+		}
+		if at, ok := b["checked_in_at"].(primitive.DateTime); ok {
+			return "", nil, errors.New("already checked in at " + at.Time().Format(time.RFC3339))
+		}
+
+		now := time.Now()
+		res, err := src.col.UpdateOne(ctx,
+			bson.M{"_id": b["_id"], "checked_in_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"checked_in_at": now, "checked_in_by": by}},
+		)
+		if err != nil {
+			return "", nil, err
+		}
+		if res.ModifiedCount == 0 {
+			return "", nil, errors.New("already checked in")
+		}
+		b["checked_in_at"] = now
+		b["checked_in_by"] = by
+		return vertical, b, nil
+	}
+	return "", nil, ErrBookingNotFound
+}
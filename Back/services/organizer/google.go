@@ -0,0 +1,233 @@
+package organizer
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultGoogleJWKSURL = "https://www.googleapis.com/oauth2/v3/certs"
+
+var googleIssuers = []string{"accounts.google.com", "https://accounts.google.com"}
+
+// GoogleIdentity is the verified identity from a Google ID token.
+type GoogleIdentity struct {
+	Subject string
+	Email   string
+	Name    string
+	Picture string
+}
+
+type googleClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // bool, or "true" in older tokens
+	Name          string      `json:"name"`
+	Picture       string      `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// jwksCache holds Google's signing keys. They are refetched when the
+// Cache-Control max-age runs out, or early when a token names an unknown
+// key, at most once a minute.
+type jwksCache struct {
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	expiresAt time.Time
+	fetchedAt time.Time
+}
+
+var googleKeys = &jwksCache{}
+
+var maxAgeRe = regexp.MustCompile(`max-age=(\d+)`)
+
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+func googleJWKSURL() string {
+	if u := os.Getenv("GOOGLE_JWKS_URL"); u != "" {
+		return u
+	}
+	return defaultGoogleJWKSURL
+}
+
+func parseRSAKey(n, e string) (*rsa.PublicKey, error) {
+	nb, err := base64.RawURLEncoding.DecodeString(n)
+	if err != nil {
+		return nil, err
+	}
+	eb, err := base64.RawURLEncoding.DecodeString(e)
+	if err != nil {
+		return nil, err
+	}
+	exp := 0
+	for _, b := range eb {
+		exp = exp<<8 | int(b)
+	}
+	if exp == 0 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(nb), E: exp}, nil
+}
+
+func (c *jwksCache) fetch() error {
+	resp, err := jwksClient.Get(googleJWKSURL())
+	if err != nil {
+		return fmt.Errorf("fetching google keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching google keys: status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("decoding google keys: %w", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range body.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		key, err := parseRSAKey(k.N, k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("no usable google keys")
+	}
+
+	ttl := time.Hour
+	if m := maxAgeRe.FindStringSubmatch(resp.Header.Get("Cache-Control")); m != nil {
+		if secs, err := strconv.Atoi(m[1]); err == nil && secs > 0 {
+			ttl = time.Duration(secs) * time.Second
+		}
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.expiresAt = c.fetchedAt.Add(ttl)
+	return nil
+}
+
+func (c *jwksCache) key(kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.keys == nil || now.After(c.expiresAt) {
+		if err := c.fetch(); err != nil && c.keys == nil {
+			return nil, err
+		}
+	}
+	if key, ok := c.keys[kid]; ok {
+		return key, nil
+	}
+	// Google may have rotated its keys since we last looked
+	if now.Sub(c.fetchedAt) > time.Minute {
+		if err := c.fetch(); err != nil {
+			return nil, err
+		}
+		if key, ok := c.keys[kid]; ok {
+			return key, nil
+		}
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+func googleAudiences() []string {
+	list := []string{}
+	for _, id := range strings.Split(os.Getenv("GOOGLE_CLIENT_ID"), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// VerifyGoogleIDToken checks a Google ID token's signature against Google's
+// published keys and its audience, issuer, expiry and verified email.
+// GOOGLE_CLIENT_ID lists the accepted client IDs, comma separated.
+func VerifyGoogleIDToken(idToken string) (*GoogleIdentity, error) {
+	audiences := googleAudiences()
+	if len(audiences) == 0 {
+		return nil, errors.New("google sign-in is not configured")
+	}
+	if idToken == "" {
+		return nil, errors.New("id_token required")
+	}
+
+	var claims googleClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return googleKeys.key(kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid google token: %w", err)
+	}
+
+	issuerOK := false
+	for _, iss := range googleIssuers {
+		if claims.Issuer == iss {
+			issuerOK = true
+			break
+		}
+	}
+	if !issuerOK {
+		return nil, errors.New("invalid google token: wrong issuer")
+	}
+
+	audOK := false
+	for _, aud := range claims.Audience {
+		for _, want := range audiences {
+			if aud == want {
+				audOK = true
+			}
+		}
+	}
+	if !audOK {
+		return nil, errors.New("invalid google token: wrong audience")
+	}
+
+	verified := false
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+	if claims.Email == "" || !verified {
+		return nil, errors.New("google account email is not verified")
+	}
+
+	return &GoogleIdentity{
+		Subject: claims.Subject,
+		Email:   strings.ToLower(claims.Email),
+		Name:    claims.Name,
+		Picture: claims.Picture,
+	}, nil
+}
@@ -52,6 +52,9 @@ func VerifyOTP(email, otp string) (*models.Organizer, error) {
 	if err := collection.FindOne(ctx, bson.M{"email": email}).Decode(&org); err != nil {
 		return nil, errors.New("organizer not found")
 	}
+	if org.DeletedAt != nil {
+		return nil, ErrDeleted
+	}
 	if org.OTP != otp {
 		return nil, errors.New("invalid otp")
 	}
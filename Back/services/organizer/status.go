@@ -77,7 +77,7 @@ func GetByID(id string) (*models.Organizer, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var org models.Organizer
-	if err := collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&org); err != nil {
+	if err := collection.FindOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}}).Decode(&org); err != nil {
 		return nil, err
 	}
 	return &org, nil
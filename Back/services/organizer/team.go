@@ -0,0 +1,581 @@
+package organizer
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	otpsvc "ticpin-backend/services/otp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Permissions an owner can give a team member.
+const (
+	PermViewBookings   = "bookings:view"
+	PermScanTickets    = "tickets:scan"
+	PermEditListings   = "listings:edit"
+	PermTriggerPayouts = "payouts:trigger"
+)
+
+var TeamPermissions = []string{PermViewBookings, PermScanTickets, PermEditListings, PermTriggerPayouts}
+
+const (
+	MemberInvited = "invited"
+	MemberActive  = "active"
+
+	memberOTPTTL         = 10 * time.Minute
+	memberOTPMaxAttempts = 5
+	memberOTPCooldown    = 30 * time.Second
+	memberOTPWindow      = time.Hour
+	memberOTPMaxSends    = 5
+	memberCacheTTL       = 30 * time.Second
+)
+
+var ErrMemberNotFound = errors.New("team member not found")
+
+// TeamChoice names one team an email belongs to, for members of several.
+type TeamChoice struct {
+	OrganizerID string `json:"organizer_id"`
+	Name        string `json:"name"`
+}
+
+// MultipleTeamsError is returned when a member signs in without saying
+// which of their teams they mean.
+type MultipleTeamsError struct {
+	Teams []TeamChoice
+}
+
+func (e *MultipleTeamsError) Error() string {
+	return "this email is on more than one team, choose one"
+}
+
+// MemberAccess is what a signed-in member may do, as the middleware sees it.
+type MemberAccess struct {
+	MemberID    string
+	OrganizerID string
+	Email       string
+	Permissions []string
+	ListingIDs  []string
+}
+
+type cachedAccess struct {
+	access    *MemberAccess
+	checkedAt time.Time
+}
+
+var memberCache sync.Map // member id -> cachedAccess
+
+// ForgetMember drops a member's cached access after it changes.
+func ForgetMember(memberID string) {
+	memberCache.Delete(memberID)
+}
+
+// HasTeamPermission reports whether perms includes perm.
+func HasTeamPermission(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// InScope reports whether a listing is within a member's listing
+// restriction. An empty restriction covers every listing.
+func InScope(listingIDs []string, listingID string) bool {
+	if len(listingIDs) == 0 {
+		return true
+	}
+	for _, id := range listingIDs {
+		if id == listingID {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizePermissions(perms []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		if !HasTeamPermission(TeamPermissions, p) {
+			return nil, fmt.Errorf("unknown permission %q", p)
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("at least one permission is required")
+	}
+	return out, nil
+}
+
+// ownedListings checks that every id is an event, play or dining listing
+// of the owner.
+func ownedListings(ctx context.Context, ownerID primitive.ObjectID, ids []string) ([]primitive.ObjectID, error) {
+	seen := map[primitive.ObjectID]bool{}
+	objIDs := []primitive.ObjectID{}
+	for _, id := range ids {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid listing id %q", id)
+		}
+		if !seen[objID] {
+			seen[objID] = true
+			objIDs = append(objIDs, objID)
+		}
+	}
+	if len(objIDs) == 0 {
+		return nil, nil
+	}
+
+	var found int64
+	for _, col := range []*mongo.Collection{config.EventsCol, config.PlaysCol, config.DiningsCol} {
+		n, err := col.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": objIDs}, "organizer_id": ownerID})
+		if err != nil {
+			return nil, err
+		}
+		found += n
+	}
+	if found != int64(len(objIDs)) {
+		return nil, errors.New("listings must be your own events, play venues or restaurants")
+	}
+	return objIDs, nil
+}
+
+func teamName(ownerID primitive.ObjectID) string {
+	if p, err := GetProfileByID(ownerID.Hex()); err == nil && p.Name != "" {
+		return p.Name
+	}
+	if org, err := GetByID(ownerID.Hex()); err == nil {
+		if org.Name != "" {
+			return org.Name
+		}
+		return org.Email
+	}
+	return "an organizer"
+}
+
+// sendMemberCode emails a new sign-in code, stored only as a hash. Sends
+// are limited by a cooldown and a number per hour, as for phone logins.
+func sendMemberCode(ctx context.Context, m *models.OrganizerMember, invite bool) error {
+	now := time.Now()
+	windowFrom, sendCount := now, 0
+	if !m.OTPSentAt.IsZero() {
+		if wait := memberOTPCooldown - now.Sub(m.OTPSentAt); wait > 0 {
+			return otpsvc.Limited("please wait before requesting another code", wait)
+		}
+		if now.Sub(m.OTPWindowFrom) < memberOTPWindow {
+			windowFrom, sendCount = m.OTPWindowFrom, m.OTPSendCount
+		}
+		if sendCount >= memberOTPMaxSends {
+			return otpsvc.Limited("too many codes requested, try again later", windowFrom.Add(memberOTPWindow).Sub(now))
+		}
+	}
+
+	code, err := otpsvc.GenerateCode()
+	if err != nil {
+		return err
+	}
+	// The write only succeeds if nobody sent a code since we looked, so two
+	// requests at once cannot both get past the cooldown
+	filter := bson.M{"_id": m.ID, "otp_sent_at": m.OTPSentAt}
+	if m.OTPSentAt.IsZero() {
+		filter["otp_sent_at"] = bson.M{"$exists": false}
+	}
+	res, err := config.OrgMembersCol.UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{
+			"otp_hash":        otpsvc.HashCode(m.ID.Hex(), code),
+			"otp_expiry":      now.Add(memberOTPTTL),
+			"otp_attempts":    0,
+			"otp_sent_at":     now,
+			"otp_send_count":  sendCount + 1,
+			"otp_window_from": windowFrom,
+		},
+		"$unset": bson.M{"otp": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return otpsvc.Limited("please wait before requesting another code", memberOTPCooldown)
+	}
+	return config.SendTeamInviteEmail(m.Email, teamName(m.OrganizerID), code, invite)
+}
+
+// InviteMember adds someone to the owner's team and emails them a sign-in
+// code. Inviting an email again resends the invitation with the new
+// permissions.
+func InviteMember(ownerID, email, name string, perms, listingIDs []string, invitedBy string) (*models.OrganizerMember, error) {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return nil, errors.New("invalid organizer id")
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || !strings.Contains(email, "@") {
+		return nil, errors.New("a valid email is required")
+	}
+	if strings.EqualFold(email, invitedBy) {
+		return nil, errors.New("you are already the owner of this team")
+	}
+	perms, err = normalizePermissions(perms)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listings, err := ownedListings(ctx, ownerObjID, listingIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var existing models.OrganizerMember
+	err = config.OrgMembersCol.FindOne(ctx, bson.M{"organizer_id": ownerObjID, "email": email}).Decode(&existing)
+	if err == nil && existing.Status == MemberActive {
+		return nil, errors.New("this email is already on your team")
+	}
+
+	now := time.Now()
+	var m models.OrganizerMember
+	err = config.OrgMembersCol.FindOneAndUpdate(ctx,
+		bson.M{"organizer_id": ownerObjID, "email": email},
+		bson.M{"$set": bson.M{
+			"name":        strings.TrimSpace(name),
+			"permissions": perms,
+			"listing_ids": listings,
+			"status":      MemberInvited,
+			"invited_by":  invitedBy,
+			"invited_at":  now,
+			"updated_at":  now,
+		}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&m)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sendMemberCode(ctx, &m, true); err != nil {
+		var limitErr *otpsvc.LimitError
+		if errors.As(err, &limitErr) {
+			return nil, err
+		}
+		fmt.Printf("ERROR: Failed to send team invite to %s: %v\n", email, err)
+		return nil, errors.New("failed to send invitation email")
+	}
+	return &m, nil
+}
+
+// ListMembers returns the owner's team, newest invitation first.
+func ListMembers(ownerID string) ([]models.OrganizerMember, error) {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return nil, errors.New("invalid organizer id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.OrgMembersCol.Find(ctx, bson.M{"organizer_id": ownerObjID},
+		options.Find().SetSort(bson.D{{Key: "invited_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	list := []models.OrganizerMember{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// UpdateMember changes a member's permissions and listing restriction.
+func UpdateMember(ownerID, memberID, name string, perms, listingIDs []string) (*models.OrganizerMember, error) {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return nil, errors.New("invalid organizer id")
+	}
+	memberObjID, err := primitive.ObjectIDFromHex(memberID)
+	if err != nil {
+		return nil, errors.New("invalid member id")
+	}
+	perms, err = normalizePermissions(perms)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	listings, err := ownedListings(ctx, ownerObjID, listingIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{"permissions": perms, "listing_ids": listings, "updated_at": time.Now()}
+	if name = strings.TrimSpace(name); name != "" {
+		set["name"] = name
+	}
+	var m models.OrganizerMember
+	err = config.OrgMembersCol.FindOneAndUpdate(ctx,
+		bson.M{"_id": memberObjID, "organizer_id": ownerObjID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&m)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMemberNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	ForgetMember(memberID)
+	return &m, nil
+}
+
+// RemoveMember takes someone off the owner's team. The caller ends their
+// sessions.
+func RemoveMember(ownerID, memberID string) error {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return errors.New("invalid organizer id")
+	}
+	memberObjID, err := primitive.ObjectIDFromHex(memberID)
+	if err != nil {
+		return errors.New("invalid member id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := config.OrgMembersCol.DeleteOne(ctx, bson.M{"_id": memberObjID, "organizer_id": ownerObjID})
+	if err != nil {
+		return err
+	}
+	ForgetMember(memberID)
+	if res.DeletedCount == 0 {
+		return ErrMemberNotFound
+	}
+	return nil
+}
+
+// MemberIDs returns the ids of everyone on the owner's team.
+func MemberIDs(ownerID string) ([]string, error) {
+	ownerObjID, err := primitive.ObjectIDFromHex(ownerID)
+	if err != nil {
+		return nil, errors.New("invalid organizer id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.OrgMembersCol.Find(ctx, bson.M{"organizer_id": ownerObjID}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID.Hex())
+	}
+	return ids, nil
+}
+
+// RemoveTeam deletes the owner's whole team, e.g. when the organizer is
+// deleted, and returns the ids of the removed members.
+func RemoveTeam(ownerID string) ([]string, error) {
+	ids, err := MemberIDs(ownerID)
+	if err != nil || len(ids) == 0 {
+		return ids, err
+	}
+	ownerObjID, _ := primitive.ObjectIDFromHex(ownerID)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := config.OrgMembersCol.DeleteMany(ctx, bson.M{"organizer_id": ownerObjID}); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		ForgetMember(id)
+	}
+	return ids, nil
+}
+
+// findMembership picks the team an email signs in to. organizerID may be
+// empty when the email is on a single team.
+func findMembership(ctx context.Context, email, organizerID string) (*models.OrganizerMember, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	filter := bson.M{"email": email}
+	if organizerID != "" {
+		objID, err := primitive.ObjectIDFromHex(organizerID)
+		if err != nil {
+			return nil, errors.New("invalid organizer id")
+		}
+		filter["organizer_id"] = objID
+	}
+
+	cursor, err := config.OrgMembersCol.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var list []models.OrganizerMember
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	switch len(list) {
+	case 0:
+		return nil, ErrMemberNotFound
+	case 1:
+		return &list[0], nil
+	}
+	teams := make([]TeamChoice, 0, len(list))
+	for _, m := range list {
+		teams = append(teams, TeamChoice{OrganizerID: m.OrganizerID.Hex(), Name: teamName(m.OrganizerID)})
+	}
+	return nil, &MultipleTeamsError{Teams: teams}
+}
+
+// SendMemberOTP emails a team member a sign-in code.
+func SendMemberOTP(email, organizerID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	m, err := findMembership(ctx, email, organizerID)
+	if err != nil {
+		return err
+	}
+	if err := sendMemberCode(ctx, m, false); err != nil {
+		var limitErr *otpsvc.LimitError
+		if errors.As(err, &limitErr) {
+			return err
+		}
+		fmt.Printf("ERROR: Failed to send team sign-in code to %s: %v\n", m.Email, err)
+		return errors.New("failed to send otp")
+	}
+	return nil
+}
+
+// VerifyMemberOTP checks a member's sign-in code. The first successful
+// sign-in accepts the invitation.
+func VerifyMemberOTP(email, organizerID, otp string) (*models.OrganizerMember, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m, err := findMembership(ctx, email, organizerID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetByID(m.OrganizerID.Hex()); err != nil {
+		return nil, ErrDeleted
+	}
+	if m.OTPHash == "" {
+		return nil, errors.New("otp not found, please request a new one")
+	}
+	if time.Now().After(m.OTPExpiry) {
+		return nil, errors.New("otp expired")
+	}
+	if !hmac.Equal([]byte(otpsvc.HashCode(m.ID.Hex(), otp)), []byte(m.OTPHash)) {
+		var updated models.OrganizerMember
+		err := config.OrgMembersCol.FindOneAndUpdate(ctx,
+			bson.M{"_id": m.ID, "otp_hash": m.OTPHash},
+			bson.M{"$inc": bson.M{"otp_attempts": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err != nil {
+			return nil, errors.New("invalid otp")
+		}
+		if updated.OTPAttempts >= memberOTPMaxAttempts {
+			config.OrgMembersCol.UpdateOne(ctx, bson.M{"_id": m.ID}, bson.M{"$unset": bson.M{"otp_hash": "", "otp_expiry": "", "otp_attempts": ""}})
+			return nil, errors.New("too many wrong attempts, please request a new otp")
+		}
+		return nil, errors.New("invalid otp")
+	}
+
+	now := time.Now()
+	set := bson.M{"status": MemberActive, "last_login_at": now}
+	if m.JoinedAt == nil {
+		set["joined_at"] = now
+		m.JoinedAt = &now
+	}
+	res, err := config.OrgMembersCol.UpdateOne(ctx, bson.M{"_id": m.ID, "otp_hash": m.OTPHash}, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"otp_hash": "", "otp_expiry": "", "otp_attempts": ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.ModifiedCount == 0 {
+		return nil, errors.New("otp already used")
+	}
+	m.Status = MemberActive
+	m.LastLoginAt = &now
+	return m, nil
+}
+
+// GetMember returns a team member by id.
+func GetMember(memberID string) (*models.OrganizerMember, error) {
+	objID, err := primitive.ObjectIDFromHex(memberID)
+	if err != nil {
+		return nil, errors.New("invalid member id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var m models.OrganizerMember
+	if err := config.OrgMembersCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&m); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+// AccessFor returns what a signed-in member may do. Results are cached for
+// a short while; removing a member also ends their sessions, so a removal
+// never waits on the cache.
+func AccessFor(memberID string) (*MemberAccess, error) {
+	if v, ok := memberCache.Load(memberID); ok {
+		cached := v.(cachedAccess)
+		if time.Since(cached.checkedAt) < memberCacheTTL {
+			return cached.access, nil
+		}
+	}
+
+	m, err := GetMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+	access := &MemberAccess{
+		MemberID:    m.ID.Hex(),
+		OrganizerID: m.OrganizerID.Hex(),
+		Email:       m.Email,
+		Permissions: m.Permissions,
+		ListingIDs:  []string{},
+	}
+	for _, id := range m.ListingIDs {
+		access.ListingIDs = append(access.ListingIDs, id.Hex())
+	}
+	memberCache.Store(memberID, cachedAccess{access: access, checkedAt: time.Now()})
+	return access, nil
+}
@@ -0,0 +1,210 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	"ticpin-backend/services/sms"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	phoneOTPTTL       = 5 * time.Minute
+	resendCooldown    = 30 * time.Second
+	sendWindow        = time.Hour
+	maxSendsPerWindow = 5
+	maxVerifyAttempts = 5
+	lockDuration      = 15 * time.Minute
+)
+
+// LimitError is returned when a phone number has to wait before it can
+// request or try a code again.
+type LimitError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string { return e.Message }
+
+// Limited builds a LimitError, rounded to whole seconds.
+func Limited(msg string, retryAfter time.Duration) *LimitError {
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return &LimitError{Message: msg, RetryAfter: retryAfter.Round(time.Second)}
+}
+
+// HashCode is how a one-time code is stored: keyed by a server secret and
+// tied to the number or account it was sent to.
+func HashCode(phone, code string) string {
+	secret := os.Getenv("OTP_HASH_SECRET")
+	key := []byte(secret)
+	if secret == "" {
+		key = config.JWTSecret()
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(phone + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateCode returns a random six digit code.
+func GenerateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// SendPhoneOTP texts a new login code to the phone number. Requests are
+// limited by a resend cooldown and a number of sends per hour.
+func SendPhoneOTP(phone string) error {
+	e164, err := sms.NormalizePhone(phone)
+	if err != nil {
+		return err
+	}
+	provider := sms.Provider()
+	if provider.Name() == "fake" && config.IsProduction() {
+		return errors.New("sms provider not configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	windowStart, sendCount := now, 0
+	var rec models.PhoneOTP
+	found := config.PhoneOTPsCol.FindOne(ctx, bson.M{"_id": e164}).Decode(&rec) == nil
+	if found {
+		if rec.LockedUntil != nil && now.Before(*rec.LockedUntil) {
+			return Limited("too many wrong attempts, try again later", rec.LockedUntil.Sub(now))
+		}
+		if wait := resendCooldown - now.Sub(rec.LastSentAt); wait > 0 {
+			return Limited("please wait before requesting another code", wait)
+		}
+		if now.Sub(rec.WindowStart) < sendWindow {
+			windowStart, sendCount = rec.WindowStart, rec.SendCount
+		}
+		if sendCount >= maxSendsPerWindow {
+			return Limited("too many codes requested, try again later", windowStart.Add(sendWindow).Sub(now))
+		}
+	}
+
+	code, err := GenerateCode()
+	if err != nil {
+		return err
+	}
+	purgeAt := windowStart.Add(sendWindow)
+	if exp := now.Add(phoneOTPTTL); exp.After(purgeAt) {
+		purgeAt = exp
+	}
+	next := models.PhoneOTP{
+		Phone:       e164,
+		CodeHash:    HashCode(e164, code),
+		SendCount:   sendCount + 1,
+		WindowStart: windowStart,
+		LastSentAt:  now,
+		ExpiresAt:   now.Add(phoneOTPTTL),
+		PurgeAt:     purgeAt,
+	}
+
+	// The write only succeeds if nobody sent a code since we looked, so two
+	// requests at once cannot both get past the cooldown.
+	if found {
+		res, err := config.PhoneOTPsCol.ReplaceOne(ctx, bson.M{"_id": e164, "last_sent_at": rec.LastSentAt}, next)
+		if err != nil {
+			return err
+		}
+		if res.MatchedCount == 0 {
+			return Limited("please wait before requesting another code", resendCooldown)
+		}
+	} else if _, err := config.PhoneOTPsCol.InsertOne(ctx, next); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return Limited("please wait before requesting another code", resendCooldown)
+		}
+		return err
+	}
+
+	if err := provider.SendOTP(e164, code); err != nil {
+		fmt.Printf("ERROR: Failed to send login OTP via %s to %s: %v\n", provider.Name(), e164, err)
+		return errors.New("failed to send otp")
+	}
+	return nil
+}
+
+// VerifyPhoneOTP checks a login code. Each code can be used once and a
+// number is locked for a while after too many wrong attempts.
+func VerifyPhoneOTP(phone, code string) error {
+	e164, err := sms.NormalizePhone(phone)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var rec models.PhoneOTP
+	if err := config.PhoneOTPsCol.FindOne(ctx, bson.M{"_id": e164}).Decode(&rec); err != nil {
+		return errors.New("otp not found, please request a new one")
+	}
+	if rec.LockedUntil != nil && now.Before(*rec.LockedUntil) {
+		return Limited("too many wrong attempts, try again later", rec.LockedUntil.Sub(now))
+	}
+	if rec.CodeHash == "" {
+		return errors.New("otp not found, please request a new one")
+	}
+	if now.After(rec.ExpiresAt) {
+		return errors.New("otp expired")
+	}
+
+	if !hmac.Equal([]byte(HashCode(e164, code)), []byte(rec.CodeHash)) {
+		var updated models.PhoneOTP
+		err := config.PhoneOTPsCol.FindOneAndUpdate(ctx,
+			bson.M{"_id": e164, "code_hash": rec.CodeHash},
+			bson.M{"$inc": bson.M{"attempts": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if err != nil {
+			return errors.New("invalid otp")
+		}
+		if updated.Attempts >= maxVerifyAttempts {
+			lockedUntil := now.Add(lockDuration)
+			purgeAt := updated.PurgeAt
+			if lockedUntil.After(purgeAt) {
+				purgeAt = lockedUntil
+			}
+			config.PhoneOTPsCol.UpdateOne(ctx, bson.M{"_id": e164}, bson.M{"$set": bson.M{
+				"code_hash":    "",
+				"locked_until": lockedUntil,
+				"purge_at":     purgeAt,
+			}})
+			return Limited("too many wrong attempts, try again later", lockDuration)
+		}
+		return fmt.Errorf("invalid otp, %d attempt(s) left", maxVerifyAttempts-updated.Attempts)
+	}
+
+	// Burn the code; keep the send counters for the rate limit
+	res, err := config.PhoneOTPsCol.UpdateOne(ctx, bson.M{"_id": e164, "code_hash": rec.CodeHash}, bson.M{
+		"$set": bson.M{"code_hash": "", "attempts": 0},
+	})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return errors.New("otp already used")
+	}
+	return nil
+}
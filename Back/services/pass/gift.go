@@ -0,0 +1,262 @@
+package pass
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"regexp"
+	"strings"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// giftValidity is how long a gift code can be redeemed after purchase.
+const giftValidity = 90 * 24 * time.Hour
+
+// GiftRequest describes a gift order as sent by the buyer's client and
+// carried in the payment order notes.
+type GiftRequest struct {
+	PlanID         string `json:"plan_id"`
+	RecipientName  string `json:"recipient_name"`
+	RecipientPhone string `json:"recipient_phone"`
+	Message        string `json:"message"`
+}
+
+func generateGiftCode() string {
+	const charset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	const length = 10
+
+	b := make([]byte, length)
+	for i := range b {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		b[i] = charset[n.Int64()]
+	}
+	return "TPG" + string(b)
+}
+
+// lastTen compares Indian numbers with or without the +91 prefix.
+func lastTen(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if len(phone) > 10 {
+		return phone[len(phone)-10:]
+	}
+	return phone
+}
+
+// IssueGift records a paid gift and returns its code. It is idempotent on
+// the order, so the verify call and the webhook can both invoke it.
+func IssueGift(buyerUserID, buyerPhone, paymentID, orderID string, price float64, req GiftRequest) (*models.PassGift, error) {
+	buyerObjID, err := primitive.ObjectIDFromHex(buyerUserID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	if orderID == "" {
+		return nil, errors.New("order id is required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var existing models.PassGift
+	if err := config.PassGiftsCol.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&existing); err == nil {
+		if existing.PaymentID == "" && paymentID != "" {
+			config.PassGiftsCol.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{"$set": bson.M{"payment_id": paymentID}})
+			existing.PaymentID = paymentID
+		}
+		return &existing, nil
+	}
+
+	// price is what the gateway collected; it must cover the gifted plan
+	plan, err := PaidPlan(req.PlanID, price)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	gift := models.PassGift{
+		PlanID:         plan.ID,
+		Plan:           *plan,
+		BuyerUserID:    buyerObjID,
+		BuyerPhone:     buyerPhone,
+		RecipientName:  strings.TrimSpace(req.RecipientName),
+		RecipientPhone: strings.TrimSpace(req.RecipientPhone),
+		Message:        strings.TrimSpace(req.Message),
+		Price:          price,
+		PaymentID:      paymentID,
+		OrderID:        orderID,
+		Status:         "issued",
+		ExpiresAt:      now.Add(giftValidity),
+		CreatedAt:      now,
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		gift.ID = primitive.NewObjectID()
+		gift.Code = generateGiftCode()
+		_, err = config.PassGiftsCol.InsertOne(ctx, gift)
+		if err == nil {
+			return &gift, nil
+		}
+		if !config.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+		// Either the code collided or the other caller issued this order first.
+		if config.PassGiftsCol.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&existing) == nil {
+			return &existing, nil
+		}
+	}
+	return nil, errors.New("failed to issue gift code")
+}
+
+// RedeemGift turns a gift code into a pass for the redeeming user. A user
+// who already holds an active pass has it extended by a term of the gifted
+// plan instead.
+func RedeemGift(code, userID, phone string) (*models.TicpinPass, *models.PassGift, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, nil, errors.New("invalid user id")
+	}
+	code = strings.ToUpper(strings.TrimSpace(code))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var gift models.PassGift
+	if err := config.PassGiftsCol.FindOne(ctx, bson.M{"code": code}).Decode(&gift); err != nil {
+		return nil, nil, errors.New("invalid gift code")
+	}
+	if gift.Status == "redeemed" {
+		return nil, nil, errors.New("this gift has already been redeemed")
+	}
+	if gift.Status == "expired" || time.Now().After(gift.ExpiresAt) {
+		config.PassGiftsCol.UpdateOne(ctx, bson.M{"_id": gift.ID, "status": "issued"}, bson.M{"$set": bson.M{"status": "expired"}})
+		return nil, nil, errors.New("this gift code has expired")
+	}
+	if gift.RecipientPhone != "" && lastTen(gift.RecipientPhone) != lastTen(phone) {
+		return nil, nil, errors.New("this gift was sent to a different phone number")
+	}
+
+	// Claim the code first so two redemptions cannot both succeed.
+	now := time.Now()
+	res, err := config.PassGiftsCol.UpdateOne(ctx, bson.M{"_id": gift.ID, "status": "issued"}, bson.M{"$set": bson.M{
+		"status":      "redeemed",
+		"redeemed_by": userObjID,
+		"redeemed_at": now,
+	}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.ModifiedCount == 0 {
+		return nil, nil, errors.New("this gift has already been redeemed")
+	}
+
+	p, extended, err := applyGift(ctx, &gift, userObjID, phone)
+	if err != nil {
+		config.PassGiftsCol.UpdateOne(ctx, bson.M{"_id": gift.ID}, bson.M{
+			"$set":   bson.M{"status": "issued"},
+			"$unset": bson.M{"redeemed_by": "", "redeemed_at": ""},
+		})
+		return nil, nil, err
+	}
+
+	config.PassGiftsCol.UpdateOne(ctx, bson.M{"_id": gift.ID}, bson.M{"$set": bson.M{
+		"redeemed_pass_id": p.ID,
+		"extended":         extended,
+	}})
+	gift.Status = "redeemed"
+	gift.RedeemedBy = userObjID
+	gift.RedeemedPassID = p.ID
+	gift.Extended = extended
+	gift.RedeemedAt = &now
+	return p, &gift, nil
+}
+
+func applyGift(ctx context.Context, gift *models.PassGift, userObjID primitive.ObjectID, phone string) (*models.TicpinPass, bool, error) {
+	if active, err := GetActiveByUserID(userObjID.Hex()); err == nil {
+		p, err := renew(active.ID.Hex(), models.RenewalRecord{PaymentID: gift.PaymentID, GiftCode: gift.Code}, &gift.Plan)
+		return p, true, err
+	}
+
+	p, err := Apply(userObjID.Hex(), gift.PaymentID, phone, gift.OrderID, models.TicpinPass{
+		PlanID: gift.PlanID,
+		Plan:   &gift.Plan,
+		Price:  gift.Price,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return p, false, nil
+}
+
+// ListGiftsByBuyer returns the gifts a user has bought, newest first.
+func ListGiftsByBuyer(userID string) ([]models.PassGift, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.PassGiftsCol.Find(ctx, bson.M{"buyer_user_id": objID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	gifts := []models.PassGift{}
+	if err := cursor.All(ctx, &gifts); err != nil {
+		return nil, err
+	}
+	return gifts, nil
+}
+
+// ListGifts pages through all gifts for admins. Issued gifts past their
+// expiry are reported as expired.
+func ListGifts(status, search string, page, limit int) ([]models.PassGift, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	now := time.Now()
+	switch status {
+	case "":
+	case "issued":
+		filter["status"] = "issued"
+		filter["expires_at"] = bson.M{"$gt": now}
+	case "expired":
+		filter["$or"] = []bson.M{
+			{"status": "expired"},
+			{"status": "issued", "expires_at": bson.M{"$lte": now}},
+		}
+	default:
+		filter["status"] = status
+	}
+	if search != "" {
+		filter["$and"] = []bson.M{{"$or": []bson.M{
+			{"code": strings.ToUpper(search)},
+			{"buyer_phone": bson.M{"$regex": regexp.QuoteMeta(search)}},
+			{"recipient_phone": bson.M{"$regex": regexp.QuoteMeta(search)}},
+		}}}
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := config.PassGiftsCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	gifts := []models.PassGift{}
+	if err := cursor.All(ctx, &gifts); err != nil {
+		return nil, 0, err
+	}
+	for i := range gifts {
+		if gifts[i].Status == "issued" && now.After(gifts[i].ExpiresAt) {
+			gifts[i].Status = "expired"
+		}
+	}
+	total, err := config.PassGiftsCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return gifts, total, nil
+}
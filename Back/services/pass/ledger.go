@@ -0,0 +1,454 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	BenefitTurf   = "turf"
+	BenefitDining = "dining"
+)
+
+func benefitField(benefit string) (string, error) {
+	switch benefit {
+	case BenefitTurf:
+		return "benefits.turf_bookings", nil
+	case BenefitDining:
+		return "benefits.dining_vouchers", nil
+	}
+	return "", errors.New("unknown benefit: " + benefit)
+}
+
+func benefitLabel(benefit string) string {
+	if benefit == BenefitTurf {
+		return "turf bookings"
+	}
+	return "dining vouchers"
+}
+
+// Redeem atomically takes one unit of a benefit from an active pass and
+// records it in the ledger against the booking that used it. bookingID may
+// be empty and linked later with LinkRedemption.
+func Redeem(passID, benefit, bookingType, bookingID string) (*models.TicpinPass, *models.PassLedgerEntry, error) {
+	objID, err := primitive.ObjectIDFromHex(passID)
+	if err != nil {
+		return nil, nil, err
+	}
+	field, err := benefitField(benefit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The remaining > 0 guard makes the decrement safe under concurrent use
+	var p models.TicpinPass
+	err = config.PassesCol.FindOneAndUpdate(ctx, bson.M{
+		"_id":                objID,
+		"status":             "active",
+		"end_date":           bson.M{"$gt": time.Now()},
+		field + ".remaining": bson.M{"$gt": 0},
+	}, bson.M{
+		"$inc": bson.M{field + ".remaining": -1, field + ".used": 1},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&p)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil, fmt.Errorf("no %s remaining on an active pass", benefitLabel(benefit))
+		}
+		return nil, nil, err
+	}
+
+	entry := &models.PassLedgerEntry{
+		ID:          primitive.NewObjectID(),
+		PassID:      p.ID,
+		UserID:      p.UserID,
+		Term:        len(p.Renewals),
+		Benefit:     benefit,
+		Type:        "redeem",
+		Quantity:    -1,
+		BookingType: bookingType,
+		BookingID:   bookingID,
+		CreatedAt:   time.Now(),
+	}
+	if benefit == BenefitDining {
+		entry.Value = p.Benefits.DiningVouchers.ValueEach
+	}
+	if _, err := config.PassLedgerCol.InsertOne(ctx, entry); err != nil {
+		// Without a ledger entry the redemption must not stand
+		_, _ = config.PassesCol.UpdateOne(ctx, bson.M{"_id": p.ID}, bson.M{
+			"$inc": bson.M{field + ".remaining": 1, field + ".used": -1},
+		})
+		return nil, nil, err
+	}
+	return &p, entry, nil
+}
+
+// RedeemOwned is Redeem for a benefit the pass holder uses by hand, with
+// no booking behind it. Only the holder may do so.
+func RedeemOwned(passID, userID, phone, benefit string) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := loadOwnedPass(ctx, passID, userID, phone); err != nil {
+		return nil, err
+	}
+	p, _, err := Redeem(passID, benefit, "manual", "")
+	return p, err
+}
+
+// LinkRedemption attaches a booking to a redemption made before the booking
+// existed.
+func LinkRedemption(entryID primitive.ObjectID, bookingID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := config.PassLedgerCol.UpdateOne(ctx, bson.M{"_id": entryID, "type": "redeem"}, bson.M{
+		"$set": bson.M{"booking_id": bookingID},
+	})
+	return err
+}
+
+// reverse writes a reversal for a redemption and gives the unit back to the
+// pass that was charged. The unique index on reversal_of makes it
+// idempotent. Units from an earlier term are not restored, since renewal
+// already reset the counters.
+func reverse(ctx context.Context, redeem *models.PassLedgerEntry, note string) (*models.TicpinPass, error) {
+	field, err := benefitField(redeem.Benefit)
+	if err != nil {
+		return nil, err
+	}
+
+	reversal := &models.PassLedgerEntry{
+		ID:          primitive.NewObjectID(),
+		PassID:      redeem.PassID,
+		UserID:      redeem.UserID,
+		Term:        redeem.Term,
+		Benefit:     redeem.Benefit,
+		Type:        "reversal",
+		Quantity:    1,
+		Value:       redeem.Value,
+		BookingType: redeem.BookingType,
+		BookingID:   redeem.BookingID,
+		ReversalOf:  redeem.ID,
+		Note:        note,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := config.PassLedgerCol.InsertOne(ctx, reversal); err != nil {
+		if config.IsDuplicateKeyError(err) {
+			return nil, errors.New("benefit already reversed")
+		}
+		return nil, err
+	}
+
+	var p models.TicpinPass
+	err = config.PassesCol.FindOneAndUpdate(ctx, bson.M{
+		"_id":           redeem.PassID,
+		"renewals":      bson.M{"$size": redeem.Term},
+		field + ".used": bson.M{"$gt": 0},
+	}, bson.M{
+		"$inc": bson.M{field + ".remaining": 1, field + ".used": -1},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&p)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// The ledger is append-only, so the unit that could not be given
+		// back is taken out again by an entry of its own
+		lapsed := *reversal
+		lapsed.ID = primitive.NewObjectID()
+		lapsed.Type = "lapsed"
+		lapsed.Quantity = -1
+		lapsed.ReversalOf = reversal.ID
+		lapsed.Note = "not restored: pass term has ended"
+		lapsed.CreatedAt = time.Now()
+		if _, err := config.PassLedgerCol.InsertOne(ctx, &lapsed); err != nil {
+			fmt.Printf("ERROR: Failed to record lapsed reversal %s: %v\n", reversal.ID.Hex(), err)
+		}
+		return nil, errors.New("pass term has ended, benefit not restored")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ReverseRedemption undoes a specific redemption, e.g. when the booking it
+// was taken for could not be created.
+func ReverseRedemption(entryID primitive.ObjectID, note string) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var redeem models.PassLedgerEntry
+	if err := config.PassLedgerCol.FindOne(ctx, bson.M{"_id": entryID, "type": "redeem"}).Decode(&redeem); err != nil {
+		return nil, errors.New("redemption not found")
+	}
+	return reverse(ctx, &redeem, note)
+}
+
+// ReverseForBooking gives back the benefit a booking consumed, on the pass
+// that was actually charged.
+func ReverseForBooking(bookingType, bookingID, note string) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var redeem models.PassLedgerEntry
+	if err := config.PassLedgerCol.FindOne(ctx, bson.M{
+		"booking_type": bookingType,
+		"booking_id":   bookingID,
+		"type":         "redeem",
+	}, options.FindOne().SetSort(bson.M{"created_at": -1})).Decode(&redeem); err != nil {
+		return nil, errors.New("no benefit redemption found for this booking")
+	}
+	return reverse(ctx, &redeem, note)
+}
+
+func UseTurfBooking(passID, bookingID string) (*models.TicpinPass, error) {
+	p, _, err := Redeem(passID, BenefitTurf, "play", bookingID)
+	return p, err
+}
+
+func UseDiningVoucher(passID, bookingID string) (*models.TicpinPass, error) {
+	p, _, err := Redeem(passID, BenefitDining, "dining", bookingID)
+	return p, err
+}
+
+func RefundTurfBooking(bookingID string) (*models.TicpinPass, error) {
+	return ReverseForBooking("play", bookingID, "booking cancelled")
+}
+
+func RefundDiningVoucher(bookingID string) (*models.TicpinPass, error) {
+	return ReverseForBooking("dining", bookingID, "booking cancelled")
+}
+
+// AdjustBenefit sets a benefit's remaining count by hand and records the
+// difference in the ledger.
+func AdjustBenefit(passID, benefit string, remaining int, note string) error {
+	objID, err := primitive.ObjectIDFromHex(passID)
+	if err != nil {
+		return err
+	}
+	field, err := benefitField(benefit)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var before models.TicpinPass
+	if err := config.PassesCol.FindOneAndUpdate(ctx, bson.M{"_id": objID}, bson.M{
+		"$set": bson.M{field + ".remaining": remaining, "updatedAt": time.Now()},
+	}).Decode(&before); err != nil {
+		return errors.New("pass not found")
+	}
+
+	previous := before.Benefits.TurfBookings.Remaining
+	if benefit == BenefitDining {
+		previous = before.Benefits.DiningVouchers.Remaining
+	}
+	if previous == remaining {
+		return nil
+	}
+	_, err = config.PassLedgerCol.InsertOne(ctx, models.PassLedgerEntry{
+		ID:        primitive.NewObjectID(),
+		PassID:    before.ID,
+		UserID:    before.UserID,
+		Term:      len(before.Renewals),
+		Benefit:   benefit,
+		Type:      "adjustment",
+		Quantity:  remaining - previous,
+		Note:      note,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+// GrantBenefit adds extra units of a benefit to a user's active pass, e.g.
+// as a reward, and records them in the ledger.
+func GrantBenefit(userID, benefit string, quantity int, note string) (*models.TicpinPass, error) {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	field, err := benefitField(benefit)
+	if err != nil {
+		return nil, err
+	}
+	if quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p models.TicpinPass
+	err = config.PassesCol.FindOneAndUpdate(ctx, bson.M{
+		"user_id":  userObjID,
+		"status":   "active",
+		"end_date": bson.M{"$gt": time.Now()},
+	}, bson.M{
+		"$inc": bson.M{field + ".remaining": quantity},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&p)
+	if err != nil {
+		return nil, errors.New("no active pass found")
+	}
+
+	_, err = config.PassLedgerCol.InsertOne(ctx, models.PassLedgerEntry{
+		ID:        primitive.NewObjectID(),
+		PassID:    p.ID,
+		UserID:    p.UserID,
+		Term:      len(p.Renewals),
+		Benefit:   benefit,
+		Type:      "adjustment",
+		Quantity:  quantity,
+		Note:      note,
+		CreatedAt: time.Now(),
+	})
+	return &p, err
+}
+
+// RevokeBenefit takes back benefits given with GrantBenefit, as long as the
+// active pass still has that many left.
+func RevokeBenefit(userID, benefit string, quantity int, note string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return errors.New("invalid user id")
+	}
+	field, err := benefitField(benefit)
+	if err != nil {
+		return err
+	}
+	if quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p models.TicpinPass
+	err = config.PassesCol.FindOneAndUpdate(ctx, bson.M{
+		"user_id":            userObjID,
+		"status":             "active",
+		"end_date":           bson.M{"$gt": time.Now()},
+		field + ".remaining": bson.M{"$gte": quantity},
+	}, bson.M{
+		"$inc": bson.M{field + ".remaining": -quantity},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&p)
+	if err != nil {
+		return errors.New("no active pass with enough benefits left")
+	}
+
+	_, err = config.PassLedgerCol.InsertOne(ctx, models.PassLedgerEntry{
+		ID:        primitive.NewObjectID(),
+		PassID:    p.ID,
+		UserID:    p.UserID,
+		Term:      len(p.Renewals),
+		Benefit:   benefit,
+		Type:      "adjustment",
+		Quantity:  -quantity,
+		Note:      note,
+		CreatedAt: time.Now(),
+	})
+	return err
+}
+
+// LedgerView is a pass's benefit history with counters derived from it.
+type LedgerView struct {
+	Pass    *models.TicpinPass       `json:"pass"`
+	Derived models.PassBenefits      `json:"derived"`
+	InSync  bool                     `json:"in_sync"`
+	Entries []models.PassLedgerEntry `json:"entries"`
+}
+
+// deriveBenefits replays the current term's ledger on top of the term's
+// starting totals.
+func deriveBenefits(p *models.TicpinPass, entries []models.PassLedgerEntry) models.PassBenefits {
+	derived := p.Benefits
+	term := len(p.Renewals)
+	turfUsed, turfAdj, diningUsed, diningAdj := 0, 0, 0, 0
+	for _, e := range entries {
+		if e.Term != term {
+			continue
+		}
+		switch {
+		case e.Benefit == BenefitTurf && e.Type == "adjustment":
+			turfAdj += e.Quantity
+		case e.Benefit == BenefitTurf:
+			turfUsed -= e.Quantity
+		case e.Benefit == BenefitDining && e.Type == "adjustment":
+			diningAdj += e.Quantity
+		case e.Benefit == BenefitDining:
+			diningUsed -= e.Quantity
+		}
+	}
+	derived.TurfBookings.Used = turfUsed
+	derived.TurfBookings.Remaining = derived.TurfBookings.Total - turfUsed + turfAdj
+	derived.DiningVouchers.Used = diningUsed
+	derived.DiningVouchers.Remaining = derived.DiningVouchers.Total - diningUsed + diningAdj
+	return derived
+}
+
+func GetLedger(passID string) (*LedgerView, error) {
+	objID, err := primitive.ObjectIDFromHex(passID)
+	if err != nil {
+		return nil, errors.New("invalid pass id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var p models.TicpinPass
+	if err := config.PassesCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&p); err != nil {
+		return nil, errors.New("pass not found")
+	}
+
+	cursor, err := config.PassLedgerCol.Find(ctx, bson.M{"pass_id": objID}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.PassLedgerEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+
+	derived := deriveBenefits(&p, entries)
+	return &LedgerView{
+		Pass:    &p,
+		Derived: derived,
+		InSync: derived.TurfBookings == p.Benefits.TurfBookings &&
+			derived.DiningVouchers == p.Benefits.DiningVouchers,
+		Entries: entries,
+	}, nil
+}
+
+// Reconcile rewrites a pass's counters from its ledger.
+func Reconcile(passID string) (*LedgerView, error) {
+	view, err := GetLedger(passID)
+	if err != nil {
+		return nil, err
+	}
+	if view.InSync {
+		return view, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := config.PassesCol.UpdateOne(ctx, bson.M{"_id": view.Pass.ID}, bson.M{"$set": bson.M{
+		"benefits.turf_bookings.used":        view.Derived.TurfBookings.Used,
+		"benefits.turf_bookings.remaining":   view.Derived.TurfBookings.Remaining,
+		"benefits.dining_vouchers.used":      view.Derived.DiningVouchers.Used,
+		"benefits.dining_vouchers.remaining": view.Derived.DiningVouchers.Remaining,
+		"updatedAt":                          time.Now(),
+	}}); err != nil {
+		return nil, err
+	}
+	view.Pass.Benefits = view.Derived
+	view.InSync = true
+	return view, nil
+}
@@ -13,17 +13,6 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	PassPrice          = 1.0
-	PassDurationMonths = 3
-)
-
-var defaultBenefits = models.PassBenefits{
-	TurfBookings:         models.BenefitCounter{Total: 2, Used: 0, Remaining: 2},
-	DiningVouchers:       models.DiningVoucherBenefit{Total: 2, Used: 0, Remaining: 2, ValueEach: 250},
-	EventsDiscountActive: true,
-}
-
 func GetActiveByUserID(userID string) (*models.TicpinPass, error) {
 	col := config.GetDB().Collection("ticpin_passes")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -114,26 +103,26 @@ func Apply(userID, paymentID, phone, orderID string, details models.TicpinPass)
 		return nil, errors.New("unexpired active pass already exists")
 	}
 
-	price := details.Price
-	if price <= 0 {
-		price = PassPrice
+	// A plan snapshot, as kept by gifts, is honoured even if the plan has
+	// since been retired
+	plan := details.Plan
+	if plan == nil {
+		planID := ""
+		if !details.PlanID.IsZero() {
+			planID = details.PlanID.Hex()
+		}
+		if plan, err = ResolvePlan(planID); err != nil {
+			return nil, err
+		}
 	}
 
-	p := &models.TicpinPass{
-		ID:        primitive.NewObjectID(),
-		UserID:    objID,
-		Phone:     phone,
-		PaymentID: paymentID,
-		OrderID:   orderID,
-		QRToken:   primitive.NewObjectID().Hex(),
-		Price:     price,
-		Status:    "active",
-		StartDate: now,
-		EndDate:   now.AddDate(0, PassDurationMonths, 0),
-		Benefits:  defaultBenefits,
-		Renewals:  []models.RenewalRecord{},
-		CreatedAt: now,
-		UpdatedAt: now,
+	newPass := NewPassFromPlan(plan, objID, 0)
+	p := &newPass
+	p.Phone = phone
+	p.PaymentID = paymentID
+	p.OrderID = orderID
+	if details.Price > 0 {
+		p.Price = details.Price
 	}
 
 	_, err = col.InsertOne(ctx, p)
@@ -141,69 +130,13 @@ func Apply(userID, paymentID, phone, orderID string, details models.TicpinPass)
 }
 
 func Renew(passID, paymentID string) (*models.TicpinPass, error) {
-	objID, err := primitive.ObjectIDFromHex(passID)
-	if err != nil {
-		return nil, err
-	}
-
-	col := config.GetDB().Collection("ticpin_passes")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var p models.TicpinPass
-	if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&p); err != nil {
-		return nil, errors.New("pass not found")
-	}
-
-	now := time.Now()
-	newStart := p.EndDate
-	if now.After(p.EndDate) {
-		newStart = now
-	}
-	newEnd := newStart.AddDate(0, PassDurationMonths, 0)
-
-	renewalRecord := models.RenewalRecord{
-		RenewedAt: now,
-		StartDate: newStart,
-		EndDate:   newEnd,
-		PaymentID: paymentID,
-		Price:     PassPrice,
-	}
-
-	update := bson.M{
-		"$set": bson.M{
-			"status":     "active",
-			"start_date": newStart,
-			"end_date":   newEnd,
-			"benefits":   defaultBenefits,
-			"payment_id": paymentID,
-			"updatedAt":  now,
-		},
-		"$push": bson.M{"renewals": renewalRecord},
-	}
-
-	if _, err := col.UpdateOne(ctx, bson.M{"_id": objID}, update); err != nil {
-		return nil, err
-	}
-
-	p.Status = "active"
-	p.StartDate = newStart
-	p.EndDate = newEnd
-	p.Benefits = defaultBenefits
-	p.PaymentID = paymentID
-	p.Renewals = append(p.Renewals, renewalRecord)
-	return &p, nil
-}
-
-func UseTurfBooking(passID string) (*models.TicpinPass, error) {
-	return useBenefitWithTransaction(passID, "turf")
+	return renew(passID, models.RenewalRecord{PaymentID: paymentID}, nil)
 }
 
-func UseDiningVoucher(passID string) (*models.TicpinPass, error) {
-	return useBenefitWithTransaction(passID, "dining")
-}
-
-func useBenefitWithTransaction(passID, benefitType string) (*models.TicpinPass, error) {
+// renew extends a pass by one term. source carries how the term was paid
+// for: a payment, an auto-renew charge or a gift, and the amount paid when
+// it is known. A non-nil plan renews on that plan instead of the pass's own.
+func renew(passID string, source models.RenewalRecord, override *models.PassPlan) (*models.TicpinPass, error) {
 	objID, err := primitive.ObjectIDFromHex(passID)
 	if err != nil {
 		return nil, err
@@ -214,220 +147,100 @@ func useBenefitWithTransaction(passID, benefitType string) (*models.TicpinPass,
 	defer cancel()
 
 	var p models.TicpinPass
-	now := time.Now()
-	if err := col.FindOne(ctx, bson.M{
-		"_id":      objID,
-		"status":   "active",
-		"end_date": bson.M{"$gt": now},
-	}).Decode(&p); err != nil {
-		return nil, errors.New("active, unexpired pass not found")
+	if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&p); err != nil {
+		return nil, errors.New("pass not found")
 	}
 
-	var updateFields bson.M
-	if benefitType == "turf" {
-		if p.Benefits.TurfBookings.Remaining <= 0 {
-			return nil, errors.New("no turf bookings remaining")
-		}
-		p.Benefits.TurfBookings.Used++
-		p.Benefits.TurfBookings.Remaining--
-		updateFields = bson.M{
-			"benefits.turf_bookings.used":      p.Benefits.TurfBookings.Used,
-			"benefits.turf_bookings.remaining": p.Benefits.TurfBookings.Remaining,
-			"updatedAt":                        time.Now(),
-		}
-	} else {
-		if p.Benefits.DiningVouchers.Remaining <= 0 {
-			return nil, errors.New("no dining vouchers remaining")
-		}
-		p.Benefits.DiningVouchers.Used++
-		p.Benefits.DiningVouchers.Remaining--
-		updateFields = bson.M{
-			"benefits.dining_vouchers.used":      p.Benefits.DiningVouchers.Used,
-			"benefits.dining_vouchers.remaining": p.Benefits.DiningVouchers.Remaining,
-			"updatedAt":                          time.Now(),
+	// Renew on the current terms of the pass's plan when it is still sold,
+	// otherwise on the terms stored with the pass.
+	plan := PlanFor(&p)
+	if override != nil {
+		plan = *override
+	} else if !p.PlanID.IsZero() {
+		if current, err := ResolvePlan(p.PlanID.Hex()); err == nil {
+			plan = *current
 		}
 	}
+	benefits := benefitsFromPlan(&plan)
 
-	// Simple atomic update without complex transactions for now
-	// Note: MongoDB transactions require replica sets, for simplicity using atomic updates
-	_, err = col.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": updateFields})
-	if err != nil {
-		return nil, err
-	}
-	return &p, nil
-}
-
-func useBenefit(passID, benefitType string) (*models.TicpinPass, error) {
-	objID, err := primitive.ObjectIDFromHex(passID)
-	if err != nil {
-		return nil, err
-	}
-
-	col := config.GetDB().Collection("ticpin_passes")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var p models.TicpinPass
 	now := time.Now()
-	if err := col.FindOne(ctx, bson.M{
-		"_id":      objID,
-		"status":   "active",
-		"end_date": bson.M{"$gt": now},
-	}).Decode(&p); err != nil {
-		return nil, errors.New("active, unexpired pass not found")
+	paymentID := source.PaymentID
+	subscriptionID := source.SubscriptionID
+	// A pass in its grace period renews from the date it originally ran out.
+	currentEnd := p.EndDate
+	if p.AutoRenew != nil && p.AutoRenew.GraceFrom != nil {
+		currentEnd = *p.AutoRenew.GraceFrom
+	}
+	newStart := currentEnd
+	if now.After(currentEnd) {
+		newStart = now
 	}
+	newEnd := newStart.AddDate(0, plan.DurationMonths, 0)
 
-	var updateFields bson.M
-	if benefitType == "turf" {
-		if p.Benefits.TurfBookings.Remaining <= 0 {
-			return nil, errors.New("no turf bookings remaining")
-		}
-		p.Benefits.TurfBookings.Used++
-		p.Benefits.TurfBookings.Remaining--
-		updateFields = bson.M{
-			"benefits.turf_bookings.used":      p.Benefits.TurfBookings.Used,
-			"benefits.turf_bookings.remaining": p.Benefits.TurfBookings.Remaining,
-			"updatedAt":                        time.Now(),
-		}
-	} else {
-		if p.Benefits.DiningVouchers.Remaining <= 0 {
-			return nil, errors.New("no dining vouchers remaining")
-		}
-		p.Benefits.DiningVouchers.Used++
-		p.Benefits.DiningVouchers.Remaining--
-		updateFields = bson.M{
-			"benefits.dining_vouchers.used":      p.Benefits.DiningVouchers.Used,
-			"benefits.dining_vouchers.remaining": p.Benefits.DiningVouchers.Remaining,
-			"updatedAt":                          time.Now(),
-		}
+	renewalRecord := source
+	renewalRecord.RenewedAt = now
+	renewalRecord.StartDate = newStart
+	renewalRecord.EndDate = newEnd
+	if renewalRecord.Price == 0 {
+		renewalRecord.Price = plan.Price
 	}
+	renewalRecord.Auto = subscriptionID != ""
 
-	if _, err = col.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": updateFields}); err != nil {
-		return nil, err
+	set := bson.M{
+		"status":     "active",
+		"start_date": newStart,
+		"end_date":   newEnd,
+		"benefits":   benefits,
+		"plan":       plan,
+		"payment_id": paymentID,
+		"updatedAt":  now,
 	}
-	return &p, nil
-}
-
-func RefundTurfBooking(passID string) (*models.TicpinPass, error) {
-	return refundBenefit(passID, "turf")
-}
-
-func RestoreTurfBooking(passID string) (*models.TicpinPass, error) {
-	return restoreBenefit(passID, "turf")
-}
-
-func RefundDiningVoucher(passID string) (*models.TicpinPass, error) {
-	return refundBenefit(passID, "dining")
-}
-
-func RestoreDiningVoucher(passID string) (*models.TicpinPass, error) {
-	return restoreBenefit(passID, "dining")
-}
-
-func refundBenefit(passID, benefitType string) (*models.TicpinPass, error) {
-	objID, err := primitive.ObjectIDFromHex(passID)
-	if err != nil {
-		return nil, err
+	if !plan.ID.IsZero() {
+		set["plan_id"] = plan.ID
 	}
-
-	col := config.GetDB().Collection("ticpin_passes")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var p models.TicpinPass
-	now := time.Now()
-	if err := col.FindOne(ctx, bson.M{
-		"_id":      objID,
-		"status":   "active",
-		"end_date": bson.M{"$gt": now},
-	}).Decode(&p); err != nil {
-		return nil, errors.New("active, unexpired pass not found")
+	if subscriptionID != "" {
+		set["auto_renew.status"] = "active"
+		set["auto_renew.last_charged_at"] = now
+		set["auto_renew.updated_at"] = now
 	}
-
-	var updateFields bson.M
-	if benefitType == "turf" {
-		if p.Benefits.TurfBookings.Used <= 0 {
-			return &p, nil // Already at min, nothing to refund
-		}
-		p.Benefits.TurfBookings.Used--
-		p.Benefits.TurfBookings.Remaining++
-		updateFields = bson.M{
-			"benefits.turf_bookings.used":      p.Benefits.TurfBookings.Used,
-			"benefits.turf_bookings.remaining": p.Benefits.TurfBookings.Remaining,
-			"updatedAt":                        time.Now(),
-		}
-	} else {
-		if p.Benefits.DiningVouchers.Used <= 0 {
-			return &p, nil
-		}
-		p.Benefits.DiningVouchers.Used--
-		p.Benefits.DiningVouchers.Remaining++
-		updateFields = bson.M{
-			"benefits.dining_vouchers.used":      p.Benefits.DiningVouchers.Used,
-			"benefits.dining_vouchers.remaining": p.Benefits.DiningVouchers.Remaining,
-			"updatedAt":                          time.Now(),
-		}
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"renewals": renewalRecord},
 	}
-
-	if _, err = col.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": updateFields}); err != nil {
-		return nil, err
+	if p.AutoRenew != nil {
+		update["$unset"] = bson.M{"auto_renew.grace_from": "", "auto_renew.grace_ends_at": ""}
 	}
-	return &p, nil
-}
 
-func restoreBenefit(passID, benefitType string) (*models.TicpinPass, error) {
-	objID, err := primitive.ObjectIDFromHex(passID)
+	// The payment filter keeps a redelivered webhook from renewing twice.
+	filter := bson.M{"_id": objID}
+	if paymentID != "" {
+		filter["renewals.payment_id"] = bson.M{"$ne": paymentID}
+	}
+	res, err := col.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return nil, err
 	}
-
-	col := config.GetDB().Collection("ticpin_passes")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	var p models.TicpinPass
-	now := time.Now()
-	if err := col.FindOne(ctx, bson.M{
-		"_id":      objID,
-		"status":   "active",
-		"end_date": bson.M{"$gt": now},
-	}).Decode(&p); err != nil {
-		return nil, errors.New("active, unexpired pass not found")
+	if res.MatchedCount == 0 {
+		return &p, nil
 	}
 
-	var updateFields bson.M
-	if benefitType == "turf" {
-		// Only restore if we have remaining capacity (prevent going above max)
-		if p.Benefits.TurfBookings.Remaining >= p.Benefits.TurfBookings.Total {
-			return &p, nil // Already at max, nothing to restore
-		}
-		if p.Benefits.TurfBookings.Used > 0 {
-			p.Benefits.TurfBookings.Used--
-			p.Benefits.TurfBookings.Remaining++
-		}
-		updateFields = bson.M{
-			"benefits.turf_bookings.used":      p.Benefits.TurfBookings.Used,
-			"benefits.turf_bookings.remaining": p.Benefits.TurfBookings.Remaining,
-			"updatedAt":                        time.Now(),
-		}
-	} else {
-		// Only restore if we have remaining capacity
-		if p.Benefits.DiningVouchers.Remaining >= p.Benefits.DiningVouchers.Total {
-			return &p, nil // Already at max, nothing to restore
-		}
-		if p.Benefits.DiningVouchers.Used > 0 {
-			p.Benefits.DiningVouchers.Used--
-			p.Benefits.DiningVouchers.Remaining++
-		}
-		updateFields = bson.M{
-			"benefits.dining_vouchers.used":      p.Benefits.DiningVouchers.Used,
-			"benefits.dining_vouchers.remaining": p.Benefits.DiningVouchers.Remaining,
-			"updatedAt":                          time.Now(),
-		}
+	p.Status = "active"
+	p.StartDate = newStart
+	p.EndDate = newEnd
+	p.Benefits = benefits
+	p.Plan = &plan
+	if !plan.ID.IsZero() {
+		p.PlanID = plan.ID
 	}
-
-	if _, err = col.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": updateFields}); err != nil {
-		return nil, err
+	p.PaymentID = paymentID
+	p.Renewals = append(p.Renewals, renewalRecord)
+	if p.AutoRenew != nil {
+		p.AutoRenew.GraceFrom = nil
+		p.AutoRenew.GraceEndsAt = nil
+		if subscriptionID != "" {
+			p.AutoRenew.Status = "active"
+			p.AutoRenew.LastChargedAt = &now
+		}
 	}
 	return &p, nil
 }
@@ -0,0 +1,321 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// legacyPlan mirrors the terms every pass was sold under before plans were
+// configurable. It backs passes without a stored plan and is used when no
+// default plan has been set up.
+var legacyPlan = models.PassPlan{
+	Code:                  "standard",
+	Name:                  "Ticpass",
+	Price:                 1.0,
+	DurationMonths:        3,
+	TurfBookings:          2,
+	DiningVouchers:        2,
+	DiningVoucherValue:    250,
+	EventsDiscountPercent: 10,
+	PlayDiscountPercent:   10,
+	IsDefault:             true,
+	Active:                true,
+}
+
+func validatePlan(p *models.PassPlan) error {
+	p.Code = strings.ToLower(strings.TrimSpace(p.Code))
+	if p.Code == "" || p.Name == "" {
+		return errors.New("code and name are required")
+	}
+	if p.Price < 0 {
+		return errors.New("price cannot be negative")
+	}
+	if p.DurationMonths <= 0 {
+		return errors.New("duration_months must be positive")
+	}
+	if p.TurfBookings < 0 || p.DiningVouchers < 0 || p.DiningVoucherValue < 0 {
+		return errors.New("benefit counts cannot be negative")
+	}
+	if p.EventsDiscountPercent < 0 || p.EventsDiscountPercent > 100 || p.PlayDiscountPercent < 0 || p.PlayDiscountPercent > 100 {
+		return errors.New("discount percentages must be between 0 and 100")
+	}
+	for _, v := range p.EligibleVerticals {
+		if v != "events" && v != "play" && v != "dining" {
+			return errors.New("eligible verticals must be events, play or dining")
+		}
+	}
+	return nil
+}
+
+// clearOtherDefaults keeps at most one default plan.
+func clearOtherDefaults(ctx context.Context, id primitive.ObjectID) error {
+	_, err := config.PassPlansCol.UpdateMany(ctx, bson.M{"_id": bson.M{"$ne": id}, "is_default": true}, bson.M{
+		"$set": bson.M{"is_default": false, "updatedAt": time.Now()},
+	})
+	return err
+}
+
+func ListPlans(activeOnly bool) ([]models.PassPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if activeOnly {
+		filter["active"] = true
+	}
+	cursor, err := config.PassPlansCol.Find(ctx, filter, options.Find().SetSort(bson.M{"price": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	plans := []models.PassPlan{}
+	if err := cursor.All(ctx, &plans); err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+func CreatePlan(p *models.PassPlan) error {
+	if err := validatePlan(p); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p.ID = primitive.NewObjectID()
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	if _, err := config.PassPlansCol.InsertOne(ctx, p); err != nil {
+		if config.IsDuplicateKeyError(err) {
+			return errors.New("a plan with this code already exists")
+		}
+		return err
+	}
+	if p.IsDefault {
+		return clearOtherDefaults(ctx, p.ID)
+	}
+	return nil
+}
+
+func UpdatePlan(id string, p *models.PassPlan) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid plan id")
+	}
+	if err := validatePlan(p); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p.ID = objID
+	p.UpdatedAt = time.Now()
+	// Edits may change the price or term, so the next auto-renew sign-up
+	// gets a fresh Razorpay plan. Existing subscriptions keep theirs.
+	res, err := config.PassPlansCol.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$unset": bson.M{"razorpay_plan_id": ""}, "$set": bson.M{
+		"code":                    p.Code,
+		"name":                    p.Name,
+		"description":             p.Description,
+		"price":                   p.Price,
+		"duration_months":         p.DurationMonths,
+		"turf_bookings":           p.TurfBookings,
+		"dining_vouchers":         p.DiningVouchers,
+		"dining_voucher_value":    p.DiningVoucherValue,
+		"events_discount_percent": p.EventsDiscountPercent,
+		"events_discount_cap":     p.EventsDiscountCap,
+		"play_discount_percent":   p.PlayDiscountPercent,
+		"play_discount_cap":       p.PlayDiscountCap,
+		"eligible_cities":         p.EligibleCities,
+		"eligible_verticals":      p.EligibleVerticals,
+		"is_default":              p.IsDefault,
+		"active":                  p.Active,
+		"updatedAt":               p.UpdatedAt,
+	}})
+	if err != nil {
+		if config.IsDuplicateKeyError(err) {
+			return errors.New("a plan with this code already exists")
+		}
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("plan not found")
+	}
+	if p.IsDefault {
+		return clearOtherDefaults(ctx, objID)
+	}
+	return nil
+}
+
+// DeactivatePlan takes a plan off sale and stops it being the default. The
+// plan itself is kept: passes sold under it hold their own copy of its
+// terms, and auto-renew subscriptions already on it keep renewing.
+func DeactivatePlan(id string) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid plan id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := config.PassPlansCol.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{
+		"active":     false,
+		"is_default": false,
+		"updatedAt":  time.Now(),
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("plan not found")
+	}
+	return nil
+}
+
+// ResolvePlan returns the active plan with the given ID, or the default plan
+// when planID is empty.
+func ResolvePlan(planID string) (*models.PassPlan, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p models.PassPlan
+	if planID != "" {
+		objID, err := primitive.ObjectIDFromHex(planID)
+		if err != nil {
+			return nil, errors.New("invalid plan id")
+		}
+		if err := config.PassPlansCol.FindOne(ctx, bson.M{"_id": objID, "active": true}).Decode(&p); err != nil {
+			return nil, errors.New("plan not found or no longer available")
+		}
+		return &p, nil
+	}
+
+	if err := config.PassPlansCol.FindOne(ctx, bson.M{"is_default": true, "active": true}).Decode(&p); err == nil {
+		return &p, nil
+	}
+	fallback := legacyPlan
+	return &fallback, nil
+}
+
+// PaidPlan returns the plan a pass order was placed for, as ResolvePlan
+// does, and refuses it when paid, the amount the gateway collected, falls
+// short of its price.
+func PaidPlan(planID string, paid float64) (*models.PassPlan, error) {
+	plan, err := ResolvePlan(planID)
+	if err != nil {
+		return nil, err
+	}
+	if paid < plan.Price-0.01 {
+		return nil, fmt.Errorf("amount paid %.2f does not cover the plan price %.2f", paid, plan.Price)
+	}
+	return plan, nil
+}
+
+// PlanFor returns the plan a pass was sold under.
+func PlanFor(p *models.TicpinPass) models.PassPlan {
+	if p != nil && p.Plan != nil {
+		return *p.Plan
+	}
+	return legacyPlan
+}
+
+func benefitsFromPlan(plan *models.PassPlan) models.PassBenefits {
+	return models.PassBenefits{
+		TurfBookings: models.BenefitCounter{
+			Total:     plan.TurfBookings,
+			Remaining: plan.TurfBookings,
+		},
+		DiningVouchers: models.DiningVoucherBenefit{
+			Total:     plan.DiningVouchers,
+			Remaining: plan.DiningVouchers,
+			ValueEach: plan.DiningVoucherValue,
+		},
+		EventsDiscountActive: plan.EventsDiscountPercent > 0,
+	}
+}
+
+// NewPassFromPlan builds an active pass for the user starting now. A
+// non-zero months overrides the plan's duration.
+func NewPassFromPlan(plan *models.PassPlan, userID primitive.ObjectID, months int) models.TicpinPass {
+	if months <= 0 {
+		months = plan.DurationMonths
+	}
+	now := time.Now()
+	snapshot := *plan
+	return models.TicpinPass{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		PlanID:    plan.ID,
+		Plan:      &snapshot,
+		QRToken:   primitive.NewObjectID().Hex(),
+		Price:     plan.Price,
+		Status:    "active",
+		StartDate: now,
+		EndDate:   now.AddDate(0, months, 0),
+		Benefits:  benefitsFromPlan(plan),
+		Renewals:  []models.RenewalRecord{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Covers reports whether the pass's plan applies to a vertical in a city.
+// Empty eligibility lists mean everywhere.
+func Covers(p *models.TicpinPass, vertical, city string) bool {
+	plan := PlanFor(p)
+	if len(plan.EligibleVerticals) > 0 {
+		found := false
+		for _, v := range plan.EligibleVerticals {
+			if v == vertical {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(plan.EligibleCities) > 0 && city != "" {
+		for _, c := range plan.EligibleCities {
+			if strings.EqualFold(c, city) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func percentOf(amount, percent, cap float64) float64 {
+	discount := math.Round(amount*percent) / 100
+	if cap > 0 && discount > cap {
+		discount = cap
+	}
+	return discount
+}
+
+// EventDiscount is the Ticpass discount on an event order subtotal.
+func EventDiscount(p *models.TicpinPass, amount float64) float64 {
+	if !p.Benefits.EventsDiscountActive {
+		return 0
+	}
+	plan := PlanFor(p)
+	return percentOf(amount, plan.EventsDiscountPercent, plan.EventsDiscountCap)
+}
+
+// PlayDiscount is the Ticpass discount on a turf order once the pass's free
+// bookings are used up.
+func PlayDiscount(p *models.TicpinPass, amount float64) float64 {
+	plan := PlanFor(p)
+	return percentOf(amount, plan.PlayDiscountPercent, plan.PlayDiscountCap)
+}
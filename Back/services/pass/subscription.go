@@ -0,0 +1,391 @@
+package pass
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	paymentsvc "ticpin-backend/services/payment"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// autoRenewCycles is how many terms a subscription may charge before the
+// user has to set up auto-renew again.
+const autoRenewCycles = 20
+
+func envDays(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// graceDays is how long a pass stays usable after an automatic renewal
+// fails, set by PASS_GRACE_DAYS.
+func graceDays() int { return envDays("PASS_GRACE_DAYS", 7) }
+
+// reminderDays is how far ahead of expiry the renewal reminder goes out, set
+// by PASS_REMINDER_DAYS.
+func reminderDays() int { return envDays("PASS_REMINDER_DAYS", 3) }
+
+func loadOwnedPass(ctx context.Context, passID, userID, phone string) (*models.TicpinPass, error) {
+	objID, err := primitive.ObjectIDFromHex(passID)
+	if err != nil {
+		return nil, errors.New("invalid pass id")
+	}
+	var p models.TicpinPass
+	if err := config.PassesCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&p); err != nil {
+		return nil, errors.New("pass not found")
+	}
+	if p.UserID.Hex() != userID && (phone == "" || p.Phone != phone) {
+		return nil, errors.New("access denied: you do not own this pass")
+	}
+	return &p, nil
+}
+
+// ensureRazorpayPlan returns the Razorpay plan that bills this pass plan,
+// creating it the first time a plan is put on auto-renew.
+func ensureRazorpayPlan(ctx context.Context, plan *models.PassPlan) (string, error) {
+	if plan.RazorpayPlanID != "" {
+		return plan.RazorpayPlanID, nil
+	}
+	id, err := paymentsvc.CreateRazorpayPlan(plan.Name, plan.Price, plan.DurationMonths)
+	if err != nil {
+		return "", err
+	}
+	if !plan.ID.IsZero() {
+		if _, err := config.PassPlansCol.UpdateOne(ctx, bson.M{"_id": plan.ID}, bson.M{"$set": bson.M{"razorpay_plan_id": id}}); err != nil {
+			fmt.Printf("DEBUG: Failed to store Razorpay plan on pass plan %s: %v\n", plan.ID.Hex(), err)
+		}
+	}
+	plan.RazorpayPlanID = id
+	return id, nil
+}
+
+// EnableAutoRenew creates a Razorpay subscription whose first charge falls
+// on the pass's expiry. The returned link lets the user authorise the
+// recurring mandate.
+func EnableAutoRenew(passID, userID, phone string) (*models.TicpinPass, *paymentsvc.SubscriptionResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	p, err := loadOwnedPass(ctx, passID, userID, phone)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.Status != "active" {
+		return nil, nil, errors.New("only active passes can be set to auto-renew")
+	}
+	if p.AutoRenew != nil && p.AutoRenew.Status != "cancelled" {
+		if p.AutoRenew.Status == "created" {
+			// Mandate not authorised yet; hand back the same link.
+			return p, &paymentsvc.SubscriptionResponse{
+				SubscriptionID: p.AutoRenew.SubscriptionID,
+				ShortURL:       p.AutoRenew.ShortURL,
+				Status:         p.AutoRenew.Status,
+				RazorpayKey:    os.Getenv("NEXT_PUBLIC_RAZORPAY_KEY_ID"),
+			}, nil
+		}
+		return nil, nil, errors.New("auto-renew is already set up for this pass")
+	}
+
+	plan := PlanFor(p)
+	if !p.PlanID.IsZero() {
+		if current, err := ResolvePlan(p.PlanID.Hex()); err == nil {
+			plan = *current
+		}
+	}
+	if plan.Price <= 0 {
+		return nil, nil, errors.New("this plan cannot be renewed automatically")
+	}
+	rzpPlanID, err := ensureRazorpayPlan(ctx, &plan)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to create Razorpay plan for pass %s: %v\n", passID, err)
+		return nil, nil, errors.New("failed to set up auto-renew")
+	}
+
+	var startAt int64
+	if p.EndDate.After(time.Now()) {
+		startAt = p.EndDate.Unix()
+	}
+	sub, err := paymentsvc.CreateRazorpaySubscription(rzpPlanID, autoRenewCycles, startAt, map[string]string{
+		"booking_type": "pass_subscription",
+		"pass_id":      p.ID.Hex(),
+		"user_id":      p.UserID.Hex(),
+	})
+	if err != nil {
+		fmt.Printf("ERROR: Failed to create Razorpay subscription for pass %s: %v\n", passID, err)
+		return nil, nil, errors.New("failed to set up auto-renew")
+	}
+
+	now := time.Now()
+	p.AutoRenew = &models.PassAutoRenew{
+		SubscriptionID: sub.SubscriptionID,
+		RazorpayPlanID: rzpPlanID,
+		Plan:           &plan,
+		Status:         "created",
+		ShortURL:       sub.ShortURL,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if _, err := config.PassesCol.UpdateOne(ctx, bson.M{"_id": p.ID}, bson.M{"$set": bson.M{
+		"auto_renew": p.AutoRenew,
+		"updatedAt":  now,
+	}}); err != nil {
+		return nil, nil, err
+	}
+	return p, sub, nil
+}
+
+func setAutoRenewStatus(ctx context.Context, passID primitive.ObjectID, status string) error {
+	now := time.Now()
+	_, err := config.PassesCol.UpdateOne(ctx, bson.M{"_id": passID}, bson.M{"$set": bson.M{
+		"auto_renew.status":     status,
+		"auto_renew.updated_at": now,
+		"updatedAt":             now,
+	}})
+	return err
+}
+
+func PauseAutoRenew(passID, userID, phone string) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	p, err := loadOwnedPass(ctx, passID, userID, phone)
+	if err != nil {
+		return nil, err
+	}
+	if p.AutoRenew == nil || p.AutoRenew.Status != "active" {
+		return nil, errors.New("auto-renew is not active for this pass")
+	}
+	if err := paymentsvc.PauseRazorpaySubscription(p.AutoRenew.SubscriptionID); err != nil {
+		fmt.Printf("ERROR: Failed to pause subscription %s: %v\n", p.AutoRenew.SubscriptionID, err)
+		return nil, errors.New("failed to pause auto-renew")
+	}
+	if err := setAutoRenewStatus(ctx, p.ID, "paused"); err != nil {
+		return nil, err
+	}
+	p.AutoRenew.Status = "paused"
+	return p, nil
+}
+
+func ResumeAutoRenew(passID, userID, phone string) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	p, err := loadOwnedPass(ctx, passID, userID, phone)
+	if err != nil {
+		return nil, err
+	}
+	if p.AutoRenew == nil || p.AutoRenew.Status != "paused" {
+		return nil, errors.New("auto-renew is not paused for this pass")
+	}
+	if err := paymentsvc.ResumeRazorpaySubscription(p.AutoRenew.SubscriptionID); err != nil {
+		fmt.Printf("ERROR: Failed to resume subscription %s: %v\n", p.AutoRenew.SubscriptionID, err)
+		return nil, errors.New("failed to resume auto-renew")
+	}
+	if err := setAutoRenewStatus(ctx, p.ID, "active"); err != nil {
+		return nil, err
+	}
+	p.AutoRenew.Status = "active"
+	return p, nil
+}
+
+// CancelAutoRenew stops future charges. The pass itself stays valid until
+// its current end date.
+func CancelAutoRenew(passID, userID, phone string) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	p, err := loadOwnedPass(ctx, passID, userID, phone)
+	if err != nil {
+		return nil, err
+	}
+	if p.AutoRenew == nil || p.AutoRenew.Status == "cancelled" {
+		return nil, errors.New("auto-renew is not set up for this pass")
+	}
+	if err := paymentsvc.CancelRazorpaySubscription(p.AutoRenew.SubscriptionID, false); err != nil {
+		fmt.Printf("ERROR: Failed to cancel subscription %s: %v\n", p.AutoRenew.SubscriptionID, err)
+		return nil, errors.New("failed to cancel auto-renew")
+	}
+	if err := setAutoRenewStatus(ctx, p.ID, "cancelled"); err != nil {
+		return nil, err
+	}
+	p.AutoRenew.Status = "cancelled"
+	return p, nil
+}
+
+func passBySubscription(ctx context.Context, subscriptionID string) (*models.TicpinPass, error) {
+	var p models.TicpinPass
+	if err := config.PassesCol.FindOne(ctx, bson.M{"auto_renew.subscription_id": subscriptionID}).Decode(&p); err != nil {
+		return nil, errors.New("no pass for subscription")
+	}
+	return &p, nil
+}
+
+// HandleSubscriptionCharged renews the pass for one more term after a
+// successful subscription.charged webhook, on the terms the subscription
+// was set up with, and records the amount Razorpay charged.
+func HandleSubscriptionCharged(subscriptionID, paymentID string, amount float64) (*models.TicpinPass, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p, err := passBySubscription(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	// Subscriptions set up before their terms were stored renew on the plan
+	return renew(p.ID.Hex(), models.RenewalRecord{PaymentID: paymentID, SubscriptionID: subscriptionID, Price: amount}, p.AutoRenew.Plan)
+}
+
+// HandleSubscriptionHalted marks auto-renew as halted once Razorpay gives up
+// retrying the charge, starts the grace period and tells the user.
+func HandleSubscriptionHalted(subscriptionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	p, err := passBySubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if err := setAutoRenewStatus(ctx, p.ID, "halted"); err != nil {
+		return err
+	}
+	graceEnd, err := startGrace(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	plan := PlanFor(p)
+	if email := passHolderEmail(ctx, p); email != "" {
+		if err := config.SendPassRenewalFailedEmail(email, plan.Name, graceEnd.Format("02 Jan 2006")); err != nil {
+			fmt.Printf("DEBUG: Failed to send renewal failure email for pass %s: %v\n", p.ID.Hex(), err)
+		}
+	}
+	return nil
+}
+
+// SyncSubscriptionStatus mirrors other subscription lifecycle events onto
+// the pass.
+func SyncSubscriptionStatus(subscriptionID, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	p, err := passBySubscription(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	return setAutoRenewStatus(ctx, p.ID, status)
+}
+
+// startGrace keeps the pass usable for graceDays past its expiry while the
+// renewal is outstanding. It returns the end of the grace period.
+func startGrace(ctx context.Context, p *models.TicpinPass) (time.Time, error) {
+	if p.AutoRenew != nil && p.AutoRenew.GraceEndsAt != nil {
+		return *p.AutoRenew.GraceEndsAt, nil
+	}
+	now := time.Now()
+	from := p.EndDate
+	if from.After(now) {
+		// Not expired yet: the grace runs from the original end date.
+		now = from
+	}
+	graceEnd := now.AddDate(0, 0, graceDays())
+	_, err := config.PassesCol.UpdateOne(ctx, bson.M{"_id": p.ID, "auto_renew.grace_from": bson.M{"$exists": false}}, bson.M{"$set": bson.M{
+		"status":                   "active",
+		"end_date":                 graceEnd,
+		"auto_renew.grace_from":    from,
+		"auto_renew.grace_ends_at": graceEnd,
+		"updatedAt":                time.Now(),
+	}})
+	return graceEnd, err
+}
+
+func passHolderEmail(ctx context.Context, p *models.TicpinPass) string {
+	var profile models.Profile
+	if err := config.ProfilesCol.FindOne(ctx, bson.M{"userId": p.UserID}).Decode(&profile); err != nil {
+		return ""
+	}
+	return profile.Email
+}
+
+// sendRenewalReminders emails holders whose pass expires within
+// reminderDays, once per expiry date.
+func sendRenewalReminders(ctx context.Context) {
+	now := time.Now()
+	cursor, err := config.PassesCol.Find(ctx, bson.M{
+		"status":                "active",
+		"end_date":              bson.M{"$gt": now, "$lte": now.AddDate(0, 0, reminderDays())},
+		"auto_renew.grace_from": bson.M{"$exists": false},
+	})
+	if err != nil {
+		fmt.Printf("DEBUG: Failed to load passes for renewal reminders: %v\n", err)
+		return
+	}
+	var passes []models.TicpinPass
+	if err := cursor.All(ctx, &passes); err != nil {
+		return
+	}
+
+	for i := range passes {
+		p := &passes[i]
+		if p.ReminderSentFor != nil && p.ReminderSentFor.Equal(p.EndDate) {
+			continue
+		}
+		if email := passHolderEmail(ctx, p); email != "" {
+			plan := PlanFor(p)
+			autoRenew := p.AutoRenew != nil && p.AutoRenew.Status == "active"
+			if autoRenew && p.AutoRenew.Plan != nil {
+				plan = *p.AutoRenew.Plan
+			}
+			if err := config.SendPassRenewalReminderEmail(email, plan.Name, p.EndDate.Format("02 Jan 2006"), plan.Price, autoRenew); err != nil {
+				fmt.Printf("DEBUG: Failed to send renewal reminder for pass %s: %v\n", p.ID.Hex(), err)
+				continue
+			}
+		}
+		config.PassesCol.UpdateOne(ctx, bson.M{"_id": p.ID}, bson.M{"$set": bson.M{"reminder_sent_for": p.EndDate}})
+	}
+}
+
+// graceOverduePasses covers auto-renewing passes whose charge has not come
+// through by the expiry date, so benefits continue while Razorpay retries.
+func graceOverduePasses(ctx context.Context) {
+	cursor, err := config.PassesCol.Find(ctx, bson.M{
+		"status":                "active",
+		"end_date":              bson.M{"$lte": time.Now()},
+		"auto_renew.status":     "active",
+		"auto_renew.grace_from": bson.M{"$exists": false},
+	})
+	if err != nil {
+		fmt.Printf("DEBUG: Failed to load overdue auto-renew passes: %v\n", err)
+		return
+	}
+	var passes []models.TicpinPass
+	if err := cursor.All(ctx, &passes); err != nil {
+		return
+	}
+	for i := range passes {
+		if _, err := startGrace(ctx, &passes[i]); err != nil {
+			fmt.Printf("DEBUG: Failed to start grace period for pass %s: %v\n", passes[i].ID.Hex(), err)
+		}
+	}
+}
+
+// ProcessRenewals sends expiry reminders, starts grace periods for late
+// automatic renewals and expires passes that have run out.
+func ProcessRenewals() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	sendRenewalReminders(ctx)
+	graceOverduePasses(ctx)
+	if err := ExpireOld(); err != nil {
+		fmt.Printf("DEBUG: Failed to expire old passes: %v\n", err)
+	}
+}
@@ -0,0 +1,135 @@
+package payment
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// ======================= RAZORPAY SUBSCRIPTIONS =======================
+
+// SubscriptionResponse is what the client needs to authorise the recurring
+// mandate for a new subscription.
+type SubscriptionResponse struct {
+	SubscriptionID string `json:"subscription_id"`
+	ShortURL       string `json:"short_url"`
+	Status         string `json:"status"`
+	RazorpayKey    string `json:"razorpay_key"`
+}
+
+func razorpaySubscriptionCall(method, url string, payload map[string]interface{}) (map[string]interface{}, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		body, _ := json.Marshal(payload)
+		reqBody = bytes.NewBuffer(body)
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(os.Getenv("NEXT_PUBLIC_RAZORPAY_KEY_ID"), os.Getenv("RAZORPAY_KEY_SECRET"))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("razorpay request failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("razorpay response parse error: %s", string(respBody))
+	}
+	return result, nil
+}
+
+// CreateRazorpayPlan registers a recurring billing plan charging amount
+// every intervalMonths months.
+func CreateRazorpayPlan(name string, amount float64, intervalMonths int) (string, error) {
+	result, err := razorpaySubscriptionCall("POST", "https://api.razorpay.com/v1/plans", map[string]interface{}{
+		"period":   "monthly",
+		"interval": intervalMonths,
+		"item": map[string]interface{}{
+			"name":     name,
+			"amount":   int64(amount * 100), // paise
+			"currency": "INR",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	id, _ := result["id"].(string)
+	if id == "" {
+		return "", fmt.Errorf("razorpay plan creation failed")
+	}
+	return id, nil
+}
+
+// CreateRazorpaySubscription starts a subscription on a plan. startAt is a
+// unix timestamp for the first charge; zero charges immediately.
+func CreateRazorpaySubscription(planID string, totalCount int, startAt int64, notes map[string]string) (*SubscriptionResponse, error) {
+	payload := map[string]interface{}{
+		"plan_id":         planID,
+		"total_count":     totalCount,
+		"customer_notify": 1,
+	}
+	if startAt > 0 {
+		payload["start_at"] = startAt
+	}
+	if len(notes) > 0 {
+		payload["notes"] = notes
+	}
+
+	result, err := razorpaySubscriptionCall("POST", "https://api.razorpay.com/v1/subscriptions", payload)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result["id"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("razorpay subscription creation failed")
+	}
+	shortURL, _ := result["short_url"].(string)
+	status, _ := result["status"].(string)
+	return &SubscriptionResponse{
+		SubscriptionID: id,
+		ShortURL:       shortURL,
+		Status:         status,
+		RazorpayKey:    os.Getenv("NEXT_PUBLIC_RAZORPAY_KEY_ID"),
+	}, nil
+}
+
+func PauseRazorpaySubscription(subscriptionID string) error {
+	_, err := razorpaySubscriptionCall("POST", fmt.Sprintf("https://api.razorpay.com/v1/subscriptions/%s/pause", subscriptionID), map[string]interface{}{
+		"pause_at": "now",
+	})
+	return err
+}
+
+func ResumeRazorpaySubscription(subscriptionID string) error {
+	_, err := razorpaySubscriptionCall("POST", fmt.Sprintf("https://api.razorpay.com/v1/subscriptions/%s/resume", subscriptionID), map[string]interface{}{
+		"resume_at": "now",
+	})
+	return err
+}
+
+// CancelRazorpaySubscription stops future charges. With atCycleEnd the
+// current cycle is left to run out.
+func CancelRazorpaySubscription(subscriptionID string, atCycleEnd bool) error {
+	cancelAt := 0
+	if atCycleEnd {
+		cancelAt = 1
+	}
+	_, err := razorpaySubscriptionCall("POST", fmt.Sprintf("https://api.razorpay.com/v1/subscriptions/%s/cancel", subscriptionID), map[string]interface{}{
+		"cancel_at_cycle_end": cancelAt,
+	})
+	return err
+}
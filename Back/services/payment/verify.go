@@ -0,0 +1,129 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+func razorpayGet(url string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(os.Getenv("NEXT_PUBLIC_RAZORPAY_KEY_ID"), os.Getenv("RAZORPAY_KEY_SECRET"))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("razorpay request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// CapturedAmount returns the rupees the gateway captured for a payment on
+// the given order, less anything already refunded. A payment that was not
+// captured, or belongs to another order, is an error.
+func CapturedAmount(gateway, orderID, paymentID string) (float64, error) {
+	if paymentID == "" {
+		return 0, fmt.Errorf("payment id missing")
+	}
+
+	if GatewayType(gateway) == GatewayCashfree {
+		baseURL := os.Getenv("CASHFREE_PAYMENT_URL")
+		if baseURL == "" {
+			baseURL = "https://api.cashfree.com/pg"
+		}
+		req, err := http.NewRequest("GET", fmt.Sprintf("%s/orders/%s/payments/%s", baseURL, orderID, paymentID), nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Add("x-client-id", os.Getenv("CASHFREE_CLIENT_ID"))
+		req.Header.Add("x-client-secret", os.Getenv("CASHFREE_CLIENT_SECRET"))
+		req.Header.Add("x-api-version", "2023-08-01")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != 200 {
+			return 0, fmt.Errorf("cashfree payment lookup failed: %s", string(body))
+		}
+		var p struct {
+			Status string  `json:"payment_status"`
+			Amount float64 `json:"payment_amount"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return 0, err
+		}
+		if p.Status != "SUCCESS" {
+			return 0, fmt.Errorf("payment %s is %s", paymentID, p.Status)
+		}
+		return p.Amount, nil
+	}
+
+	var p struct {
+		OrderID        string `json:"order_id"`
+		Status         string `json:"status"`
+		Amount         int64  `json:"amount"`
+		AmountRefunded int64  `json:"amount_refunded"`
+	}
+	if err := razorpayGet("https://api.razorpay.com/v1/payments/"+paymentID, &p); err != nil {
+		return 0, err
+	}
+	if orderID != "" && p.OrderID != orderID {
+		return 0, fmt.Errorf("payment %s does not belong to order %s", paymentID, orderID)
+	}
+	if p.Status != "captured" && p.Status != "refunded" {
+		return 0, fmt.Errorf("payment %s is %s", paymentID, p.Status)
+	}
+	return float64(p.Amount-p.AmountRefunded) / 100, nil
+}
+
+// RazorpayOrder is an order as Razorpay holds it. Amounts are in rupees;
+// Notes are the ones set when the order was created.
+type RazorpayOrder struct {
+	ID         string
+	Amount     float64
+	AmountPaid float64
+	Status     string
+	Notes      map[string]string
+}
+
+// FetchRazorpayOrder loads an order from Razorpay, so that what was paid for
+// comes from the gateway rather than the client.
+func FetchRazorpayOrder(orderID string) (*RazorpayOrder, error) {
+	if orderID == "" {
+		return nil, fmt.Errorf("order id missing")
+	}
+	var o struct {
+		ID         string          `json:"id"`
+		Amount     int64           `json:"amount"`
+		AmountPaid int64           `json:"amount_paid"`
+		Status     string          `json:"status"`
+		Notes      json.RawMessage `json:"notes"`
+	}
+	if err := razorpayGet("https://api.razorpay.com/v1/orders/"+orderID, &o); err != nil {
+		return nil, err
+	}
+	// Razorpay sends an empty array when an order has no notes
+	notes := map[string]string{}
+	_ = json.Unmarshal(o.Notes, &notes)
+	return &RazorpayOrder{
+		ID:         o.ID,
+		Amount:     float64(o.Amount) / 100,
+		AmountPaid: float64(o.AmountPaid) / 100,
+		Status:     o.Status,
+		Notes:      notes,
+	}, nil
+}
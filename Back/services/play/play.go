@@ -46,7 +46,7 @@ func GetAll(category string, status string, limit int, after string) ([]models.P
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{}
+	filter := bson.M{"deleted_at": bson.M{"$exists": false}}
 	if category != "" {
 		filter["category"] = category
 	}
@@ -110,7 +110,7 @@ func GetByID(id string, bypassCache bool) (*models.Play, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var p models.Play
-	if err := col.FindOne(ctx, bson.M{"_id": objID}).Decode(&p); err != nil {
+	if err := col.FindOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}}).Decode(&p); err != nil {
 		return nil, err
 	}
 
@@ -124,7 +124,7 @@ func GetByName(name string) (*models.Play, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var p models.Play
-	if err := col.FindOne(ctx, bson.M{"name": name}).Decode(&p); err != nil {
+	if err := col.FindOne(ctx, bson.M{"name": name, "deleted_at": bson.M{"$exists": false}}).Decode(&p); err != nil {
 		return nil, err
 	}
 	return &p, nil
@@ -138,7 +138,7 @@ func GetByOrganizer(organizerID string) ([]models.Play, error) {
 	col := config.PlaysCol
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	cursor, err := col.Find(ctx, bson.M{"organizer_id": objID})
+	cursor, err := col.Find(ctx, bson.M{"organizer_id": objID, "deleted_at": bson.M{"$exists": false}})
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +163,7 @@ func Update(id string, organizerID string, update *models.Play) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	var original models.Play
-	if err := col.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID}).Decode(&original); err != nil {
+	if err := col.FindOne(ctx, bson.M{"_id": objID, "organizer_id": orgID, "deleted_at": bson.M{"$exists": false}}).Decode(&original); err != nil {
 		return errors.New("play not found or not owned by this organizer")
 	}
 
@@ -292,11 +292,13 @@ func Delete(id string, organizerID string) error {
 	col := config.PlaysCol
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	res, err := col.DeleteOne(ctx, bson.M{"_id": objID, "organizer_id": orgID})
+	res, err := col.UpdateOne(ctx, bson.M{"_id": objID, "organizer_id": orgID, "deleted_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"deleted_at": time.Now(), "deleted_by": organizerID},
+	})
 	if err != nil {
 		return err
 	}
-	if res.DeletedCount == 0 {
+	if res.MatchedCount == 0 {
 		return errors.New("play not found or not owned by this organizer")
 	}
 
@@ -0,0 +1,173 @@
+package redemption
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Ledger runs the reservation lifecycle shared by coupon and offer
+// redemptions: a use is reserved at checkout, attached to its booking,
+// committed when the payment lands and released when the payment fails,
+// the reservation expires or the booking is cancelled.
+type Ledger struct {
+	Col *mongo.Collection
+	// Parent is the field holding what was redeemed, e.g. "coupon_id".
+	Parent string
+	// OnChange, when set, runs after a use is committed or a committed use
+	// is released, e.g. to refresh a cached used count.
+	OnChange func(ctx context.Context, parentID primitive.ObjectID)
+}
+
+type entry struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Status        string             `bson:"status"`
+	ReleaseReason string             `bson:"release_reason"`
+	Raw           bson.Raw           `bson:"-"`
+}
+
+func (l *Ledger) find(ctx context.Context, filter bson.M) (*entry, error) {
+	raw, err := l.Col.FindOne(ctx, filter).Raw()
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := bson.Unmarshal(raw, &e); err != nil {
+		return nil, err
+	}
+	e.Raw = raw
+	return &e, nil
+}
+
+func (l *Ledger) changed(ctx context.Context, e *entry) {
+	if l.OnChange == nil || l.Parent == "" {
+		return
+	}
+	if id, ok := e.Raw.Lookup(l.Parent).ObjectIDOK(); ok {
+		l.OnChange(ctx, id)
+	}
+}
+
+// RefFilter matches the redemption for a booking or payment order.
+func RefFilter(ref string) bson.M {
+	return bson.M{"$or": []bson.M{{"booking_id": ref}, {"order_id": ref}}}
+}
+
+// Ahead counts the redemptions matching filter that were made up to id, so
+// the earlier checkout wins a race for the last use.
+func (l *Ledger) Ahead(ctx context.Context, filter bson.M, id primitive.ObjectID) (int64, error) {
+	return l.Col.CountDocuments(ctx, bson.M{"$and": []bson.M{filter, {"_id": bson.M{"$lte": id}}}})
+}
+
+// AttachBooking records the booking a reservation was made for once the
+// booking exists.
+func (l *Ledger) AttachBooking(id primitive.ObjectID, bookingID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := l.Col.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"booking_id": bookingID}})
+	return err
+}
+
+// Commit marks the use for a booking or payment order as used. A payment
+// that lands after the reservation expired, or that succeeds on a retry of
+// the same order after a failed attempt, is still honoured.
+func (l *Ledger) Commit(ref string) error {
+	if ref == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, err := l.find(ctx, bson.M{"$and": []bson.M{
+		RefFilter(ref),
+		{"$or": []bson.M{
+			{"status": "reserved"},
+			{"status": "released", "release_reason": bson.M{"$in": []string{"expired", "payment_failed"}}},
+		}},
+	}})
+	if err != nil {
+		return nil
+	}
+	claim := bson.M{"_id": e.ID, "status": e.Status}
+	if e.Status == "released" {
+		claim["release_reason"] = e.ReleaseReason
+	}
+	now := time.Now()
+	res, err := l.Col.UpdateOne(ctx, claim, bson.M{
+		"$set":   bson.M{"status": "committed", "committed_at": now},
+		"$unset": bson.M{"release_reason": "", "released_at": ""},
+	})
+	if err != nil || res.ModifiedCount == 0 {
+		return err
+	}
+	l.changed(ctx, e)
+	return nil
+}
+
+func (l *Ledger) release(ctx context.Context, filter bson.M, reason string) error {
+	e, err := l.find(ctx, filter)
+	if err != nil || e.Status == "released" {
+		return nil
+	}
+	now := time.Now()
+	// Only the status just read is released, so a reservation committed in
+	// the meantime keeps its used count right
+	res, err := l.Col.UpdateOne(ctx, bson.M{"_id": e.ID, "status": e.Status}, bson.M{"$set": bson.M{
+		"status":         "released",
+		"release_reason": reason,
+		"released_at":    now,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount > 0 && e.Status == "committed" {
+		l.changed(ctx, e)
+	}
+	return nil
+}
+
+// Release frees the use held by a booking or payment order, e.g. when the
+// payment fails or the booking is cancelled.
+func (l *Ledger) Release(ref, reason string) error {
+	if ref == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := RefFilter(ref)
+	filter["status"] = bson.M{"$in": []string{"reserved", "committed"}}
+	return l.release(ctx, filter, reason)
+}
+
+// ReleaseReservation frees a reservation whose booking could not be made.
+func (l *Ledger) ReleaseReservation(id primitive.ObjectID, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return l.release(ctx, bson.M{"_id": id, "status": "reserved"}, reason)
+}
+
+// ReleaseExpired releases reservations whose payment never arrived.
+func (l *Ledger) ReleaseExpired() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	res, err := l.Col.UpdateMany(ctx, bson.M{
+		"status":     "reserved",
+		"expires_at": bson.M{"$lte": now},
+	}, bson.M{"$set": bson.M{
+		"status":         "released",
+		"release_reason": "expired",
+		"released_at":    now,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
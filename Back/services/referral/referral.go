@@ -0,0 +1,691 @@
+package referral
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	bookingsvc "ticpin-backend/services/booking"
+	couponsvc "ticpin-backend/services/coupon"
+	passsvc "ticpin-backend/services/pass"
+	paymentsvc "ticpin-backend/services/payment"
+	walletsvc "ticpin-backend/services/wallet"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const settingsID = "default"
+
+// Reasons a referral is rejected at signup.
+const (
+	RejectSelfReferral = "self_referral"
+	RejectSameDevice   = "same_device"
+	RejectPhonePattern = "phone_pattern"
+	RejectSameIP       = "same_ip"
+	RejectLimitReached = "limit_reached"
+)
+
+func defaultSettings() *models.ReferralSettings {
+	return &models.ReferralSettings{
+		ID:      settingsID,
+		Enabled: true,
+		ReferrerReward: models.ReferralRewardRule{
+			Type:          "coupon",
+			Category:      "event",
+			DiscountType:  "flat",
+			DiscountValue: 100,
+			ValidDays:     60,
+		},
+		RefereeReward: models.ReferralRewardRule{
+			Type:          "coupon",
+			Category:      "event",
+			DiscountType:  "flat",
+			DiscountValue: 100,
+			ValidDays:     60,
+		},
+		MinBookingAmount:    199,
+		MaxReferralsPerUser: 50,
+		QualifyWithinDays:   30,
+	}
+}
+
+// GetSettings returns the referral program settings, falling back to the
+// defaults until an admin saves them.
+func GetSettings() (*models.ReferralSettings, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var s models.ReferralSettings
+	err := config.ReferralSettingsCol.FindOne(ctx, bson.M{"_id": settingsID}).Decode(&s)
+	if err == mongo.ErrNoDocuments {
+		return defaultSettings(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func validateRule(side string, r models.ReferralRewardRule) error {
+	switch r.Type {
+	case "":
+		return nil
+	case "coupon":
+		if r.Category != "event" && r.Category != "play" && r.Category != "dining" {
+			return fmt.Errorf("%s reward category must be event, play or dining", side)
+		}
+		if r.DiscountType != "percent" && r.DiscountType != "flat" {
+			return fmt.Errorf("%s reward discount_type must be percent or flat", side)
+		}
+		if r.DiscountValue <= 0 {
+			return fmt.Errorf("%s reward discount_value must be greater than 0", side)
+		}
+	case "pass_benefit":
+		if r.Benefit != passsvc.BenefitTurf && r.Benefit != passsvc.BenefitDining {
+			return fmt.Errorf("%s reward benefit must be turf or dining", side)
+		}
+		if r.Quantity <= 0 {
+			return fmt.Errorf("%s reward quantity must be greater than 0", side)
+		}
+	case "wallet_credit":
+		if r.Amount <= 0 {
+			return fmt.Errorf("%s reward amount must be greater than 0", side)
+		}
+	default:
+		return fmt.Errorf("unknown %s reward type: %s", side, r.Type)
+	}
+	return nil
+}
+
+func UpdateSettings(s *models.ReferralSettings) error {
+	if err := validateRule("referrer", s.ReferrerReward); err != nil {
+		return err
+	}
+	if err := validateRule("referee", s.RefereeReward); err != nil {
+		return err
+	}
+	if s.MinBookingAmount < 0 || s.MaxReferralsPerUser < 0 || s.QualifyWithinDays < 0 {
+		return errors.New("referral limits cannot be negative")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.ID = settingsID
+	s.UpdatedAt = time.Now()
+	_, err := config.ReferralSettingsCol.ReplaceOne(ctx, bson.M{"_id": settingsID}, s, options.Replace().SetUpsert(true))
+	return err
+}
+
+func randomCode(prefix string, length int) string {
+	const charset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	b := make([]byte, length)
+	for i := range b {
+		n, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		b[i] = charset[n.Int64()]
+	}
+	return prefix + string(b)
+}
+
+// GetOrCreateCode returns the user's referral code, assigning one the first
+// time it is asked for.
+func GetOrCreateCode(userID string) (string, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return "", errors.New("invalid user id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var u models.User
+	if err := config.UsersCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&u); err != nil {
+		return "", errors.New("user not found")
+	}
+	if u.ReferralCode != "" {
+		return u.ReferralCode, nil
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		code := randomCode("TP", 6)
+		res, err := config.UsersCol.UpdateOne(ctx, bson.M{
+			"_id":           objID,
+			"referral_code": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"referral_code": code}})
+		if err != nil {
+			if config.IsDuplicateKeyError(err) {
+				continue
+			}
+			return "", err
+		}
+		if res.ModifiedCount == 0 {
+			// Another request assigned a code first
+			if err := config.UsersCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&u); err != nil {
+				return "", err
+			}
+			return u.ReferralCode, nil
+		}
+		return code, nil
+	}
+	return "", errors.New("failed to create referral code")
+}
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+// lastTen reduces a phone number to its last ten digits so +91 and bare
+// numbers compare equal.
+func lastTen(phone string) string {
+	phone = nonDigits.ReplaceAllString(phone, "")
+	if len(phone) > 10 {
+		return phone[len(phone)-10:]
+	}
+	return phone
+}
+
+// fraudReason applies the signup checks and returns why the referral must
+// be rejected, or "" when it looks genuine.
+func fraudReason(ctx context.Context, s *models.ReferralSettings, referrer *models.User, referee *models.User, deviceID, ip string) string {
+	referrerPhone, refereePhone := lastTen(referrer.Phone), lastTen(referee.Phone)
+	if referrer.ID == referee.ID || referrerPhone == refereePhone {
+		return RejectSelfReferral
+	}
+
+	if deviceID != "" {
+		if n, _ := config.ReferralsCol.CountDocuments(ctx, bson.M{"device_id": deviceID}); n > 0 {
+			return RejectSameDevice
+		}
+	}
+
+	// Runs of numbers that differ only in the last two digits are a common
+	// sign of one person farming referrals with bulk SIMs.
+	if len(referrerPhone) == 10 && len(refereePhone) == 10 {
+		prefix := refereePhone[:8]
+		if referrerPhone[:8] == prefix {
+			return RejectPhonePattern
+		}
+		n, _ := config.ReferralsCol.CountDocuments(ctx, bson.M{
+			"referrer_id":   referrer.ID,
+			"referee_phone": bson.M{"$regex": prefix + `\d{2}$`},
+		})
+		if n >= 2 {
+			return RejectPhonePattern
+		}
+	}
+
+	if ip != "" {
+		n, _ := config.ReferralsCol.CountDocuments(ctx, bson.M{
+			"referrer_id": referrer.ID,
+			"ip":          ip,
+			"created_at":  bson.M{"$gte": time.Now().Add(-24 * time.Hour)},
+		})
+		if n >= 3 {
+			return RejectSameIP
+		}
+	}
+
+	if s.MaxReferralsPerUser > 0 {
+		n, _ := config.ReferralsCol.CountDocuments(ctx, bson.M{
+			"referrer_id": referrer.ID,
+			"status":      bson.M{"$ne": "rejected"},
+		})
+		if n >= int64(s.MaxReferralsPerUser) {
+			return RejectLimitReached
+		}
+	}
+	return ""
+}
+
+// RecordSignup links a newly created user to the owner of the referral code
+// they signed up with. Suspicious signups are kept as rejected referrals so
+// admins can review them.
+func RecordSignup(referee *models.User, code, deviceID, ip string) (*models.Referral, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return nil, nil
+	}
+	s, err := GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	if !s.Enabled {
+		return nil, errors.New("referral program is not active")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var referrer models.User
+	if err := config.UsersCol.FindOne(ctx, bson.M{"referral_code": code}).Decode(&referrer); err != nil {
+		return nil, errors.New("invalid referral code")
+	}
+
+	r := models.Referral{
+		ID:            primitive.NewObjectID(),
+		Code:          code,
+		ReferrerID:    referrer.ID,
+		ReferrerPhone: referrer.Phone,
+		RefereeID:     referee.ID,
+		RefereePhone:  referee.Phone,
+		DeviceID:      strings.TrimSpace(deviceID),
+		IP:            ip,
+		Status:        "pending",
+		CreatedAt:     time.Now(),
+	}
+	if reason := fraudReason(ctx, s, &referrer, referee, r.DeviceID, ip); reason != "" {
+		r.Status = "rejected"
+		r.RejectReason = reason
+		fmt.Printf("DEBUG: Referral from %s to %s rejected: %s\n", referrer.ID.Hex(), referee.ID.Hex(), reason)
+	}
+
+	if _, err := config.ReferralsCol.InsertOne(ctx, r); err != nil {
+		if config.IsDuplicateKeyError(err) {
+			return nil, errors.New("user was already referred")
+		}
+		return nil, err
+	}
+	if r.Status == "pending" {
+		config.UsersCol.UpdateOne(ctx, bson.M{"_id": referee.ID}, bson.M{"$set": bson.M{"referred_by": referrer.ID}})
+	}
+	return &r, nil
+}
+
+// qualifyingCandidates is how many of a referee's earliest bookings per
+// vertical are looked at for one that qualifies.
+const qualifyingCandidates = 20
+
+type paidBooking struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	GrandTotal     float64            `bson:"grand_total"`
+	WalletAmount   float64            `bson:"wallet_amount"`
+	BookedAt       time.Time          `bson:"booked_at"`
+	PaymentID      string             `bson:"payment_id"`
+	OrderID        string             `bson:"order_id"`
+	PaymentGateway string             `bson:"payment_gateway"`
+	EventID        primitive.ObjectID `bson:"event_id"`
+	ShowtimeID     primitive.ObjectID `bson:"showtime_id"`
+	Date           string             `bson:"date"`
+}
+
+// cancellationClosed reports whether a booking can no longer be cancelled,
+// which happens once the day it is for has passed.
+func cancellationClosed(ctx context.Context, bookingType string, b *paidBooking) bool {
+	var day time.Time
+	if bookingType == "event" {
+		var e models.Event
+		if err := config.EventsCol.FindOne(ctx, bson.M{"_id": b.EventID}).Decode(&e); err != nil {
+			return false
+		}
+		day, _ = bookingsvc.BookingSchedule(&e, &models.Booking{ShowtimeID: b.ShowtimeID})
+	} else {
+		var ok bool
+		if day, ok = bookingsvc.ParseBookingDate(b.Date); !ok {
+			return false
+		}
+	}
+	if day.IsZero() {
+		return false
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return day.UTC().Truncate(24 * time.Hour).Before(today)
+}
+
+// firstPaidBooking finds the referee's earliest booking made in time that the gateway confirms was paid for, with at least minAmount
+// captured, and that can no longer be cancelled.
+func firstPaidBooking(ctx context.Context, r *models.Referral, s *models.ReferralSettings) (string, string, float64, bool) {
+	minAmount := s.MinBookingAmount
+	filter := bson.M{
+		"user_id":     r.RefereeID.Hex(),
+		"status":      bson.M{"$in": []string{"booked", "confirmed", "completed"}},
+		"grand_total": bson.M{"$gt": 0, "$gte": minAmount},
+		"payment_id":  bson.M{"$gt": ""},
+		"booked_at":   bookedWithin(s, r),
+	}
+	opts := options.Find().SetSort(bson.M{"booked_at": 1}).SetLimit(qualifyingCandidates)
+
+	var (
+		found       bool
+		bookingType string
+		bookingID   string
+		amount      float64
+		earliest    time.Time
+	)
+	for bType, col := range map[string]*mongo.Collection{
+		"event":  config.EventBookingsCol,
+		"play":   config.PlayBookingsCol,
+		"dining": config.DiningBookingsCol,
+	} {
+		cursor, err := col.Find(ctx, filter, opts)
+		if err != nil {
+			continue
+		}
+		var candidates []paidBooking
+		if err := cursor.All(ctx, &candidates); err != nil {
+			continue
+		}
+		for i := range candidates {
+			b := &candidates[i]
+			if found && !b.BookedAt.Before(earliest) {
+				break
+			}
+			if !cancellationClosed(ctx, bType, b) {
+				continue
+			}
+			captured, err := paymentsvc.CapturedAmount(b.PaymentGateway, b.OrderID, b.PaymentID)
+			if err != nil || captured <= 0 || captured < minAmount {
+				continue
+			}
+			found = true
+			bookingType, bookingID, amount, earliest = bType, b.ID.Hex(), captured, b.BookedAt
+			break
+		}
+	}
+	return bookingType, bookingID, amount, found
+}
+
+// grant gives one side of a referral its reward. A reward that cannot be
+// given yet comes back pending so it is retried later.
+func grant(userID primitive.ObjectID, rule models.ReferralRewardRule, note string) *models.ReferralGrant {
+	if rule.Type == "" {
+		return nil
+	}
+	g := &models.ReferralGrant{Type: rule.Type, Status: "pending"}
+	now := time.Now()
+
+	switch rule.Type {
+	case "coupon":
+		validDays := rule.ValidDays
+		if validDays <= 0 {
+			validDays = 30
+		}
+		c := models.Coupon{
+			Code:          randomCode("REF", 8),
+			Description:   note,
+			Category:      rule.Category,
+			DiscountType:  rule.DiscountType,
+			DiscountValue: rule.DiscountValue,
+			UserIDs:       []primitive.ObjectID{userID},
+			ValidFrom:     now,
+			ValidUntil:    now.AddDate(0, 0, validDays),
+			MaxUses:       1,
+			IsActive:      true,
+		}
+		if err := couponsvc.Create(&c); err != nil {
+			g.Note = err.Error()
+			return g
+		}
+		g.CouponCode = c.Code
+	case "pass_benefit":
+		g.Benefit = rule.Benefit
+		g.Quantity = rule.Quantity
+		if _, err := passsvc.GrantBenefit(userID.Hex(), rule.Benefit, rule.Quantity, note); err != nil {
+			g.Note = err.Error()
+			return g
+		}
+	case "wallet_credit":
+		g.Amount = rule.Amount
+		var expiresAt *time.Time
+		if rule.ValidDays > 0 {
+			t := now.AddDate(0, 0, rule.ValidDays)
+			expiresAt = &t
+		}
+		if _, err := walletsvc.Credit(userID.Hex(), rule.Amount, "referral", note, walletsvc.Ref{}, expiresAt); err != nil {
+			g.Note = err.Error()
+			return g
+		}
+	}
+	g.Status = "granted"
+	g.Note = ""
+	g.GrantedAt = &now
+	return g
+}
+
+// CheckQualification rewards both sides of a pending referral once the
+// referee has a paid booking that can no longer be cancelled. It is safe to
+// call repeatedly.
+func CheckQualification(refereeID string) error {
+	objID, err := primitive.ObjectIDFromHex(refereeID)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var r models.Referral
+	if err := config.ReferralsCol.FindOne(ctx, bson.M{"referee_id": objID, "status": "pending"}).Decode(&r); err != nil {
+		return nil
+	}
+	s, err := GetSettings()
+	if err != nil {
+		return err
+	}
+	return qualify(ctx, s, &r)
+}
+
+// bookedWithin returns the booked_at range a qualifying booking must fall
+// in: after signup and, when the settings limit it, within the qualifying
+// days.
+func bookedWithin(s *models.ReferralSettings, r *models.Referral) bson.M {
+	within := bson.M{"$gte": r.CreatedAt}
+	if s.QualifyWithinDays > 0 {
+		within["$lte"] = r.CreatedAt.AddDate(0, 0, s.QualifyWithinDays)
+	}
+	return within
+}
+
+// awaitingBooking reports whether the referee has a live paid booking,
+// made in time, that may still qualify once it can no longer be cancelled.
+func awaitingBooking(ctx context.Context, s *models.ReferralSettings, r *models.Referral) bool {
+	filter := bson.M{
+		"user_id":     r.RefereeID.Hex(),
+		"status":      bson.M{"$in": []string{"booked", "confirmed", "completed"}},
+		"grand_total": bson.M{"$gt": 0, "$gte": s.MinBookingAmount},
+		"payment_id":  bson.M{"$gt": ""},
+		"booked_at":   bookedWithin(s, r),
+	}
+	for _, col := range []*mongo.Collection{config.EventBookingsCol, config.PlayBookingsCol, config.DiningBookingsCol} {
+		if n, _ := col.CountDocuments(ctx, filter); n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func qualify(ctx context.Context, s *models.ReferralSettings, r *models.Referral) error {
+	bookingType, bookingID, amount, ok := firstPaidBooking(ctx, r, s)
+	if !ok {
+		// A booking made in time still counts after the qualifying days
+		// are over; the referral only expires once none is left
+		if s.QualifyWithinDays > 0 && time.Since(r.CreatedAt) > time.Duration(s.QualifyWithinDays)*24*time.Hour && !awaitingBooking(ctx, s, r) {
+			_, err := config.ReferralsCol.UpdateOne(ctx, bson.M{"_id": r.ID, "status": "pending"}, bson.M{"$set": bson.M{"status": "expired"}})
+			return err
+		}
+		return nil
+	}
+
+	// Claim the referral so concurrent checks cannot reward it twice. The
+	// rewards owed are recorded with the claim, so if granting them is cut
+	// short they are still retried.
+	now := time.Now()
+	claim := bson.M{
+		"status":         "rewarded",
+		"booking_type":   bookingType,
+		"booking_id":     bookingID,
+		"booking_amount": amount,
+		"qualified_at":   now,
+	}
+	if s.ReferrerReward.Type != "" {
+		claim["referrer_grant"] = &models.ReferralGrant{Type: s.ReferrerReward.Type, Status: "pending"}
+	}
+	if s.RefereeReward.Type != "" {
+		claim["referee_grant"] = &models.ReferralGrant{Type: s.RefereeReward.Type, Status: "pending"}
+	}
+	res, err := config.ReferralsCol.UpdateOne(ctx, bson.M{"_id": r.ID, "status": "pending"}, bson.M{"$set": claim})
+	if err != nil || res.ModifiedCount == 0 {
+		return err
+	}
+
+	r.Status = "rewarded"
+	r.ReferrerGrant, _ = claim["referrer_grant"].(*models.ReferralGrant)
+	r.RefereeGrant, _ = claim["referee_grant"].(*models.ReferralGrant)
+	grantOwed(ctx, s, r)
+	return nil
+}
+
+// grantOwed gives out the rewards of a referral that are still pending and
+// records them. A reward given to a referral that was clawed back in the
+// meantime is taken back at once.
+func grantOwed(ctx context.Context, s *models.ReferralSettings, r *models.Referral) {
+	sides := []struct {
+		field  string
+		userID primitive.ObjectID
+		owed   *models.ReferralGrant
+		rule   models.ReferralRewardRule
+		note   string
+	}{
+		{"referrer_grant", r.ReferrerID, r.ReferrerGrant, s.ReferrerReward, "Referral reward for inviting a friend"},
+		{"referee_grant", r.RefereeID, r.RefereeGrant, s.RefereeReward, "Referral reward for joining Ticpin"},
+	}
+	for _, side := range sides {
+		if side.owed != nil && side.owed.Status != "pending" {
+			continue
+		}
+		g := grant(side.userID, side.rule, side.note)
+		if g == nil || g.Status != "granted" {
+			if g != nil {
+				config.ReferralsCol.UpdateOne(ctx, bson.M{"_id": r.ID, "status": "rewarded"}, bson.M{"$set": bson.M{side.field + ".note": g.Note}})
+			}
+			continue
+		}
+		res, err := config.ReferralsCol.UpdateOne(ctx, bson.M{"_id": r.ID, "status": "rewarded"}, bson.M{"$set": bson.M{side.field: g}})
+		if err != nil {
+			fmt.Printf("ERROR: Failed to record referral reward for %s: %v\n", r.ID.Hex(), err)
+			continue
+		}
+		if res.MatchedCount == 0 {
+			revoke(side.userID, g, "referral reversed")
+		}
+	}
+}
+
+// retryPendingGrants gives out rewards that could not be granted when the
+// referral qualified, using the rule in force now.
+func retryPendingGrants(ctx context.Context, s *models.ReferralSettings) {
+	owed := []bson.M{
+		{"referrer_grant.status": "pending"},
+		{"referee_grant.status": "pending"},
+	}
+	// Referrals rewarded before the rewards were recorded with the claim
+	if s.ReferrerReward.Type != "" {
+		owed = append(owed, bson.M{"referrer_grant": bson.M{"$exists": false}})
+	}
+	if s.RefereeReward.Type != "" {
+		owed = append(owed, bson.M{"referee_grant": bson.M{"$exists": false}})
+	}
+	cursor, err := config.ReferralsCol.Find(ctx, bson.M{"status": "rewarded", "$or": owed})
+	if err != nil {
+		return
+	}
+	var list []models.Referral
+	if err := cursor.All(ctx, &list); err != nil {
+		return
+	}
+	for i := range list {
+		grantOwed(ctx, s, &list[i])
+	}
+}
+
+// revoke takes back a reward that was granted. Rewards already spent are
+// left alone and logged.
+func revoke(userID primitive.ObjectID, g *models.ReferralGrant, note string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var err error
+	switch g.Type {
+	case "coupon":
+		var res *mongo.UpdateResult
+		res, err = config.CouponsCol.UpdateOne(ctx, bson.M{"code": g.CouponCode}, bson.M{"$set": bson.M{"is_active": false}})
+		if err == nil && res.MatchedCount == 0 {
+			err = errors.New("coupon not found")
+		}
+	case "pass_benefit":
+		err = passsvc.RevokeBenefit(userID.Hex(), g.Benefit, g.Quantity, note)
+	case "wallet_credit":
+		_, err = walletsvc.Debit(userID.Hex(), g.Amount, "referral_reversal", note, walletsvc.Ref{})
+	}
+	if err != nil {
+		fmt.Printf("ERROR: Failed to take back %s referral reward from %s: %v\n", g.Type, userID.Hex(), err)
+	}
+}
+
+// ClawBack reverses a referral whose qualifying booking was cancelled or
+// refunded, taking back both rewards. Bookings that did not qualify a
+// referral are ignored.
+func ClawBack(bookingID, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var r models.Referral
+	err := config.ReferralsCol.FindOneAndUpdate(ctx, bson.M{
+		"booking_id": bookingID,
+		"status":     "rewarded",
+	}, bson.M{"$set": bson.M{
+		"status":         "reversed",
+		"reversed_at":    now,
+		"reverse_reason": reason,
+	}}).Decode(&r)
+	if err != nil {
+		return
+	}
+
+	set := bson.M{}
+	if g := r.ReferrerGrant; g != nil && g.Status == "granted" {
+		revoke(r.ReferrerID, g, "Referral reversed: "+reason)
+		set["referrer_grant.status"] = "revoked"
+	}
+	if g := r.RefereeGrant; g != nil && g.Status == "granted" {
+		revoke(r.RefereeID, g, "Referral reversed: "+reason)
+		set["referee_grant.status"] = "revoked"
+	}
+	if len(set) > 0 {
+		config.ReferralsCol.UpdateOne(ctx, bson.M{"_id": r.ID}, bson.M{"$set": set})
+	}
+	fmt.Printf("DEBUG: Referral %s reversed after booking %s was %s\n", r.ID.Hex(), bookingID, reason)
+}
+
+// ProcessPending checks every pending referral for a qualifying booking and
+// retries rewards that are still owed.
+func ProcessPending() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	s, err := GetSettings()
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load referral settings: %v\n", err)
+		return
+	}
+
+	cursor, err := config.ReferralsCol.Find(ctx, bson.M{"status": "pending"})
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load pending referrals: %v\n", err)
+		return
+	}
+	var pending []models.Referral
+	if err := cursor.All(ctx, &pending); err != nil {
+		return
+	}
+	for i := range pending {
+		if err := qualify(ctx, s, &pending[i]); err != nil {
+			fmt.Printf("ERROR: Failed to process referral %s: %v\n", pending[i].ID.Hex(), err)
+		}
+	}
+	retryPendingGrants(ctx, s)
+}
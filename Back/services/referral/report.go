@@ -0,0 +1,186 @@
+package referral
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Summary is what a user sees about their own referrals.
+type Summary struct {
+	Code      string            `json:"code"`
+	Invited   int               `json:"invited"`
+	Pending   int               `json:"pending"`
+	Rewarded  int               `json:"rewarded"`
+	Referrals []models.Referral `json:"referrals"`
+}
+
+func GetSummary(userID string) (*Summary, error) {
+	code, err := GetOrCreateCode(userID)
+	if err != nil {
+		return nil, err
+	}
+	objID, _ := primitive.ObjectIDFromHex(userID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Rejected referrals and the other side's contact details stay private
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetProjection(bson.M{
+		"code":           1,
+		"status":         1,
+		"referrer_grant": 1,
+		"created_at":     1,
+		"qualified_at":   1,
+	})
+	cursor, err := config.ReferralsCol.Find(ctx, bson.M{
+		"referrer_id": objID,
+		"status":      bson.M{"$ne": "rejected"},
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.Referral{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+
+	s := &Summary{Code: code, Referrals: list, Invited: len(list)}
+	for _, r := range list {
+		switch r.Status {
+		case "pending":
+			s.Pending++
+		case "rewarded":
+			s.Rewarded++
+		}
+	}
+	return s, nil
+}
+
+// ListReferrals pages through referrals for admins.
+func ListReferrals(status, referrerID string, page, limit int) ([]models.Referral, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+	if referrerID != "" {
+		objID, err := primitive.ObjectIDFromHex(referrerID)
+		if err != nil {
+			return nil, 0, errors.New("invalid referrer id")
+		}
+		filter["referrer_id"] = objID
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := config.ReferralsCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	list := []models.Referral{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, 0, err
+	}
+	total, err := config.ReferralsCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
+
+type ReferrerStat struct {
+	ReferrerID    primitive.ObjectID `bson:"_id" json:"referrer_id"`
+	ReferrerPhone string             `bson:"phone" json:"referrer_phone"`
+	Rewarded      int                `bson:"rewarded" json:"rewarded"`
+	BookingValue  float64            `bson:"booking_value" json:"booking_value"`
+}
+
+// Stats summarises the program for admins.
+type Stats struct {
+	ByStatus      map[string]int64 `json:"by_status"`
+	RejectReasons map[string]int64 `json:"reject_reasons"`
+	BookingValue  float64          `json:"booking_value"`
+	TopReferrers  []ReferrerStat   `json:"top_referrers"`
+}
+
+func countBy(ctx context.Context, match bson.M, field string) (map[string]int64, error) {
+	cursor, err := config.ReferralsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		ID    string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	out := map[string]int64{}
+	for _, row := range rows {
+		out[row.ID] = row.Count
+	}
+	return out, nil
+}
+
+func GetStats() (*Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stats := &Stats{TopReferrers: []ReferrerStat{}}
+	var err error
+	if stats.ByStatus, err = countBy(ctx, bson.M{}, "status"); err != nil {
+		return nil, err
+	}
+	if stats.RejectReasons, err = countBy(ctx, bson.M{"status": "rejected"}, "reject_reason"); err != nil {
+		return nil, err
+	}
+
+	cursor, err := config.ReferralsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": "rewarded"}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$referrer_id",
+			"phone":         bson.M{"$first": "$referrer_phone"},
+			"rewarded":      bson.M{"$sum": 1},
+			"booking_value": bson.M{"$sum": "$booking_amount"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"rewarded": -1}}},
+		{{Key: "$limit", Value: 20}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &stats.TopReferrers); err != nil {
+		return nil, err
+	}
+
+	cursor, err = config.ReferralsCol.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": "rewarded"}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "total": bson.M{"$sum": "$booking_amount"}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		Total float64 `bson:"total"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	if len(totals) > 0 {
+		stats.BookingValue = totals[0].Total
+	}
+	return stats, nil
+}
@@ -0,0 +1,230 @@
+package session
+
+import (
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	organizersvc "ticpin-backend/services/organizer"
+	userservice "ticpin-backend/services/user"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func clientOf(c *fiber.Ctx) Client {
+	return Client{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+		DeviceID:  c.Get("X-Device-ID"),
+	}
+}
+
+func authSession(s *models.Session, refreshToken string, renewal bool) config.AuthSession {
+	return config.AuthSession{
+		ID:            s.ID.Hex(),
+		ClaimsVersion: s.ClaimsVersion,
+		RefreshToken:  refreshToken,
+		ExpiresAt:     s.ExpiresAt,
+		Renewal:       renewal,
+		MFA:           s.MFAVerifiedAt != nil,
+	}
+}
+
+// StartOrganizer signs an organizer in on this device: it opens a session
+// and sets the access, refresh and session info cookies.
+func StartOrganizer(c *fiber.Ctx, organizerID, email, role, vertical string, isAdmin bool, categoryStatus map[string]string) error {
+	s, token, err := Create(KindOrganizer, organizerID, vertical, clientOf(c))
+	if err != nil {
+		return err
+	}
+	return config.SetAuthCookies(c, organizerID, email, role, vertical, isAdmin, categoryStatus, authSession(s, token, false))
+}
+
+// StartAdmin signs an admin in through the admin login, which has already
+// checked their password or phone and, when mfa is set, their second factor.
+func StartAdmin(c *fiber.Ctx, org *models.Organizer, mfa bool) error {
+	s, token, err := Create(KindOrganizer, org.ID.Hex(), "admin", clientOf(c))
+	if err != nil {
+		return err
+	}
+	if mfa {
+		if err := MarkMFA(s.ID.Hex()); err != nil {
+			return err
+		}
+		now := time.Now()
+		s.MFAVerifiedAt = &now
+		s.ClaimsVersion++
+	}
+	return config.SetAuthCookies(c, org.ID.Hex(), org.Email, "admin", "admin", true, org.CategoryStatus, authSession(s, token, false))
+}
+
+// organizerCookies issues an access token for the session with claims
+// read fresh from the organizer record.
+func organizerCookies(c *fiber.Ctx, s *models.Session, refreshToken string) (*config.OrganizerClaims, error) {
+	org, err := organizersvc.GetByID(s.SubjectID)
+	if err != nil {
+		_ = Revoke(s.ID.Hex(), "", "account_not_found")
+		return nil, ErrRevoked
+	}
+	isAdmin := organizersvc.IsAdmin(*org)
+	role := "organizer"
+	if isAdmin {
+		role = "admin"
+	}
+	if err := config.SetAuthCookies(c, org.ID.Hex(), org.Email, role, s.Vertical, isAdmin, org.CategoryStatus, authSession(s, refreshToken, true)); err != nil {
+		return nil, err
+	}
+	return &config.OrganizerClaims{
+		OrganizerID:    org.ID.Hex(),
+		Email:          org.Email,
+		Role:           role,
+		IsAdmin:        isAdmin,
+		CategoryStatus: org.CategoryStatus,
+		SessionID:      s.ID.Hex(),
+		ClaimsVersion:  s.ClaimsVersion,
+		MFA:            s.MFAVerifiedAt != nil,
+	}, nil
+}
+
+// StartMember signs a team member in to their organizer on this device.
+// The cookies are the organizer's, with the member's id and email.
+func StartMember(c *fiber.Ctx, m *models.OrganizerMember, vertical string) error {
+	org, err := organizersvc.GetByID(m.OrganizerID.Hex())
+	if err != nil {
+		return err
+	}
+	if vertical == "" && len(org.OrganizerCategory) > 0 {
+		vertical = org.OrganizerCategory[0]
+	}
+	s, token, err := Create(KindMember, m.ID.Hex(), vertical, clientOf(c))
+	if err != nil {
+		return err
+	}
+	sess := authSession(s, token, false)
+	sess.MemberID = m.ID.Hex()
+	return config.SetAuthCookies(c, org.ID.Hex(), m.Email, "member", vertical, false, org.CategoryStatus, sess)
+}
+
+// memberCookies is organizerCookies for a team member. The owner's
+// category approvals apply to the member.
+func memberCookies(c *fiber.Ctx, s *models.Session, refreshToken string) (*config.OrganizerClaims, error) {
+	m, err := organizersvc.GetMember(s.SubjectID)
+	if err != nil {
+		_ = Revoke(s.ID.Hex(), "", "member_removed")
+		return nil, ErrRevoked
+	}
+	org, err := organizersvc.GetByID(m.OrganizerID.Hex())
+	if err != nil {
+		_ = Revoke(s.ID.Hex(), "", "account_not_found")
+		return nil, ErrRevoked
+	}
+	sess := authSession(s, refreshToken, true)
+	sess.MemberID = m.ID.Hex()
+	if err := config.SetAuthCookies(c, org.ID.Hex(), m.Email, "member", s.Vertical, false, org.CategoryStatus, sess); err != nil {
+		return nil, err
+	}
+	return &config.OrganizerClaims{
+		OrganizerID:    org.ID.Hex(),
+		Email:          m.Email,
+		Role:           "member",
+		CategoryStatus: org.CategoryStatus,
+		SessionID:      s.ID.Hex(),
+		ClaimsVersion:  s.ClaimsVersion,
+		MemberID:       m.ID.Hex(),
+	}, nil
+}
+
+// RefreshOrganizer rotates the organizer's refresh cookie and issues a new
+// access token.
+func RefreshOrganizer(c *fiber.Ctx) (*config.OrganizerClaims, error) {
+	s, token, err := Rotate(KindOrganizer, c.Cookies("ticpin_refresh"), clientOf(c))
+	if err != nil {
+		return nil, err
+	}
+	if s.Kind == KindMember {
+		return memberCookies(c, s, token)
+	}
+	return organizerCookies(c, s, token)
+}
+
+// ReissueOrganizer issues a new access token for a live session, picking
+// up changes such as a category approval.
+func ReissueOrganizer(c *fiber.Ctx, sessionID string) (*config.OrganizerClaims, error) {
+	s, err := Get(sessionID)
+	if err != nil || s.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+	switch s.Kind {
+	case KindOrganizer:
+		return organizerCookies(c, s, "")
+	case KindMember:
+		return memberCookies(c, s, "")
+	}
+	return nil, ErrRevoked
+}
+
+// StartUser signs a user in on this device.
+func StartUser(c *fiber.Ctx, userID, phone, name string) error {
+	s, token, err := Create(KindUser, userID, "", clientOf(c))
+	if err != nil {
+		return err
+	}
+	return config.SetUserAuthCookies(c, userID, phone, name, authSession(s, token, false))
+}
+
+func userCookies(c *fiber.Ctx, s *models.Session, refreshToken string) (*config.UserClaims, error) {
+	u, err := userservice.GetByID(s.SubjectID)
+	if err != nil {
+		_ = Revoke(s.ID.Hex(), "", "account_not_found")
+		return nil, ErrRevoked
+	}
+	if err := config.SetUserAuthCookies(c, u.ID.Hex(), u.Phone, u.Name, authSession(s, refreshToken, true)); err != nil {
+		return nil, err
+	}
+	return &config.UserClaims{
+		UserID:        u.ID.Hex(),
+		Phone:         u.Phone,
+		SessionID:     s.ID.Hex(),
+		ClaimsVersion: s.ClaimsVersion,
+	}, nil
+}
+
+// RefreshUser rotates the user's refresh cookie and issues a new access
+// token.
+func RefreshUser(c *fiber.Ctx) (*config.UserClaims, error) {
+	s, token, err := Rotate(KindUser, c.Cookies("ticpin_user_refresh"), clientOf(c))
+	if err != nil {
+		return nil, err
+	}
+	return userCookies(c, s, token)
+}
+
+// ReissueUser issues a new access token for a live user session.
+func ReissueUser(c *fiber.Ctx, sessionID string) (*config.UserClaims, error) {
+	s, err := Get(sessionID)
+	if err != nil || s.RevokedAt != nil || s.Kind != KindUser {
+		return nil, ErrRevoked
+	}
+	return userCookies(c, s, "")
+}
+
+// CurrentID returns the id of the session the request's cookies belong
+// to, from the access token if it is still valid, else the refresh token.
+func CurrentID(c *fiber.Ctx, kind string) string {
+	if kind == KindOrganizer {
+		if claims, err := config.ParseOrganizerToken(c.Cookies("ticpin_token")); err == nil {
+			return claims.SessionID
+		}
+		if s, err := FindByRefresh(kind, c.Cookies("ticpin_refresh")); err == nil {
+			return s.ID.Hex()
+		}
+		return ""
+	}
+	if claims, err := config.ParseUserToken(c.Cookies("ticpin_user_token")); err == nil {
+		return claims.SessionID
+	}
+	if s, err := FindByRefresh(kind, c.Cookies("ticpin_user_refresh")); err == nil {
+		return s.ID.Hex()
+	}
+	return ""
+}
@@ -0,0 +1,454 @@
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+	organizersvc "ticpin-backend/services/organizer"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	KindUser      = "user"
+	KindOrganizer = "organizer"
+	// A team member signed in to an organizer; the subject is the member.
+	KindMember = "organizer_member"
+
+	// Two tabs refreshing at once both present the same token; the one
+	// that loses the race is let through instead of being taken for theft.
+	rotationGrace = 30 * time.Second
+	// How long a session's revoked state and claims version are cached
+	// before the middleware looks again.
+	stateCacheTTL = 30 * time.Second
+)
+
+var (
+	ErrInvalidRefresh = errors.New("invalid or expired refresh token")
+	ErrRevoked        = errors.New("session has been revoked")
+)
+
+// Client describes the device a session was started from.
+type Client struct {
+	UserAgent string
+	IP        string
+	DeviceID  string
+}
+
+func ttlFor(kind string) time.Duration {
+	if kind == KindOrganizer || kind == KindMember {
+		return config.OrganizerSessionTTL
+	}
+	return config.UserSessionTTL
+}
+
+// refreshKinds matches the sessions a refresh cookie may belong to. Owners
+// and team members share the organizer cookies.
+func refreshKinds(kind string) interface{} {
+	if kind == KindOrganizer {
+		return bson.M{"$in": []string{KindOrganizer, KindMember}}
+	}
+	return kind
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// DescribeDevice turns a user agent into something like "Chrome on Android".
+func DescribeDevice(ua string) string {
+	l := strings.ToLower(ua)
+	browser := ""
+	switch {
+	case strings.Contains(l, "edg/"):
+		browser = "Edge"
+	case strings.Contains(l, "opr/") || strings.Contains(l, "opera"):
+		browser = "Opera"
+	case strings.Contains(l, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(l, "chrome/") || strings.Contains(l, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(l, "safari/"):
+		browser = "Safari"
+	case strings.Contains(l, "okhttp") || strings.Contains(l, "dart") || strings.Contains(l, "cfnetwork"):
+		browser = "Ticpin app"
+	}
+	platform := ""
+	switch {
+	case strings.Contains(l, "android"):
+		platform = "Android"
+	case strings.Contains(l, "iphone") || strings.Contains(l, "ipad") || strings.Contains(l, "ios"):
+		platform = "iOS"
+	case strings.Contains(l, "windows"):
+		platform = "Windows"
+	case strings.Contains(l, "mac os") || strings.Contains(l, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(l, "linux"):
+		platform = "Linux"
+	}
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return "Unknown device"
+}
+
+type cachedState struct {
+	revoked       bool
+	claimsVersion int
+	checkedAt     time.Time
+}
+
+var stateCache sync.Map // session id -> cachedState
+
+func forget(ids ...string) {
+	for _, id := range ids {
+		stateCache.Delete(id)
+	}
+}
+
+// Create starts a session and returns it with its first refresh token.
+func Create(kind, subjectID, vertical string, client Client) (*models.Session, string, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	s := &models.Session{
+		ID:          primitive.NewObjectID(),
+		Kind:        kind,
+		SubjectID:   subjectID,
+		Vertical:    vertical,
+		RefreshHash: hashToken(token),
+		DeviceID:    client.DeviceID,
+		Device:      DescribeDevice(client.UserAgent),
+		UserAgent:   client.UserAgent,
+		IP:          client.IP,
+		CreatedAt:   now,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(ttlFor(kind)),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := config.SessionsCol.InsertOne(ctx, s); err != nil {
+		return nil, "", err
+	}
+	return s, token, nil
+}
+
+// Rotate exchanges a refresh token for a new one and slides the session's
+// expiry. A token that was already rotated away revokes the whole session,
+// since only a copy of it could still be in use, unless it is presented
+// within rotationGrace of the rotation; then the session is returned with
+// an empty token and the caller keeps the cookie the other request set.
+func Rotate(kind, refreshToken string, client Client) (*models.Session, string, error) {
+	if refreshToken == "" {
+		return nil, "", ErrInvalidRefresh
+	}
+	hash := hashToken(refreshToken)
+	next, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var s models.Session
+	err = config.SessionsCol.FindOneAndUpdate(ctx,
+		bson.M{
+			"kind":         refreshKinds(kind),
+			"refresh_hash": hash,
+			"revoked_at":   bson.M{"$exists": false},
+			"expires_at":   bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{
+			"refresh_hash":      hashToken(next),
+			"prev_refresh_hash": hash,
+			"rotated_at":        now,
+			"last_used_at":      now,
+			"expires_at":        now.Add(ttlFor(kind)),
+			"ip":                client.IP,
+			"user_agent":        client.UserAgent,
+			"device":            DescribeDevice(client.UserAgent),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&s)
+	if err == nil {
+		return &s, next, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, "", err
+	}
+
+	if err := config.SessionsCol.FindOne(ctx, bson.M{"kind": refreshKinds(kind), "prev_refresh_hash": hash}).Decode(&s); err != nil {
+		return nil, "", ErrInvalidRefresh
+	}
+	if s.RevokedAt != nil || now.After(s.ExpiresAt) {
+		return nil, "", ErrInvalidRefresh
+	}
+	if s.RotatedAt != nil && now.Sub(*s.RotatedAt) < rotationGrace {
+		return &s, "", nil
+	}
+	fmt.Printf("DEBUG: Refresh token reused for %s session %s of %s, revoking\n", kind, s.ID.Hex(), s.SubjectID)
+	_ = Revoke(s.ID.Hex(), "", "refresh_token_reused")
+	return nil, "", ErrInvalidRefresh
+}
+
+// FindByRefresh returns the live session a refresh token belongs to.
+func FindByRefresh(kind, refreshToken string) (*models.Session, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefresh
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hash := hashToken(refreshToken)
+	var s models.Session
+	err := config.SessionsCol.FindOne(ctx, bson.M{
+		"kind":       refreshKinds(kind),
+		"$or":        []bson.M{{"refresh_hash": hash}, {"prev_refresh_hash": hash}},
+		"revoked_at": bson.M{"$exists": false},
+	}).Decode(&s)
+	if err != nil {
+		return nil, ErrInvalidRefresh
+	}
+	return &s, nil
+}
+
+// Get returns a session by id.
+func Get(sessionID string) (*models.Session, error) {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, errors.New("invalid session id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var s models.Session
+	if err := config.SessionsCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Check reports the claims version of a live session, or ErrRevoked. The
+// answer is cached for a short while, so a revocation made on another
+// instance takes at most stateCacheTTL to be noticed.
+func Check(sessionID string) (int, error) {
+	if v, ok := stateCache.Load(sessionID); ok {
+		st := v.(cachedState)
+		if time.Since(st.checkedAt) < stateCacheTTL {
+			if st.revoked {
+				return 0, ErrRevoked
+			}
+			return st.claimsVersion, nil
+		}
+	}
+
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return 0, ErrRevoked
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var s models.Session
+	err = config.SessionsCol.FindOne(ctx, bson.M{"_id": objID},
+		options.FindOne().SetProjection(bson.M{"revoked_at": 1, "expires_at": 1, "claims_version": 1}),
+	).Decode(&s)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+	st := cachedState{checkedAt: time.Now()}
+	if err == mongo.ErrNoDocuments || s.RevokedAt != nil || time.Now().After(s.ExpiresAt) {
+		st.revoked = true
+	} else {
+		st.claimsVersion = s.ClaimsVersion
+	}
+	stateCache.Store(sessionID, st)
+	if st.revoked {
+		return 0, ErrRevoked
+	}
+	return st.claimsVersion, nil
+}
+
+// Revoke ends one session. When subjectID is set the session must belong
+// to it.
+func Revoke(sessionID, subjectID, reason string) error {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return errors.New("invalid session id")
+	}
+	filter := bson.M{"_id": objID, "revoked_at": bson.M{"$exists": false}}
+	if subjectID != "" {
+		filter["subject_id"] = subjectID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := config.SessionsCol.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"revoked_at":    time.Now(),
+		"revoke_reason": reason,
+	}})
+	if err != nil {
+		return err
+	}
+	forget(sessionID)
+	if res.MatchedCount == 0 {
+		return errors.New("session not found")
+	}
+	return nil
+}
+
+// RevokeAll ends every live session of a user or organizer except
+// exceptID, and returns how many were ended.
+func RevokeAll(kind, subjectID, exceptID, reason string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"kind": kind, "subject_id": subjectID, "revoked_at": bson.M{"$exists": false}}
+	if objID, err := primitive.ObjectIDFromHex(exceptID); err == nil {
+		filter["_id"] = bson.M{"$ne": objID}
+	}
+	ids, err := sessionIDs(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	res, err := config.SessionsCol.UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"revoked_at":    time.Now(),
+		"revoke_reason": reason,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	forget(ids...)
+	return res.ModifiedCount, nil
+}
+
+// MarkMFA records that a session passed a two-factor check. Its next
+// access token carries the fact.
+func MarkMFA(sessionID string) error {
+	objID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return errors.New("invalid session id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := config.SessionsCol.UpdateOne(ctx, bson.M{"_id": objID, "revoked_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"mfa_verified_at": time.Now()},
+		"$inc": bson.M{"claims_version": 1},
+	})
+	if err != nil {
+		return err
+	}
+	forget(sessionID)
+	if res.MatchedCount == 0 {
+		return ErrRevoked
+	}
+	return nil
+}
+
+// MarkStale makes every live session of a user or organizer pick up fresh
+// claims on its next request, e.g. after an admin approves a category. An
+// organizer's team members are included, as they carry its approvals.
+func MarkStale(kind, subjectID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"kind": kind, "subject_id": subjectID, "revoked_at": bson.M{"$exists": false}}
+	if kind == KindOrganizer {
+		if memberIDs, err := organizersvc.MemberIDs(subjectID); err == nil && len(memberIDs) > 0 {
+			filter = bson.M{
+				"$or": []bson.M{
+					{"kind": KindOrganizer, "subject_id": subjectID},
+					{"kind": KindMember, "subject_id": bson.M{"$in": memberIDs}},
+				},
+				"revoked_at": bson.M{"$exists": false},
+			}
+		}
+	}
+	ids, err := sessionIDs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if _, err := config.SessionsCol.UpdateMany(ctx, filter, bson.M{"$inc": bson.M{"claims_version": 1}}); err != nil {
+		return err
+	}
+	forget(ids...)
+	return nil
+}
+
+func sessionIDs(ctx context.Context, filter bson.M) ([]string, error) {
+	cursor, err := config.SessionsCol.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		ids = append(ids, d.ID.Hex())
+	}
+	return ids, nil
+}
+
+// List returns the live sessions of a user or organizer, most recently
+// used first. currentID is flagged as the caller's own session.
+func List(kind, subjectID, currentID string) ([]models.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.SessionsCol.Find(ctx, bson.M{
+		"kind":       kind,
+		"subject_id": subjectID,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	list := []models.Session{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Current = list[i].ID.Hex() == currentID
+	}
+	return list, nil
+}
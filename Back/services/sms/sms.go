@@ -0,0 +1,212 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMSProvider delivers one-time passwords by SMS. Phone numbers are passed
+// in E.164 form, e.g. +919876543210.
+type SMSProvider interface {
+	Name() string
+	SendOTP(phone, code string) error
+}
+
+var nonDigits = regexp.MustCompile(`\D`)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// NormalizePhone turns a user supplied Indian mobile number into E.164.
+// Numbers that already carry another country code are kept as they are.
+func NormalizePhone(phone string) (string, error) {
+	trimmed := strings.TrimSpace(phone)
+	digits := nonDigits.ReplaceAllString(trimmed, "")
+	switch {
+	case len(digits) == 10:
+		return "+91" + digits, nil
+	case len(digits) == 11 && strings.HasPrefix(digits, "0"):
+		return "+91" + digits[1:], nil
+	case len(digits) == 12 && strings.HasPrefix(digits, "91"):
+		return "+" + digits, nil
+	case strings.HasPrefix(trimmed, "+") && len(digits) >= 8 && len(digits) <= 15:
+		return "+" + digits, nil
+	}
+	return "", errors.New("invalid phone number")
+}
+
+func otpMessage(code string) string {
+	return fmt.Sprintf("%s is your Ticpin login code. It expires in 5 minutes. Do not share it with anyone.", code)
+}
+
+// MSG91 sends OTPs through an approved MSG91 flow template that has an
+// "otp" variable.
+type MSG91 struct {
+	AuthKey    string
+	TemplateID string
+	BaseURL    string
+}
+
+func (p *MSG91) Name() string { return "msg91" }
+
+func (p *MSG91) SendOTP(phone, code string) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"template_id": p.TemplateID,
+		"short_url":   "0",
+		"recipients": []map[string]string{
+			{"mobiles": strings.TrimPrefix(phone, "+"), "otp": code},
+		},
+	})
+	req, err := http.NewRequest("POST", p.BaseURL+"/api/v5/flow/", bytes.NewBuffer(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authkey", p.AuthKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return do(req, "msg91")
+}
+
+// Twilio sends OTPs as a plain text message from a Twilio number or
+// messaging service.
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string
+}
+
+func (p *Twilio) Name() string { return "twilio" }
+
+func (p *Twilio) SendOTP(phone, code string) error {
+	form := url.Values{}
+	form.Set("To", phone)
+	if strings.HasPrefix(p.From, "MG") {
+		form.Set("MessagingServiceSid", p.From)
+	} else {
+		form.Set("From", p.From)
+	}
+	form.Set("Body", otpMessage(code))
+
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", p.BaseURL, p.AccountSID)
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return do(req, "twilio")
+}
+
+func do(req *http.Request, provider string) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("%s error (%d): %s", provider, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// Fake keeps the last code sent to each number instead of sending it. It
+// is meant for local development and must not be used in production.
+type Fake struct {
+	mu    sync.Mutex
+	codes map[string]string
+}
+
+func (p *Fake) Name() string { return "fake" }
+
+func (p *Fake) SendOTP(phone, code string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.codes == nil {
+		p.codes = map[string]string{}
+	}
+	p.codes[phone] = code
+	fmt.Printf("DEBUG: [fake sms] OTP for %s is %s\n", phone, code)
+	return nil
+}
+
+// LastCode returns the last code the fake provider "sent" to a number.
+func (p *Fake) LastCode(phone string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.codes[phone]
+}
+
+var (
+	providerOnce sync.Once
+	provider     SMSProvider
+)
+
+// newProvider picks the provider from SMS_PROVIDER, or from whichever
+// provider's credentials are set.
+func newProvider() SMSProvider {
+	name := strings.ToLower(os.Getenv("SMS_PROVIDER"))
+	if name == "" {
+		switch {
+		case os.Getenv("MSG91_AUTH_KEY") != "":
+			name = "msg91"
+		case os.Getenv("TWILIO_ACCOUNT_SID") != "":
+			name = "twilio"
+		default:
+			name = "fake"
+		}
+	}
+
+	switch name {
+	case "msg91":
+		baseURL := os.Getenv("MSG91_BASE_URL")
+		if baseURL == "" {
+			baseURL = "https://control.msg91.com"
+		}
+		return &MSG91{
+			AuthKey:    os.Getenv("MSG91_AUTH_KEY"),
+			TemplateID: os.Getenv("MSG91_OTP_TEMPLATE_ID"),
+			BaseURL:    strings.TrimRight(baseURL, "/"),
+		}
+	case "twilio":
+		baseURL := os.Getenv("TWILIO_BASE_URL")
+		if baseURL == "" {
+			baseURL = "https://api.twilio.com"
+		}
+		return &Twilio{
+			AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM"),
+			BaseURL:    strings.TrimRight(baseURL, "/"),
+		}
+	}
+	fmt.Println("WARNING: No SMS provider configured, OTPs are only logged (SMS_PROVIDER=fake)")
+	return &Fake{}
+}
+
+// Provider returns the configured SMS provider.
+func Provider() SMSProvider {
+	providerOnce.Do(func() {
+		if provider == nil {
+			provider = newProvider()
+		}
+	})
+	return provider
+}
+
+// SetProvider replaces the configured provider, e.g. with a Fake.
+func SetProvider(p SMSProvider) {
+	providerOnce.Do(func() {})
+	provider = p
+}
@@ -0,0 +1,398 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"ticpin-backend/cache"
+	"ticpin-backend/config"
+	organizersvc "ticpin-backend/services/organizer"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Kinds of records that are soft deleted. The names match the admin
+// routes and the audit log entities.
+const (
+	KindEvents     = "events"
+	KindPlay       = "play"
+	KindDining     = "dining"
+	KindOrganizers = "organizers"
+	KindUsers      = "users"
+)
+
+const purgeBatch = 200
+
+var ErrNotFound = errors.New("record not found or already deleted")
+
+var ErrOrganizerDeleted = errors.New("the organizer of this listing is deleted, restore the organizer first")
+
+// retention is how long a deleted record can still be restored. Override
+// with SOFT_DELETE_RETENTION_DAYS.
+func retention() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("SOFT_DELETE_RETENTION_DAYS")); err == nil && v > 0 {
+		return time.Duration(v) * 24 * time.Hour
+	}
+	return 90 * 24 * time.Hour
+}
+
+// reference is a financial record that points at a deleted record. While
+// any exists the record is kept, however old its deletion.
+type reference struct {
+	col   *mongo.Collection
+	field string
+	hex   bool // the field holds the id as a hex string
+}
+
+type kind struct {
+	col      *mongo.Collection
+	cacheKey string
+	label    []string
+	refs     []reference
+}
+
+func kinds() map[string]kind {
+	return map[string]kind{
+		KindEvents: {
+			col: config.EventsCol, cacheKey: "event", label: []string{"name"},
+			refs: []reference{
+				{col: config.EventBookingsCol, field: "event_id"},
+				{col: config.BookingsCol, field: "event_id"},
+				{col: config.EventChangesCol, field: "event_id"},
+			},
+		},
+		KindPlay: {
+			col: config.PlaysCol, cacheKey: "play", label: []string{"name"},
+			refs: []reference{{col: config.PlayBookingsCol, field: "play_id"}},
+		},
+		KindDining: {
+			col: config.DiningsCol, cacheKey: "dining", label: []string{"name"},
+			refs: []reference{{col: config.DiningBookingsCol, field: "dining_id"}},
+		},
+		KindOrganizers: {
+			col: config.OrgsCol, label: []string{"name", "email"},
+			refs: []reference{
+				{col: config.EventBookingsCol, field: "organizer_id"},
+				{col: config.BookingsCol, field: "organizer_id"},
+				{col: config.PlayBookingsCol, field: "organizer_id"},
+				{col: config.DiningBookingsCol, field: "organizer_id"},
+				{col: config.EventChangesCol, field: "organizer_id"},
+			},
+		},
+		KindUsers: {
+			col: config.UsersCol, label: []string{"name", "phone"},
+			refs: []reference{
+				{col: config.EventBookingsCol, field: "user_id", hex: true},
+				{col: config.BookingsCol, field: "user_id", hex: true},
+				{col: config.PlayBookingsCol, field: "user_id", hex: true},
+				{col: config.DiningBookingsCol, field: "user_id", hex: true},
+				{col: config.WalletEntriesCol, field: "user_id"},
+				{col: config.PassesCol, field: "user_id"},
+				{col: config.PassGiftsCol, field: "buyer_user_id"},
+				{col: config.CouponRedemptionsCol, field: "user_id", hex: true},
+				{col: config.OfferRedemptionsCol, field: "user_id", hex: true},
+				{col: config.EventChangeBookingsCol, field: "user_id", hex: true},
+			},
+		},
+	}
+}
+
+func lookup(name string) (kind, error) {
+	k, ok := kinds()[name]
+	if !ok {
+		return kind{}, fmt.Errorf("unknown record type %q", name)
+	}
+	return k, nil
+}
+
+func forget(k kind, id string) {
+	if k.cacheKey == "" {
+		return
+	}
+	cacheManager := cache.NewCacheManager()
+	cacheManager.DeleteEntity(k.cacheKey, id)
+	cacheManager.DeleteList(k.cacheKey)
+}
+
+// SoftDelete hides a record everywhere except admin tools until it is
+// restored or purged.
+func SoftDelete(name, id, actor string) error {
+	k, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	res, err := k.col.UpdateOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"deleted_at": now, "deleted_by": actor},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	forget(k, id)
+
+	// An organizer's live listings go with them, stamped with the same time
+	// so that restoring the organizer brings back exactly those
+	if name == KindOrganizers {
+		for _, l := range []string{KindEvents, KindPlay, KindDining} {
+			lk, _ := lookup(l)
+			if _, err := lk.col.UpdateMany(ctx, bson.M{"organizer_id": objID, "deleted_at": bson.M{"$exists": false}}, bson.M{
+				"$set": bson.M{"deleted_at": now, "deleted_by": actor},
+			}); err != nil {
+				fmt.Printf("ERROR: Failed to delete %s of organizer %s: %v\n", l, id, err)
+			}
+			cache.NewCacheManager().DeleteList(lk.cacheKey)
+		}
+	}
+	fmt.Printf("DEBUG: %s %s deleted by %s\n", name, id, actor)
+	return nil
+}
+
+// Restore brings a soft deleted record back.
+func Restore(name, id string) error {
+	k, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid id")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var doc struct {
+		DeletedAt   time.Time          `bson:"deleted_at"`
+		OrganizerID primitive.ObjectID `bson:"organizer_id"`
+	}
+	if err := k.col.FindOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": true}}).Decode(&doc); err != nil {
+		return errors.New("record not found in deleted items")
+	}
+	// A listing cannot come back without its organizer
+	if name != KindOrganizers && !doc.OrganizerID.IsZero() {
+		n, err := config.OrgsCol.CountDocuments(ctx, bson.M{"_id": doc.OrganizerID, "deleted_at": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrOrganizerDeleted
+		}
+	}
+	res, err := k.col.UpdateOne(ctx, bson.M{"_id": objID, "deleted_at": doc.DeletedAt}, bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("record not found in deleted items")
+	}
+	forget(k, id)
+
+	if name == KindOrganizers {
+		for _, l := range []string{KindEvents, KindPlay, KindDining} {
+			lk, _ := lookup(l)
+			if _, err := lk.col.UpdateMany(ctx, bson.M{"organizer_id": objID, "deleted_at": doc.DeletedAt}, bson.M{
+				"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+			}); err != nil {
+				fmt.Printf("ERROR: Failed to restore %s of organizer %s: %v\n", l, id, err)
+			}
+			cache.NewCacheManager().DeleteList(lk.cacheKey)
+		}
+	}
+	return nil
+}
+
+// Item is a soft deleted record as listed for admins.
+type Item struct {
+	ID         string    `json:"id"`
+	Label      string    `json:"label"`
+	DeletedAt  time.Time `json:"deleted_at"`
+	DeletedBy  string    `json:"deleted_by"`
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// ListDeleted returns soft deleted records of one type, most recently
+// deleted first.
+func ListDeleted(name string, page, limit int64) ([]Item, int64, error) {
+	k, err := lookup(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"deleted_at": bson.M{"$exists": true}}
+	total, err := k.col.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	projection := bson.M{"deleted_at": 1, "deleted_by": 1}
+	for _, f := range k.label {
+		projection[f] = 1
+	}
+	cursor, err := k.col.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "deleted_at", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit).
+		SetProjection(projection))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []struct {
+		ID        primitive.ObjectID `bson:"_id"`
+		Name      string             `bson:"name"`
+		Email     string             `bson:"email"`
+		Phone     string             `bson:"phone"`
+		DeletedAt time.Time          `bson:"deleted_at"`
+		DeletedBy string             `bson:"deleted_by"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]Item, 0, len(docs))
+	for _, d := range docs {
+		label := d.Name
+		if label == "" {
+			label = d.Email
+		}
+		if label == "" {
+			label = d.Phone
+		}
+		items = append(items, Item{
+			ID:         d.ID.Hex(),
+			Label:      label,
+			DeletedAt:  d.DeletedAt,
+			DeletedBy:  d.DeletedBy,
+			PurgeAfter: d.DeletedAt.Add(retention()),
+		})
+	}
+	return items, total, nil
+}
+
+// referenced reports whether any financial record still points at id.
+func referenced(ctx context.Context, k kind, id primitive.ObjectID) (bool, error) {
+	for _, r := range k.refs {
+		var value interface{} = id
+		if r.hex {
+			value = id.Hex()
+		}
+		n, err := r.col.CountDocuments(ctx, bson.M{r.field: value}, options.Count().SetLimit(1))
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// purgeRelated removes what belongs only to the purged record.
+func purgeRelated(ctx context.Context, name string, id primitive.ObjectID) {
+	switch name {
+	case KindOrganizers:
+		for _, col := range []*mongo.Collection{config.EventsCol, config.PlaysCol, config.DiningsCol} {
+			_, _ = col.DeleteMany(ctx, bson.M{"organizer_id": id})
+		}
+		_, _ = config.GetDB().Collection("organizer_setups").DeleteMany(ctx, bson.M{"organizerId": id})
+		_, _ = config.ProfilesCol.DeleteOne(ctx, bson.M{"organizerId": id})
+		_, _ = organizersvc.RemoveTeam(id.Hex())
+	case KindUsers:
+		_, _ = config.ProfilesCol.DeleteOne(ctx, bson.M{"userId": id})
+		_, _ = config.WalletsCol.DeleteOne(ctx, bson.M{"_id": id})
+	}
+}
+
+// Purge permanently removes records of one type deleted before the cutoff,
+// except those financial records still refer to. It returns how many were
+// removed and how many were kept.
+func Purge(name string, before time.Time) (int, int, error) {
+	k, err := lookup(name)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	cursor, err := k.col.Find(ctx, bson.M{"deleted_at": bson.M{"$lt": before}}, options.Find().
+		SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	purged, kept := 0, 0
+	for purged < purgeBatch && cursor.Next(ctx) {
+		var d struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&d); err != nil {
+			return purged, kept, err
+		}
+		inUse, err := referenced(ctx, k, d.ID)
+		if err != nil {
+			return purged, kept, err
+		}
+		if inUse {
+			kept++
+			continue
+		}
+		res, err := k.col.DeleteOne(ctx, bson.M{"_id": d.ID, "deleted_at": bson.M{"$lt": before}})
+		if err != nil {
+			return purged, kept, err
+		}
+		if res.DeletedCount == 0 {
+			continue
+		}
+		purgeRelated(ctx, name, d.ID)
+		forget(k, d.ID.Hex())
+		purged++
+	}
+	return purged, kept, nil
+}
+
+// PurgeExpired purges soft deleted records once they are past the
+// retention period.
+func PurgeExpired() {
+	cutoff := time.Now().Add(-retention())
+	for _, name := range []string{KindEvents, KindPlay, KindDining, KindUsers, KindOrganizers} {
+		purged, kept, err := Purge(name, cutoff)
+		if err != nil {
+			fmt.Printf("ERROR: Failed to purge deleted %s: %v\n", name, err)
+			continue
+		}
+		if purged > 0 || kept > 0 {
+			fmt.Printf("DEBUG: Purged %d deleted %s, kept %d still referenced by bookings or payments\n", purged, name, kept)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package user
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"ticpin-backend/config"
@@ -10,8 +12,13 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// ErrDeleted is returned when signing in to an account an admin deleted.
+var ErrDeleted = errors.New("this account has been deleted")
+
 func Create(user *models.User) error {
 	user.ID = primitive.NewObjectID()
 	user.CreatedAt = time.Now()
@@ -24,27 +31,59 @@ func Create(user *models.User) error {
 	return err
 }
 
-func Login(phone string) (*models.User, error) {
+// legacyPhones lists the forms an E.164 Indian number may have been stored
+// in before sign-in normalised phone numbers.
+func legacyPhones(e164 string) []string {
+	digits := strings.TrimPrefix(e164, "+")
+	variants := []string{digits}
+	if local := strings.TrimPrefix(digits, "91"); len(digits) == 12 && len(local) == 10 {
+		variants = append(variants, local, "0"+local, "+91 "+local, "91"+local)
+	}
+	return variants
+}
+
+// Login finds the user with this phone, creating them on first sign-in.
+// phone must already be in E.164 form. An account stored under an older
+// format of the same number is moved over to E.164. created reports
+// whether the account is new.
+func Login(phone string) (u *models.User, created bool, err error) {
 	collection := config.GetDB().Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var u models.User
-	err := collection.FindOne(ctx, bson.M{"phone": phone}).Decode(&u)
+	var existing models.User
+	err = collection.FindOne(ctx, bson.M{"phone": phone}).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		err = collection.FindOne(ctx, bson.M{"phone": bson.M{"$in": legacyPhones(phone)}},
+			options.FindOne().SetSort(bson.M{"createdAt": 1})).Decode(&existing)
+		if err == nil {
+			if _, uerr := collection.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{"$set": bson.M{"phone": phone}}); uerr != nil {
+				fmt.Printf("ERROR: Failed to normalise phone for user %s: %v\n", existing.ID.Hex(), uerr)
+			} else {
+				existing.Phone = phone
+			}
+		}
+	}
 	if err == nil {
-		return &u, nil
+		if existing.DeletedAt != nil {
+			return nil, false, ErrDeleted
+		}
+		return &existing, false, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
 	}
 
-	u = models.User{
+	u = &models.User{
 		ID:        primitive.NewObjectID(),
 		Phone:     phone,
 		CreatedAt: time.Now(),
 	}
 	_, err = collection.InsertOne(ctx, u)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	return &u, nil
+	return u, true, nil
 }
 
 func GetByID(id string) (*models.User, error) {
@@ -55,12 +94,12 @@ func GetByID(id string) (*models.User, error) {
 	var u models.User
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err == nil {
-		if err := collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&u); err == nil {
+		if err := collection.FindOne(ctx, bson.M{"_id": objID, "deleted_at": bson.M{"$exists": false}}).Decode(&u); err == nil {
 			return &u, nil
 		}
 	}
 
-	if err := collection.FindOne(ctx, bson.M{"phone": id}).Decode(&u); err == nil {
+	if err := collection.FindOne(ctx, bson.M{"phone": id, "deleted_at": bson.M{"$exists": false}}).Decode(&u); err == nil {
 		return &u, nil
 	}
 
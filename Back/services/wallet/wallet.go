@@ -0,0 +1,465 @@
+package wallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"ticpin-backend/config"
+	"ticpin-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// holdTTL matches the payment link expiry; a booking hold still unpaid after
+// it goes back to the wallet.
+const holdTTL = 30 * time.Minute
+
+// creditValidity is how long credit lasts unless a caller sets its own
+// expiry. Override with WALLET_CREDIT_EXPIRY_DAYS.
+func creditValidity() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("WALLET_CREDIT_EXPIRY_DAYS")); err == nil && v > 0 {
+		return time.Duration(v) * 24 * time.Hour
+	}
+	return 365 * 24 * time.Hour
+}
+
+var ErrInsufficientBalance = errors.New("insufficient wallet balance")
+
+// ErrHoldLost means a booking was paid for after its wallet hold was given
+// back and the wallet can no longer cover it.
+var ErrHoldLost = errors.New("wallet hold was released before the payment arrived")
+
+// Ref ties a wallet movement to what caused it.
+type Ref struct {
+	BookingType string
+	BookingID   string
+	OrderID     string
+	CreatedBy   string
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func incBalance(ctx context.Context, userID primitive.ObjectID, delta float64) error {
+	_, err := config.WalletsCol.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$inc": bson.M{"balance": delta},
+		"$set": bson.M{"updated_at": time.Now()},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetBalance returns the user's spendable balance.
+func GetBalance(userID string) (float64, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return 0, errors.New("invalid user id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var w models.Wallet
+	if err := config.WalletsCol.FindOne(ctx, bson.M{"_id": objID}).Decode(&w); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return round2(w.Balance), nil
+}
+
+// Credit adds store credit to a user's wallet. expiresAt may be nil for the
+// default validity.
+func Credit(userID string, amount float64, source, reason string, ref Ref, expiresAt *time.Time) (*models.WalletEntry, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	amount = round2(amount)
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	if expiresAt == nil {
+		exp := time.Now().Add(creditValidity())
+		expiresAt = &exp
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e := models.WalletEntry{
+		ID:          primitive.NewObjectID(),
+		UserID:      objID,
+		Type:        "credit",
+		Source:      source,
+		Amount:      amount,
+		Remaining:   amount,
+		Reason:      reason,
+		BookingType: ref.BookingType,
+		BookingID:   ref.BookingID,
+		OrderID:     ref.OrderID,
+		CreatedBy:   ref.CreatedBy,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := config.WalletEntriesCol.InsertOne(ctx, e); err != nil {
+		if config.IsDuplicateKeyError(err) {
+			return nil, errors.New("credit already issued for this booking")
+		}
+		return nil, err
+	}
+	if err := incBalance(ctx, objID, amount); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// draw takes amount from the user's unexpired credits, soonest-expiring
+// first. It may come up short, e.g. when credit expired but was not yet
+// written off.
+func draw(ctx context.Context, userID primitive.ObjectID, amount float64) ([]models.WalletDraw, float64) {
+	var draws []models.WalletDraw
+	left := amount
+	for attempt := 0; left > 0.001 && attempt < 50; attempt++ {
+		var credit models.WalletEntry
+		err := config.WalletEntriesCol.FindOne(ctx, bson.M{
+			"user_id":    userID,
+			"type":       "credit",
+			"remaining":  bson.M{"$gt": 0},
+			"expires_at": bson.M{"$gt": time.Now()},
+		}, options.FindOne().SetSort(bson.M{"expires_at": 1})).Decode(&credit)
+		if err != nil {
+			break
+		}
+		take := math.Min(left, credit.Remaining)
+		res, err := config.WalletEntriesCol.UpdateOne(ctx, bson.M{
+			"_id":       credit.ID,
+			"remaining": bson.M{"$gte": take},
+		}, bson.M{"$inc": bson.M{"remaining": -take}})
+		if err != nil || res.ModifiedCount == 0 {
+			continue
+		}
+		draws = append(draws, models.WalletDraw{CreditID: credit.ID, Amount: take})
+		left = round2(left - take)
+	}
+	return draws, left
+}
+
+// undraw puts draws back on the credits they were taken from.
+func undraw(ctx context.Context, draws []models.WalletDraw) {
+	for _, d := range draws {
+		config.WalletEntriesCol.UpdateOne(ctx, bson.M{"_id": d.CreditID}, bson.M{"$inc": bson.M{"remaining": d.Amount}})
+	}
+}
+
+// spend draws amount from the user's credits and takes it off the balance,
+// or takes nothing and returns ErrInsufficientBalance. Credit that expired
+// but is still counted in the balance cannot be spent.
+func spend(ctx context.Context, userID primitive.ObjectID, amount float64) ([]models.WalletDraw, error) {
+	draws, left := draw(ctx, userID, amount)
+	if left > 0.001 {
+		undraw(ctx, draws)
+		return nil, ErrInsufficientBalance
+	}
+	res, err := config.WalletsCol.UpdateOne(ctx, bson.M{
+		"_id":     userID,
+		"balance": bson.M{"$gte": amount},
+	}, bson.M{
+		"$inc": bson.M{"balance": -amount},
+		"$set": bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		undraw(ctx, draws)
+		return nil, err
+	}
+	if res.ModifiedCount == 0 {
+		undraw(ctx, draws)
+		return nil, ErrInsufficientBalance
+	}
+	return draws, nil
+}
+
+// debit takes amount from the wallet. The conditional draws and balance
+// guard make it safe under concurrent checkouts.
+func debit(userID string, amount float64, source, status, reason string, ref Ref) (*models.WalletEntry, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	amount = round2(amount)
+	if amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	draws, err := spend(ctx, objID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	e := models.WalletEntry{
+		ID:          primitive.NewObjectID(),
+		UserID:      objID,
+		Type:        "debit",
+		Source:      source,
+		Amount:      amount,
+		Status:      status,
+		Reason:      reason,
+		BookingType: ref.BookingType,
+		BookingID:   ref.BookingID,
+		OrderID:     ref.OrderID,
+		CreatedBy:   ref.CreatedBy,
+		Draws:       draws,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := config.WalletEntriesCol.InsertOne(ctx, e); err != nil {
+		incBalance(ctx, objID, amount)
+		undraw(ctx, e.Draws)
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Debit takes credit out of the wallet for good, e.g. an admin correction.
+func Debit(userID string, amount float64, source, reason string, ref Ref) (*models.WalletEntry, error) {
+	return debit(userID, amount, source, "settled", reason, ref)
+}
+
+// Hold sets wallet money aside for a booking that is waiting on its gateway
+// payment. It is settled or released with the booking.
+func Hold(userID string, amount float64, bookingType, orderID string) (*models.WalletEntry, error) {
+	return debit(userID, amount, "booking", "held", "", Ref{BookingType: bookingType, OrderID: orderID})
+}
+
+// AttachBooking records the booking a hold was made for once it exists.
+func AttachBooking(entryID primitive.ObjectID, bookingID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := config.WalletEntriesCol.UpdateOne(ctx, bson.M{"_id": entryID}, bson.M{"$set": bson.M{"booking_id": bookingID}})
+	return err
+}
+
+func refFilter(ref string) bson.M {
+	return bson.M{"$or": []bson.M{{"booking_id": ref}, {"order_id": ref}}}
+}
+
+// Settle marks the wallet part of a booking or payment order as spent. A
+// hold that expired while the payment was on its way, or was given back
+// after a failed attempt that the user then retried on the same order, is
+// taken again; when
+// the wallet no longer covers it Settle returns ErrHoldLost and the caller
+// refunds the payment.
+func Settle(ref string) error {
+	if ref == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := config.WalletEntriesCol.UpdateOne(ctx, bson.M{"$and": []bson.M{
+		refFilter(ref),
+		{"type": "debit", "status": "held"},
+	}}, bson.M{"$set": bson.M{"status": "settled"}})
+	if err != nil || res.MatchedCount > 0 {
+		return err
+	}
+
+	// Claim the released hold so that a second confirmation does not take
+	// it twice
+	var e models.WalletEntry
+	err = config.WalletEntriesCol.FindOneAndUpdate(ctx, bson.M{"$and": []bson.M{
+		refFilter(ref),
+		{"type": "debit", "source": "booking", "status": "released", "reason": bson.M{"$in": []string{"expired", "payment_failed"}}},
+	}}, bson.M{"$set": bson.M{"status": "retaking"}}).Decode(&e)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+	draws, err := spend(ctx, e.UserID, e.Amount)
+	if err == ErrInsufficientBalance {
+		config.WalletEntriesCol.UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{"$set": bson.M{"status": "released", "reason": "hold_lost"}})
+		return ErrHoldLost
+	}
+	if err != nil {
+		config.WalletEntriesCol.UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{"$set": bson.M{"status": "released"}})
+		return err
+	}
+	_, err = config.WalletEntriesCol.UpdateOne(ctx, bson.M{"_id": e.ID}, bson.M{
+		"$set":   bson.M{"status": "settled", "reason": "", "draws": draws},
+		"$unset": bson.M{"released_at": ""},
+	})
+	return err
+}
+
+// release gives a debit back to the credits it was drawn from.
+func release(ctx context.Context, filter bson.M, reason string) error {
+	now := time.Now()
+	var e models.WalletEntry
+	err := config.WalletEntriesCol.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{
+		"status":      "released",
+		"reason":      reason,
+		"released_at": now,
+	}}).Decode(&e)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+	undraw(ctx, e.Draws)
+	// Credits that expired while drawn are picked up by the next expiry run
+	return incBalance(ctx, e.UserID, e.Amount)
+}
+
+// Release returns the wallet part of a booking or payment order, e.g. when
+// the payment fails or the booking is cancelled.
+func Release(ref, reason string) error {
+	if ref == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return release(ctx, bson.M{"$and": []bson.M{
+		refFilter(ref),
+		{"type": "debit", "source": "booking", "status": bson.M{"$in": []string{"held", "settled"}}},
+	}}, reason)
+}
+
+// ReleaseHold returns a hold whose booking could not be made.
+func ReleaseHold(entryID primitive.ObjectID, reason string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return release(ctx, bson.M{"_id": entryID, "status": "held"}, reason)
+}
+
+// ExpireCredits writes off credit past its expiry.
+func ExpireCredits() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := config.WalletEntriesCol.Find(ctx, bson.M{
+		"type":       "credit",
+		"remaining":  bson.M{"$gt": 0},
+		"expires_at": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var credits []models.WalletEntry
+	if err := cursor.All(ctx, &credits); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, credit := range credits {
+		var before models.WalletEntry
+		err := config.WalletEntriesCol.FindOneAndUpdate(ctx, bson.M{
+			"_id":       credit.ID,
+			"remaining": bson.M{"$gt": 0},
+		}, bson.M{"$set": bson.M{"remaining": 0}}).Decode(&before)
+		if err != nil {
+			continue
+		}
+		amount := round2(before.Remaining)
+		if err := incBalance(ctx, before.UserID, -amount); err != nil {
+			fmt.Printf("ERROR: Failed to expire wallet credit %s: %v\n", before.ID.Hex(), err)
+			continue
+		}
+		config.WalletEntriesCol.InsertOne(ctx, models.WalletEntry{
+			ID:        primitive.NewObjectID(),
+			UserID:    before.UserID,
+			Type:      "debit",
+			Source:    "expiry",
+			Amount:    amount,
+			Status:    "settled",
+			Reason:    "credit expired",
+			Draws:     []models.WalletDraw{{CreditID: before.ID, Amount: amount}},
+			CreatedAt: time.Now(),
+		})
+		expired++
+	}
+	return expired, nil
+}
+
+// releaseStaleHolds returns holds whose payment never arrived.
+func releaseStaleHolds() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cursor, err := config.WalletEntriesCol.Find(ctx, bson.M{
+		"type":       "debit",
+		"status":     "held",
+		"created_at": bson.M{"$lte": time.Now().Add(-holdTTL)},
+	}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return
+	}
+	var holds []models.WalletEntry
+	if err := cursor.All(ctx, &holds); err != nil {
+		return
+	}
+	for _, h := range holds {
+		if err := release(ctx, bson.M{"_id": h.ID, "status": "held"}, "expired"); err != nil {
+			fmt.Printf("ERROR: Failed to release wallet hold %s: %v\n", h.ID.Hex(), err)
+		}
+	}
+}
+
+// ProcessExpiry returns stale holds and expires credits past their date.
+func ProcessExpiry() {
+	releaseStaleHolds()
+	if n, err := ExpireCredits(); err != nil {
+		fmt.Printf("ERROR: Failed to expire wallet credits: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("DEBUG: Expired %d wallet credits\n", n)
+	}
+}
+
+// Statement is a wallet's balance with a page of its ledger.
+type Statement struct {
+	Balance float64              `json:"balance"`
+	Entries []models.WalletEntry `json:"entries"`
+	Total   int64                `json:"total"`
+}
+
+func GetStatement(userID string, page, limit int) (*Statement, error) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	balance, err := GetBalance(userID)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": objID}
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetSkip(int64((page - 1) * limit)).SetLimit(int64(limit))
+	cursor, err := config.WalletEntriesCol.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.WalletEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	total, err := config.WalletEntriesCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Statement{Balance: balance, Entries: entries, Total: total}, nil
+}
@@ -29,6 +29,12 @@ func Shutdown() {
 	}
 }
 
+// Running reports whether the pool was started, which it is not on
+// Vercel.
+func Running() bool {
+	return taskQueue != nil
+}
+
 func Submit(task Task) {
 	if taskQueue == nil {
 		log.Println("Worker pool not initialized, executing task synchronously")
@@ -56,13 +56,11 @@ const ChatSupport = () => {
 
             const response = await fetch("/backend/api/chat/sessions", {
                 method: "POST",
-                headers: { "Content-Type": "application/json" },
+                headers: { "Content-Type": "application/json", "X-Account-Type": userType },
                 credentials: "include",
                 body: JSON.stringify({
-                    userId,
                     userEmail,
                     userName,
-                    userType,
                     category,
                 }),
             });
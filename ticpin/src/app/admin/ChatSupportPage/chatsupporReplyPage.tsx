@@ -7,6 +7,7 @@ import { Suspense, useState, useEffect, useRef } from "react";
 import { useUserSession } from "@/lib/auth/user";
 import { getOrganizerSession } from "@/lib/auth/organizer";
 import { FileText } from "lucide-react";
+import { useChatStream, useRemoteTyping, useTypingNotifier, ChatStreamEvent } from "@/hooks/useChatStream";
 
 interface Question {
     question: string;
@@ -46,6 +47,7 @@ const ChatSupportReplyContent = () => {
     const [accepting, setAccepting] = useState(false);
     const [ending, setEnding] = useState(false);
     const [lightboxImage, setLightboxImage] = useState<string | null>(null);
+    const [userTyping, setUserTyping] = useRemoteTyping();
     const messagesEndRef = useRef<HTMLDivElement>(null);
 
     const userType = organizerSession ? "organizer" : "user";
@@ -75,14 +77,14 @@ const ChatSupportReplyContent = () => {
                 }
 
                 // Fetch messages
-                const messagesRes = await fetch(`/backend/api/chat/sessions/${sessionId}/messages`);
+                const messagesRes = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/messages`, { credentials: 'include' });
                 if (messagesRes.ok) {
                     const mData = await messagesRes.json();
                     setMessages(mData);
                 }
 
                 // Fetch session details to get status
-                const sessionsRes = await fetch(`/backend/api/chat/sessions?admin=true`);
+                const sessionsRes = await fetch(`/backend/api/admin/chat/sessions?view=mine`, { credentials: 'include' });
                 if (sessionsRes.ok) {
                     const sessionsData = await sessionsRes.json();
                     const currentSession = sessionsData.sessions?.find((s: any) => s.sessionId === sessionId || s.session_id === sessionId);
@@ -98,24 +100,58 @@ const ChatSupportReplyContent = () => {
         };
 
         fetchData();
+    }, [sessionId, supportType]);
 
-        // Poll for new messages every 15 seconds
-        const interval = setInterval(async () => {
-            if (sessionId) {
-                try {
-                    const messagesRes = await fetch(`/backend/api/chat/sessions/${sessionId}/messages`);
-                    if (messagesRes.ok) {
-                        const mData = await messagesRes.json();
-                        setMessages(mData);
-                    }
-                } catch (error) {
-                    console.error("Error polling messages:", error);
-                }
+    const refreshMessages = async () => {
+        if (!sessionId) return;
+        try {
+            const messagesRes = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/messages`, { credentials: 'include' });
+            if (messagesRes.ok) {
+                const mData = await messagesRes.json();
+                setMessages(mData);
             }
-        }, 15000);
+        } catch (error) {
+            console.error("Error polling messages:", error);
+        }
+    };
 
+    const live = useChatStream(
+        sessionId ? `/backend/api/admin/chat/sessions/${sessionId}/stream` : null,
+        (e: ChatStreamEvent) => {
+            switch (e.type) {
+                case "message":
+                    if (e.message?.sender === "user") setUserTyping(false);
+                    refreshMessages();
+                    break;
+                case "accepted":
+                    setSessionStatus("active");
+                    break;
+                case "closed":
+                    setSessionStatus("closed");
+                    refreshMessages();
+                    break;
+                case "typing":
+                    if (e.from === "user") setUserTyping(!!e.typing);
+                    break;
+            }
+        },
+    );
+
+    // Poll as a fallback, and only now and then while the stream is live
+    useEffect(() => {
+        if (!sessionId) return;
+        const interval = setInterval(refreshMessages, live ? 60000 : 15000);
         return () => clearInterval(interval);
-    }, [sessionId, supportType]);
+    }, [sessionId, live]);
+
+    const notifyTyping = useTypingNotifier(sessionId && sessionStatus === "active" ? (typing: boolean) => {
+        fetch(`/backend/api/admin/chat/sessions/${sessionId}/typing`, {
+            method: "POST",
+            credentials: "include",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ typing }),
+        }).catch(() => {});
+    } : null);
 
     useEffect(() => {
         messagesEndRef.current?.scrollIntoView({ behavior: "smooth" });
@@ -137,17 +173,11 @@ const ChatSupportReplyContent = () => {
 
         setSending(true);
         try {
-            const response = await fetch(`/backend/api/chat/sessions/${sessionId}/messages?admin=true`, {
+            const response = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/messages`, {
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
                 credentials: "include",
-                body: JSON.stringify({
-                    userId,
-                    userEmail,
-                    userType: "admin",
-                    message: currentMsg,
-                    sender: "admin",
-                }),
+                body: JSON.stringify({ message: currentMsg }),
             });
 
             if (!response.ok) {
@@ -164,14 +194,14 @@ const ChatSupportReplyContent = () => {
         if (!sessionId || accepting) return;
         setAccepting(true);
         try {
-            const response = await fetch(`/backend/api/chat/sessions/${sessionId}/accept`, {
+            const response = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/accept`, {
                 method: 'POST',
                 credentials: 'include',
             });
             if (response.ok) {
                 setSessionStatus('active');
                 // Refresh messages to show welcome message
-                const messagesRes = await fetch(`/backend/api/chat/sessions/${sessionId}/messages`);
+                const messagesRes = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/messages`, { credentials: 'include' });
                 if (messagesRes.ok) {
                     const mData = await messagesRes.json();
                     setMessages(mData);
@@ -188,14 +218,14 @@ const ChatSupportReplyContent = () => {
         if (!sessionId || ending) return;
         setEnding(true);
         try {
-            const response = await fetch(`/backend/api/chat/sessions/${sessionId}/end`, {
+            const response = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/end`, {
                 method: 'POST',
                 credentials: 'include',
             });
             if (response.ok) {
                 setSessionStatus('closed');
                 // Refresh messages to show system message
-                const messagesRes = await fetch(`/backend/api/chat/sessions/${sessionId}/messages`);
+                const messagesRes = await fetch(`/backend/api/admin/chat/sessions/${sessionId}/messages`, { credentials: 'include' });
                 if (messagesRes.ok) {
                     const mData = await messagesRes.json();
                     setMessages(mData);
@@ -221,17 +251,11 @@ const ChatSupportReplyContent = () => {
         // Send to backend
         if (sessionId) {
             try {
-                await fetch(`/backend/api/chat/sessions/${sessionId}/messages`, {
+                await fetch(`/backend/api/admin/chat/sessions/${sessionId}/messages`, {
                     method: "POST",
                     headers: { "Content-Type": "application/json" },
                     credentials: "include",
-                    body: JSON.stringify({
-                        userId,
-                        userEmail,
-                        userType,
-                        message: question,
-                        sender: "user",
-                    }),
+                    body: JSON.stringify({ message: question }),
                 });
             } catch (error) {
                 console.error("Error saving question:", error);
@@ -390,6 +414,9 @@ const ChatSupportReplyContent = () => {
                                     </div>
                                 </div>
                             ))}
+                            {userTyping && (
+                                <p className="text-[12px] text-[#999] italic">Customer is typing...</p>
+                            )}
                             <div ref={messagesEndRef} />
                         </div>
 
@@ -425,7 +452,7 @@ const ChatSupportReplyContent = () => {
                             <input
                                 type="text"
                                 value={inputMessage}
-                                onChange={(e) => setInputMessage(e.target.value)}
+                                onChange={(e) => { setInputMessage(e.target.value); notifyTyping(); }}
                                 onKeyPress={handleKeyPress}
                                 placeholder="Type your message here"
                                 className="flex-1 h-[44px] bg-white border border-[#5331EA] rounded-[24px] px-5 text-[15px] text-[#5331EA] placeholder-[#5331EA] outline-none"
@@ -3,9 +3,10 @@
 import Image from "next/image";
 import Link from "next/link";
 import { useRouter } from "next/navigation";
-import { useEffect, useState } from "react";
+import { useEffect, useRef, useState } from "react";
 import { ChevronLeft, MessageCircle, User } from "lucide-react";
 import { getOrganizerSession } from "@/lib/auth/organizer";
+import { useChatStream } from "@/hooks/useChatStream";
 
 interface ChatSession {
     id: string;
@@ -67,7 +68,7 @@ export default function AdminChatSessionsPage() {
         if (!selectedUserType) return;
         setLoading(true);
         try {
-            const res = await fetch(`/backend/api/chat/sessions?category=${activeCategory}&userType=${selectedUserType}&dateFilter=${selectedDateFilter}&admin=true&limit=10&page=${pageNum}`);
+            const res = await fetch(`/backend/api/admin/chat/sessions?category=${activeCategory}&userType=${selectedUserType}&dateFilter=${selectedDateFilter}&limit=10&page=${pageNum}`, { credentials: 'include' });
             if (res.ok) {
                 const data = await res.json();
                 setSessions(data.sessions || []);
@@ -80,6 +81,19 @@ export default function AdminChatSessionsPage() {
         }
     };
 
+    // Refresh the list when the queue changes, at most once a second
+    const refreshTimer = useRef<ReturnType<typeof setTimeout> | null>(null);
+    useChatStream(authorized && selectedUserType ? "/backend/api/admin/chat/stream" : null, (e) => {
+        if (e.type === "presence" || e.type === "typing") return;
+        if (refreshTimer.current) return;
+        refreshTimer.current = setTimeout(() => {
+            refreshTimer.current = null;
+            fetchSessions(page);
+        }, 1000);
+    });
+
+    useEffect(() => () => { if (refreshTimer.current) clearTimeout(refreshTimer.current); }, []);
+
     const handlePageChange = (newPage: number) => {
         if (newPage >= 1 && newPage <= totalPages) {
             setPage(newPage);
@@ -95,7 +109,7 @@ export default function AdminChatSessionsPage() {
     const handleAcceptTicket = async (e: React.MouseEvent, session: ChatSession) => {
         e.stopPropagation();
         try {
-            const res = await fetch(`/backend/api/chat/sessions/${session.sessionId}/accept`, {
+            const res = await fetch(`/backend/api/admin/chat/sessions/${session.sessionId}/accept`, {
                 method: 'POST',
                 credentials: 'include',
             });
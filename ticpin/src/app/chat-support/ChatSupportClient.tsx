@@ -1,6 +1,7 @@
 'use client';
 
 import React, { useState, useEffect, useRef } from 'react';
+import { useChatStream, useRemoteTyping, useTypingNotifier } from '@/hooks/useChatStream';
 import Image from 'next/image';
 import { useRouter, useSearchParams } from 'next/navigation';
 import { useIdentityStore } from '@/store/useIdentityStore';
@@ -91,7 +92,7 @@ export default function ChatSupportClient() {
     const [messages, setMessages] = useState<any[]>([]);
     const [inputValue, setInputValue] = useState('');
     const [loading, setLoading] = useState(false);
-    const [isTyping, setIsTyping] = useState(false);
+    const [isTyping, setIsTyping] = useRemoteTyping();
     const [questions, setQuestions] = useState<any[]>([]);
     const [sessionStatus, setSessionStatus] = useState<'pending' | 'active' | 'closed'>('pending');
     const [attachedFiles, setAttachedFiles] = useState<File[]>([]);
@@ -103,6 +104,15 @@ export default function ChatSupportClient() {
     const [isListening, setIsListening] = useState(false);
 
     const isAdmin = userSession?.phone === '6383667872' || (organizerSession?.isAdmin === true);
+    const accountType = organizerSession ? 'organizer' : 'user';
+
+    // Admins answer on the agent routes; everyone else uses their own sessions
+    const chatUrl = (path: string) => isAdmin ? `/backend/api/admin/chat${path}` : `/backend/api/chat${path}`;
+    const chatInit = (init: RequestInit = {}): RequestInit => ({
+        ...init,
+        credentials: 'include',
+        headers: isAdmin ? init.headers : { ...(init.headers as Record<string, string>), 'X-Account-Type': accountType },
+    });
 
     // Fetch all existing sessions for the user on mount
     useEffect(() => {
@@ -112,7 +122,7 @@ export default function ChatSupportClient() {
 
             Promise.all(
                 categories.map(category =>
-                    fetch(`/backend/api/chat/sessions?userId=${effectiveSession.id}&category=${category}`, { credentials: 'include' })
+                    fetch(`/backend/api/chat/sessions?category=${category}`, { credentials: 'include', headers: { 'X-Account-Type': accountType } })
                         .then(res => res.json())
                         .then(data => {
                             if (data.sessions && data.sessions.length > 0) {
@@ -140,7 +150,7 @@ export default function ChatSupportClient() {
 
             // Check for existing session for this category
             if (effectiveSession?.id) {
-                fetch(`/backend/api/chat/sessions?userId=${effectiveSession.id}&category=${selectedCategory}`, { credentials: 'include' })
+                fetch(`/backend/api/chat/sessions?category=${selectedCategory}`, { credentials: 'include', headers: { 'X-Account-Type': accountType } })
                     .then(res => res.json())
                     .then(data => {
                         if (data.sessions && data.sessions.length > 0) {
@@ -162,7 +172,7 @@ export default function ChatSupportClient() {
     const fetchAdminSessions = async (category: string) => {
         setLoading(true);
         try {
-            const res = await fetch(`/backend/api/chat/sessions?admin=true&category=${category}`, { credentials: 'include' });
+            const res = await fetch(`/backend/api/admin/chat/sessions?category=${category}`, { credentials: 'include' });
             if (res.ok) {
                 const data = await res.json();
                 setAdminSessions(data);
@@ -195,12 +205,10 @@ export default function ChatSupportClient() {
         try {
             const res = await fetch('/backend/api/chat/sessions', {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: { 'Content-Type': 'application/json', 'X-Account-Type': accountType },
                 body: JSON.stringify({
-                    userId: effectiveSession?.id,
                     userName: (effectiveSession as any)?.name || (effectiveSession?.email ? effectiveSession.email.split('@')[0] : 'User'),
                     userEmail: effectiveSession?.email || (effectiveSession as any)?.phone || '',
-                    userType: organizerSession ? 'organizer' : 'user',
                     category: category
                 }),
                 credentials: 'include'
@@ -239,7 +247,7 @@ export default function ChatSupportClient() {
         }
 
         try {
-            const res = await fetch(`/backend/api/chat/sessions/${sessionId}/messages?admin=${isAdmin}&userId=${effectiveSession.id}`, { credentials: 'include' });
+            const res = await fetch(chatUrl(`/sessions/${sessionId}/messages`), chatInit());
             if (res.ok) {
                 const data = await res.json();
 
@@ -272,16 +280,52 @@ export default function ChatSupportClient() {
         }
     };
 
-    // Auto-poll for new messages every 15 seconds
+    // Live updates for the open session; EventSource cannot send headers so
+    // the account goes in the query
+    const live = useChatStream(
+        activeSession?.sessionId
+            ? chatUrl(`/sessions/${activeSession.sessionId}/stream${isAdmin ? '' : `?as=${accountType}`}`)
+            : null,
+        (e) => {
+            if (!activeSession?.sessionId) return;
+            const mine = isAdmin ? 'admin' : 'user';
+            switch (e.type) {
+                case 'message':
+                    if (e.message?.sender !== mine) setIsTyping(false);
+                    fetchMessages(activeSession.sessionId);
+                    break;
+                case 'accepted':
+                    setSessionStatus('active');
+                    break;
+                case 'closed':
+                    fetchMessages(activeSession.sessionId);
+                    setSessionStatus('closed');
+                    break;
+                case 'typing':
+                    if (e.from !== mine) setIsTyping(!!e.typing);
+                    break;
+            }
+        },
+    );
+
+    // Poll as a fallback, and only now and then while the stream is live
     useEffect(() => {
         if (!activeSession?.sessionId) return;
 
         const interval = setInterval(() => {
             fetchMessages(activeSession.sessionId);
-        }, 15000); // Poll every 15 seconds instead of 5
+        }, live ? 60000 : 15000);
 
         return () => clearInterval(interval);
-    }, [activeSession?.sessionId, effectiveSession?.id]);
+    }, [activeSession?.sessionId, effectiveSession?.id, live]);
+
+    const notifyTyping = useTypingNotifier(activeSession?.sessionId ? (typing: boolean) => {
+        fetch(chatUrl(`/sessions/${activeSession.sessionId}/typing`), chatInit({
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ typing }),
+        })).catch(() => {});
+    } : null);
 
     const handleSendMessageWithContent = async (content: string, previewUrl?: string, fileType?: string, filesToUpload: File[] = []) => {
         if (!content.trim() && filesToUpload.length === 0) return;
@@ -300,22 +344,17 @@ export default function ChatSupportClient() {
         setUploadingFiles(true);
         try {
             const formData = new FormData();
-            formData.append('userId', effectiveSession.id);
-            formData.append('userEmail', effectiveSession.email || (effectiveSession as any).phone || '');
-            formData.append('userType', organizerSession ? 'organizer' : 'user');
             formData.append('message', content);
-            formData.append('sender', isAdmin ? 'admin' : 'user');
 
             // Add file if any
             if (filesToUpload.length > 0) {
                 formData.append('file0', filesToUpload[0]);
             }
 
-            const res = await fetch(`/backend/api/chat/sessions/${activeSession.sessionId}/messages`, {
+            const res = await fetch(chatUrl(`/sessions/${activeSession.sessionId}/messages`), chatInit({
                 method: 'POST',
                 body: formData,
-                credentials: 'include'
-            });
+            }));
 
             if (res.ok) {
                 // Refresh messages after sending to get permanent URLs
@@ -805,7 +844,7 @@ export default function ChatSupportClient() {
                                     <input
                                         type="text"
                                         value={inputValue}
-                                        onChange={(e) => setInputValue(e.target.value)}
+                                        onChange={(e) => { setInputValue(e.target.value); notifyTyping(); }}
                                         onKeyDown={(e) => e.key === 'Enter' && handleSendMessage()}
                                         placeholder="Type your message here"
                                         className="w-full bg-transparent border-none outline-none text-[15px] text-black"
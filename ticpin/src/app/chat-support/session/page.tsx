@@ -8,6 +8,7 @@ import { Suspense, useState, useEffect, useRef } from "react";
 import { useUserSession } from "@/lib/auth/user";
 import { getOrganizerSession } from "@/lib/auth/organizer";
 import { ChevronLeft, Phone, Send } from "lucide-react";
+import { useChatStream, useRemoteTyping, useTypingNotifier, ChatStreamEvent } from "@/hooks/useChatStream";
 
 interface Message {
     id: string;
@@ -42,12 +43,22 @@ const ChatSessionContent = () => {
     const [loading, setLoading] = useState(true);
     const [sending, setSending] = useState(false);
     const [session, setSession] = useState<Session | null>(null);
+    const [agentOnline, setAgentOnline] = useState(false);
+    const [otherTyping, setOtherTyping] = useRemoteTyping();
     const messagesEndRef = useRef<HTMLDivElement>(null);
 
     const userType = organizerSession ? "organizer" : "user";
     const userId = organizerSession?.id || userSession?.id;
     const userEmail = organizerSession?.email || userSession?.email || "";
 
+    // Admins answer on the agent routes; everyone else uses their own sessions
+    const chatUrl = (path: string) => isAdmin ? `/backend/api/admin/chat${path}` : `/backend/api/chat${path}`;
+    const chatInit = (init: RequestInit = {}): RequestInit => ({
+        ...init,
+        credentials: "include",
+        headers: isAdmin ? init.headers : { ...(init.headers as Record<string, string>), "X-Account-Type": userType },
+    });
+
     if (!userId) {
         return <div className="p-6 text-red-500">No valid session found. Please login again.</div>;
     }
@@ -61,27 +72,22 @@ const ChatSessionContent = () => {
             }
 
             try {
-                // For users, include their userId to verify they own this session
-                const fetchUrl = isAdmin 
-                    ? `/backend/api/chat/sessions/${sessionId}/messages`
-                    : `/backend/api/chat/sessions/${sessionId}/messages?userId=${userId}`;
-                    
-                // Fetch messages
-                const messagesRes = await fetch(fetchUrl);
+                // The server only returns sessions the signed-in account owns
+                const messagesRes = await fetch(chatUrl(`/sessions/${sessionId}/messages`), chatInit());
                 if (messagesRes.ok) {
                     const mData = await messagesRes.json();
                     setMessages(mData);
-                } else if (messagesRes.status === 403) {
+                } else if (messagesRes.status === 403 || messagesRes.status === 404) {
                     // Not authorized - redirect
                     router.push('/chat-support');
                     return;
                 }
 
                 // Fetch session details
-                const sessionsRes = await fetch(`/backend/api/chat/sessions?userId=${userId}&userType=${userType}`);
+                const sessionsRes = await fetch(chatUrl(`/sessions`), chatInit());
                 if (sessionsRes.ok) {
-                    const sessions = await sessionsRes.json();
-                    const currentSession = sessions.find((s: Session) => s.sessionId === sessionId);
+                    const data = await sessionsRes.json();
+                    const currentSession = (data.sessions || []).find((s: Session) => s.sessionId === sessionId);
                     if (currentSession) {
                         setSession(currentSession);
                     } else if (!isAdmin) {
@@ -109,24 +115,65 @@ const ChatSessionContent = () => {
         };
 
         fetchData();
-        
-        // Poll for new messages every 5 seconds
-        const interval = setInterval(async () => {
-            if (sessionId) {
-                try {
-                    const messagesRes = await fetch(`/backend/api/chat/sessions/${sessionId}/messages`);
-                    if (messagesRes.ok) {
-                        const mData = await messagesRes.json();
-                        setMessages(mData);
-                    }
-                } catch (error) {
-                    console.error("Error polling messages:", error);
-                }
+    }, [sessionId, supportType, userEmail, userId]);
+
+    const refreshMessages = async () => {
+        if (!sessionId) return;
+        try {
+            const messagesRes = await fetch(chatUrl(`/sessions/${sessionId}/messages`), chatInit());
+            if (messagesRes.ok) {
+                const mData = await messagesRes.json();
+                setMessages(mData);
+            }
+        } catch (error) {
+            console.error("Error polling messages:", error);
+        }
+    };
+
+    // Live updates; EventSource cannot send headers so the account goes in the query
+    const live = useChatStream(
+        sessionId ? chatUrl(`/sessions/${sessionId}/stream${isAdmin ? "" : `?as=${userType}`}`) : null,
+        (e: ChatStreamEvent) => {
+            const mine = isAdmin ? "admin" : "user";
+            switch (e.type) {
+                case "message":
+                    if (e.message?.sender !== "system" && e.message?.sender !== mine) setOtherTyping(false);
+                    refreshMessages();
+                    break;
+                case "accepted":
+                    setSession(prev => prev ? { ...prev, status: "active" } : prev);
+                    setAgentOnline(!!e.online);
+                    break;
+                case "assigned":
+                    setAgentOnline(!!e.online);
+                    break;
+                case "closed":
+                    setSession(prev => prev ? { ...prev, status: "closed" } : prev);
+                    break;
+                case "presence":
+                    setAgentOnline(!!e.online);
+                    break;
+                case "typing":
+                    if (e.from !== mine) setOtherTyping(!!e.typing);
+                    break;
             }
-        }, 5000);
+        },
+    );
 
+    // Poll as a fallback, and only now and then while the stream is live
+    useEffect(() => {
+        if (!sessionId) return;
+        const interval = setInterval(refreshMessages, live ? 60000 : 5000);
         return () => clearInterval(interval);
-    }, [sessionId, supportType, userEmail, userId]);
+    }, [sessionId, live]);
+
+    const notifyTyping = useTypingNotifier(sessionId ? (typing: boolean) => {
+        fetch(chatUrl(`/sessions/${sessionId}/typing`), chatInit({
+            method: "POST",
+            headers: { "Content-Type": "application/json" },
+            body: JSON.stringify({ typing }),
+        })).catch(() => {});
+    } : null);
 
     useEffect(() => {
         messagesEndRef.current?.scrollIntoView({ behavior: "smooth" });
@@ -148,25 +195,15 @@ const ChatSessionContent = () => {
 
         setSending(true);
         try {
-            const response = await fetch(`/backend/api/chat/sessions/${sessionId}/messages`, {
+            const response = await fetch(chatUrl(`/sessions/${sessionId}/messages`), chatInit({
                 method: "POST",
                 headers: { "Content-Type": "application/json" },
-                credentials: "include",
-                body: JSON.stringify({
-                    userId,
-                    userEmail,
-                    userType,
-                    message: currentMsg,
-                    sender: isAdmin ? "admin" : "user",
-                }),
-            });
+                body: JSON.stringify({ message: currentMsg }),
+            }));
 
             if (response.ok) {
                 // Mark as read
-                await fetch(`/backend/api/chat/sessions/${sessionId}/read`, {
-                    method: "PUT",
-                    credentials: "include",
-                });
+                await fetch(chatUrl(`/sessions/${sessionId}/read`), chatInit({ method: "PUT" }));
             }
         } catch (error) {
             console.error("Error sending message:", error);
@@ -208,6 +245,9 @@ const ChatSessionContent = () => {
                         {isAdmin && session && (
                             <p className="text-[12px] text-[#686868]">{session.userEmail}</p>
                         )}
+                        {!isAdmin && live && (
+                            <p className="text-[12px] text-[#686868]">{agentOnline ? "Agent online" : "Agent offline"}</p>
+                        )}
                     </div>
                 </div>
                 <div className="relative w-[120px] h-[30px]">
@@ -248,6 +288,9 @@ const ChatSessionContent = () => {
                             </div>
                         </div>
                     ))}
+                    {otherTyping && (
+                        <p className="text-[12px] text-[#999] italic">{isAdmin ? "Customer is typing..." : "Support is typing..."}</p>
+                    )}
                     <div ref={messagesEndRef} />
                 </div>
 
@@ -257,7 +300,7 @@ const ChatSessionContent = () => {
                         <input
                             type="text"
                             value={inputMessage}
-                            onChange={(e) => setInputMessage(e.target.value)}
+                            onChange={(e) => { setInputMessage(e.target.value); notifyTyping(); }}
                             onKeyPress={handleKeyPress}
                             placeholder="Type your message..."
                             className="flex-1 h-[44px] bg-[#F5F5F5] border-none rounded-full px-4 text-[14px] text-black placeholder-[#999] outline-none"
@@ -167,6 +167,8 @@ const DiningBooking: React.FC = () => {
 
     const handleSlotClick = async (slot: string) => {
         if (!venue) return;
+        // Slots are held against the signed-in user
+        if (!session) { setShowAuthModal(true); return; }
         
         const dateStr = selectedDate.fullDate.toISOString().split('T')[0];
         
@@ -508,7 +510,7 @@ const DiningBooking: React.FC = () => {
                 onClose={() => setShowAuthModal(false)}
                 onSuccess={() => {
                     setShowAuthModal(false);
-                    handleBooking();
+                    if (selectedSlot) handleBooking();
                 }}
             />
             
@@ -10,6 +10,7 @@ import { TicketSkeleton } from '@/components/ui/Skeleton';
 import { Zap, Clock } from 'lucide-react';
 import { useSlotLock } from '@/hooks/useSlotLock';
 import { toast } from '@/components/ui/Toast';
+import AuthModal from '@/components/modals/AuthModal';
 
 interface TicketCategory {
     name: string;
@@ -42,6 +43,7 @@ export default function TicketSelectionPage() {
     const [coupons, setCoupons] = useState<any[]>([]);
     const [pass, setPass] = useState<TicpinPass | null>(null);
     const [usePass, setUsePass] = useState(false);
+    const [showAuthModal, setShowAuthModal] = useState(false);
     const session = useUserSession();
 
     useEffect(() => {
@@ -88,6 +90,8 @@ export default function TicketSelectionPage() {
         const avail = getAvailable(cat);
         const current = counts[i] ?? 0;
         if (current >= avail) return;
+        // Tickets are held against the signed-in user
+        if (!session) { setShowAuthModal(true); return; }
         
         try {
             const dateStr = event?.date || new Date().toISOString().split('T')[0];
@@ -303,6 +307,12 @@ export default function TicketSelectionPage() {
                     </span>
                 </button>
             </footer>
+
+            <AuthModal
+                isOpen={showAuthModal}
+                onClose={() => setShowAuthModal(false)}
+                onSuccess={() => setShowAuthModal(false)}
+            />
         </div>
     );
 }
@@ -226,6 +226,8 @@ export default function PlayBookPage() {
 
     const toggleCourt = async (uniqueId: string) => {
         if (!selectedSlot || !venue) return;
+        // Courts are held against the signed-in user
+        if (!session) { setShowAuthModal(true); return; }
 
         const court = courts?.find((c, idx) => `${c.id}-${idx}` === uniqueId);
         if (!court) return;
@@ -892,7 +894,7 @@ export default function PlayBookPage() {
                 onClose={() => setShowAuthModal(false)}
                 onSuccess={() => {
                     setShowAuthModal(false);
-                    if (venue) doBooking(venue);
+                    if (venue && selectedCourtIds.length > 0) doBooking(venue);
                 }}
             />
             
@@ -90,18 +90,16 @@ export default function TicpassPage() {
           handler: async function (response: any) {
             // Payment successful - create pass subscription
             try {
-              const passResponse = await fetch('/backend/api/pass/create', {
+              const passResponse = await fetch('/backend/api/payment/verify-pass', {
                 method: 'POST',
-                headers: {
-                  'Content-Type': 'application/json',
-                  'Authorization': `Bearer ${await user.getIdToken()}`
-                },
+                headers: { 'Content-Type': 'application/json' },
+                credentials: 'include',
                 body: JSON.stringify({
-                  user_id: user.uid,
-                  order_id: orderResponse.order_id,
-                  payment_id: response.razorpay_payment_id,
-                  amount: 799,
-                  duration_months: 3
+                  razorpay_order_id: response.razorpay_order_id,
+                  razorpay_payment_id: response.razorpay_payment_id,
+                  razorpay_signature: response.razorpay_signature,
+                  email: user.email || '',
+                  phone: user.phoneNumber || ''
                 })
               });
 
@@ -44,20 +44,22 @@ export default function FloatingChatWidget() {
             return;
         }
 
+        // Support chats belong to a signed-in account
+        if (!effectiveSession?.id) {
+            toast.error('Please sign in to raise a support ticket');
+            return;
+        }
+        const accountType = organizerSession ? 'organizer' : 'user';
+
         setLoading(true);
         try {
-            // Use a stable identifier for guest if not logged in
-            const userId = effectiveSession?.id || `guest-${formData.email.replace(/[^a-zA-Z0-9]/g, '')}`;
-
             // Start a chat session (Raise a ticket)
             const sessionRes = await fetch('/backend/api/chat/sessions', {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: { 'Content-Type': 'application/json', 'X-Account-Type': accountType },
                 body: JSON.stringify({
-                    userId: userId,
                     userName: formData.name,
                     userEmail: formData.email,
-                    userType: organizerSession ? 'organizer' : 'user',
                     category: formData.category
                 }),
                 credentials: 'include'
@@ -71,14 +73,11 @@ export default function FloatingChatWidget() {
 
             // Send the initial message
             const messageFormData = new FormData();
-            messageFormData.append('userId', userId);
-            messageFormData.append('userEmail', formData.email);
-            messageFormData.append('userType', organizerSession ? 'organizer' : 'user');
             messageFormData.append('message', formData.message);
-            messageFormData.append('sender', 'user');
 
             const messageRes = await fetch(`/backend/api/chat/sessions/${sessionData.sessionId}/messages`, {
                 method: 'POST',
+                headers: { 'X-Account-Type': accountType },
                 body: messageFormData,
                 credentials: 'include'
             });
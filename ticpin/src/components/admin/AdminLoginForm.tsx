@@ -5,9 +5,9 @@ import { useRouter } from 'next/navigation';
 import { ChevronRight, LogOut, X, ChevronDown } from 'lucide-react';
 import Image from 'next/image';
 import { saveOrganizerSession } from '@/lib/auth/organizer';
-import { auth, googleProvider, signInWithPopup, RecaptchaVerifier, signInWithPhoneNumber, type ConfirmationResult } from '@/lib/firebase';
-
-const ADMIN_PHONE = '6383667872';
+import { auth, googleProvider, signInWithPopup } from '@/lib/firebase';
+import { GoogleAuthProvider } from 'firebase/auth';
+import { adminApi, type AdminLoginResult } from '@/lib/api/admin';
 
 export default function AdminLoginForm() {
     const [loginMethod, setLoginMethod] = useState<'email' | 'phone'>('email');
@@ -15,47 +15,40 @@ export default function AdminLoginForm() {
     const [password, setPassword] = useState('');
     const [phone, setPhone] = useState('');
     const [otp, setOtp] = useState(['', '', '', '', '', '']);
-    const [view, setView] = useState<'input' | 'otp'>('input');
+    const [view, setView] = useState<'input' | 'otp' | 'mfa'>('input');
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState('');
-    const [confirmationResult, setConfirmationResult] = useState<ConfirmationResult | null>(null);
+    const [mfaToken, setMfaToken] = useState('');
+    const [mfaCode, setMfaCode] = useState('');
+    const [useRecoveryCode, setUseRecoveryCode] = useState(false);
 
     const router = useRouter();
     const otpRefs = useRef<(HTMLInputElement | null)[]>([]);
-    const recaptchaVerifierRef = useRef<RecaptchaVerifier | null>(null);
 
-    const getRecaptchaVerifier = (): RecaptchaVerifier => {
-        if (!auth) throw new Error('Firebase is not configured');
-        if (recaptchaVerifierRef.current) {
-            recaptchaVerifierRef.current.clear();
-            recaptchaVerifierRef.current = null;
+    // Admins with two-factor authentication get an mfa_token first and
+    // finish signing in with a code from their authenticator app.
+    const completeLogin = (data: AdminLoginResult) => {
+        if (data.mfa_required && data.mfa_token) {
+            setMfaToken(data.mfa_token);
+            setMfaCode('');
+            setView('mfa');
+            return;
         }
-        const verifier = new RecaptchaVerifier(auth, 'recaptcha-admin-container', { size: 'invisible' });
-        recaptchaVerifierRef.current = verifier;
-        return verifier;
+        saveOrganizerSession({
+            id: data.id || 'admin',
+            email: data.email || '',
+            vertical: 'admin',
+            isAdmin: true,
+            categoryStatus: {},
+        });
+        router.replace('/admin');
     };
 
     const handleEmailLogin = async () => {
         if (!email || !password) { setError('Email and password are required'); return; }
         setLoading(true); setError('');
         try {
-            const res = await fetch('/backend/api/admin/login', {
-                method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ email, password }),
-            });
-            const data = await res.json();
-            if (!res.ok) throw new Error(data.error || 'Login failed');
-
-            saveOrganizerSession({
-                id: data.id || data._id || 'admin',
-                email: data.email,
-                vertical: 'admin',
-                isAdmin: true,
-                categoryStatus: {},
-            });
-
-            router.replace('/admin');
+            completeLogin(await adminApi.login(email, password));
         } catch (e: any) {
             setError(e.message);
         } finally { setLoading(false); }
@@ -66,13 +59,13 @@ export default function AdminLoginForm() {
         try {
             if (!auth) throw new Error('Firebase not configured');
             const result = await signInWithPopup(auth, googleProvider);
-            const userEmail = result.user.email;
-            if (!userEmail) throw new Error('No email from google');
+            const idToken = GoogleAuthProvider.credentialFromResult(result)?.idToken;
+            if (!idToken) throw new Error('No ID token from google');
 
             const res = await fetch('/backend/api/organizer/google-auth', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ email: userEmail }),
+                body: JSON.stringify({ id_token: idToken }),
             });
             const data = await res.json();
             if (!res.ok) throw new Error(data.error || 'Google auth failed');
@@ -96,15 +89,9 @@ export default function AdminLoginForm() {
     };
 
     const handleSendPhoneOtp = async () => {
-        if (phone !== ADMIN_PHONE) {
-            setError('This phone number is not authorized for admin access.');
-            return;
-        }
         setLoading(true); setError('');
         try {
-            const verifier = getRecaptchaVerifier();
-            const result = await signInWithPhoneNumber(auth!, '+91' + phone, verifier);
-            setConfirmationResult(result);
+            await adminApi.sendLoginPhoneOtp(phone);
             setView('otp');
         } catch (e: any) {
             setError(e.message);
@@ -116,20 +103,18 @@ export default function AdminLoginForm() {
         if (code.length !== 6) { setError('Enter 6 digits'); return; }
         setLoading(true); setError('');
         try {
-            await confirmationResult?.confirm(code);
-            // After phone verification, we need to create a session.
-            // Since the backend might not have a dedicated "admin phone login" to set cookies,
-            // we can simulate it if the number is correct, or ideally the backend should handle it.
-            // For now, let's treat the admin phone number as a master key.
+            completeLogin(await adminApi.loginWithPhone(phone, code));
+        } catch (e: any) {
+            setError(e.message);
+        } finally { setLoading(false); }
+    };
 
-            saveOrganizerSession({
-                id: 'admin_phone_session',
-                email: 'admin@ticpin.com', // Placeholder if needed
-                vertical: 'admin',
-                isAdmin: true,
-                categoryStatus: {},
-            });
-            router.replace('/admin');
+    const handleVerifyMfa = async () => {
+        if (!mfaCode.trim()) { setError(useRecoveryCode ? 'Enter a recovery code' : 'Enter the 6-digit code'); return; }
+        setLoading(true); setError('');
+        try {
+            const code = useRecoveryCode ? { recovery_code: mfaCode.trim() } : { code: mfaCode.trim() };
+            completeLogin(await adminApi.verifyLogin(mfaToken, code));
         } catch (e: any) {
             setError(e.message);
         } finally { setLoading(false); }
@@ -145,7 +130,6 @@ export default function AdminLoginForm() {
 
     return (
         <div className="min-h-screen bg-[#ECE8FD] flex items-center justify-center p-6 font-sans">
-            <div id="recaptcha-admin-container"></div>
             <div className="w-full max-w-[500px] bg-white rounded-[40px] shadow-2xl p-10 space-y-8 animate-in zoom-in duration-300">
                 <div className="text-center space-y-2">
                     <h1 className="text-[32px] font-bold text-black tracking-tight">Admin Portal</h1>
@@ -224,7 +208,7 @@ export default function AdminLoginForm() {
                                     disabled={loading || phone.length !== 10}
                                     className="w-full h-14 bg-black text-white font-bold rounded-[18px] hover:bg-zinc-800 transition-all flex items-center justify-center gap-2 group shadow-xl shadow-black/10"
                                 >
-                                    {loading ? 'Sending OTP...' : 'Send Magic Link'}
+                                    {loading ? 'Sending OTP...' : 'Send OTP'}
                                     {!loading && <ChevronRight size={20} className="group-hover:translate-x-1 transition-transform" />}
                                 </button>
                             </div>
@@ -253,6 +237,48 @@ export default function AdminLoginForm() {
                             Continue with Google
                         </button>
                     </div>
+                ) : view === 'mfa' ? (
+                    <div className="space-y-8 py-4">
+                        <div className="text-center space-y-2">
+                            <h2 className="text-2xl font-bold text-black">Two-Factor Authentication</h2>
+                            <p className="text-sm text-[#686868] font-medium">
+                                {useRecoveryCode ? 'Enter one of your recovery codes' : 'Enter the 6-digit code from your authenticator app'}
+                            </p>
+                        </div>
+
+                        <input
+                            type="text"
+                            inputMode={useRecoveryCode ? 'text' : 'numeric'}
+                            autoComplete="one-time-code"
+                            placeholder={useRecoveryCode ? 'XXXX-XXXX' : '123456'}
+                            value={mfaCode}
+                            onChange={(e) => setMfaCode(useRecoveryCode ? e.target.value.toUpperCase() : e.target.value.replace(/\D/g, '').slice(0, 6))}
+                            onKeyDown={(e) => { if (e.key === 'Enter') handleVerifyMfa(); }}
+                            className="w-full h-14 px-5 bg-zinc-50 border border-zinc-200 rounded-[18px] text-zinc-900 text-center text-xl font-bold tracking-[0.3em] focus:outline-none focus:border-black transition-all"
+                        />
+
+                        <div className="space-y-4 pt-4">
+                            <button
+                                onClick={handleVerifyMfa}
+                                disabled={loading || !mfaCode}
+                                className="w-full h-14 bg-black text-white font-bold rounded-[18px] hover:bg-zinc-800 transition-all shadow-xl shadow-black/10"
+                            >
+                                {loading ? 'Verifying...' : 'Unlock Admin Panel'}
+                            </button>
+                            <button
+                                onClick={() => { setUseRecoveryCode(!useRecoveryCode); setMfaCode(''); setError(''); }}
+                                className="w-full text-zinc-500 font-semibold text-sm hover:text-black transition-colors"
+                            >
+                                {useRecoveryCode ? 'Use authenticator app instead' : 'Use a recovery code'}
+                            </button>
+                            <button
+                                onClick={() => { setView('input'); setError(''); setMfaToken(''); setMfaCode(''); }}
+                                className="w-full text-zinc-500 font-semibold text-sm hover:text-black transition-colors"
+                            >
+                                Cancel and return
+                            </button>
+                        </div>
+                    </div>
                 ) : (
                     <div className="space-y-8 py-4">
                         <div className="text-center space-y-2">
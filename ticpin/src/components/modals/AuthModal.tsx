@@ -132,10 +132,25 @@ export default function AuthModal({ isOpen, onClose, onSuccess, initialView = 'n
         setLoading(true);
         setError('');
 
-        setTimeout(() => {
-            setLoading(false);
+        try {
+            const res = await fetch('/backend/api/user/login/otp', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                credentials: 'include',
+                body: JSON.stringify({ phone: number }),
+            });
+            const data = await res.json();
+            if (!res.ok) {
+                setError(data.error || 'Failed to send OTP');
+                return;
+            }
+            setOtp(['', '', '', '', '', '']);
             setView('otp');
-        }, 1000);
+        } catch (err) {
+            setError('Failed to send OTP. Please try again.');
+        } finally {
+            setLoading(false);
+        }
     };
 
     const handleOtpChange = (index: number, value: string) => {
@@ -173,7 +188,7 @@ export default function AuthModal({ isOpen, onClose, onSuccess, initialView = 'n
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 credentials: 'include',
-                body: JSON.stringify({ phone: number }),
+                body: JSON.stringify({ phone: number, otp: otp.join('') }),
             });
             
             const data = await res.json();
@@ -7,12 +7,13 @@ import { ChevronRight } from 'lucide-react';
 import { getOrganizerSession, saveOrganizerSession } from '@/lib/auth/organizer';
 import { getUserSession } from '@/lib/auth/user';
 import { auth, googleProvider, signInWithPopup } from '@/lib/firebase';
+import { GoogleAuthProvider } from 'firebase/auth';
 import { useIdentityStore } from '@/store/useIdentityStore';
 import { toast } from '@/components/ui/Toast';
 
 interface LoginApi {
     login: (email: string, password: string) => Promise<unknown>;
-    googleAuth: (email: string) => Promise<{
+    googleAuth: (idToken: string) => Promise<{
         id?: string;
         _id?: string;
         email: string;
@@ -91,9 +92,9 @@ export default function OrganizerLoginForm({ vertical, api, setupPath, otpPath,
         try {
             if (!auth) throw new Error('Google Auth is not configured. Please check your credentials.');
             const result = await signInWithPopup(auth, googleProvider);
-            const userEmail = result.user.email;
-            if (!userEmail) throw new Error('No email from google');
-            const res = await api.googleAuth(userEmail);
+            const idToken = GoogleAuthProvider.credentialFromResult(result)?.idToken;
+            if (!idToken) throw new Error('No ID token from google');
+            const res = await api.googleAuth(idToken);
             const session = {
                 id: (res.id ?? res._id ?? '') as string,
                 email: res.email,
@@ -5,6 +5,7 @@ import Link from 'next/link';
 import { useRouter } from 'next/navigation';
 import { ChevronRight } from 'lucide-react';
 import { auth, googleProvider, signInWithPopup } from '@/lib/firebase';
+import { GoogleAuthProvider } from 'firebase/auth';
 import { saveOrganizerSession } from '@/lib/auth/organizer';
 import { getUserSession } from '@/lib/auth/user';
 import { useIdentityStore } from '@/store/useIdentityStore';
@@ -12,7 +13,7 @@ import { toast } from '@/components/ui/Toast';
 
 interface SigninApi {
     signin: (email: string, password: string) => Promise<unknown>;
-    googleAuth: (email: string) => Promise<{
+    googleAuth: (idToken: string) => Promise<{
         id?: string;
         _id?: string;
         email: string;
@@ -83,9 +84,9 @@ export default function OrganizerSigninForm({ vertical, api, setupPath, otpPath,
         try {
             if (!auth) throw new Error('Google Auth is not configured. Please check your credentials.');
             const result = await signInWithPopup(auth, googleProvider);
-            const userEmail = result.user.email;
-            if (!userEmail) throw new Error('No email from google');
-            const res = await api.googleAuth(userEmail);
+            const idToken = GoogleAuthProvider.credentialFromResult(result)?.idToken;
+            if (!idToken) throw new Error('No ID token from google');
+            const res = await api.googleAuth(idToken);
             const session = {
                 id: (res.id ?? res._id ?? '') as string,
                 email: res.email,
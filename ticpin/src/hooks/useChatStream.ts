@@ -0,0 +1,92 @@
+'use client';
+
+import { useState, useEffect, useRef, useCallback } from 'react';
+
+export type ChatStreamEvent = {
+    type: 'message' | 'read' | 'created' | 'accepted' | 'assigned' | 'closed' | 'presence' | 'typing';
+    sessionId?: string;
+    message?: any;
+    session?: any;
+    reader?: 'user' | 'admin';
+    agent?: string;
+    online?: boolean;
+    typing?: boolean;
+    from?: 'user' | 'admin';
+    at: string;
+};
+
+const EVENT_TYPES: ChatStreamEvent['type'][] = ['message', 'read', 'created', 'accepted', 'assigned', 'closed', 'presence', 'typing'];
+
+// Live chat updates over server-sent events. When the stream is not
+// available, connected stays false and callers keep polling the REST routes.
+export function useChatStream(url: string | null, onEvent: (e: ChatStreamEvent) => void) {
+    const [connected, setConnected] = useState(false);
+    const handler = useRef(onEvent);
+    handler.current = onEvent;
+
+    useEffect(() => {
+        if (!url || typeof EventSource === 'undefined') return;
+
+        const source = new EventSource(url, { withCredentials: true });
+        source.onopen = () => setConnected(true);
+        source.onerror = () => {
+            // EventSource retries on its own unless the server refused it
+            setConnected(false);
+        };
+        const listener = (e: MessageEvent) => {
+            try {
+                handler.current(JSON.parse(e.data));
+            } catch (err) {
+                console.error('Bad chat event:', err);
+            }
+        };
+        EVENT_TYPES.forEach(type => source.addEventListener(type, listener as EventListener));
+
+        return () => {
+            source.close();
+            setConnected(false);
+        };
+    }, [url]);
+
+    return connected;
+}
+
+// Typing indicator for the other side of a chat. It clears itself in case
+// the "stopped typing" event is missed.
+export function useRemoteTyping(timeoutMs = 6000) {
+    const [typing, setTyping] = useState(false);
+    const timer = useRef<ReturnType<typeof setTimeout> | null>(null);
+
+    const update = useCallback((isTyping: boolean) => {
+        if (timer.current) clearTimeout(timer.current);
+        setTyping(isTyping);
+        if (isTyping) timer.current = setTimeout(() => setTyping(false), timeoutMs);
+    }, [timeoutMs]);
+
+    useEffect(() => () => { if (timer.current) clearTimeout(timer.current); }, []);
+
+    return [typing, update] as const;
+}
+
+// Returns a function to call on every keystroke; it posts at most one
+// "typing" every few seconds and a "stopped" once input goes quiet.
+export function useTypingNotifier(send: ((typing: boolean) => void) | null, quietMs = 3000) {
+    const lastSent = useRef(0);
+    const quiet = useRef<ReturnType<typeof setTimeout> | null>(null);
+
+    useEffect(() => () => { if (quiet.current) clearTimeout(quiet.current); }, []);
+
+    return useCallback(() => {
+        if (!send) return;
+        const now = Date.now();
+        if (now - lastSent.current > quietMs) {
+            lastSent.current = now;
+            send(true);
+        }
+        if (quiet.current) clearTimeout(quiet.current);
+        quiet.current = setTimeout(() => {
+            lastSent.current = 0;
+            send(false);
+        }, quietMs);
+    }, [send, quietMs]);
+}
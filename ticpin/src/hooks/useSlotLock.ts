@@ -14,26 +14,14 @@ export interface SlotLock {
 }
 
 export function useSlotLock(type: 'play' | 'event' | 'dining') {
-    const [lockKey, setLockKey] = useState<string>('');
     const [locks, setLocks] = useState<SlotLock[]>([]);
     const [timeRemaining, setTimeRemaining] = useState<number>(0);
     const [loading, setLoading] = useState(true);
 
-    // 1. Persistent lock key
-    useEffect(() => {
-        let key = localStorage.getItem('ticpin_lock_key');
-        if (!key) {
-            key = window.crypto?.randomUUID ? window.crypto.randomUUID() : Math.random().toString(36).substring(2) + Date.now().toString(36);
-            localStorage.setItem('ticpin_lock_key', key);
-        }
-        setLockKey(key);
-    }, []);
-
-    // 2. Fetch locks
+    // 1. Fetch locks (locks belong to the signed-in user)
     const fetchLocks = useCallback(async () => {
-        if (!lockKey) return;
         try {
-            const res = await fetch(`/backend/api/booking/lock/status?lock_key=${lockKey}&type=${type}`);
+            const res = await fetch(`/backend/api/booking/lock/status?type=${type}`, { credentials: 'include' });
             if (res.ok) {
                 const data = await res.json();
                 setLocks(data.locks || []);
@@ -43,15 +31,13 @@ export function useSlotLock(type: 'play' | 'event' | 'dining') {
         } finally {
             setLoading(false);
         }
-    }, [lockKey, type]);
+    }, [type]);
 
     useEffect(() => {
-        if (lockKey) {
-            fetchLocks();
-        }
-    }, [lockKey, fetchLocks]);
+        fetchLocks();
+    }, [fetchLocks]);
 
-    // 3. Countdown timer
+    // 2. Countdown timer
     useEffect(() => {
         if (locks.length === 0) {
             setTimeRemaining(0);
@@ -85,15 +71,14 @@ export function useSlotLock(type: 'play' | 'event' | 'dining') {
         return () => clearInterval(interval);
     }, [locks]);
 
-    // 4. APIs
+    // 3. APIs
     const lockSlot = async (referenceId: string, date: string, slot: string, courtName?: string) => {
-        if (!lockKey) return false;
         try {
             const res = await fetch('/backend/api/booking/lock', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
+                credentials: 'include',
                 body: JSON.stringify({
-                    lock_key: lockKey,
                     type,
                     reference_id: referenceId,
                     date,
@@ -116,13 +101,12 @@ export function useSlotLock(type: 'play' | 'event' | 'dining') {
     };
 
     const unlockSlot = async (referenceId: string, date: string, slot: string, courtName?: string) => {
-        if (!lockKey) return false;
         try {
             const res = await fetch('/backend/api/booking/unlock', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
+                credentials: 'include',
                 body: JSON.stringify({
-                    lock_key: lockKey,
                     type,
                     reference_id: referenceId,
                     date,
@@ -142,7 +126,6 @@ export function useSlotLock(type: 'play' | 'event' | 'dining') {
     };
 
     return {
-        lockKey,
         locks,
         timeRemaining,
         loading,
@@ -284,19 +284,113 @@ export interface PassRecord {
     createdAt: string;
 }
 
+export interface AdminLoginResult {
+    mfa_required?: boolean;
+    mfa_token?: string;
+    mfa_setup_required?: boolean;
+    id?: string;
+    email?: string;
+    name?: string;
+    roles?: string[];
+}
+
+export interface TwoFactorStatus {
+    enabled: boolean;
+    enabled_at?: string;
+    recovery_codes_left: number;
+    required: boolean;
+    session_verified: boolean;
+}
+
+export interface AdminLoginEvent {
+    id: string;
+    email: string;
+    method: string;
+    outcome: string;
+    reason?: string;
+    ip: string;
+    user_agent: string;
+    device: string;
+    new_device?: boolean;
+    created_at: string;
+}
+
+export interface AuditLogEntry {
+    id: string;
+    actor_type: 'admin' | 'organizer' | 'organizer_member';
+    actor_id: string;
+    actor_email: string;
+    member_id?: string;
+    action: string;
+    method: string;
+    route: string;
+    path: string;
+    entity: string;
+    entity_id?: string;
+    changes?: Array<{ field: string; from: unknown; to: unknown }>;
+    before?: Record<string, unknown>;
+    after?: Record<string, unknown>;
+    status: number;
+    ip: string;
+    user_agent: string;
+    created_at: string;
+}
+
+export interface AuditLogQuery {
+    actor?: string;
+    entity?: string;
+    entity_id?: string;
+    action?: string;
+    from?: string;
+    to?: string;
+    page?: number;
+    limit?: number;
+}
+
+export type DeletedKind = 'events' | 'dining' | 'play' | 'organizers' | 'users';
+
+export interface DeletedItem {
+    id: string;
+    label: string;
+    deleted_at: string;
+    deleted_by: string;
+    purge_after: string;
+}
+
 // ─── Admin API ────────────────────────────────────────────────────────────────
 
 export const adminApi = {
 
     // ── Auth ──────────────────────────────────────────────────────────────────
 
-    /** POST /api/admin/login */
+    /** POST /api/admin/login — returns mfa_token when a second factor is needed */
     login: (email: string, password: string) =>
-        adminRequest<{ message: string; email: string }>('/login', {
+        adminRequest<AdminLoginResult>('/login', {
             method: 'POST',
             body: JSON.stringify({ email, password }),
         }),
 
+    /** POST /api/admin/login/phone-otp */
+    sendLoginPhoneOtp: (phone: string) =>
+        adminRequest<{ message: string }>('/login/phone-otp', {
+            method: 'POST',
+            body: JSON.stringify({ phone }),
+        }),
+
+    /** POST /api/admin/login with a phone code */
+    loginWithPhone: (phone: string, otp: string) =>
+        adminRequest<AdminLoginResult>('/login', {
+            method: 'POST',
+            body: JSON.stringify({ phone, otp }),
+        }),
+
+    /** POST /api/admin/login/verify — finish sign-in with a TOTP or recovery code */
+    verifyLogin: (mfa_token: string, code: { code?: string; recovery_code?: string }) =>
+        adminRequest<AdminLoginResult>('/login/verify', {
+            method: 'POST',
+            body: JSON.stringify({ mfa_token, ...code }),
+        }),
+
     /** POST /api/organizer/logout — clear session cookie */
     logout: async () => {
         try {
@@ -308,6 +402,10 @@ export const adminApi = {
         }
     },
 
+    /** GET /api/admin/me/permissions — permissions granted by the admin's roles ("*" = all) */
+    getMyPermissions: () =>
+        adminRequest<{ email: string; permissions: string[] }>('/me/permissions'),
+
     // ── Stats ─────────────────────────────────────────────────────────────────
 
     /** GET /api/admin/stats */
@@ -594,6 +692,96 @@ export const adminApi = {
 
     /** GET /api/admin/passes/search-users?q= */
     searchPassUsers: (q: string) => adminRequest<Array<{ id: string; name: string; phone: string }>>(`/passes/search-users?q=${q}`),
+
+    // ── Two-factor authentication & security ──────────────────────────────────
+
+    /** GET /api/admin/2fa */
+    getTwoFactorStatus: () => adminRequest<TwoFactorStatus>('/2fa'),
+
+    /** POST /api/admin/2fa/setup — secret and otpauth URL for the QR code */
+    beginTwoFactorSetup: () =>
+        adminRequest<{ secret: string; otpauth_url: string }>('/2fa/setup', { method: 'POST' }),
+
+    /** POST /api/admin/2fa/enable — recovery codes are only returned once */
+    enableTwoFactor: (code: string) =>
+        adminRequest<{ enabled: boolean; recovery_codes: string[] }>('/2fa/enable', {
+            method: 'POST',
+            body: JSON.stringify({ code }),
+        }),
+
+    /** POST /api/admin/2fa/verify — pass 2FA for the current session */
+    verifyTwoFactor: (code: { code?: string; recovery_code?: string }) =>
+        adminRequest<{ verified: boolean }>('/2fa/verify', {
+            method: 'POST',
+            body: JSON.stringify(code),
+        }),
+
+    /** POST /api/admin/2fa/disable */
+    disableTwoFactor: (code: { code?: string; recovery_code?: string }) =>
+        adminRequest<{ enabled: boolean }>('/2fa/disable', {
+            method: 'POST',
+            body: JSON.stringify(code),
+        }),
+
+    /** POST /api/admin/2fa/recovery-codes */
+    regenerateRecoveryCodes: (code: string) =>
+        adminRequest<{ recovery_codes: string[] }>('/2fa/recovery-codes', {
+            method: 'POST',
+            body: JSON.stringify({ code }),
+        }),
+
+    /** GET /api/admin/security/settings */
+    getSecuritySettings: () =>
+        adminRequest<{ require_2fa: boolean; updated_by?: string; updated_at: string }>('/security/settings'),
+
+    /** PUT /api/admin/security/settings */
+    updateSecuritySettings: (require_2fa: boolean) =>
+        adminRequest<{ require_2fa: boolean; updated_by?: string; updated_at: string }>('/security/settings', {
+            method: 'PUT',
+            body: JSON.stringify({ require_2fa }),
+        }),
+
+    /** GET /api/admin/security/login-events?email=&outcome=&limit= */
+    listLoginEvents: (params: { email?: string; outcome?: string; limit?: number } = {}) => {
+        const q = new URLSearchParams();
+        if (params.email) q.set('email', params.email);
+        if (params.outcome) q.set('outcome', params.outcome);
+        if (params.limit) q.set('limit', String(params.limit));
+        return adminRequest<AdminLoginEvent[]>(`/security/login-events?${q.toString()}`);
+    },
+
+    /** POST /api/admin/admins/2fa/reset */
+    resetAdminTwoFactor: (email: string) =>
+        adminRequest<{ message: string }>('/admins/2fa/reset', {
+            method: 'POST',
+            body: JSON.stringify({ email }),
+        }),
+
+    /** GET /api/admin/audit-logs?actor=&entity=&entity_id=&action=&from=&to=&page=&limit= */
+    listAuditLogs: (query: AuditLogQuery = {}) => {
+        const q = new URLSearchParams();
+        Object.entries(query).forEach(([k, v]) => {
+            if (v !== undefined && v !== '') q.set(k, String(v));
+        });
+        return adminRequest<{ logs: AuditLogEntry[]; total: number; page: number; limit: number; totalPages: number }>(`/audit-logs?${q.toString()}`);
+    },
+
+    /** GET /api/admin/deleted/:kind?page=&limit= */
+    listDeleted: (kind: DeletedKind, page = 1, limit = 20) =>
+        adminRequest<{ items: DeletedItem[]; total: number; page: number; limit: number; totalPages: number }>(
+            `/deleted/${kind}?page=${page}&limit=${limit}`
+        ),
+
+    /** POST /api/admin/:kind/:id/restore */
+    restoreDeleted: (kind: DeletedKind, id: string) =>
+        adminRequest<{ message: string }>(`/${kind}/${id}/restore`, { method: 'POST' }),
+
+    /** POST /api/admin/admins/unlock */
+    unlockAdmin: (email: string) =>
+        adminRequest<{ message: string }>('/admins/unlock', {
+            method: 'POST',
+            body: JSON.stringify({ email }),
+        }),
 };
 
 // ─── Media upload ─────────────────────────────────────────────────────────────
@@ -57,10 +57,10 @@ export const diningApi = {
       body: JSON.stringify({ email, password }),
     }),
 
-  googleAuth: (email: string) =>
+  googleAuth: (idToken: string) =>
     request<VerifyResponse>('/organizer/dining/google-auth', {
       method: 'POST',
-      body: JSON.stringify({ email }),
+      body: JSON.stringify({ id_token: idToken }),
     }),
 
   verifyOTP: (email: string, otp: string) =>
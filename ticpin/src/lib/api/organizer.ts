@@ -62,6 +62,30 @@ export interface OrganizerProfile {
   preferredLanguage?: string;
 }
 
+export type TeamPermission = 'bookings:view' | 'tickets:scan' | 'listings:edit' | 'payouts:trigger';
+
+export interface TeamMember {
+  id: string;
+  organizer_id: string;
+  email: string;
+  name?: string;
+  permissions: TeamPermission[];
+  listing_ids?: string[];
+  status: 'invited' | 'active';
+  invited_by: string;
+  invited_at: string;
+  joined_at?: string;
+  last_login_at?: string;
+}
+
+export interface TeamAccess {
+  organizer_id: string;
+  member_id?: string;
+  owner: boolean;
+  permissions: TeamPermission[];
+  listing_ids: string[];
+}
+
 export const organizerApi = {
   /** GET /api/organizer/me — refreshes session cookie with latest DB data (categoryStatus) */
   getMe: () =>
@@ -127,4 +151,50 @@ export const organizerApi = {
       method: 'POST',
       body: JSON.stringify({ organizerId, otp }),
     }),
+
+  /** GET /api/organizer/team/me — what the signed-in owner or team member may do */
+  getMyAccess: () =>
+    request<TeamAccess>('/organizer/team/me'),
+
+  /** GET /api/organizer/team — the owner's team members */
+  listTeam: () =>
+    request<TeamMember[]>('/organizer/team'),
+
+  /** POST /api/organizer/team/invite — invites a member by email; listing_ids empty means all listings */
+  inviteMember: (email: string, name: string, permissions: TeamPermission[], listing_ids: string[] = []) =>
+    request<{ message: string; member: TeamMember }>('/organizer/team/invite', {
+      method: 'POST',
+      body: JSON.stringify({ email, name, permissions, listing_ids }),
+    }),
+
+  /** PUT /api/organizer/team/:memberId — changes a member's permissions and listings */
+  updateMember: (memberId: string, name: string, permissions: TeamPermission[], listing_ids: string[] = []) =>
+    request<{ message: string; member: TeamMember }>(`/organizer/team/${memberId}`, {
+      method: 'PUT',
+      body: JSON.stringify({ name, permissions, listing_ids }),
+    }),
+
+  /** DELETE /api/organizer/team/:memberId — removes a member and signs them out */
+  removeMember: (memberId: string) =>
+    request<{ message: string }>(`/organizer/team/${memberId}`, { method: 'DELETE' }),
+
+  /** POST /api/organizer/team/send-otp — emails a team member their sign-in code */
+  sendTeamOTP: (email: string, organizer_id?: string) =>
+    request<{ message: string }>('/organizer/team/send-otp', {
+      method: 'POST',
+      body: JSON.stringify({ email, organizer_id }),
+    }),
+
+  /** POST /api/organizer/team/verify-otp — signs a team member in */
+  verifyTeamOTP: (email: string, otp: string, organizer_id?: string, vertical?: string) =>
+    request<{ id: string; memberId: string; email: string; permissions: TeamPermission[]; listing_ids?: string[] }>('/organizer/team/verify-otp', {
+      method: 'POST',
+      body: JSON.stringify({ email, otp, organizer_id, vertical }),
+    }),
+
+  /** POST /api/organizer/bookings/:bookingId/check-in — marks a scanned ticket as used */
+  checkIn: (bookingId: string) =>
+    request<{ message: string; type: string; booking: any }>(`/organizer/bookings/${encodeURIComponent(bookingId)}/check-in`, {
+      method: 'POST',
+    }),
 };
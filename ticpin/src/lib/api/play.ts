@@ -27,10 +27,10 @@ export const playApi = {
       body: JSON.stringify({ email, password }),
     }),
 
-  googleAuth: (email: string) =>
+  googleAuth: (idToken: string) =>
     request<VerifyResponse>('/organizer/play/google-auth', {
       method: 'POST',
-      body: JSON.stringify({ email }),
+      body: JSON.stringify({ id_token: idToken }),
     }),
 
   verifyOTP: (email: string, otp: string) =>
@@ -1,6 +1,7 @@
 // ─── Organizer Session ────────────────────────────────────────────
-// Session is stored in TWO cookies set by the backend on VerifyOTP:
-//   ticpin_token   — HttpOnly JWT (not readable by JS, protects against XSS)
+// Session is stored in cookies set by the backend on login:
+//   ticpin_token   — HttpOnly JWT, valid 15 minutes (not readable by JS, protects against XSS)
+//   ticpin_refresh — HttpOnly refresh token; the backend swaps it for a new ticpin_token when that expires
 //   ticpin_session — base64 JSON  (readable by JS for UI; non-sensitive identity info)
 //
 // The frontend reads/writes ticpin_session for UI state.
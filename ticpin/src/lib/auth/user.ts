@@ -50,8 +50,13 @@ export function saveUserSession(session: UserSession): void {
     window.dispatchEvent(new Event('user-auth-change'));
 }
 
-/** Clear user session */
+/** Clear user session. Fires backend logout to revoke the server-side session. */
 export function clearUserSession(): void {
+    if (typeof window === 'undefined') return;
+
+    // Fire-and-forget — revokes the session and clears the HttpOnly cookies
+    fetch('/backend/api/user/logout', { method: 'POST', credentials: 'include' }).catch(() => { });
+
     clearAllData();
 }
 